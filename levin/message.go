@@ -3,6 +3,7 @@ package levin
 import (
 	"bytes"
 	"net"
+	"strconv"
 )
 
 // header
@@ -11,6 +12,7 @@ var levinSignature = []byte{0x01, 0x21, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01}
 var levinPacketRequest = uint32(1)
 var levinPacketResponse = uint32(2)
 var levinProtocolVer1 = uint32(1)
+var levinMaxPacketSize = uint64(100000000)
 var levinRequestReturnCode = int32(0)
 var levinResponseReturnCode = int32(1)
 
@@ -48,14 +50,13 @@ var serializeTypeUint64 = byte(5)
 var serializeTypeUint32 = byte(6)
 var serializeTypeUint16 = byte(7)
 var serializeTypeUint8 = byte(8)
+var serializeTypeDouble = byte(9)
 var serializeTypeString = byte(10)
+var serializeTypeBool = byte(11)
 var serializeTypeObject = byte(12)
 var serializeTypeArray = byte(13)
 var serializeFlagArray = byte(0x80)
 
-// var serializeTypeDouble = byte(9)
-// var serializeTypeBool = byte(11)
-
 type LevinProtocolMessage struct {
 	// 字节流数据的缓冲区，接收对端的数据，暂存要发送给对端的数据
 	header_bytes  []byte
@@ -72,12 +73,29 @@ type LevinProtocolMessage struct {
 	version         uint32
 
 	// payload的反序列化后的字段
-	payload map[string]interface{}
+	payload    map[string]interface{}
+	decode_err error // 反序列化过程中遇到的格式错误
 }
 
 const CommandHandshake = 1001
 const CommandTimedSync = 1002
 const CommandPingPong = 1003
+const CommandRequestSupportFlags = 1007
+
+// command的可读名称，用于日志和监控指标
+func CommandName(command uint32) string {
+	switch command {
+	case CommandHandshake:
+		return "handshake"
+	case CommandTimedSync:
+		return "timed_sync"
+	case CommandPingPong:
+		return "ping"
+	case CommandRequestSupportFlags:
+		return "support_flags"
+	}
+	return strconv.FormatUint(uint64(command), 10)
+}
 
 type PeerlistEntry struct {
 	IP     uint32
@@ -115,6 +133,20 @@ func (msg *LevinProtocolMessage) GetExpectResponse() bool {
 	return msg.expect_response
 }
 
+func (msg *LevinProtocolMessage) GetReturnCode() int32 {
+	return msg.return_code
+}
+
+// 消息是否是对某个请求的响应（通过flags判断）
+func (msg *LevinProtocolMessage) IsResponse() bool {
+	return (msg.flags & levinPacketResponse) != 0
+}
+
+// 反序列化后的payload，ping请求等没有payload的消息返回空map
+func (msg *LevinProtocolMessage) GetPayload() map[string]interface{} {
+	return msg.payload
+}
+
 func (msg *LevinProtocolMessage) HeaderBytes() []byte {
 	return msg.header_bytes
 }
@@ -131,28 +163,49 @@ func (msg *LevinProtocolMessage) PayloadBytes() []byte {
 ======================================
 */
 
-func (msg *LevinProtocolMessage) CreateHandshakeRequest(my_port uint32, network_id []byte, peer_id uint64) {
+// 使用任意payload创建一个请求消息
+func (msg *LevinProtocolMessage) CreateRequest(command uint32, payload map[string]interface{}) error {
+	if err := msg.writePayload(payload); err != nil {
+		return err
+	}
+	msg.writeHeader(command, uint64(len(msg.payload_bytes)), true)
+	return nil
+}
+
+func (msg *LevinProtocolMessage) CreateHandshakeRequest(my_port uint32, network_id []byte, peer_id uint64) error {
 	payload_map := msg.writeHandshakeRequestPayload(my_port, network_id, peer_id)
-	msg.writePayload(payload_map)
+	if err := msg.writePayload(payload_map); err != nil {
+		return err
+	}
 	msg.writeHeader(CommandHandshake, uint64(len(msg.payload_bytes)), true)
+	return nil
 }
 
-func (msg *LevinProtocolMessage) CreateHandshakeResponse(my_port uint32, network_id []byte, peer_id uint64, peerlist []PeerlistEntry) {
+func (msg *LevinProtocolMessage) CreateHandshakeResponse(my_port uint32, network_id []byte, peer_id uint64, peerlist []PeerlistEntry) error {
 	payload_map := msg.writeHandshakeResponsePayload(my_port, network_id, peer_id, peerlist)
-	msg.writePayload(payload_map)
+	if err := msg.writePayload(payload_map); err != nil {
+		return err
+	}
 	msg.writeHeader(CommandHandshake, uint64(len(msg.payload_bytes)), false)
+	return nil
 }
 
-func (msg *LevinProtocolMessage) CreateTimedSyncRequest(network_id []byte) {
+func (msg *LevinProtocolMessage) CreateTimedSyncRequest(network_id []byte) error {
 	payload_map := msg.writeTimedSyncRequestPayload(network_id)
-	msg.writePayload(payload_map)
+	if err := msg.writePayload(payload_map); err != nil {
+		return err
+	}
 	msg.writeHeader(CommandTimedSync, uint64(len(msg.payload_bytes)), true)
+	return nil
 }
 
-func (msg *LevinProtocolMessage) CreateTimedSyncResponse(my_port uint32, network_id []byte, peer_id uint64, peerlist []PeerlistEntry) {
+func (msg *LevinProtocolMessage) CreateTimedSyncResponse(my_port uint32, network_id []byte, peer_id uint64, peerlist []PeerlistEntry) error {
 	payload_map := msg.writeTimedSyncResponsePayload(my_port, network_id, peer_id, peerlist)
-	msg.writePayload(payload_map)
+	if err := msg.writePayload(payload_map); err != nil {
+		return err
+	}
 	msg.writeHeader(CommandTimedSync, uint64(len(msg.payload_bytes)), false)
+	return nil
 }
 
 func (msg *LevinProtocolMessage) CreatePingRequest() {
@@ -160,10 +213,101 @@ func (msg *LevinProtocolMessage) CreatePingRequest() {
 	// Ping Request msg has no payload
 }
 
-func (msg *LevinProtocolMessage) CreatePongResponse(peer_id uint64) {
+func (msg *LevinProtocolMessage) CreatePongResponse(peer_id uint64) error {
 	payload_map := msg.writePongResponsePayload(peer_id)
-	msg.writePayload(payload_map)
+	if err := msg.writePayload(payload_map); err != nil {
+		return err
+	}
 	msg.writeHeader(CommandPingPong, uint64(len(msg.payload_bytes)), false)
+	return nil
+}
+
+func (msg *LevinProtocolMessage) CreateSupportFlagsResponse() error {
+	payload_map := msg.writeSupportFlagsResponsePayload()
+	if err := msg.writePayload(payload_map); err != nil {
+		return err
+	}
+	msg.writeHeader(CommandRequestSupportFlags, uint64(len(msg.payload_bytes)), false)
+	return nil
+}
+
+/*
+======================================
+
+	Request Payload
+
+======================================
+*/
+
+// Handshake请求的payload，配合CreateRequest使用
+func HandshakeRequestPayload(my_port uint32, network_id []byte, peer_id uint64) map[string]interface{} {
+	msg := LevinProtocolMessage{}
+	return msg.writeHandshakeRequestPayload(my_port, network_id, peer_id)
+}
+
+// Timed Sync请求的payload，配合CreateRequest使用
+func TimedSyncRequestPayload(network_id []byte) map[string]interface{} {
+	msg := LevinProtocolMessage{}
+	return msg.writeTimedSyncRequestPayload(network_id)
+}
+
+// Support Flags请求没有字段，payload为空的section
+func SupportFlagsRequestPayload() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+/*
+======================================
+
+	Parse Monero message Payload
+
+======================================
+*/
+
+// 从Handshake/Timed Sync响应的payload中解析local_peerlist_new
+func ParsePeerlist(payload map[string]interface{}) []PeerlistEntry {
+	peerlist := []PeerlistEntry{}
+	local_peerlist_new, ok := payload["local_peerlist_new"].([]interface{})
+	if !ok {
+		return peerlist
+	}
+	for _, item := range local_peerlist_new {
+		peer, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		entry := PeerlistEntry{}
+		entry.PeerId, _ = peer["id"].(uint64)
+		if adr, ok := peer["adr"].(map[string]interface{}); ok {
+			if addr, ok := adr["addr"].(map[string]interface{}); ok {
+				entry.IP, _ = addr["m_ip"].(uint32)
+				entry.Port, _ = addr["m_port"].(uint16)
+			}
+		}
+		peerlist = append(peerlist, entry)
+	}
+	return peerlist
+}
+
+// 从Handshake响应的payload中解析对端的peer_id
+func ParsePeerId(payload map[string]interface{}) (uint64, bool) {
+	node_data, ok := payload["node_data"].(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	peer_id, ok := node_data["peer_id"].(uint64)
+	return peer_id, ok
+}
+
+// 从Support Flags响应的payload中解析support_flags
+func ParseSupportFlags(payload map[string]interface{}) (uint32, bool) {
+	switch support_flags := payload["support_flags"].(type) {
+	case uint32:
+		return support_flags, true
+	case uint8:
+		return uint32(support_flags), true
+	}
+	return 0, false
 }
 
 /*
@@ -318,3 +462,10 @@ func (msg *LevinProtocolMessage) writePongResponsePayload(peer_id uint64) map[st
 	data["status"] = string([]byte{0x4f, 0x4b})
 	return data
 }
+
+// Support Flags响应
+func (msg *LevinProtocolMessage) writeSupportFlagsResponsePayload() map[string]interface{} {
+	data := make(map[string]interface{})
+	data["support_flags"] = uint32(p2pSupportFlags)
+	return data
+}
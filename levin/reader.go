@@ -5,7 +5,8 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"math"
 	"net"
 )
 
@@ -20,21 +21,17 @@ import (
 // 读取消息的头部（包含头部的解析、反序列化）
 func (msg *LevinProtocolMessage) readHeader(conn net.Conn) error {
 	msg.header_bytes = make([]byte, levinMessageHeaderLength)
-	// 读取header
-	header_length, err := conn.Read(msg.header_bytes)
+	// 读取header，一次Read可能读不满33字节，使用io.ReadFull
+	header_length, err := io.ReadFull(conn, msg.header_bytes)
 	if err != nil {
-		log.Println("Error reading levin message from connection: "+conn.RemoteAddr().String(), err)
-		log.Println("Disconnect with connection " + conn.RemoteAddr().String())
 		return err
 	}
 	if header_length < levinMessageHeaderLength {
-		log.Printf("Error levin message header length. Expected length: %d, received data length: %d\n", levinMessageHeaderLength, header_length)
 		return fmt.Errorf("error levin message header length, expected length: %d, received data length: %d", levinMessageHeaderLength, header_length)
 	}
 	// 1. 读取前8个字节的signature，判断是不是门罗币网络层协议消息
 	msg.ptr = 0
 	if !bytes.Equal(msg.header_bytes[msg.ptr:msg.ptr+8], levinSignature) {
-		log.Println("Error receiving data from " + conn.RemoteAddr().String() + ": not Monero network protocol message.")
 		return errors.New("Error receiving data from " + conn.RemoteAddr().String() + ": not Monero network protocol message.")
 	}
 	msg.ptr += 8
@@ -43,6 +40,9 @@ func (msg *LevinProtocolMessage) readHeader(conn net.Conn) error {
 	// 2. 继续接收后8个字节的数据，表示消息的数据长度
 	msg.length = binary.LittleEndian.Uint64(msg.header_bytes[msg.ptr : msg.ptr+8])
 	msg.ptr += 8
+	if msg.length > levinMaxPacketSize {
+		return fmt.Errorf("levin message from %s too large: %d bytes", conn.RemoteAddr().String(), msg.length)
+	}
 
 	// 3. 接收1个字节的bool类型的reture_data数据，0表示不需要回复(request数据)，1表示需要回复(response数据)
 	msg.expect_response = (msg.header_bytes[msg.ptr] != 0)
@@ -69,39 +69,50 @@ func (msg *LevinProtocolMessage) readHeader(conn net.Conn) error {
 // 读取消息的payload
 func (msg *LevinProtocolMessage) readPayload(conn net.Conn) error {
 	// 读取payload
-	// 先读取payload开头的
-	buffer := make([]byte, 2048) // 在这里先设置缓冲区的大小为2048
-	// 对端发送的数据存在两种情况，一种是发送的数据长度比较小，tcp的一个报文就可以传过来，这个时候message_length长度的缓冲区就可以直接拿下
-	// 第二种情况是，传送的数据量较大，即message_length大于单个tcp报文的最大长度，就需要传多次
-	for uint64(len(msg.payload_bytes)) < msg.length {
-		n, err := conn.Read(buffer)
-		if err != nil {
-			log.Println("Error reading remaining levin message from connection:"+conn.RemoteAddr().String(), err)
+	// 对端发送的数据可能被拆成多个tcp报文，也可能和下一条消息粘在一起，
+	// 所以这里严格读取header中声明的length个字节，不多读也不少读
+	msg.payload_bytes = make([]byte, msg.length)
+	if _, err := io.ReadFull(conn, msg.payload_bytes); err != nil {
+		return fmt.Errorf("error reading levin payload: %w", err)
+	}
+	// ping请求等消息没有payload
+	if msg.length == 0 {
+		msg.payload = make(map[string]interface{})
+		return nil
+	}
+	return msg.decodePayload()
+}
+
+// 反序列化payload_bytes中的portable storage数据
+func (msg *LevinProtocolMessage) decodePayload() (err error) {
+	// payload来自对端，格式错误时下面的切片操作可能越界，转换为error返回
+	defer func() {
+		if r := recover(); r != nil {
+			msg.payload = nil
+			err = fmt.Errorf("malformed portable storage data: %v", r)
 		}
-		msg.payload_bytes = append(msg.payload_bytes, buffer[:n]...)
+	}()
+	if len(msg.payload_bytes) < 9 {
+		return fmt.Errorf("error levin payload length %d", len(msg.payload_bytes))
 	}
 
-	// 读取完毕
 	// 1. 先检查msg payload的前9个字节是否等于签名值
 	msg.ptr = uint64(0)
 	if !bytes.Equal(msg.payload_bytes[msg.ptr:msg.ptr+4], portableStorageSignature1) {
-		log.Println("Error portable storage signature1!")
 		return errors.New("error portable storage signature1")
 	}
 	msg.ptr += 4
 	if !bytes.Equal(msg.payload_bytes[msg.ptr:msg.ptr+4], portableStorageSignature2) {
-		log.Println("Error portable storage signature2!")
 		return errors.New("error portable storage signature2")
 	}
 	msg.ptr += 4
 	if msg.payload_bytes[msg.ptr] != portableStorageFormatVer {
-		log.Println("Error portable storage format ver!")
 		return errors.New("error portable storage format ver")
 	}
 	msg.ptr += 1
 	// 2. 检查payload真正的数据部分，递归的反序列化
 	msg.payload = msg.readSection()
-	return nil
+	return msg.decode_err
 }
 
 // 获取字符串的长度，这个字符串可能是键名，也可以是数据
@@ -114,16 +125,14 @@ func (msg *LevinProtocolMessage) getKeyNum() uint64 {
 		key_num = uint64(key_num_byte) >> 2 // key_num_byte的高6位即为数据的长度
 		msg.ptr++
 	} else if key_num_mask == portableRawSizeMarkWord {
-		key_num = uint64(binary.BigEndian.Uint16(msg.payload_bytes[msg.ptr:msg.ptr+2])) >> 2
+		key_num = uint64(binary.LittleEndian.Uint16(msg.payload_bytes[msg.ptr:msg.ptr+2])) >> 2
 		msg.ptr += 2
 	} else if key_num_mask == portableRawSizeMarkDword {
-		key_num = uint64(binary.BigEndian.Uint32(msg.payload_bytes[msg.ptr:msg.ptr+4])) >> 2
-		msg.ptr += 3
-	} else if key_num_mask == portableRawSizeMarkInt64 {
-		key_num = binary.BigEndian.Uint64(msg.payload_bytes[msg.ptr:msg.ptr+8]) >> 2
+		key_num = uint64(binary.LittleEndian.Uint32(msg.payload_bytes[msg.ptr:msg.ptr+4])) >> 2
 		msg.ptr += 4
-	} else {
-		log.Println("Invalid key num!")
+	} else if key_num_mask == portableRawSizeMarkInt64 {
+		key_num = binary.LittleEndian.Uint64(msg.payload_bytes[msg.ptr:msg.ptr+8]) >> 2
+		msg.ptr += 8
 	}
 	return key_num
 }
@@ -168,6 +177,11 @@ func (msg *LevinProtocolMessage) readEntry() interface{} {
 func (msg *LevinProtocolMessage) readArrayEntry(entry_type byte) interface{} {
 	entry_type &= ^serializeFlagArray // entry_type和serializeFlagArray按位取反的结果相与
 	key_num := msg.getKeyNum()
+	// 每个元素至少占1个字节，元素个数不可能超过剩余的字节数
+	if key_num > uint64(len(msg.payload_bytes))-msg.ptr {
+		msg.decode_err = fmt.Errorf("array length %d exceeds payload", key_num)
+		return []interface{}{}
+	}
 	array := make([]interface{}, key_num)
 	for key_num > 0 {
 		array[uint64(len(array))-key_num] = msg.read(entry_type, 0)
@@ -181,17 +195,17 @@ func (msg *LevinProtocolMessage) readEntryArrayEntry() interface{} {
 	entry_type := msg.payload_bytes[msg.ptr]
 	msg.ptr++
 	if (entry_type & serializeFlagArray) != 0 {
-		log.Println("wrong type sequences")
+		msg.decode_err = errors.New("wrong type sequences")
 	}
 	return msg.readArrayEntry(entry_type)
 }
 
 // 读取简单数据
-func (msg *LevinProtocolMessage) read(entry_type byte, count byte) interface{} {
+func (msg *LevinProtocolMessage) read(entry_type byte, count uint64) interface{} {
 	// 可以在调用处使用断言来区分实际返回的类型
 	if entry_type == 0 && count > 0 {
-		data := msg.payload_bytes[msg.ptr : msg.ptr+uint64(count)]
-		msg.ptr += uint64(count)
+		data := msg.payload_bytes[msg.ptr : msg.ptr+count]
+		msg.ptr += count
 		return data
 	}
 
@@ -235,12 +249,25 @@ func (msg *LevinProtocolMessage) read(entry_type byte, count byte) interface{} {
 		msg.ptr++
 		return data
 	}
+	if entry_type == serializeTypeDouble {
+		data := math.Float64frombits(binary.LittleEndian.Uint64(msg.payload_bytes[msg.ptr : msg.ptr+8]))
+		msg.ptr += 8
+		return data
+	}
+	if entry_type == serializeTypeBool {
+		data := msg.payload_bytes[msg.ptr] != 0
+		msg.ptr++
+		return data
+	}
 	if entry_type == serializeTypeObject {
 		return msg.readSection()
 	}
 	if entry_type == serializeTypeString {
 		key_num := msg.getKeyNum()
-		return msg.read(0, byte(key_num))
+		if key_num == 0 {
+			return []byte{}
+		}
+		return msg.read(0, key_num)
 	}
 	return nil
 }
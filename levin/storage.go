@@ -0,0 +1,34 @@
+package levin
+
+/*
+======================================
+
+	Portable Storage
+
+======================================
+*/
+
+// monerod的.bin RPC接口直接在HTTP body中传输portable storage数据，
+// 这里复用levin消息payload的序列化和反序列化逻辑
+
+// 将section序列化为带签名头的portable storage数据
+// 支持的值类型：各种整数、bool、float64、string（按原始字节写入）、
+// map[string]interface{}、[]map[string]interface{}、[]interface{}（元素为section）、[]uint64和[]string
+func EncodePortableStorage(section map[string]interface{}) ([]byte, error) {
+	msg := LevinProtocolMessage{}
+	if err := msg.writePayload(section); err != nil {
+		return nil, err
+	}
+	return msg.payload_bytes, nil
+}
+
+// 反序列化portable storage数据
+// string类型的值返回[]byte，简单数组和section数组都返回[]interface{}
+func DecodePortableStorage(data []byte) (map[string]interface{}, error) {
+	// 限制容量，避免越过数据末尾的切片操作读到data之后的内存
+	msg := LevinProtocolMessage{payload_bytes: data[:len(data):len(data)], length: uint64(len(data))}
+	if err := msg.decodePayload(); err != nil {
+		return nil, err
+	}
+	return msg.payload, nil
+}
@@ -2,7 +2,9 @@ package levin
 
 import (
 	"encoding/binary"
-	"log"
+	"errors"
+	"fmt"
+	"math"
 )
 
 /*
@@ -52,17 +54,17 @@ func (msg *LevinProtocolMessage) writeHeader(message_type uint32, payload_length
 }
 
 // 写payload，接收传入的map[string]interface{}，对键值对进行反序列化
-func (msg *LevinProtocolMessage) writePayload(payload map[string]interface{}) {
+func (msg *LevinProtocolMessage) writePayload(payload map[string]interface{}) error {
 	msg.payload_bytes = make([]byte, 0)
 	// 先写payload的头部
 	msg.payload_bytes = append(msg.payload_bytes, portableStorageSignature1...)
 	msg.payload_bytes = append(msg.payload_bytes, portableStorageSignature2...)
 	msg.payload_bytes = append(msg.payload_bytes, portableStorageFormatVer)
 	// 开始写余下的内容
-	msg.writeSection(payload)
+	return msg.writeSection(payload)
 }
 
-func (msg *LevinProtocolMessage) setKeyNum(keyNum uint64) {
+func (msg *LevinProtocolMessage) setKeyNum(keyNum uint64) error {
 	if keyNum <= 63 {
 		out := (byte(keyNum) << 2) | portableRawSizeMarkByte
 		msg.payload_bytes = append(msg.payload_bytes, byte(out))
@@ -76,28 +78,35 @@ func (msg *LevinProtocolMessage) setKeyNum(keyNum uint64) {
 		out := (keyNum << 2) | uint64(portableRawSizeMarkInt64)
 		msg.payload_bytes = binary.LittleEndian.AppendUint64(msg.payload_bytes, out)
 	} else {
-		log.Fatalln("failed to pack varint - too big amount")
+		return errors.New("failed to pack varint - too big amount")
 	}
+	return nil
 }
 
-func (msg *LevinProtocolMessage) writeSection(data map[string]interface{}) {
+func (msg *LevinProtocolMessage) writeSection(data map[string]interface{}) error {
 	// 将Payload看作一整个Seciton
-	msg.setKeyNum(uint64(len(data))) // 先写section的长度字段
+	// 先写section的长度字段
+	if err := msg.setKeyNum(uint64(len(data))); err != nil {
+		return err
+	}
 	for key, value := range data {
 		// 写键值对的键字符串的长度
 		msg.payload_bytes = append(msg.payload_bytes, byte(len(key)))
 		// 写键值对的键字符串
 		msg.payload_bytes = append(msg.payload_bytes, []byte(key)...)
-		msg.write(value)
+		if err := msg.write(value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
 	}
+	return nil
 }
 
-func (msg *LevinProtocolMessage) writeSectionEntry(data map[string]interface{}) {
+func (msg *LevinProtocolMessage) writeSectionEntry(data map[string]interface{}) error {
 	msg.payload_bytes = append(msg.payload_bytes, serializeTypeObject)
-	msg.writeSection(data)
+	return msg.writeSection(data)
 }
 
-func (msg *LevinProtocolMessage) write(data interface{}) {
+func (msg *LevinProtocolMessage) write(data interface{}) error {
 	switch data := data.(type) {
 	case uint64:
 		msg.payload_bytes = append(msg.payload_bytes, serializeTypeUint64)
@@ -121,19 +130,68 @@ func (msg *LevinProtocolMessage) write(data interface{}) {
 		msg.payload_bytes = append(msg.payload_bytes, serializeTypeUint8, data)
 	case int8:
 		msg.payload_bytes = append(msg.payload_bytes, serializeTypeInt8, uint8(data))
+	case bool:
+		if data {
+			msg.payload_bytes = append(msg.payload_bytes, serializeTypeBool, 1)
+		} else {
+			msg.payload_bytes = append(msg.payload_bytes, serializeTypeBool, 0)
+		}
+	case float64:
+		msg.payload_bytes = append(msg.payload_bytes, serializeTypeDouble)
+		msg.payload_bytes = binary.LittleEndian.AppendUint64(msg.payload_bytes, math.Float64bits(data))
 	case string:
 		msg.payload_bytes = append(msg.payload_bytes, serializeTypeString)
-		msg.setKeyNum(uint64(len(data)))
+		if err := msg.setKeyNum(uint64(len(data))); err != nil {
+			return err
+		}
 		msg.payload_bytes = append(msg.payload_bytes, []byte(data)...)
 	case map[string]interface{}:
-		msg.writeSectionEntry(data)
+		return msg.writeSectionEntry(data)
 	case []interface{}:
 		msg.payload_bytes = append(msg.payload_bytes, byte(0x8c))
-		msg.setKeyNum(uint64(len(data)))
+		if err := msg.setKeyNum(uint64(len(data))); err != nil {
+			return err
+		}
 		for i := 0; i < len(data); i++ {
-			msg.writeSection(data[i].(map[string]interface{}))
+			section, ok := data[i].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("unable to cast array element %T to section", data[i])
+			}
+			if err := msg.writeSection(section); err != nil {
+				return err
+			}
+		}
+	case []map[string]interface{}:
+		msg.payload_bytes = append(msg.payload_bytes, serializeFlagArray|serializeTypeObject)
+		if err := msg.setKeyNum(uint64(len(data))); err != nil {
+			return err
+		}
+		for _, section := range data {
+			if err := msg.writeSection(section); err != nil {
+				return err
+			}
+		}
+	case []uint64:
+		msg.payload_bytes = append(msg.payload_bytes, serializeFlagArray|serializeTypeUint64)
+		if err := msg.setKeyNum(uint64(len(data))); err != nil {
+			return err
+		}
+		for _, value := range data {
+			msg.payload_bytes = binary.LittleEndian.AppendUint64(msg.payload_bytes, value)
+		}
+	case []string:
+		msg.payload_bytes = append(msg.payload_bytes, serializeFlagArray|serializeTypeString)
+		if err := msg.setKeyNum(uint64(len(data))); err != nil {
+			return err
+		}
+		for _, value := range data {
+			if err := msg.setKeyNum(uint64(len(value))); err != nil {
+				return err
+			}
+			msg.payload_bytes = append(msg.payload_bytes, []byte(value)...)
 		}
 	default:
-		log.Fatalln("Unable to cast input to serialized data")
+		return fmt.Errorf("unable to cast input %T to serialized data", data)
 	}
+	return nil
 }
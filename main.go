@@ -1,31 +1,70 @@
 package main
 
 import (
+	"gomonero/metrics"
 	"gomonero/node"
+	"gomonero/rpcproxy"
 	"gomonero/web"
+	"log/slog"
 	"net/http"
+	"os"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	slog.SetDefault(logger)
+
 	// 启动节点
-	node := node.CreateNode("testnet", 28083)
-	node.Start()
+	node := node.CreateNodeWithConfig(node.NodeConfig{
+		NetworkType: "testnet",
+		ListenPort:  28083,
+		Logger:      logger,
+	})
+	if err := node.Start(); err != nil {
+		logger.Error("start node failed", "error", err)
+		os.Exit(1)
+	}
 	defer node.Stop()
 
 	// 启动Gin框架，等待http请求
 	r := gin.Default()
 
+	// Prometheus监控指标
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	/*
 		========================
 		blockchain_explorer路由组
 		========================
 	*/
 	blockchain_explorer_router := r.Group("/blockchain_explorer")
+	daemon_proxy := rpcproxy.CreateDaemonRPCProxy("127.0.0.1", 28081, os.Getenv("DAEMON_RPC_USER"), os.Getenv("DAEMON_RPC_PASSWORD"))
+	// id为区块高度或区块哈希
 	blockchain_explorer_router.GET("/block", func(c *gin.Context) {
-
+		block, err := web.GetExplorerBlock(c.Request.Context(), &daemon_proxy, c.Query("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"Error":  err.Error(),
+				"status": "Failed",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, block)
+	})
+	// 按哈希查询交易，包含解析后的extra
+	blockchain_explorer_router.GET("/tx", func(c *gin.Context) {
+		tx, err := web.GetExplorerTransaction(c.Request.Context(), &daemon_proxy, c.Query("hash"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"Error":  err.Error(),
+				"status": "Failed",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, tx)
 	})
 
 	/*
@@ -87,8 +126,26 @@ func main() {
 		===================
 	*/
 	monero_wallet_router := r.Group("/monero_wallet")
+	wallet_proxy := rpcproxy.CreateWalletRPCProxy("127.0.0.1", 28088, os.Getenv("WALLET_RPC_USER"), os.Getenv("WALLET_RPC_PASSWORD"))
+	// format=atomic时金额以piconero整数返回，默认以XMR字符串返回
 	monero_wallet_router.GET("/balance", func(c *gin.Context) {
-
+		account_index, err := strconv.ParseUint(c.DefaultQuery("account_index", "0"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"Error":  err.Error(),
+				"status": "Failed",
+			})
+			return
+		}
+		balance, err := web.GetWalletBalance(c.Request.Context(), &wallet_proxy, uint32(account_index), c.Query("format") != "atomic")
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"Error":  err.Error(),
+				"status": "Failed",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, balance)
 	})
 
 	// 路由错误时提示404 Not Found
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+/*
+==============================
+node和rpcproxy导出的Prometheus指标
+==============================
+*/
+
+const namespace = "gomonero"
+
+// 当前的连接数，direction为inbound或outbound，同一进程中的多个Node累加在一起
+var Peers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: "node",
+	Name:      "peers",
+	Help:      "Number of established peer connections.",
+}, []string{"direction"})
+
+// 收发的levin消息数，direction为in或out
+var LevinMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "levin",
+	Name:      "messages_total",
+	Help:      "Levin messages sent and received, by command.",
+}, []string{"command", "direction"})
+
+// 收发的levin字节数（header+payload），direction为in或out
+var LevinBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "levin",
+	Name:      "bytes_total",
+	Help:      "Levin bytes sent and received.",
+}, []string{"direction"})
+
+// 握手失败次数
+var HandshakeFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "node",
+	Name:      "handshake_failures_total",
+	Help:      "Failed outgoing handshakes, by reason.",
+}, []string{"reason"})
+
+// 从对端收到的peerlist的大小
+var PeerlistSize = promauto.NewHistogram(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "node",
+	Name:      "received_peerlist_size",
+	Help:      "Number of entries in peerlists received from peers.",
+	Buckets:   prometheus.LinearBuckets(0, 25, 11),
+})
+
+// RPC调用的耗时，kind为json、other或binary，result为ok或error
+var RPCDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "rpc",
+	Name:      "request_duration_seconds",
+	Help:      "Latency of RPC calls made by the RPC proxies.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"kind", "method", "result"})
+
+// RPC调用失败次数
+var RPCErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "rpc",
+	Name:      "errors_total",
+	Help:      "Failed RPC calls made by the RPC proxies.",
+}, []string{"kind", "method"})
+
+// RPC调用的重试次数
+var RPCRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "rpc",
+	Name:      "retries_total",
+	Help:      "Retried RPC attempts made by the RPC proxies.",
+}, []string{"kind", "method"})
+
+// 熔断器是否打开，backend为RPC服务的地址
+var RPCCircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: "rpc",
+	Name:      "circuit_open",
+	Help:      "Whether the circuit breaker for an RPC backend is open (1) or closed (0).",
+}, []string{"backend"})
+
+// 记录一次RPC调用
+func ObserveRPC(kind string, method string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+		RPCErrors.WithLabelValues(kind, method).Inc()
+	}
+	RPCDuration.WithLabelValues(kind, method, result).Observe(time.Since(start).Seconds())
+}
+
+// /metrics路由使用的http.Handler
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,268 @@
+// address实现门罗币地址的编码、解析和离线校验
+package address
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gomonero/monero/crypto"
+)
+
+type Network int
+
+const (
+	Mainnet Network = iota
+	Testnet
+	Stagenet
+)
+
+func (network Network) String() string {
+	switch network {
+	case Mainnet:
+		return "mainnet"
+	case Testnet:
+		return "testnet"
+	case Stagenet:
+		return "stagenet"
+	}
+	return fmt.Sprintf("Network(%d)", int(network))
+}
+
+// 按名称（mainnet、testnet、stagenet）查找网络
+func ParseNetwork(name string) (Network, error) {
+	for _, network := range []Network{Mainnet, Testnet, Stagenet} {
+		if network.String() == name {
+			return network, nil
+		}
+	}
+	return 0, fmt.Errorf("address: unknown network %q", name)
+}
+
+type Type int
+
+const (
+	Standard Type = iota
+	Subaddress
+	Integrated
+)
+
+func (address_type Type) String() string {
+	switch address_type {
+	case Standard:
+		return "standard"
+	case Subaddress:
+		return "subaddress"
+	case Integrated:
+		return "integrated"
+	}
+	return fmt.Sprintf("Type(%d)", int(address_type))
+}
+
+// 各网络、各类型地址的前缀（varint编码后放在地址开头）
+var prefixes = map[Network]map[Type]uint64{
+	Mainnet:  {Standard: 18, Subaddress: 42, Integrated: 19},
+	Testnet:  {Standard: 53, Subaddress: 63, Integrated: 54},
+	Stagenet: {Standard: 24, Subaddress: 36, Integrated: 25},
+}
+
+const (
+	KeySize       = 32
+	PaymentIdSize = 8
+	checksumSize  = 4
+)
+
+var (
+	ErrChecksum      = errors.New("address: checksum mismatch")
+	ErrUnknownPrefix = errors.New("address: unknown prefix")
+	ErrInvalidLength = errors.New("address: invalid length")
+	ErrWrongNetwork  = errors.New("address: wrong network")
+	ErrNotStandard   = errors.New("address: integrated addresses can only be made from standard addresses")
+)
+
+type Address struct {
+	Network   Network
+	Type      Type
+	SpendKey  [KeySize]byte       // 公钥
+	ViewKey   [KeySize]byte       // 公钥
+	PaymentId [PaymentIdSize]byte // 只有集成地址有
+}
+
+/*
+==========
+
+	解析
+
+==========
+*/
+
+// 解析地址，校验前缀、长度和checksum
+func Parse(encoded string) (Address, error) {
+	address := Address{}
+	data, err := DecodeBase58(encoded)
+	if err != nil {
+		return address, err
+	}
+	if len(data) < checksumSize {
+		return address, ErrInvalidLength
+	}
+	payload, checksum := data[:len(data)-checksumSize], data[len(data)-checksumSize:]
+	if !bytes.Equal(keccak256(payload)[:checksumSize], checksum) {
+		return address, ErrChecksum
+	}
+
+	prefix, prefix_size := binary.Uvarint(payload)
+	if prefix_size <= 0 {
+		return address, ErrUnknownPrefix
+	}
+	found := false
+	for network, types := range prefixes {
+		for address_type, type_prefix := range types {
+			if type_prefix == prefix {
+				address.Network, address.Type, found = network, address_type, true
+			}
+		}
+	}
+	if !found {
+		return address, fmt.Errorf("%w: %d", ErrUnknownPrefix, prefix)
+	}
+
+	keys := payload[prefix_size:]
+	expected := 2 * KeySize
+	if address.Type == Integrated {
+		expected += PaymentIdSize
+	}
+	if len(keys) != expected {
+		return address, ErrInvalidLength
+	}
+	copy(address.SpendKey[:], keys[:KeySize])
+	copy(address.ViewKey[:], keys[KeySize:2*KeySize])
+	if address.Type == Integrated {
+		copy(address.PaymentId[:], keys[2*KeySize:])
+	}
+	return address, nil
+}
+
+// 解析地址并要求属于network
+func ParseForNetwork(encoded string, network Network) (Address, error) {
+	address, err := Parse(encoded)
+	if err != nil {
+		return address, err
+	}
+	if address.Network != network {
+		return address, fmt.Errorf("%w: %s address on %s", ErrWrongNetwork, address.Network, network)
+	}
+	return address, nil
+}
+
+// 离线校验地址，不合法时返回原因
+func Validate(encoded string) error {
+	_, err := Parse(encoded)
+	return err
+}
+
+/*
+==========
+
+	编码
+
+==========
+*/
+
+func (address Address) String() string {
+	prefix, ok := prefixes[address.Network][address.Type]
+	if !ok {
+		return ""
+	}
+	payload := binary.AppendUvarint(nil, prefix)
+	payload = append(payload, address.SpendKey[:]...)
+	payload = append(payload, address.ViewKey[:]...)
+	if address.Type == Integrated {
+		payload = append(payload, address.PaymentId[:]...)
+	}
+	payload = append(payload, keccak256(payload)[:checksumSize]...)
+	return EncodeBase58(payload)
+}
+
+/*
+==========
+
+	生成
+
+==========
+*/
+
+// 用私有view key和公有spend key离线生成子地址，(0, 0)为主地址
+// 子地址的spend公钥D = B + Hs("SubAddr\0" || a || major || minor)*G，view公钥C = a*D
+func GenerateSubaddress(network Network, view_secret_key [KeySize]byte, spend_public_key [KeySize]byte, major uint32, minor uint32) (Address, error) {
+	address := Address{Network: network, Type: Standard}
+	if _, ok := prefixes[network]; !ok {
+		return address, fmt.Errorf("address: unknown network %s", network)
+	}
+	if major == 0 && minor == 0 {
+		view_public_key, err := crypto.SecretKeyToPublicKey(view_secret_key)
+		if err != nil {
+			return address, err
+		}
+		address.SpendKey, address.ViewKey = spend_public_key, view_public_key
+		return address, nil
+	}
+	spend_key, err := crypto.SubaddressSpendPublicKey(view_secret_key, spend_public_key, major, minor)
+	if err != nil {
+		return address, err
+	}
+	view_key, err := crypto.ScalarMultKey(spend_key, view_secret_key)
+	if err != nil {
+		return address, err
+	}
+	address.Type, address.SpendKey, address.ViewKey = Subaddress, spend_key, view_key
+	return address, nil
+}
+
+// 主地址加上payment id得到集成地址，子地址不能生成集成地址
+func (address Address) Integrated(payment_id [PaymentIdSize]byte) (Address, error) {
+	if address.Type != Standard {
+		return address, ErrNotStandard
+	}
+	address.Type, address.PaymentId = Integrated, payment_id
+	return address, nil
+}
+
+// 集成地址对应的主地址
+func (address Address) Standard() Address {
+	if address.Type == Integrated {
+		address.Type, address.PaymentId = Standard, [PaymentIdSize]byte{}
+	}
+	return address
+}
+
+// 解析16位十六进制的payment id
+func ParsePaymentId(payment_id string) ([PaymentIdSize]byte, error) {
+	data, err := hex.DecodeString(payment_id)
+	if err != nil || len(data) != PaymentIdSize {
+		return [PaymentIdSize]byte{}, fmt.Errorf("address: payment id must be %d bytes of hex, got %q", PaymentIdSize, payment_id)
+	}
+	return [PaymentIdSize]byte(data), nil
+}
+
+func (address Address) SpendKeyHex() string {
+	return hex.EncodeToString(address.SpendKey[:])
+}
+
+func (address Address) ViewKeyHex() string {
+	return hex.EncodeToString(address.ViewKey[:])
+}
+
+// 集成地址的payment id，其他地址返回空字符串
+func (address Address) PaymentIdHex() string {
+	if address.Type != Integrated {
+		return ""
+	}
+	return hex.EncodeToString(address.PaymentId[:])
+}
+
+func keccak256(data []byte) []byte {
+	hash := crypto.Keccak256(data)
+	return hash[:]
+}
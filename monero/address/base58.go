@@ -0,0 +1,119 @@
+package address
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+/*
+=====================================
+
+	门罗币的base58：按8字节分块编码，块之间互不进位
+
+=====================================
+*/
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+const (
+	fullBlockSize        = 8
+	fullEncodedBlockSize = 11
+)
+
+// 长度为i字节的块编码后的字符数
+var encodedBlockSizes = []int{0, 2, 3, 5, 6, 7, 9, 10, 11}
+
+var ErrInvalidBase58 = errors.New("address: invalid base58")
+
+var base58Index = func() [256]int {
+	index := [256]int{}
+	for i := range index {
+		index[i] = -1
+	}
+	for i := 0; i < len(base58Alphabet); i++ {
+		index[base58Alphabet[i]] = i
+	}
+	return index
+}()
+
+func EncodeBase58(data []byte) string {
+	full_blocks := len(data) / fullBlockSize
+	last_block_size := len(data) % fullBlockSize
+	result := make([]byte, 0, full_blocks*fullEncodedBlockSize+encodedBlockSizes[last_block_size])
+	for i := 0; i < full_blocks; i++ {
+		result = encodeBlock(result, data[i*fullBlockSize:(i+1)*fullBlockSize])
+	}
+	if last_block_size > 0 {
+		result = encodeBlock(result, data[full_blocks*fullBlockSize:])
+	}
+	return string(result)
+}
+
+// 把最多8字节的块当作大端整数，编码为固定长度的base58，高位补'1'
+func encodeBlock(result []byte, block []byte) []byte {
+	buffer := [fullBlockSize]byte{}
+	copy(buffer[fullBlockSize-len(block):], block)
+	num := binary.BigEndian.Uint64(buffer[:])
+	encoded := make([]byte, encodedBlockSizes[len(block)])
+	for i := len(encoded) - 1; i >= 0; i-- {
+		encoded[i] = base58Alphabet[num%58]
+		num /= 58
+	}
+	return append(result, encoded...)
+}
+
+func DecodeBase58(encoded string) ([]byte, error) {
+	full_blocks := len(encoded) / fullEncodedBlockSize
+	last_encoded_size := len(encoded) % fullEncodedBlockSize
+	last_block_size := -1
+	for size, encoded_size := range encodedBlockSizes {
+		if encoded_size == last_encoded_size {
+			last_block_size = size
+			break
+		}
+	}
+	if last_block_size < 0 {
+		return nil, ErrInvalidBase58
+	}
+	result := make([]byte, 0, full_blocks*fullBlockSize+last_block_size)
+	var err error
+	for i := 0; i < full_blocks; i++ {
+		result, err = decodeBlock(result, encoded[i*fullEncodedBlockSize:(i+1)*fullEncodedBlockSize], fullBlockSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if last_block_size > 0 {
+		result, err = decodeBlock(result, encoded[full_blocks*fullEncodedBlockSize:], last_block_size)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func decodeBlock(result []byte, encoded string, size int) ([]byte, error) {
+	num := uint64(0)
+	for i := 0; i < len(encoded); i++ {
+		digit := base58Index[encoded[i]]
+		if digit < 0 {
+			return nil, ErrInvalidBase58
+		}
+		high, low := bits.Mul64(num, 58)
+		if high != 0 {
+			return nil, ErrInvalidBase58
+		}
+		num, high = bits.Add64(low, uint64(digit), 0)
+		if high != 0 {
+			return nil, ErrInvalidBase58
+		}
+	}
+	// 不满8字节的块，数值不能超出块的长度
+	if size < fullBlockSize && num>>(8*size) != 0 {
+		return nil, ErrInvalidBase58
+	}
+	buffer := [fullBlockSize]byte{}
+	binary.BigEndian.PutUint64(buffer[:], num)
+	return append(result, buffer[fullBlockSize-size:]...), nil
+}
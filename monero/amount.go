@@ -0,0 +1,190 @@
+// monero包含门罗币的基础类型
+package monero
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+/*
+===================
+
+	金额（原子单位）
+
+===================
+*/
+
+// 以piconero（原子单位）表示的金额，1 XMR = 10^12 piconero
+// JSON中序列化为整数，与monerod和wallet-rpc一致；需要以XMR字符串输出时转换为AmountXMR
+type Amount uint64
+
+const (
+	Piconero Amount = 1
+	XMR      Amount = 1000000000000
+
+	// XMR的小数位数
+	Decimals = 12
+)
+
+var (
+	ErrAmountOverflow  = errors.New("amount overflow")
+	ErrAmountUnderflow = errors.New("amount underflow")
+	ErrInvalidAmount   = errors.New("invalid amount")
+)
+
+// 精确地解析以XMR为单位的十进制金额，如"1.5"、"0.000000000001"
+// 不接受负数、指数形式和超过12位的小数
+func ParseAmount(xmr string) (Amount, error) {
+	integer, fraction, has_point := strings.Cut(xmr, ".")
+	if integer == "" && (!has_point || fraction == "") {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, xmr)
+	}
+	if len(fraction) > Decimals {
+		return 0, fmt.Errorf("%w: %q has more than %d decimals", ErrInvalidAmount, xmr, Decimals)
+	}
+	if !isDigits(integer) || !isDigits(fraction) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, xmr)
+	}
+
+	whole := uint64(0)
+	if integer != "" {
+		var err error
+		whole, err = strconv.ParseUint(integer, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrAmountOverflow, xmr)
+		}
+	}
+	part := uint64(0)
+	if fraction != "" {
+		// 补足12位后即为piconero
+		part, _ = strconv.ParseUint(fraction+strings.Repeat("0", Decimals-len(fraction)), 10, 64)
+	}
+	amount, err := Amount(whole).Mul(uint64(XMR))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrAmountOverflow, xmr)
+	}
+	return amount.Add(Amount(part))
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// 以XMR为单位的十进制字符串，去掉小数末尾的0，如1500000000000 -> "1.5"
+func (amount Amount) String() string {
+	whole := uint64(amount / XMR)
+	part := uint64(amount % XMR)
+	if part == 0 {
+		return strconv.FormatUint(whole, 10)
+	}
+	fraction := fmt.Sprintf("%012d", part)
+	return strconv.FormatUint(whole, 10) + "." + strings.TrimRight(fraction, "0")
+}
+
+// 保留12位小数的XMR字符串，如"1.500000000000"
+func (amount Amount) StringFixed() string {
+	return fmt.Sprintf("%d.%012d", uint64(amount/XMR), uint64(amount%XMR))
+}
+
+func (amount Amount) Uint64() uint64 {
+	return uint64(amount)
+}
+
+/* ==== 不溢出的运算 ==== */
+
+func (amount Amount) Add(other Amount) (Amount, error) {
+	sum, carry := bits.Add64(uint64(amount), uint64(other), 0)
+	if carry != 0 {
+		return 0, ErrAmountOverflow
+	}
+	return Amount(sum), nil
+}
+
+func (amount Amount) Sub(other Amount) (Amount, error) {
+	difference, borrow := bits.Sub64(uint64(amount), uint64(other), 0)
+	if borrow != 0 {
+		return 0, ErrAmountUnderflow
+	}
+	return Amount(difference), nil
+}
+
+func (amount Amount) Mul(n uint64) (Amount, error) {
+	high, low := bits.Mul64(uint64(amount), n)
+	if high != 0 {
+		return 0, ErrAmountOverflow
+	}
+	return Amount(low), nil
+}
+
+// 多个金额的和
+func SumAmounts(amounts ...Amount) (Amount, error) {
+	total := Amount(0)
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+/* ==== JSON ==== */
+
+// 序列化为原子单位的整数
+func (amount Amount) MarshalJSON() ([]byte, error) {
+	return strconv.AppendUint(nil, uint64(amount), 10), nil
+}
+
+// 接受原子单位的整数，或以XMR为单位的字符串（如"1.5"）；与encoding/json的惯例一致，null不改变原值
+func (amount *Amount) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		xmr := ""
+		if err := json.Unmarshal(data, &xmr); err != nil {
+			return err
+		}
+		parsed, err := ParseAmount(xmr)
+		if err != nil {
+			return err
+		}
+		*amount = parsed
+		return nil
+	}
+	value, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, data)
+	}
+	*amount = Amount(value)
+	return nil
+}
+
+// JSON中序列化为XMR字符串的金额，用于对外的web接口，如"1.5"
+type AmountXMR Amount
+
+func (amount Amount) XMR() AmountXMR {
+	return AmountXMR(amount)
+}
+
+func (amount AmountXMR) String() string {
+	return Amount(amount).String()
+}
+
+func (amount AmountXMR) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Amount(amount).String())
+}
+
+// 与Amount相同，接受整数或XMR字符串
+func (amount *AmountXMR) UnmarshalJSON(data []byte) error {
+	return (*Amount)(amount).UnmarshalJSON(data)
+}
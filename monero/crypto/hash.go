@@ -0,0 +1,49 @@
+// crypto实现门罗币用到的哈希和密码学运算
+package crypto
+
+import (
+	"golang.org/x/crypto/sha3"
+)
+
+const HashSize = 32
+
+// CryptoNote的cn_fast_hash：原始Keccak-256（padding为0x01，不是SHA3的0x06）
+func Keccak256(data ...[]byte) [HashSize]byte {
+	hash := sha3.NewLegacyKeccak256()
+	for _, chunk := range data {
+		hash.Write(chunk)
+	}
+	result := [HashSize]byte{}
+	hash.Sum(result[:0])
+	return result
+}
+
+// CryptoNote的tree_hash：区块中交易哈希的Merkle根
+// 哈希数不是2的幂时，先把末尾的哈希两两合并，使剩下的数量为2的幂
+func TreeHash(hashes [][HashSize]byte) [HashSize]byte {
+	switch len(hashes) {
+	case 0:
+		return [HashSize]byte{}
+	case 1:
+		return hashes[0]
+	case 2:
+		return Keccak256(hashes[0][:], hashes[1][:])
+	}
+	// cnt为小于哈希数的最大的2的幂
+	cnt := 1
+	for cnt*2 < len(hashes) {
+		cnt *= 2
+	}
+	ints := make([][HashSize]byte, cnt)
+	copy(ints, hashes[:2*cnt-len(hashes)])
+	for i, j := 2*cnt-len(hashes), 2*cnt-len(hashes); j < cnt; i, j = i+2, j+1 {
+		ints[j] = Keccak256(hashes[i][:], hashes[i+1][:])
+	}
+	for cnt > 2 {
+		cnt /= 2
+		for i, j := 0, 0; j < cnt; i, j = i+2, j+1 {
+			ints[j] = Keccak256(ints[i][:], ints[i+1][:])
+		}
+	}
+	return Keccak256(ints[0][:], ints[1][:])
+}
@@ -0,0 +1,230 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"filippo.io/edwards25519"
+)
+
+/*
+==============
+
+	钥匙派生
+
+==============
+*/
+
+const KeySize = 32
+
+var (
+	ErrInvalidSecretKey = errors.New("crypto: secret key is not a reduced scalar")
+	ErrInvalidPublicKey = errors.New("crypto: invalid public key")
+)
+
+// Pedersen承诺中金额的生成元H
+var pointH, _ = new(edwards25519.Point).SetBytes([]byte{
+	0x8b, 0x65, 0x59, 0x70, 0x15, 0x37, 0x99, 0xaf, 0x2a, 0xea, 0xdc, 0x9f, 0xf1, 0xad, 0xd0, 0xea,
+	0x6c, 0x72, 0x51, 0xd5, 0x41, 0x54, 0xcf, 0xa9, 0x2c, 0x17, 0x3a, 0x0d, 0xd3, 0x9c, 0x1f, 0x94,
+})
+
+// Hs：Keccak后对群的阶l取模（sc_reduce32）
+func HashToScalar(data ...[]byte) [KeySize]byte {
+	hash := Keccak256(data...)
+	return ReduceScalar(hash)
+}
+
+// sc_reduce32：32字节的小端整数对l取模
+func ReduceScalar(data [KeySize]byte) [KeySize]byte {
+	wide := [64]byte{}
+	copy(wide[:], data[:])
+	scalar, _ := new(edwards25519.Scalar).SetUniformBytes(wide[:])
+	return [KeySize]byte(scalar.Bytes())
+}
+
+func parseScalar(key [KeySize]byte) (*edwards25519.Scalar, error) {
+	scalar, err := new(edwards25519.Scalar).SetCanonicalBytes(key[:])
+	if err != nil {
+		return nil, ErrInvalidSecretKey
+	}
+	return scalar, nil
+}
+
+func parsePoint(key [KeySize]byte) (*edwards25519.Point, error) {
+	point, err := new(edwards25519.Point).SetBytes(key[:])
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	return point, nil
+}
+
+// 私钥对应的公钥：a*G
+func SecretKeyToPublicKey(secret_key [KeySize]byte) ([KeySize]byte, error) {
+	scalar, err := parseScalar(secret_key)
+	if err != nil {
+		return [KeySize]byte{}, err
+	}
+	return [KeySize]byte(new(edwards25519.Point).ScalarBaseMult(scalar).Bytes()), nil
+}
+
+// 共享密钥：8*a*R，a为私钥，R为对方的公钥
+func GenerateKeyDerivation(public_key [KeySize]byte, secret_key [KeySize]byte) ([KeySize]byte, error) {
+	point, err := parsePoint(public_key)
+	if err != nil {
+		return [KeySize]byte{}, err
+	}
+	scalar, err := parseScalar(secret_key)
+	if err != nil {
+		return [KeySize]byte{}, err
+	}
+	point.ScalarMult(scalar, point)
+	return [KeySize]byte(point.MultByCofactor(point).Bytes()), nil
+}
+
+// Hs(derivation || varint(output_index))，也用于解密金额
+func DerivationToScalar(derivation [KeySize]byte, output_index uint64) [KeySize]byte {
+	return HashToScalar(derivation[:], binary.AppendUvarint(nil, output_index))
+}
+
+// 一次性输出公钥：Hs(derivation || index)*G + B
+func DerivePublicKey(derivation [KeySize]byte, output_index uint64, spend_public_key [KeySize]byte) ([KeySize]byte, error) {
+	base, err := parsePoint(spend_public_key)
+	if err != nil {
+		return [KeySize]byte{}, err
+	}
+	scalar, _ := parseScalar(DerivationToScalar(derivation, output_index))
+	point := new(edwards25519.Point).ScalarBaseMult(scalar)
+	return [KeySize]byte(point.Add(point, base).Bytes()), nil
+}
+
+// DerivePublicKey的逆运算：P - Hs(derivation || index)*G，用于在子地址中查找输出的接收者
+func DeriveSubaddressPublicKey(output_key [KeySize]byte, derivation [KeySize]byte, output_index uint64) ([KeySize]byte, error) {
+	point, err := parsePoint(output_key)
+	if err != nil {
+		return [KeySize]byte{}, err
+	}
+	scalar, _ := parseScalar(DerivationToScalar(derivation, output_index))
+	base := new(edwards25519.Point).ScalarBaseMult(scalar)
+	return [KeySize]byte(point.Subtract(point, base).Bytes()), nil
+}
+
+// view tag：Keccak("view_tag" || derivation || varint(index))的第一个字节
+func DeriveViewTag(derivation [KeySize]byte, output_index uint64) byte {
+	hash := Keccak256([]byte("view_tag"), derivation[:], binary.AppendUvarint(nil, output_index))
+	return hash[0]
+}
+
+/* ==== 子地址 ==== */
+
+// a*P，如子地址的view公钥C = a*D
+func ScalarMultKey(public_key [KeySize]byte, secret_key [KeySize]byte) ([KeySize]byte, error) {
+	point, err := parsePoint(public_key)
+	if err != nil {
+		return [KeySize]byte{}, err
+	}
+	scalar, err := parseScalar(secret_key)
+	if err != nil {
+		return [KeySize]byte{}, err
+	}
+	return [KeySize]byte(point.ScalarMult(scalar, point).Bytes()), nil
+}
+
+// 子地址私钥的偏移m = Hs("SubAddr\0" || a || major || minor)
+func SubaddressSecretKey(view_secret_key [KeySize]byte, major uint32, minor uint32) [KeySize]byte {
+	index := binary.LittleEndian.AppendUint32(nil, major)
+	index = binary.LittleEndian.AppendUint32(index, minor)
+	return HashToScalar([]byte("SubAddr\x00"), view_secret_key[:], index)
+}
+
+// 子地址的spend公钥D = B + m*G，(0, 0)是主地址本身
+func SubaddressSpendPublicKey(view_secret_key [KeySize]byte, spend_public_key [KeySize]byte, major uint32, minor uint32) ([KeySize]byte, error) {
+	if major == 0 && minor == 0 {
+		return spend_public_key, nil
+	}
+	base, err := parsePoint(spend_public_key)
+	if err != nil {
+		return [KeySize]byte{}, err
+	}
+	scalar, _ := parseScalar(SubaddressSecretKey(view_secret_key, major, minor))
+	point := new(edwards25519.Point).ScalarBaseMult(scalar)
+	return [KeySize]byte(point.Add(point, base).Bytes()), nil
+}
+
+/* ==== RingCT金额 ==== */
+
+// Pedersen承诺：mask*G + amount*H
+func Commit(amount uint64, mask [KeySize]byte) ([KeySize]byte, error) {
+	mask_scalar, err := parseScalar(mask)
+	if err != nil {
+		return [KeySize]byte{}, err
+	}
+	amount_key := [KeySize]byte{}
+	binary.LittleEndian.PutUint64(amount_key[:], amount)
+	amount_scalar, _ := parseScalar(amount_key)
+	point := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(amount_scalar, pointH, mask_scalar)
+	return [KeySize]byte(point.Bytes()), nil
+}
+
+// 解密紧凑格式（RctTypeBulletproof2及之后）的ecdhInfo
+// shared_secret为DerivationToScalar(derivation, index)
+// amount = 加密金额 xor Keccak("amount" || shared_secret)[:8]，mask = Hs("commitment_mask" || shared_secret)
+func DecodeCompactAmount(encrypted_amount [8]byte, shared_secret [KeySize]byte) (uint64, [KeySize]byte) {
+	pad := Keccak256([]byte("amount"), shared_secret[:])
+	for i := range encrypted_amount {
+		encrypted_amount[i] ^= pad[i]
+	}
+	mask := HashToScalar([]byte("commitment_mask"), shared_secret[:])
+	return binary.LittleEndian.Uint64(encrypted_amount[:]), mask
+}
+
+// 解密旧格式（RctTypeFull、RctTypeSimple、RctTypeBulletproof）的ecdhInfo
+// mask = 加密mask - Hs(shared_secret)，amount = 加密金额 - Hs(Hs(shared_secret))
+func DecodeAmount(encrypted_mask [KeySize]byte, encrypted_amount [KeySize]byte, shared_secret [KeySize]byte) (uint64, [KeySize]byte, error) {
+	mask_scalar, err := parseScalar(encrypted_mask)
+	if err != nil {
+		return 0, [KeySize]byte{}, err
+	}
+	amount_scalar, err := parseScalar(encrypted_amount)
+	if err != nil {
+		return 0, [KeySize]byte{}, err
+	}
+	secret1 := HashToScalar(shared_secret[:])
+	secret2 := HashToScalar(secret1[:])
+	s1, _ := parseScalar(secret1)
+	s2, _ := parseScalar(secret2)
+	mask := [KeySize]byte(mask_scalar.Subtract(mask_scalar, s1).Bytes())
+	amount := [KeySize]byte(amount_scalar.Subtract(amount_scalar, s2).Bytes())
+	for _, b := range amount[8:] {
+		if b != 0 {
+			return 0, mask, errors.New("crypto: decoded amount does not fit in 64 bits")
+		}
+	}
+	return binary.LittleEndian.Uint64(amount[:8]), mask, nil
+}
+
+// DecodeCompactAmount的逆运算，构造交易时使用
+func EncodeCompactAmount(amount uint64, shared_secret [KeySize]byte) [8]byte {
+	pad := Keccak256([]byte("amount"), shared_secret[:])
+	encrypted_amount := [8]byte{}
+	binary.LittleEndian.PutUint64(encrypted_amount[:], amount)
+	for i := range encrypted_amount {
+		encrypted_amount[i] ^= pad[i]
+	}
+	return encrypted_amount
+}
+
+// DecodeAmount的逆运算，构造交易时使用
+func EncodeAmount(amount uint64, mask [KeySize]byte, shared_secret [KeySize]byte) ([KeySize]byte, [KeySize]byte, error) {
+	mask_scalar, err := parseScalar(mask)
+	if err != nil {
+		return [KeySize]byte{}, [KeySize]byte{}, err
+	}
+	amount_key := [KeySize]byte{}
+	binary.LittleEndian.PutUint64(amount_key[:], amount)
+	amount_scalar, _ := parseScalar(amount_key)
+	secret1 := HashToScalar(shared_secret[:])
+	secret2 := HashToScalar(secret1[:])
+	s1, _ := parseScalar(secret1)
+	s2, _ := parseScalar(secret2)
+	return [KeySize]byte(mask_scalar.Add(mask_scalar, s1).Bytes()), [KeySize]byte(amount_scalar.Add(amount_scalar, s2).Bytes()), nil
+}
@@ -0,0 +1,209 @@
+package cryptonote
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+=================
+
+	交易的extra字段
+
+=================
+*/
+
+// extra中字段的tag
+const (
+	ExtraTagPadding           = byte(0x00)
+	ExtraTagPubKey            = byte(0x01)
+	ExtraTagNonce             = byte(0x02)
+	ExtraTagMergeMining       = byte(0x03)
+	ExtraTagAdditionalPubKeys = byte(0x04)
+	ExtraTagMinerGate         = byte(0xde)
+)
+
+// nonce中第一个字节表示的内容
+const (
+	ExtraNoncePaymentId          = byte(0x00) // 32字节的明文payment id（已弃用）
+	ExtraNonceEncryptedPaymentId = byte(0x01) // 8字节的加密payment id
+)
+
+const (
+	maxExtraPadding = 255
+	maxExtraNonce   = 255
+)
+
+// extra中的一个字段，Type为字段的名称，只有与Type对应的成员有值
+type ExtraField struct {
+	Tag  byte   `json:"tag"`
+	Type string `json:"type"`
+
+	PubKey            *Key     `json:"pub_key,omitempty"`
+	AdditionalPubKeys []Key    `json:"additional_pub_keys,omitempty"`
+	Nonce             HexBytes `json:"nonce,omitempty"`
+	// 从nonce中解出的payment id
+	PaymentId          HexBytes `json:"payment_id,omitempty"`
+	EncryptedPaymentId HexBytes `json:"encrypted_payment_id,omitempty"`
+	MergeMiningDepth   uint64   `json:"merge_mining_depth,omitempty"`
+	MergeMiningRoot    *Hash    `json:"merge_mining_root,omitempty"`
+	PaddingSize        int      `json:"padding_size,omitempty"`
+	// minergate字段的内容
+	Data HexBytes `json:"data,omitempty"`
+}
+
+// 解析后的extra
+// 遇到未知的tag或格式错误时停止解析，已解析的字段保留在Fields中，剩余的字节放在Unparsed中
+type Extra struct {
+	Fields   []ExtraField `json:"fields"`
+	Unparsed HexBytes     `json:"unparsed,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// 解析extra，不会失败：格式错误记录在Extra.Error中
+func ParseExtra(data []byte) Extra {
+	extra := Extra{Fields: []ExtraField{}}
+	r := reader{data: data}
+	for r.remaining() > 0 {
+		start := r.ptr
+		field, err := r.readExtraField()
+		if err != nil {
+			extra.Unparsed = append(HexBytes{}, data[start:]...)
+			extra.Error = err.Error()
+			break
+		}
+		extra.Fields = append(extra.Fields, field)
+	}
+	return extra
+}
+
+// 解析交易的extra
+func (tx *Transaction) ParseExtra() Extra {
+	return ParseExtra(tx.Extra)
+}
+
+// 第一个交易公钥
+func (extra Extra) TxPubKey() (Key, bool) {
+	for _, field := range extra.Fields {
+		if field.PubKey != nil {
+			return *field.PubKey, true
+		}
+	}
+	return Key{}, false
+}
+
+// 发送给子地址时每个输出一个的额外交易公钥
+func (extra Extra) AdditionalPubKeys() []Key {
+	for _, field := range extra.Fields {
+		if field.Tag == ExtraTagAdditionalPubKeys {
+			return field.AdditionalPubKeys
+		}
+	}
+	return nil
+}
+
+// 加密的payment id（8字节）
+func (extra Extra) EncryptedPaymentId() ([]byte, bool) {
+	for _, field := range extra.Fields {
+		if field.EncryptedPaymentId != nil {
+			return field.EncryptedPaymentId, true
+		}
+	}
+	return nil, false
+}
+
+// 明文的payment id（32字节）
+func (extra Extra) PaymentId() ([]byte, bool) {
+	for _, field := range extra.Fields {
+		if field.PaymentId != nil {
+			return field.PaymentId, true
+		}
+	}
+	return nil, false
+}
+
+func (r *reader) readExtraField() (ExtraField, error) {
+	field := ExtraField{}
+	var err error
+	if field.Tag, err = r.readByte(); err != nil {
+		return field, err
+	}
+	switch field.Tag {
+	case ExtraTagPadding:
+		// padding是直到末尾的0字节
+		field.Type = "padding"
+		padding := r.data[r.ptr:]
+		if len(padding)+1 > maxExtraPadding {
+			return field, fmt.Errorf("cryptonote: padding of %d bytes exceeds %d", len(padding)+1, maxExtraPadding)
+		}
+		if len(bytes.Trim(padding, "\x00")) != 0 {
+			return field, fmt.Errorf("cryptonote: non-zero byte in padding")
+		}
+		field.PaddingSize = len(padding) + 1
+		r.ptr = len(r.data)
+	case ExtraTagPubKey:
+		field.Type = "tx_pubkey"
+		key, err := r.readKey()
+		if err != nil {
+			return field, err
+		}
+		field.PubKey = &key
+	case ExtraTagNonce:
+		field.Type = "nonce"
+		nonce, err := r.readExtraBlob(maxExtraNonce)
+		if err != nil {
+			return field, err
+		}
+		field.Nonce = nonce
+		if len(nonce) == 33 && nonce[0] == ExtraNoncePaymentId {
+			field.PaymentId = append(HexBytes{}, nonce[1:]...)
+		}
+		if len(nonce) == 9 && nonce[0] == ExtraNonceEncryptedPaymentId {
+			field.EncryptedPaymentId = append(HexBytes{}, nonce[1:]...)
+		}
+	case ExtraTagMergeMining:
+		// 长度前缀的blob，内容为depth(varint) + merkle root
+		field.Type = "merge_mining"
+		blob, err := r.readExtraBlob(r.remaining())
+		if err != nil {
+			return field, err
+		}
+		depth, size := binary.Uvarint(blob)
+		if size <= 0 || len(blob)-size != 32 {
+			return field, fmt.Errorf("cryptonote: malformed merge mining tag")
+		}
+		root := Hash{}
+		copy(root[:], blob[size:])
+		field.MergeMiningDepth, field.MergeMiningRoot = depth, &root
+	case ExtraTagAdditionalPubKeys:
+		field.Type = "additional_pubkeys"
+		if field.AdditionalPubKeys, err = r.readKeyVector(); err != nil {
+			return field, err
+		}
+	case ExtraTagMinerGate:
+		field.Type = "minergate"
+		if field.Data, err = r.readExtraBlob(r.remaining()); err != nil {
+			return field, err
+		}
+	default:
+		return field, fmt.Errorf("cryptonote: unknown extra tag 0x%02x", field.Tag)
+	}
+	return field, nil
+}
+
+// varint长度前缀的字节串
+func (r *reader) readExtraBlob(max_size int) (HexBytes, error) {
+	size, err := r.readVarint()
+	if err != nil {
+		return nil, err
+	}
+	if size > uint64(max_size) {
+		return nil, fmt.Errorf("cryptonote: extra field of %d bytes exceeds %d", size, max_size)
+	}
+	data, err := r.readBytes(int(size))
+	if err != nil {
+		return nil, err
+	}
+	return append(HexBytes{}, data...), nil
+}
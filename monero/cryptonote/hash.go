@@ -0,0 +1,119 @@
+package cryptonote
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"gomonero/monero/crypto"
+)
+
+/*
+=============
+
+	哈希
+
+=============
+*/
+
+var (
+	ErrPrunedTransaction = errors.New("cryptonote: prunable data is missing, use HashWithPrunableHash")
+	ErrHashMismatch      = errors.New("cryptonote: hash mismatch")
+)
+
+// 区块的哈希blob：区块头 + 所有交易（含miner tx）哈希的tree hash + 交易数
+// 这也是挖矿时计算PoW的数据
+func (block *Block) HashingBlob() ([]byte, error) {
+	miner_tx_hash, err := block.MinerTx.Hash()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([][crypto.HashSize]byte, 0, len(block.TxHashes)+1)
+	hashes = append(hashes, miner_tx_hash)
+	for _, hash := range block.TxHashes {
+		hashes = append(hashes, hash)
+	}
+	root := crypto.TreeHash(hashes)
+	blob := block.BlockHeader.Serialize()
+	blob = append(blob, root[:]...)
+	return binary.AppendUvarint(blob, uint64(len(hashes))), nil
+}
+
+// 区块哈希：Keccak(varint(len(blob)) || blob)
+// 注意主网高度202612的区块因历史原因的特殊哈希没有特殊处理
+func (block *Block) Hash() (Hash, error) {
+	blob, err := block.HashingBlob()
+	if err != nil {
+		return Hash{}, err
+	}
+	return Hash(crypto.Keccak256(binary.AppendUvarint(nil, uint64(len(blob))), blob)), nil
+}
+
+// 交易前缀的哈希，签名和钥匙派生都基于它
+func (tx *Transaction) PrefixHash() Hash {
+	return Hash(crypto.Keccak256(tx.SerializePrefix()))
+}
+
+// prunable部分的哈希，coinbase（RctTypeNull）为全0
+func (tx *Transaction) PrunableHash() (Hash, error) {
+	if tx.RctSignatures == nil || tx.RctSignatures.Type == RctTypeNull {
+		return Hash{}, nil
+	}
+	if tx.Pruned {
+		return Hash{}, ErrPrunedTransaction
+	}
+	prunable, err := tx.SerializeRctPrunable()
+	if err != nil {
+		return Hash{}, err
+	}
+	return Hash(crypto.Keccak256(prunable)), nil
+}
+
+// 交易哈希
+// v1：整个交易的Keccak；v2：Keccak(前缀哈希 || RingCT base哈希 || prunable哈希)
+func (tx *Transaction) Hash() (Hash, error) {
+	if tx.Version == 1 {
+		if tx.Pruned {
+			return Hash{}, errors.New("cryptonote: cannot hash a pruned v1 transaction")
+		}
+		blob, err := tx.Serialize()
+		if err != nil {
+			return Hash{}, err
+		}
+		return Hash(crypto.Keccak256(blob)), nil
+	}
+	prunable_hash, err := tx.PrunableHash()
+	if err != nil {
+		return Hash{}, err
+	}
+	return tx.HashWithPrunableHash(prunable_hash)
+}
+
+// 用给定的prunable哈希计算v2交易的哈希，用于剪枝后的交易（monerod的get_transactions返回prunable_hash）
+func (tx *Transaction) HashWithPrunableHash(prunable_hash Hash) (Hash, error) {
+	if tx.Version != 2 {
+		return Hash{}, errors.New("cryptonote: prunable hash only applies to v2 transactions")
+	}
+	base, err := tx.SerializeRctBase()
+	if err != nil {
+		return Hash{}, err
+	}
+	prefix_hash := tx.PrefixHash()
+	base_hash := crypto.Keccak256(base)
+	return Hash(crypto.Keccak256(prefix_hash[:], base_hash[:], prunable_hash[:])), nil
+}
+
+// 解析区块并校验其哈希等于expected（如节点通告的top_id或RPC返回的区块哈希）
+func VerifyBlock(blob []byte, expected Hash) (Block, error) {
+	block, err := ParseBlock(blob)
+	if err != nil {
+		return block, err
+	}
+	hash, err := block.Hash()
+	if err != nil {
+		return block, err
+	}
+	if hash != expected {
+		return block, fmt.Errorf("%w: computed %s, expected %s", ErrHashMismatch, hash, expected)
+	}
+	return block, nil
+}
@@ -0,0 +1,524 @@
+package cryptonote
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+/*
+===============
+
+	反序列化
+
+===============
+*/
+
+var ErrTrailingData = errors.New("cryptonote: trailing data after object")
+
+type reader struct {
+	data []byte
+	ptr  int
+}
+
+func (r *reader) remaining() int {
+	return len(r.data) - r.ptr
+}
+
+func (r *reader) readBytes(n int) ([]byte, error) {
+	if n < 0 || r.remaining() < n {
+		return nil, fmt.Errorf("cryptonote: reading %d bytes at offset %d: %w", n, r.ptr, io.ErrUnexpectedEOF)
+	}
+	data := r.data[r.ptr : r.ptr+n]
+	r.ptr += n
+	return data, nil
+}
+
+func (r *reader) readByte() (byte, error) {
+	data, err := r.readBytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// 门罗币的varint：每字节7位，小端，最高位表示后面还有字节；不接受多余的0字节
+func (r *reader) readVarint() (uint64, error) {
+	value, size := binary.Uvarint(r.data[r.ptr:])
+	if size == 0 {
+		return 0, fmt.Errorf("cryptonote: varint at offset %d: %w", r.ptr, io.ErrUnexpectedEOF)
+	}
+	if size < 0 {
+		return 0, fmt.Errorf("cryptonote: varint at offset %d overflows", r.ptr)
+	}
+	if size > 1 && r.data[r.ptr+size-1] == 0 {
+		return 0, fmt.Errorf("cryptonote: non-canonical varint at offset %d", r.ptr)
+	}
+	r.ptr += size
+	return value, nil
+}
+
+// 读取数组长度，每个元素至少min_size字节，防止伪造的长度导致分配过多内存
+func (r *reader) readCount(min_size int) (int, error) {
+	count, err := r.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	if min_size > 0 && count > uint64(r.remaining()/min_size) {
+		return 0, fmt.Errorf("cryptonote: array of %d elements at offset %d exceeds data: %w", count, r.ptr, io.ErrUnexpectedEOF)
+	}
+	return int(count), nil
+}
+
+func (r *reader) readKey() (Key, error) {
+	key := Key{}
+	data, err := r.readBytes(32)
+	copy(key[:], data)
+	return key, err
+}
+
+func (r *reader) readKeys(n int) ([]Key, error) {
+	if n > r.remaining()/32 {
+		return nil, fmt.Errorf("cryptonote: %d keys at offset %d exceed data: %w", n, r.ptr, io.ErrUnexpectedEOF)
+	}
+	keys := make([]Key, n)
+	for i := range keys {
+		var err error
+		if keys[i], err = r.readKey(); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// 带varint长度前缀的key数组
+func (r *reader) readKeyVector() ([]Key, error) {
+	count, err := r.readCount(32)
+	if err != nil {
+		return nil, err
+	}
+	return r.readKeys(count)
+}
+
+/* ==== 区块 ==== */
+
+func ParseBlock(blob []byte) (Block, error) {
+	r := reader{data: blob}
+	block, err := r.readBlock()
+	if err != nil {
+		return block, err
+	}
+	if r.remaining() != 0 {
+		return block, ErrTrailingData
+	}
+	return block, nil
+}
+
+func ParseBlockHex(blob string) (Block, error) {
+	data, err := hex.DecodeString(blob)
+	if err != nil {
+		return Block{}, err
+	}
+	return ParseBlock(data)
+}
+
+func (r *reader) readBlockHeader() (BlockHeader, error) {
+	header := BlockHeader{}
+	var err error
+	if header.MajorVersion, err = r.readVarint(); err != nil {
+		return header, err
+	}
+	if header.MinorVersion, err = r.readVarint(); err != nil {
+		return header, err
+	}
+	if header.Timestamp, err = r.readVarint(); err != nil {
+		return header, err
+	}
+	prev_id, err := r.readBytes(32)
+	if err != nil {
+		return header, err
+	}
+	copy(header.PrevId[:], prev_id)
+	nonce, err := r.readBytes(4)
+	if err != nil {
+		return header, err
+	}
+	header.Nonce = binary.LittleEndian.Uint32(nonce)
+	return header, nil
+}
+
+func (r *reader) readBlock() (Block, error) {
+	block := Block{}
+	var err error
+	if block.BlockHeader, err = r.readBlockHeader(); err != nil {
+		return block, err
+	}
+	if block.MinerTx, err = r.readTransaction(false); err != nil {
+		return block, fmt.Errorf("miner tx: %w", err)
+	}
+	count, err := r.readCount(32)
+	if err != nil {
+		return block, err
+	}
+	block.TxHashes = make([]Hash, count)
+	for i := range block.TxHashes {
+		key, err := r.readKey()
+		if err != nil {
+			return block, err
+		}
+		block.TxHashes[i] = Hash(key)
+	}
+	return block, nil
+}
+
+/* ==== 交易 ==== */
+
+// 解析完整的或剪枝后的交易
+func ParseTransaction(blob []byte) (Transaction, error) {
+	r := reader{data: blob}
+	tx, err := r.readTransaction(true)
+	if err != nil {
+		return tx, err
+	}
+	if r.remaining() != 0 {
+		return tx, ErrTrailingData
+	}
+	return tx, nil
+}
+
+func ParseTransactionHex(blob string) (Transaction, error) {
+	data, err := hex.DecodeString(blob)
+	if err != nil {
+		return Transaction{}, err
+	}
+	return ParseTransaction(data)
+}
+
+// allow_pruned为true时，数据在签名之前结束的交易视为剪枝后的交易
+func (r *reader) readTransaction(allow_pruned bool) (Transaction, error) {
+	tx := Transaction{}
+	if err := r.readTransactionPrefix(&tx); err != nil {
+		return tx, err
+	}
+
+	if tx.Version == 1 {
+		if tx.IsCoinbase() {
+			return tx, nil
+		}
+		if allow_pruned && r.remaining() == 0 {
+			tx.Pruned = true
+			return tx, nil
+		}
+		tx.Signatures = make([][]Signature, len(tx.Inputs))
+		for i, input := range tx.Inputs {
+			keys, err := r.readKeys(2 * input.RingSize())
+			if err != nil {
+				return tx, fmt.Errorf("signatures of input %d: %w", i, err)
+			}
+			tx.Signatures[i] = make([]Signature, input.RingSize())
+			for j := range tx.Signatures[i] {
+				tx.Signatures[i][j] = Signature{C: keys[2*j], R: keys[2*j+1]}
+			}
+		}
+		return tx, nil
+	}
+	if tx.Version != 2 {
+		return tx, fmt.Errorf("cryptonote: unsupported transaction version %d", tx.Version)
+	}
+
+	rct, err := r.readRctBase(len(tx.Inputs), len(tx.Outputs))
+	if err != nil {
+		return tx, fmt.Errorf("rct signatures: %w", err)
+	}
+	tx.RctSignatures = &rct
+	if rct.Type == RctTypeNull {
+		return tx, nil
+	}
+	if allow_pruned && r.remaining() == 0 {
+		tx.Pruned = true
+		return tx, nil
+	}
+	prunable, err := r.readRctPrunable(rct.Type, tx.Inputs, len(tx.Outputs))
+	if err != nil {
+		return tx, fmt.Errorf("rct prunable: %w", err)
+	}
+	rct.Prunable = &prunable
+	return tx, nil
+}
+
+func (r *reader) readTransactionPrefix(tx *Transaction) error {
+	var err error
+	if tx.Version, err = r.readVarint(); err != nil {
+		return err
+	}
+	if tx.UnlockTime, err = r.readVarint(); err != nil {
+		return err
+	}
+
+	input_count, err := r.readCount(2)
+	if err != nil {
+		return err
+	}
+	tx.Inputs = make([]TxInput, input_count)
+	for i := range tx.Inputs {
+		if tx.Inputs[i], err = r.readInput(); err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
+		}
+	}
+
+	output_count, err := r.readCount(34)
+	if err != nil {
+		return err
+	}
+	tx.Outputs = make([]TxOutput, output_count)
+	for i := range tx.Outputs {
+		if tx.Outputs[i], err = r.readOutput(); err != nil {
+			return fmt.Errorf("output %d: %w", i, err)
+		}
+	}
+
+	extra_size, err := r.readCount(1)
+	if err != nil {
+		return err
+	}
+	extra, err := r.readBytes(extra_size)
+	if err != nil {
+		return err
+	}
+	tx.Extra = append(HexBytes{}, extra...)
+	return nil
+}
+
+func (r *reader) readInput() (TxInput, error) {
+	input := TxInput{}
+	var err error
+	if input.Type, err = r.readByte(); err != nil {
+		return input, err
+	}
+	switch input.Type {
+	case TxInGen:
+		input.Height, err = r.readVarint()
+		return input, err
+	case TxInToKey:
+		if input.Amount, err = r.readVarint(); err != nil {
+			return input, err
+		}
+		count, err := r.readCount(1)
+		if err != nil {
+			return input, err
+		}
+		input.KeyOffsets = make([]uint64, count)
+		for i := range input.KeyOffsets {
+			if input.KeyOffsets[i], err = r.readVarint(); err != nil {
+				return input, err
+			}
+		}
+		input.KeyImage, err = r.readKey()
+		return input, err
+	}
+	return input, fmt.Errorf("cryptonote: unsupported input type 0x%02x", input.Type)
+}
+
+func (r *reader) readOutput() (TxOutput, error) {
+	output := TxOutput{}
+	var err error
+	if output.Amount, err = r.readVarint(); err != nil {
+		return output, err
+	}
+	if output.Type, err = r.readByte(); err != nil {
+		return output, err
+	}
+	switch output.Type {
+	case TxOutToKey:
+		output.Key, err = r.readKey()
+		return output, err
+	case TxOutToTaggedKey:
+		if output.Key, err = r.readKey(); err != nil {
+			return output, err
+		}
+		output.ViewTag, err = r.readByte()
+		return output, err
+	}
+	return output, fmt.Errorf("cryptonote: unsupported output type 0x%02x", output.Type)
+}
+
+/* ==== RingCT ==== */
+
+func (r *reader) readRctBase(inputs int, outputs int) (RctSignatures, error) {
+	rct := RctSignatures{}
+	var err error
+	if rct.Type, err = r.readByte(); err != nil {
+		return rct, err
+	}
+	if rct.Type == RctTypeNull {
+		return rct, nil
+	}
+	if rct.Type > RctTypeBulletproofPlus {
+		return rct, fmt.Errorf("cryptonote: unsupported rct type %d", rct.Type)
+	}
+	if rct.TxnFee, err = r.readVarint(); err != nil {
+		return rct, err
+	}
+	if rct.Type == RctTypeSimple {
+		if rct.PseudoOuts, err = r.readKeys(inputs); err != nil {
+			return rct, err
+		}
+	}
+	rct.EcdhInfo = make([]EcdhInfo, outputs)
+	for i := range rct.EcdhInfo {
+		if compactEcdh(rct.Type) {
+			amount, err := r.readBytes(8)
+			if err != nil {
+				return rct, err
+			}
+			copy(rct.EcdhInfo[i].Amount[:], amount)
+			continue
+		}
+		if rct.EcdhInfo[i].Mask, err = r.readKey(); err != nil {
+			return rct, err
+		}
+		if rct.EcdhInfo[i].Amount, err = r.readKey(); err != nil {
+			return rct, err
+		}
+	}
+	rct.OutPk, err = r.readKeys(outputs)
+	return rct, err
+}
+
+func (r *reader) readRctPrunable(rct_type byte, inputs []TxInput, outputs int) (RctPrunable, error) {
+	prunable := RctPrunable{}
+	if len(inputs) == 0 {
+		return prunable, errors.New("cryptonote: rct transaction without inputs")
+	}
+
+	// 1. 范围证明
+	switch rct_type {
+	case RctTypeFull, RctTypeSimple:
+		prunable.RangeSigs = make([]RangeSig, outputs)
+		for i := range prunable.RangeSigs {
+			keys, err := r.readKeys(64 + 64 + 1 + 64)
+			if err != nil {
+				return prunable, err
+			}
+			range_sig := &prunable.RangeSigs[i]
+			copy(range_sig.Asig.S0[:], keys[:64])
+			copy(range_sig.Asig.S1[:], keys[64:128])
+			range_sig.Asig.Ee = keys[128]
+			copy(range_sig.Ci[:], keys[129:])
+		}
+	case RctTypeBulletproofPlus:
+		count, err := r.readCount(32*6 + 2)
+		if err != nil {
+			return prunable, err
+		}
+		prunable.BulletproofsPlus = make([]BulletproofPlus, count)
+		for i := range prunable.BulletproofsPlus {
+			if prunable.BulletproofsPlus[i], err = r.readBulletproofPlus(); err != nil {
+				return prunable, err
+			}
+		}
+	default:
+		count := 0
+		if rct_type == RctTypeBulletproof {
+			// RctTypeBulletproof的数量是uint32
+			data, err := r.readBytes(4)
+			if err != nil {
+				return prunable, err
+			}
+			count = int(binary.LittleEndian.Uint32(data))
+			if count > r.remaining()/(32*9+2) {
+				return prunable, fmt.Errorf("cryptonote: %d bulletproofs exceed data: %w", count, io.ErrUnexpectedEOF)
+			}
+		} else {
+			var err error
+			if count, err = r.readCount(32*9 + 2); err != nil {
+				return prunable, err
+			}
+		}
+		prunable.Bulletproofs = make([]Bulletproof, count)
+		for i := range prunable.Bulletproofs {
+			var err error
+			if prunable.Bulletproofs[i], err = r.readBulletproof(); err != nil {
+				return prunable, err
+			}
+		}
+	}
+
+	// 2. 环签名
+	if rct_type == RctTypeCLSAG || rct_type == RctTypeBulletproofPlus {
+		prunable.CLSAGs = make([]CLSAG, len(inputs))
+		for i, input := range inputs {
+			keys, err := r.readKeys(input.RingSize() + 2)
+			if err != nil {
+				return prunable, err
+			}
+			prunable.CLSAGs[i] = CLSAG{S: keys[:input.RingSize()], C1: keys[input.RingSize()], D: keys[input.RingSize()+1]}
+		}
+	} else {
+		// RctTypeFull只有一个MLSAG，每行有输入数+1列；其他类型每个输入一个MLSAG，每行2列
+		mlsags, columns := len(inputs), 2
+		if rct_type == RctTypeFull {
+			mlsags, columns = 1, len(inputs)+1
+		}
+		prunable.MLSAGs = make([]MLSAG, mlsags)
+		for i := range prunable.MLSAGs {
+			rows := inputs[i].RingSize()
+			prunable.MLSAGs[i].SS = make([][]Key, rows)
+			for j := range prunable.MLSAGs[i].SS {
+				var err error
+				if prunable.MLSAGs[i].SS[j], err = r.readKeys(columns); err != nil {
+					return prunable, err
+				}
+			}
+			var err error
+			if prunable.MLSAGs[i].CC, err = r.readKey(); err != nil {
+				return prunable, err
+			}
+		}
+	}
+
+	// 3. pseudoOuts
+	if prunablePseudoOuts(rct_type) {
+		var err error
+		if prunable.PseudoOuts, err = r.readKeys(len(inputs)); err != nil {
+			return prunable, err
+		}
+	}
+	return prunable, nil
+}
+
+func (r *reader) readBulletproof() (Bulletproof, error) {
+	bp := Bulletproof{}
+	keys, err := r.readKeys(6)
+	if err != nil {
+		return bp, err
+	}
+	bp.A, bp.S, bp.T1, bp.T2, bp.Taux, bp.Mu = keys[0], keys[1], keys[2], keys[3], keys[4], keys[5]
+	if bp.L, err = r.readKeyVector(); err != nil {
+		return bp, err
+	}
+	if bp.R, err = r.readKeyVector(); err != nil {
+		return bp, err
+	}
+	if keys, err = r.readKeys(3); err != nil {
+		return bp, err
+	}
+	bp.Aa, bp.B, bp.T = keys[0], keys[1], keys[2]
+	return bp, nil
+}
+
+func (r *reader) readBulletproofPlus() (BulletproofPlus, error) {
+	bpp := BulletproofPlus{}
+	keys, err := r.readKeys(6)
+	if err != nil {
+		return bpp, err
+	}
+	bpp.A, bpp.A1, bpp.B, bpp.R1, bpp.S1, bpp.D1 = keys[0], keys[1], keys[2], keys[3], keys[4], keys[5]
+	if bpp.L, err = r.readKeyVector(); err != nil {
+		return bpp, err
+	}
+	bpp.R, err = r.readKeyVector()
+	return bpp, err
+}
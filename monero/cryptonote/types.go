@@ -0,0 +1,252 @@
+// cryptonote实现门罗币区块和交易的二进制格式的解析和序列化
+package cryptonote
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// 32字节的哈希
+type Hash [32]byte
+
+// 32字节的公钥、key image、承诺等
+type Key [32]byte
+
+func (hash Hash) String() string {
+	return hex.EncodeToString(hash[:])
+}
+
+func (hash Hash) MarshalText() ([]byte, error) {
+	return []byte(hash.String()), nil
+}
+
+func (hash *Hash) UnmarshalText(text []byte) error {
+	return decodeHex32(text, (*[32]byte)(hash))
+}
+
+func (key Key) String() string {
+	return hex.EncodeToString(key[:])
+}
+
+func (key Key) MarshalText() ([]byte, error) {
+	return []byte(key.String()), nil
+}
+
+func (key *Key) UnmarshalText(text []byte) error {
+	return decodeHex32(text, (*[32]byte)(key))
+}
+
+func decodeHex32(text []byte, out *[32]byte) error {
+	if hex.DecodedLen(len(text)) != 32 {
+		return fmt.Errorf("expected 64 hex characters, got %d", len(text))
+	}
+	_, err := hex.Decode(out[:], text)
+	return err
+}
+
+// JSON中序列化为hex字符串的字节数组
+type HexBytes []byte
+
+func (data HexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(data)), nil
+}
+
+func (data *HexBytes) UnmarshalText(text []byte) error {
+	decoded, err := hex.DecodeString(string(text))
+	*data = decoded
+	return err
+}
+
+/*
+==========
+
+	区块
+
+==========
+*/
+
+type BlockHeader struct {
+	MajorVersion uint64 `json:"major_version"`
+	MinorVersion uint64 `json:"minor_version"`
+	Timestamp    uint64 `json:"timestamp"`
+	PrevId       Hash   `json:"prev_id"`
+	Nonce        uint32 `json:"nonce"`
+}
+
+type Block struct {
+	BlockHeader
+	MinerTx  Transaction `json:"miner_tx"`
+	TxHashes []Hash      `json:"tx_hashes"`
+}
+
+/*
+==========
+
+	交易
+
+==========
+*/
+
+// 输入类型
+const (
+	TxInGen   = byte(0xff) // coinbase
+	TxInToKey = byte(0x02)
+)
+
+// 输出类型
+const (
+	TxOutToKey       = byte(0x02)
+	TxOutToTaggedKey = byte(0x03) // 带view tag，HF15起使用
+)
+
+type TxInput struct {
+	Type byte `json:"type"`
+	// TxInGen
+	Height uint64 `json:"height,omitempty"`
+	// TxInToKey
+	Amount     uint64   `json:"amount,omitempty"` // RingCT交易中为0
+	KeyOffsets []uint64 `json:"key_offsets,omitempty"`
+	KeyImage   Key      `json:"key_image"`
+}
+
+// 环的大小，coinbase输入为0
+func (input TxInput) RingSize() int {
+	return len(input.KeyOffsets)
+}
+
+type TxOutput struct {
+	Amount  uint64 `json:"amount"` // RingCT交易中为0
+	Type    byte   `json:"type"`
+	Key     Key    `json:"key"`
+	ViewTag byte   `json:"view_tag,omitempty"` // 只有TxOutToTaggedKey有
+}
+
+// v1交易的环签名中的一项
+type Signature struct {
+	C Key `json:"c"`
+	R Key `json:"r"`
+}
+
+type Transaction struct {
+	Version    uint64     `json:"version"`
+	UnlockTime uint64     `json:"unlock_time"`
+	Inputs     []TxInput  `json:"vin"`
+	Outputs    []TxOutput `json:"vout"`
+	Extra      HexBytes   `json:"extra"`
+
+	// v1：每个输入一组环签名，每组的大小等于环的大小
+	Signatures [][]Signature `json:"signatures,omitempty"`
+	// v2
+	RctSignatures *RctSignatures `json:"rct_signatures,omitempty"`
+
+	// 剪枝后的交易没有签名（v1）或没有RctSignatures.Prunable（v2）
+	Pruned bool `json:"pruned"`
+}
+
+// 是否是coinbase交易
+func (tx *Transaction) IsCoinbase() bool {
+	return len(tx.Inputs) == 1 && tx.Inputs[0].Type == TxInGen
+}
+
+/*
+==========
+
+	RingCT
+
+==========
+*/
+
+// RingCT签名类型
+const (
+	RctTypeNull            = byte(0) // coinbase
+	RctTypeFull            = byte(1)
+	RctTypeSimple          = byte(2)
+	RctTypeBulletproof     = byte(3)
+	RctTypeBulletproof2    = byte(4)
+	RctTypeCLSAG           = byte(5)
+	RctTypeBulletproofPlus = byte(6)
+)
+
+// 加密的金额：Bulletproof2之后只有8字节的Amount
+type EcdhInfo struct {
+	Mask   Key `json:"mask"`
+	Amount Key `json:"amount"`
+}
+
+type RctSignatures struct {
+	Type   byte   `json:"type"`
+	TxnFee uint64 `json:"txn_fee"`
+	// 只有RctTypeSimple的pseudoOuts在这里，之后的类型在Prunable中
+	PseudoOuts []Key      `json:"pseudo_outs,omitempty"`
+	EcdhInfo   []EcdhInfo `json:"ecdh_info"`
+	OutPk      []Key      `json:"out_pk"`
+
+	Prunable *RctPrunable `json:"prunable,omitempty"`
+}
+
+// ecdhInfo是否只有8字节的金额
+func compactEcdh(rct_type byte) bool {
+	return rct_type == RctTypeBulletproof2 || rct_type == RctTypeCLSAG || rct_type == RctTypeBulletproofPlus
+}
+
+// pseudoOuts是否在prunable部分
+func prunablePseudoOuts(rct_type byte) bool {
+	return rct_type >= RctTypeBulletproof && rct_type <= RctTypeBulletproofPlus
+}
+
+type BorromeanSignature struct {
+	S0 [64]Key `json:"s0"`
+	S1 [64]Key `json:"s1"`
+	Ee Key     `json:"ee"`
+}
+
+// RctTypeFull和RctTypeSimple的范围证明
+type RangeSig struct {
+	Asig BorromeanSignature `json:"asig"`
+	Ci   [64]Key            `json:"ci"`
+}
+
+type Bulletproof struct {
+	A    Key   `json:"A"`
+	S    Key   `json:"S"`
+	T1   Key   `json:"T1"`
+	T2   Key   `json:"T2"`
+	Taux Key   `json:"taux"`
+	Mu   Key   `json:"mu"`
+	L    []Key `json:"L"`
+	R    []Key `json:"R"`
+	Aa   Key   `json:"a"`
+	B    Key   `json:"b"`
+	T    Key   `json:"t"`
+}
+
+type BulletproofPlus struct {
+	A  Key   `json:"A"`
+	A1 Key   `json:"A1"`
+	B  Key   `json:"B"`
+	R1 Key   `json:"r1"`
+	S1 Key   `json:"s1"`
+	D1 Key   `json:"d1"`
+	L  []Key `json:"L"`
+	R  []Key `json:"R"`
+}
+
+type MLSAG struct {
+	SS [][]Key `json:"ss"`
+	CC Key     `json:"cc"`
+}
+
+type CLSAG struct {
+	S  []Key `json:"s"`
+	C1 Key   `json:"c1"`
+	D  Key   `json:"D"`
+}
+
+type RctPrunable struct {
+	RangeSigs        []RangeSig        `json:"range_sigs,omitempty"`
+	Bulletproofs     []Bulletproof     `json:"bp,omitempty"`
+	BulletproofsPlus []BulletproofPlus `json:"bpp,omitempty"`
+	MLSAGs           []MLSAG           `json:"mlsags,omitempty"`
+	CLSAGs           []CLSAG           `json:"clsags,omitempty"`
+	PseudoOuts       []Key             `json:"pseudo_outs,omitempty"`
+}
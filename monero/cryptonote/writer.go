@@ -0,0 +1,281 @@
+package cryptonote
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+=============
+
+	序列化
+
+=============
+*/
+
+type writer struct {
+	data []byte
+}
+
+func (w *writer) writeVarint(value uint64) {
+	w.data = binary.AppendUvarint(w.data, value)
+}
+
+func (w *writer) writeKeys(keys ...Key) {
+	for _, key := range keys {
+		w.data = append(w.data, key[:]...)
+	}
+}
+
+func (w *writer) writeKeyVector(keys []Key) {
+	w.writeVarint(uint64(len(keys)))
+	w.writeKeys(keys...)
+}
+
+/* ==== 区块 ==== */
+
+func (header *BlockHeader) Serialize() []byte {
+	w := writer{}
+	w.writeBlockHeader(header)
+	return w.data
+}
+
+func (w *writer) writeBlockHeader(header *BlockHeader) {
+	w.writeVarint(header.MajorVersion)
+	w.writeVarint(header.MinorVersion)
+	w.writeVarint(header.Timestamp)
+	w.data = append(w.data, header.PrevId[:]...)
+	w.data = binary.LittleEndian.AppendUint32(w.data, header.Nonce)
+}
+
+func (block *Block) Serialize() ([]byte, error) {
+	w := writer{}
+	w.writeBlockHeader(&block.BlockHeader)
+	if err := w.writeTransaction(&block.MinerTx); err != nil {
+		return nil, fmt.Errorf("miner tx: %w", err)
+	}
+	w.writeVarint(uint64(len(block.TxHashes)))
+	for _, hash := range block.TxHashes {
+		w.data = append(w.data, hash[:]...)
+	}
+	return w.data, nil
+}
+
+/* ==== 交易 ==== */
+
+// 序列化交易，Pruned为true时只包含前缀和RingCT的base部分
+func (tx *Transaction) Serialize() ([]byte, error) {
+	w := writer{}
+	if err := w.writeTransaction(tx); err != nil {
+		return nil, err
+	}
+	return w.data, nil
+}
+
+// 交易前缀：version到extra
+func (tx *Transaction) SerializePrefix() []byte {
+	w := writer{}
+	w.writeTransactionPrefix(tx)
+	return w.data
+}
+
+// RingCT的base部分：type、txnFee、ecdhInfo和outPk
+func (tx *Transaction) SerializeRctBase() ([]byte, error) {
+	if tx.RctSignatures == nil {
+		return nil, fmt.Errorf("cryptonote: transaction has no rct signatures")
+	}
+	w := writer{}
+	w.writeRctBase(tx.RctSignatures)
+	return w.data, nil
+}
+
+// RingCT的prunable部分：范围证明、环签名和pseudoOuts
+func (tx *Transaction) SerializeRctPrunable() ([]byte, error) {
+	if tx.RctSignatures == nil || tx.RctSignatures.Prunable == nil {
+		return nil, fmt.Errorf("cryptonote: transaction has no prunable data")
+	}
+	w := writer{}
+	if err := w.writeRctPrunable(tx.RctSignatures.Type, tx.RctSignatures.Prunable, tx.Inputs, len(tx.Outputs)); err != nil {
+		return nil, err
+	}
+	return w.data, nil
+}
+
+func (w *writer) writeTransaction(tx *Transaction) error {
+	w.writeTransactionPrefix(tx)
+	switch tx.Version {
+	case 1:
+		if tx.IsCoinbase() || tx.Pruned {
+			return nil
+		}
+		if len(tx.Signatures) != len(tx.Inputs) {
+			return fmt.Errorf("cryptonote: %d signature groups for %d inputs", len(tx.Signatures), len(tx.Inputs))
+		}
+		for i, signatures := range tx.Signatures {
+			if len(signatures) != tx.Inputs[i].RingSize() {
+				return fmt.Errorf("cryptonote: %d signatures for ring of %d in input %d", len(signatures), tx.Inputs[i].RingSize(), i)
+			}
+			for _, signature := range signatures {
+				w.writeKeys(signature.C, signature.R)
+			}
+		}
+		return nil
+	case 2:
+		if tx.RctSignatures == nil {
+			return fmt.Errorf("cryptonote: v2 transaction without rct signatures")
+		}
+		if err := w.checkRctBase(tx.RctSignatures, len(tx.Inputs), len(tx.Outputs)); err != nil {
+			return err
+		}
+		w.writeRctBase(tx.RctSignatures)
+		if tx.RctSignatures.Type == RctTypeNull || tx.Pruned {
+			return nil
+		}
+		if tx.RctSignatures.Prunable == nil {
+			return fmt.Errorf("cryptonote: unpruned transaction without prunable data")
+		}
+		return w.writeRctPrunable(tx.RctSignatures.Type, tx.RctSignatures.Prunable, tx.Inputs, len(tx.Outputs))
+	}
+	return fmt.Errorf("cryptonote: unsupported transaction version %d", tx.Version)
+}
+
+func (w *writer) writeTransactionPrefix(tx *Transaction) {
+	w.writeVarint(tx.Version)
+	w.writeVarint(tx.UnlockTime)
+	w.writeVarint(uint64(len(tx.Inputs)))
+	for _, input := range tx.Inputs {
+		w.data = append(w.data, input.Type)
+		switch input.Type {
+		case TxInGen:
+			w.writeVarint(input.Height)
+		case TxInToKey:
+			w.writeVarint(input.Amount)
+			w.writeVarint(uint64(len(input.KeyOffsets)))
+			for _, offset := range input.KeyOffsets {
+				w.writeVarint(offset)
+			}
+			w.writeKeys(input.KeyImage)
+		}
+	}
+	w.writeVarint(uint64(len(tx.Outputs)))
+	for _, output := range tx.Outputs {
+		w.writeVarint(output.Amount)
+		w.data = append(w.data, output.Type)
+		w.writeKeys(output.Key)
+		if output.Type == TxOutToTaggedKey {
+			w.data = append(w.data, output.ViewTag)
+		}
+	}
+	w.writeVarint(uint64(len(tx.Extra)))
+	w.data = append(w.data, tx.Extra...)
+}
+
+/* ==== RingCT ==== */
+
+// 数组长度由输入输出数决定、不写入数据，长度不对时无法再解析，写之前检查
+func (w *writer) checkRctBase(rct *RctSignatures, inputs int, outputs int) error {
+	if rct.Type == RctTypeNull {
+		return nil
+	}
+	if len(rct.EcdhInfo) != outputs || len(rct.OutPk) != outputs {
+		return fmt.Errorf("cryptonote: ecdhInfo/outPk do not match %d outputs", outputs)
+	}
+	if rct.Type == RctTypeSimple && len(rct.PseudoOuts) != inputs {
+		return fmt.Errorf("cryptonote: pseudoOuts do not match %d inputs", inputs)
+	}
+	return nil
+}
+
+func (w *writer) writeRctBase(rct *RctSignatures) {
+	w.data = append(w.data, rct.Type)
+	if rct.Type == RctTypeNull {
+		return
+	}
+	w.writeVarint(rct.TxnFee)
+	if rct.Type == RctTypeSimple {
+		w.writeKeys(rct.PseudoOuts...)
+	}
+	for _, ecdh := range rct.EcdhInfo {
+		if compactEcdh(rct.Type) {
+			w.data = append(w.data, ecdh.Amount[:8]...)
+			continue
+		}
+		w.writeKeys(ecdh.Mask, ecdh.Amount)
+	}
+	w.writeKeys(rct.OutPk...)
+}
+
+func (w *writer) writeRctPrunable(rct_type byte, prunable *RctPrunable, inputs []TxInput, outputs int) error {
+	switch rct_type {
+	case RctTypeFull, RctTypeSimple:
+		if len(prunable.RangeSigs) != outputs {
+			return fmt.Errorf("cryptonote: %d range proofs for %d outputs", len(prunable.RangeSigs), outputs)
+		}
+		for _, range_sig := range prunable.RangeSigs {
+			w.writeKeys(range_sig.Asig.S0[:]...)
+			w.writeKeys(range_sig.Asig.S1[:]...)
+			w.writeKeys(range_sig.Asig.Ee)
+			w.writeKeys(range_sig.Ci[:]...)
+		}
+	case RctTypeBulletproofPlus:
+		w.writeVarint(uint64(len(prunable.BulletproofsPlus)))
+		for _, bpp := range prunable.BulletproofsPlus {
+			w.writeKeys(bpp.A, bpp.A1, bpp.B, bpp.R1, bpp.S1, bpp.D1)
+			w.writeKeyVector(bpp.L)
+			w.writeKeyVector(bpp.R)
+		}
+	default:
+		if rct_type == RctTypeBulletproof {
+			w.data = binary.LittleEndian.AppendUint32(w.data, uint32(len(prunable.Bulletproofs)))
+		} else {
+			w.writeVarint(uint64(len(prunable.Bulletproofs)))
+		}
+		for _, bp := range prunable.Bulletproofs {
+			w.writeKeys(bp.A, bp.S, bp.T1, bp.T2, bp.Taux, bp.Mu)
+			w.writeKeyVector(bp.L)
+			w.writeKeyVector(bp.R)
+			w.writeKeys(bp.Aa, bp.B, bp.T)
+		}
+	}
+
+	if rct_type == RctTypeCLSAG || rct_type == RctTypeBulletproofPlus {
+		if len(prunable.CLSAGs) != len(inputs) {
+			return fmt.Errorf("cryptonote: %d CLSAGs for %d inputs", len(prunable.CLSAGs), len(inputs))
+		}
+		for i, clsag := range prunable.CLSAGs {
+			if len(clsag.S) != inputs[i].RingSize() {
+				return fmt.Errorf("cryptonote: CLSAG %d does not match ring size %d", i, inputs[i].RingSize())
+			}
+			w.writeKeys(clsag.S...)
+			w.writeKeys(clsag.C1, clsag.D)
+		}
+	} else {
+		mlsags, columns := len(inputs), 2
+		if rct_type == RctTypeFull {
+			mlsags, columns = 1, len(inputs)+1
+		}
+		if len(prunable.MLSAGs) != mlsags {
+			return fmt.Errorf("cryptonote: %d MLSAGs, expected %d", len(prunable.MLSAGs), mlsags)
+		}
+		for i, mlsag := range prunable.MLSAGs {
+			if len(mlsag.SS) != inputs[i].RingSize() {
+				return fmt.Errorf("cryptonote: MLSAG %d does not match ring size %d", i, inputs[i].RingSize())
+			}
+			for _, row := range mlsag.SS {
+				if len(row) != columns {
+					return fmt.Errorf("cryptonote: MLSAG %d row has %d columns, expected %d", i, len(row), columns)
+				}
+				w.writeKeys(row...)
+			}
+			w.writeKeys(mlsag.CC)
+		}
+	}
+
+	if prunablePseudoOuts(rct_type) {
+		if len(prunable.PseudoOuts) != len(inputs) {
+			return fmt.Errorf("cryptonote: %d pseudoOuts for %d inputs", len(prunable.PseudoOuts), len(inputs))
+		}
+		w.writeKeys(prunable.PseudoOuts...)
+	}
+	return nil
+}
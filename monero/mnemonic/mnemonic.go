@@ -0,0 +1,243 @@
+// mnemonic实现门罗币25个助记词（Electrum风格）与私钥的相互转换，以及从助记词派生钱包的密钥和地址
+//
+// 目前只带有英文单词表。monero支持的其他语言（Deutsch、日本語等）的名称和助记词会返回ErrUnsupportedLanguage，
+// 这类助记词仍然可以直接交给monero-wallet-rpc恢复，只是无法在这里离线解码
+package mnemonic
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"gomonero/monero/address"
+	"gomonero/monero/crypto"
+	"hash/crc32"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	SeedWords = 25 // 24个词编码32字节的私钥，最后一个是校验词
+	keyWords  = 24
+)
+
+var (
+	ErrWordCount           = errors.New("mnemonic: seed must have 24 or 25 words")
+	ErrUnknownWord         = errors.New("mnemonic: word is not in any word list")
+	ErrChecksum            = errors.New("mnemonic: checksum word mismatch")
+	ErrInvalidSeed         = errors.New("mnemonic: invalid word sequence")
+	ErrUnknownList         = errors.New("mnemonic: unknown word list")
+	ErrUnsupportedLanguage = errors.New("mnemonic: only the English word list is supported") // monero支持但这里没有单词表的语言
+	ErrKeyNotScalar        = errors.New("mnemonic: key is not a reduced scalar")
+)
+
+type WordList struct {
+	Name      string // 即restore_deterministic_wallet和create_wallet的language参数
+	PrefixLen int    // 单词的前PrefixLen个字符唯一，解码时只比较前缀，校验词也由前缀计算
+	Words     []string
+
+	once     sync.Once
+	prefixes map[string]int
+}
+
+var English = &WordList{Name: "English", PrefixLen: 3, Words: englishWords}
+
+// 支持的单词表，目前只有英文
+var WordLists = []*WordList{English}
+
+// monero-wallet-rpc的get_languages返回的其他语言（本地名称和英文名称），这里没有它们的单词表
+var unsupportedLanguages = map[string]bool{
+	"Deutsch": true, "Español": true, "Esperanto": true, "Français": true, "Italiano": true, "Lojban": true,
+	"Nederlands": true, "Português": true, "русский язык": true, "日本語": true, "简体中文 (中国)": true, "EnglishOld": true,
+	"German": true, "Spanish": true, "French": true, "Italian": true, "Dutch": true, "Portuguese": true,
+	"Russian": true, "Japanese": true, "Chinese (simplified)": true,
+}
+
+// 按名称查找单词表，monero支持的其他语言返回ErrUnsupportedLanguage
+func FindWordList(name string) (*WordList, error) {
+	for _, list := range WordLists {
+		if list.Name == name {
+			return list, nil
+		}
+	}
+	if unsupportedLanguages[name] {
+		return nil, fmt.Errorf("%w, got %q", ErrUnsupportedLanguage, name)
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownList, name)
+}
+
+func (list *WordList) prefix(word string) string {
+	if utf8.RuneCountInString(word) <= list.PrefixLen {
+		return word
+	}
+	runes := []rune(word)
+	return string(runes[:list.PrefixLen])
+}
+
+func (list *WordList) lookup(word string) (int, bool) {
+	list.once.Do(func() {
+		list.prefixes = make(map[string]int, len(list.Words))
+		for i, word := range list.Words {
+			list.prefixes[list.prefix(word)] = i
+		}
+	})
+	index, ok := list.prefixes[list.prefix(word)]
+	return index, ok
+}
+
+// 校验词：前24个词的前缀拼接后的crc32对24取模，选中的词重复一次
+func (list *WordList) checksumIndex(words []string) int {
+	prefixes := ""
+	for _, word := range words[:keyWords] {
+		prefixes += list.prefix(word)
+	}
+	return int(crc32.ChecksumIEEE([]byte(prefixes)) % keyWords)
+}
+
+/*
+==============
+
+	编码和解码
+
+==============
+*/
+
+// 把32字节的私钥编码为25个词
+// 每4字节x编码为3个词：w1 = x%n，w2 = (x/n + w1)%n，w3 = (x/n/n + w2)%n
+func (list *WordList) Encode(key [crypto.KeySize]byte) string {
+	n := uint32(len(list.Words))
+	words := make([]string, 0, SeedWords)
+	for i := 0; i < crypto.KeySize; i += 4 {
+		x := binary.LittleEndian.Uint32(key[i : i+4])
+		w1 := x % n
+		w2 := (x/n + w1) % n
+		w3 := (x/n/n + w2) % n
+		words = append(words, list.Words[w1], list.Words[w2], list.Words[w3])
+	}
+	words = append(words, words[list.checksumIndex(words)])
+	return strings.Join(words, " ")
+}
+
+// 用指定的单词表解码助记词，25个词时检查校验词，24个词时没有校验
+func (list *WordList) Decode(seed string) ([crypto.KeySize]byte, error) {
+	key := [crypto.KeySize]byte{}
+	words := strings.Fields(strings.ToLower(seed))
+	if len(words) != keyWords && len(words) != SeedWords {
+		return key, fmt.Errorf("%w, got %d", ErrWordCount, len(words))
+	}
+	indices := make([]uint32, keyWords)
+	for i, word := range words[:keyWords] {
+		index, ok := list.lookup(word)
+		if !ok {
+			return key, fmt.Errorf("%w: %q", ErrUnknownWord, word)
+		}
+		indices[i] = uint32(index)
+	}
+	if len(words) == SeedWords && list.prefix(words[keyWords]) != list.prefix(words[list.checksumIndex(words)]) {
+		return key, ErrChecksum
+	}
+	n := uint32(len(list.Words))
+	for i := 0; i < keyWords; i += 3 {
+		w1, w2, w3 := indices[i], indices[i+1], indices[i+2]
+		// 在uint64中计算，避免溢出后通过下面的检查
+		x := uint64(w1) + uint64(n)*uint64((n-w1+w2)%n) + uint64(n)*uint64(n)*uint64((n-w2+w3)%n)
+		if x%uint64(n) != uint64(w1) || x > 0xffffffff {
+			return key, ErrInvalidSeed
+		}
+		binary.LittleEndian.PutUint32(key[i/3*4:], uint32(x))
+	}
+	return key, nil
+}
+
+// 解码助记词，自动识别单词表
+// 大部分词都不在任何单词表中时认为是其他语言的助记词，返回ErrUnsupportedLanguage；只有个别词不认识时返回ErrUnknownWord
+func Decode(seed string) ([crypto.KeySize]byte, *WordList, error) {
+	words := strings.Fields(strings.ToLower(seed))
+	if len(words) == 0 {
+		return [crypto.KeySize]byte{}, nil, ErrWordCount
+	}
+	for _, list := range WordLists {
+		if _, ok := list.lookup(words[0]); !ok {
+			continue
+		}
+		key, err := list.Decode(seed)
+		return key, list, err
+	}
+	if !mostlyKnown(words) {
+		return [crypto.KeySize]byte{}, nil, fmt.Errorf("%w: %q is not an English word", ErrUnsupportedLanguage, words[0])
+	}
+	return [crypto.KeySize]byte{}, nil, fmt.Errorf("%w: %q", ErrUnknownWord, words[0])
+}
+
+// 至少一半的词在某个单词表中
+func mostlyKnown(words []string) bool {
+	for _, list := range WordLists {
+		known := 0
+		for _, word := range words {
+			if _, ok := list.lookup(word); ok {
+				known++
+			}
+		}
+		if known*2 >= len(words) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+==========
+
+	钱包密钥
+
+==========
+*/
+
+// 从助记词派生的钱包密钥
+type Keys struct {
+	SpendSecretKey [crypto.KeySize]byte
+	SpendPublicKey [crypto.KeySize]byte
+	ViewSecretKey  [crypto.KeySize]byte
+	ViewPublicKey  [crypto.KeySize]byte
+}
+
+// 由私有spend key派生其他密钥，私有view key = Hs(私有spend key)
+// 与monero-wallet-rpc恢复钱包时一样，spend key先对l取模
+func DeriveKeys(spend_secret_key [crypto.KeySize]byte) Keys {
+	keys := Keys{SpendSecretKey: crypto.ReduceScalar(spend_secret_key)}
+	keys.ViewSecretKey = crypto.HashToScalar(keys.SpendSecretKey[:])
+	// 已经取模，不会失败
+	keys.SpendPublicKey, _ = crypto.SecretKeyToPublicKey(keys.SpendSecretKey)
+	keys.ViewPublicKey, _ = crypto.SecretKeyToPublicKey(keys.ViewSecretKey)
+	return keys
+}
+
+// 解码助记词并派生钱包密钥
+func SeedToKeys(seed string) (Keys, error) {
+	key, _, err := Decode(seed)
+	if err != nil {
+		return Keys{}, err
+	}
+	return DeriveKeys(key), nil
+}
+
+// 钱包的主地址
+func (keys Keys) Address(network address.Network) address.Address {
+	return address.Address{Network: network, Type: address.Standard, SpendKey: keys.SpendPublicKey, ViewKey: keys.ViewPublicKey}
+}
+
+// 钱包的助记词
+func (keys Keys) Seed(list *WordList) string {
+	return list.Encode(keys.SpendSecretKey)
+}
+
+// 随机生成新钱包，用于为测试准备钱包
+func Generate(list *WordList) (string, Keys, error) {
+	key := [crypto.KeySize]byte{}
+	if _, err := rand.Read(key[:]); err != nil {
+		return "", Keys{}, err
+	}
+	keys := DeriveKeys(key)
+	return keys.Seed(list), keys, nil
+}
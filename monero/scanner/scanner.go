@@ -0,0 +1,266 @@
+// scanner用私有view key和公有spend key离线识别属于钱包的输出，不需要monero-wallet-rpc
+package scanner
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gomonero/monero"
+	"gomonero/monero/crypto"
+	"gomonero/monero/cryptonote"
+	"gomonero/rpcproxy"
+)
+
+var ErrCommitmentMismatch = errors.New("scanner: decoded amount does not match the output commitment")
+
+type SubaddressIndex struct {
+	Major uint32 `json:"major"`
+	Minor uint32 `json:"minor"`
+}
+
+// 属于钱包的输出
+type OwnedOutput struct {
+	TxHash      cryptonote.Hash `json:"tx_hash"`
+	Height      uint64          `json:"height"`       // 只有扫描区块时才有
+	OutputIndex int             `json:"output_index"` // 在交易中的序号
+	GlobalIndex uint64          `json:"global_index"` // 只有扫描get_blocks.bin的结果时才有
+	Key         cryptonote.Key  `json:"key"`
+	Amount      monero.Amount   `json:"amount"`
+	// Pedersen承诺的mask，明文金额（v1交易和coinbase）为全0
+	Mask       cryptonote.Key  `json:"mask"`
+	Subaddress SubaddressIndex `json:"subaddress"`
+	TxPubKey   cryptonote.Key  `json:"tx_pub_key"`
+	Coinbase   bool            `json:"coinbase"`
+	// 输出属于钱包，但金额无法解密或与承诺不一致（如ecdhInfo被篡改），Amount和Mask为0，不能花费
+	Invalid       bool  `json:"invalid"`
+	InvalidReason error `json:"-"` // 如ErrCommitmentMismatch
+}
+
+type Scanner struct {
+	view_secret_key  [crypto.KeySize]byte
+	spend_public_key [crypto.KeySize]byte
+	// 子地址的spend公钥 -> 子地址序号
+	subaddresses map[[crypto.KeySize]byte]SubaddressIndex
+}
+
+// 用十六进制的私有view key和公有spend key创建Scanner，默认只识别主地址(0, 0)
+func CreateScanner(view_secret_key string, spend_public_key string) (*Scanner, error) {
+	scanner := &Scanner{subaddresses: map[[crypto.KeySize]byte]SubaddressIndex{}}
+	if err := decodeKey(view_secret_key, &scanner.view_secret_key); err != nil {
+		return nil, fmt.Errorf("view key: %w", err)
+	}
+	if err := decodeKey(spend_public_key, &scanner.spend_public_key); err != nil {
+		return nil, fmt.Errorf("spend key: %w", err)
+	}
+	if _, err := crypto.SecretKeyToPublicKey(scanner.view_secret_key); err != nil {
+		return nil, err
+	}
+	if err := scanner.AddSubaddresses(SubaddressIndex{}); err != nil {
+		return nil, err
+	}
+	return scanner, nil
+}
+
+// 添加要识别的子地址
+func (scanner *Scanner) AddSubaddresses(indices ...SubaddressIndex) error {
+	for _, index := range indices {
+		key, err := crypto.SubaddressSpendPublicKey(scanner.view_secret_key, scanner.spend_public_key, index.Major, index.Minor)
+		if err != nil {
+			return err
+		}
+		scanner.subaddresses[key] = index
+	}
+	return nil
+}
+
+/*
+=================
+
+	扫描交易
+
+=================
+*/
+
+// 扫描一个交易，tx_hash由调用者提供（剪枝的交易无法自己计算哈希）
+// 金额校验失败的输出标记为Invalid后照常返回，不影响同一交易中的其他输出
+func (scanner *Scanner) ScanTransaction(tx *cryptonote.Transaction, tx_hash cryptonote.Hash) ([]OwnedOutput, error) {
+	extra := tx.ParseExtra()
+	tx_pub_key, has_tx_pub_key := extra.TxPubKey()
+	additional_pub_keys := extra.AdditionalPubKeys()
+	// 额外公钥必须与输出一一对应
+	if len(additional_pub_keys) != len(tx.Outputs) {
+		additional_pub_keys = nil
+	}
+	if !has_tx_pub_key && additional_pub_keys == nil {
+		return nil, nil
+	}
+	var derivation [crypto.KeySize]byte
+	var err error
+	if has_tx_pub_key {
+		// 无效的公钥当作没有
+		if derivation, err = crypto.GenerateKeyDerivation(tx_pub_key, scanner.view_secret_key); err != nil {
+			has_tx_pub_key = false
+		}
+	}
+
+	outputs := []OwnedOutput{}
+	for i, output := range tx.Outputs {
+		candidates := []cryptonote.Key{}
+		derivations := [][crypto.KeySize]byte{}
+		if has_tx_pub_key {
+			candidates, derivations = append(candidates, tx_pub_key), append(derivations, derivation)
+		}
+		if additional_pub_keys != nil {
+			if additional, err := crypto.GenerateKeyDerivation(additional_pub_keys[i], scanner.view_secret_key); err == nil {
+				candidates, derivations = append(candidates, additional_pub_keys[i]), append(derivations, additional)
+			}
+		}
+		for j, derivation := range derivations {
+			index, ok := scanner.matchOutput(output, derivation, uint64(i))
+			if !ok {
+				continue
+			}
+			owned := OwnedOutput{
+				TxHash:      tx_hash,
+				OutputIndex: i,
+				Key:         output.Key,
+				Subaddress:  index,
+				TxPubKey:    candidates[j],
+				Coinbase:    tx.IsCoinbase(),
+			}
+			if err := decodeAmount(tx, i, derivation, &owned); err != nil {
+				owned.Invalid = true
+				owned.InvalidReason = fmt.Errorf("output %d of %s: %w", i, tx_hash, err)
+			}
+			outputs = append(outputs, owned)
+			break
+		}
+	}
+	return outputs, nil
+}
+
+// 先比较view tag（只需要一次哈希），再计算输出对应的spend公钥并在子地址中查找
+func (scanner *Scanner) matchOutput(output cryptonote.TxOutput, derivation [crypto.KeySize]byte, output_index uint64) (SubaddressIndex, bool) {
+	if output.Type == cryptonote.TxOutToTaggedKey && crypto.DeriveViewTag(derivation, output_index) != output.ViewTag {
+		return SubaddressIndex{}, false
+	}
+	spend_public_key, err := crypto.DeriveSubaddressPublicKey(output.Key, derivation, output_index)
+	if err != nil {
+		return SubaddressIndex{}, false
+	}
+	index, ok := scanner.subaddresses[spend_public_key]
+	return index, ok
+}
+
+// 解密RingCT金额并用输出的承诺校验
+func decodeAmount(tx *cryptonote.Transaction, output_index int, derivation [crypto.KeySize]byte, owned *OwnedOutput) error {
+	rct := tx.RctSignatures
+	if tx.Version == 1 || rct == nil || rct.Type == cryptonote.RctTypeNull {
+		owned.Amount = monero.Amount(tx.Outputs[output_index].Amount)
+		return nil
+	}
+	if output_index >= len(rct.EcdhInfo) || output_index >= len(rct.OutPk) {
+		return errors.New("missing ecdhInfo")
+	}
+	shared_secret := crypto.DerivationToScalar(derivation, uint64(output_index))
+	ecdh := rct.EcdhInfo[output_index]
+	var amount uint64
+	var mask [crypto.KeySize]byte
+	switch rct.Type {
+	case cryptonote.RctTypeFull, cryptonote.RctTypeSimple, cryptonote.RctTypeBulletproof:
+		var err error
+		if amount, mask, err = crypto.DecodeAmount(ecdh.Mask, ecdh.Amount, shared_secret); err != nil {
+			return err
+		}
+	default:
+		amount, mask = crypto.DecodeCompactAmount([8]byte(ecdh.Amount[:8]), shared_secret)
+	}
+	commitment, err := crypto.Commit(amount, mask)
+	if err != nil {
+		return err
+	}
+	if commitment != rct.OutPk[output_index] {
+		return ErrCommitmentMismatch
+	}
+	owned.Amount, owned.Mask = monero.Amount(amount), mask
+	return nil
+}
+
+/*
+=================
+
+	扫描区块
+
+=================
+*/
+
+// 扫描get_blocks.bin返回的区块，第i个区块的高度为start_height+i
+// output_indices可以为空，不为空时填充输出的全局索引；Invalid的输出也一起返回，由调用者决定如何处理
+func (scanner *Scanner) ScanBlockEntries(start_height uint64, blocks []rpcproxy.BlockCompleteEntry, output_indices []rpcproxy.BlockOutputIndices) ([]OwnedOutput, error) {
+	outputs := []OwnedOutput{}
+	for i, entry := range blocks {
+		height := start_height + uint64(i)
+		block, err := cryptonote.ParseBlock(entry.Block)
+		if err != nil {
+			return outputs, fmt.Errorf("block %d: %w", height, err)
+		}
+		if len(entry.Txs) != len(block.TxHashes) {
+			return outputs, fmt.Errorf("block %d: %d transactions for %d hashes", height, len(entry.Txs), len(block.TxHashes))
+		}
+		miner_tx_hash, err := block.MinerTx.Hash()
+		if err != nil {
+			return outputs, fmt.Errorf("block %d: %w", height, err)
+		}
+		txs := []*cryptonote.Transaction{&block.MinerTx}
+		hashes := []cryptonote.Hash{miner_tx_hash}
+		for j, tx_entry := range entry.Txs {
+			tx, err := cryptonote.ParseTransaction(tx_entry.Blob)
+			if err != nil {
+				return outputs, fmt.Errorf("block %d tx %s: %w", height, block.TxHashes[j], err)
+			}
+			txs, hashes = append(txs, &tx), append(hashes, block.TxHashes[j])
+		}
+		var block_indices []rpcproxy.TxOutputIndices
+		if i < len(output_indices) && len(output_indices[i].Indices) == len(txs) {
+			block_indices = output_indices[i].Indices
+		}
+		for j, tx := range txs {
+			tx_outputs, err := scanner.ScanTransaction(tx, hashes[j])
+			if err != nil {
+				return outputs, fmt.Errorf("block %d: %w", height, err)
+			}
+			for _, output := range tx_outputs {
+				output.Height = height
+				if block_indices != nil && output.OutputIndex < len(block_indices[j].Indices) {
+					output.GlobalIndex = block_indices[j].Indices[output.OutputIndex]
+				}
+				outputs = append(outputs, output)
+			}
+		}
+	}
+	return outputs, nil
+}
+
+// 通过get_blocks.bin下载区块并扫描，返回下一次扫描的起始高度
+// Invalid的输出不算错误，高度照常前进，不会反复扫描同一个区块
+func (scanner *Scanner) ScanBlocks(ctx context.Context, daemon *rpcproxy.DaemonRPCProxy, request rpcproxy.GetBlocksBinRequest) ([]OwnedOutput, uint64, error) {
+	result, err := daemon.GetBlocksBin(ctx, request)
+	if err != nil {
+		return nil, request.StartHeight, err
+	}
+	outputs, err := scanner.ScanBlockEntries(result.StartHeight, result.Blocks, result.OutputIndices)
+	if err != nil {
+		return outputs, request.StartHeight, err
+	}
+	return outputs, result.StartHeight + uint64(len(result.Blocks)), nil
+}
+
+func decodeKey(key string, out *[crypto.KeySize]byte) error {
+	data, err := hex.DecodeString(key)
+	if err != nil || len(data) != crypto.KeySize {
+		return fmt.Errorf("expected %d bytes of hex, got %q", crypto.KeySize, key)
+	}
+	copy(out[:], data)
+	return nil
+}
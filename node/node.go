@@ -1,13 +1,15 @@
 package node
 
 import (
+	"context"
 	"crypto/rand"
-	"fmt"
+	"errors"
 	"gomonero/levin"
-	"log"
+	"gomonero/metrics"
+	"io"
+	"log/slog"
 	"math/big"
 	"net"
-	"os"
 	"strconv"
 	"sync"
 )
@@ -17,24 +19,40 @@ type Node struct {
 	my_port        uint32
 	network_id     []byte
 	peer_id        uint64
+	logger         *slog.Logger
 	listener       net.Listener
-	in_peers       map[net.Conn]bool
+	in_peers       map[net.Conn]*Peer
 	in_peers_lock  sync.Mutex
-	out_peers      map[net.Conn]bool
+	out_peers      map[net.Conn]*Peer
 	out_peers_lock sync.Mutex
 }
 
+// 节点的配置
+type NodeConfig struct {
+	NetworkType string       // "mainnet"或"testnet"，默认mainnet
+	ListenPort  uint32       // 监听传入连接的端口
+	Logger      *slog.Logger // 为nil时使用slog.Default()
+}
+
 func CreateNode(network_type string, listen_port uint32) *Node {
+	return CreateNodeWithConfig(NodeConfig{NetworkType: network_type, ListenPort: listen_port})
+}
+
+func CreateNodeWithConfig(config NodeConfig) *Node {
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
 	// 生成peer_id
 	max := new(big.Int).Lsh(big.NewInt(1), 64)
 	random_num, err := rand.Int(rand.Reader, max)
 	if err != nil {
-		fmt.Println("Error generating random peer_id: ", err)
+		logger.Error("generate random peer_id failed", "error", err)
 		return nil
 	}
 	// 确定network_id
 	var network_id []byte
-	switch network_type {
+	switch config.NetworkType {
 	case "mainnet":
 		network_id = levin.NetworkIdMainnet
 	case "testnet":
@@ -43,16 +61,17 @@ func CreateNode(network_type string, listen_port uint32) *Node {
 		network_id = levin.NetworkIdMainnet
 	}
 	node := Node{
-		my_port:    listen_port,
+		my_port:    config.ListenPort,
 		network_id: network_id,
 		peer_id:    random_num.Uint64(),
-		in_peers:   make(map[net.Conn]bool),
-		out_peers:  make(map[net.Conn]bool),
+		logger:     logger.With("component", "node"),
+		in_peers:   make(map[net.Conn]*Peer),
+		out_peers:  make(map[net.Conn]*Peer),
 	}
 	return &node
 }
 
-func (node *Node) Start() {
+func (node *Node) Start() error {
 	// IO多路复用启动Server
 	// 在Linux环境下，goroutine底层会调用epoll来实现高并发
 
@@ -60,27 +79,31 @@ func (node *Node) Start() {
 	var err error
 	node.listener, err = net.Listen("tcp", ":"+strconv.Itoa(int(node.my_port)))
 	if err != nil {
-		log.Println("Error create listener: ", err)
-		os.Exit(1)
+		node.logger.Error("create listener failed", "port", node.my_port, "error", err)
+		return err
 	}
-	fmt.Println("Node Server is listening on port " + strconv.Itoa(int(node.my_port)))
+	node.logger.Info("node server is listening", "port", node.my_port)
 
 	// 2. 使用协程处理传入连接请求
 	go node.acceptIncomingConnection()
+	return nil
 }
 
 func (node *Node) Stop() {
-	node.listener.Close()
+	if node.listener != nil {
+		node.listener.Close()
+	}
 }
 
 func (node *Node) acceptIncomingConnection() {
 	for {
 		conn, err := node.listener.Accept()
-		fmt.Println("Accept")
 		if err != nil {
-			fmt.Println("Error accepting connection:", err)
-			os.Exit(0)
+			// Stop关闭listener后Accept返回错误，退出循环
+			node.logger.Info("stop accepting connection", "reason", err)
+			return
 		}
+		node.logger.Debug("accept incoming connection", "peer", conn.RemoteAddr().String())
 
 		// 3. 并发处理连接
 		go node.handleIncomingConnection(conn)
@@ -89,145 +112,155 @@ func (node *Node) acceptIncomingConnection() {
 
 func (node *Node) handleIncomingConnection(conn net.Conn) {
 	defer node.dropIncommingConnection(conn)
-	// fmt.Println("Accept incoming connection from " + (*conn).RemoteAddr().String())
 
-	for {
-		// 读消息
-		msg := levin.LevinProtocolMessage{}
-		err := msg.ReadBuffer(conn)
-		if err != nil {
-			return
-		}
+	peer := newPeer(node, conn, false)
+	peer.run()
+}
 
-		// 处理消息
-		if msg.GetCommand() == levin.CommandHandshake {
-			if msg.GetExpectResponse() {
-				response_msg := levin.LevinProtocolMessage{}
-				response_msg.CreateHandshakeResponse(node.my_port, node.network_id, node.peer_id, nil)
-				data_to_send := append(response_msg.HeaderBytes(), response_msg.PayloadBytes()...)
-				_, err := conn.Write(data_to_send)
-				if err != nil {
-					log.Println("Error sending data:", err)
-					return
-				} else {
-					fmt.Println("Handshake response sent!")
-					// 成功接受传入连接，将传入连接记录下来
-					node.recordIncomingConnection(conn)
-				}
-			} else {
-				fmt.Println("Receive Handshake response!")
-			}
-			continue
-		}
-		if msg.GetCommand() == levin.CommandPingPong {
-			if msg.GetExpectResponse() {
-				response_msg := levin.LevinProtocolMessage{}
-				response_msg.CreatePongResponse(node.peer_id)
-				data_to_send := append(response_msg.HeaderBytes(), response_msg.PayloadBytes()...)
-				_, err := conn.Write(data_to_send)
-				if err != nil {
-					log.Println("Error sending data:", err)
-					return
-				} else {
-					fmt.Println("Pong response sent!")
-				}
-			} else {
-				fmt.Println("Receive Pong response")
-			}
-			continue
-		}
-		if msg.GetCommand() == levin.CommandTimedSync {
-			if msg.GetExpectResponse() {
-				response_msg := levin.LevinProtocolMessage{}
-				response_msg.CreateTimedSyncResponse(node.my_port, node.network_id, node.peer_id, generateRamdomPeerlist(levin.MaxPeerlistEntryNum))
-				data_to_send := append(response_msg.HeaderBytes(), response_msg.PayloadBytes()...)
-				_, err := conn.Write(data_to_send)
-				if err != nil {
-					log.Println("Error sending data:", err)
-					return
-				} else {
-					fmt.Println("Timed Sync response sent!")
-				}
-			} else {
-				fmt.Println("Receive Timed Sync response")
-			}
-			continue
+// 处理对端主动发来的消息（请求，以及没有Invoke在等待的响应），返回error时断开连接
+func (node *Node) handleMessage(peer *Peer, msg *levin.LevinProtocolMessage) error {
+	if !msg.GetExpectResponse() {
+		peer.logger().Debug("receive message", "command", msg.GetCommand())
+		return nil
+	}
+	response_msg := levin.LevinProtocolMessage{}
+	var err error
+	switch msg.GetCommand() {
+	case levin.CommandHandshake:
+		if peer_id, ok := levin.ParsePeerId(msg.GetPayload()); ok {
+			peer.peer_id.Store(peer_id)
 		}
+		err = response_msg.CreateHandshakeResponse(node.my_port, node.network_id, node.peer_id, nil)
+	case levin.CommandPingPong:
+		err = response_msg.CreatePongResponse(node.peer_id)
+	case levin.CommandTimedSync:
+		err = response_msg.CreateTimedSyncResponse(node.my_port, node.network_id, node.peer_id, generateRamdomPeerlist(levin.MaxPeerlistEntryNum))
+	case levin.CommandRequestSupportFlags:
+		err = response_msg.CreateSupportFlagsResponse()
+	default:
+		peer.logger().Debug("ignore unsupported request", "command", msg.GetCommand())
+		return nil
+	}
+	if err != nil {
+		peer.logger().Error("create response failed", "command", msg.GetCommand(), "error", err)
+		return err
 	}
+	if err := peer.send(&response_msg); err != nil {
+		peer.logger().Warn("send response failed", "command", msg.GetCommand(), "error", err)
+		return err
+	}
+	peer.logger().Debug("response sent", "command", msg.GetCommand())
+	// 成功接受传入连接的握手，将传入连接记录下来
+	if msg.GetCommand() == levin.CommandHandshake && !peer.IsOutgoing() {
+		node.recordIncomingConnection(peer)
+		peer.logger().Info("incoming connection established")
+	}
+	return nil
 }
 
-// disconnect表示是否在连接建立完成后立刻断开连接
-func (node *Node) EstablishOutgoingConnection(ip string, port uint16, disconnect_immediately bool) error {
+// 建立传出连接并完成握手，返回的Peer可以用Invoke继续向对端发送请求
+func (node *Node) ConnectPeer(ctx context.Context, ip string, port uint16) (*Peer, error) {
 	// 建立tcp连接
 	address := ip + ":" + strconv.Itoa(int(port))
-	conn, err := net.Dial("tcp", address)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Fail to connect to target %s: %v\n", address, err)
-		return err
-	}
-	// fmt.Printf("Successfully connect to target %s\n", address)
-	// 发送握手请求
-	request_msg := levin.LevinProtocolMessage{}
-	request_msg.CreateHandshakeRequest(node.my_port, node.network_id, node.peer_id)
-	data_to_send := append(request_msg.HeaderBytes(), request_msg.PayloadBytes()...)
-	_, err = conn.Write(data_to_send)
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
 	if err != nil {
-		log.Println("Error sending data:", err)
-		return err
-	} else {
-		fmt.Println("Handshake request sent!")
+		node.logger.Warn("connect to target failed", "peer", address, "direction", "outbound", "error", err)
+		metrics.HandshakeFailures.WithLabelValues("dial").Inc()
+		return nil, err
 	}
-	// 此时monero传出连接已经建立，将连接加入到node的记录中
-	node.recordOutgoingConnection(conn)
-	// 循环接收对端的响应
+	peer := newPeer(node, conn, true)
+	// 循环接收对端的消息
 	go func() {
 		defer node.dropOutgoingConnection(conn)
-		for {
-			// 读消息
-			msg := levin.LevinProtocolMessage{}
-			err := msg.ReadBuffer(conn)
-			if err != nil {
-				return
-			}
-
-			// 处理Handshake响应消息
-			if msg.GetCommand() == levin.CommandHandshake && !msg.GetExpectResponse() {
-				// 是否立刻断开连接
-				if disconnect_immediately {
-					break // 立刻断开连接：whitelist attack
-				}
-				// 否则，不断开连接：graylist attack和传入连接占领
-			}
-			if msg.GetCommand() == levin.CommandTimedSync {
-				// timed sync 请求
-				if msg.GetExpectResponse() {
-					response_msg := levin.LevinProtocolMessage{}
-					response_msg.CreateTimedSyncResponse(node.my_port, node.network_id, node.peer_id, generateRamdomPeerlist(levin.MaxPeerlistEntryNum))
-				}
-			}
-		}
+		peer.run()
 	}()
+	// 发送握手请求并等待响应
+	_, err = peer.Handshake(ctx)
+	if err != nil {
+		peer.logger().Warn("handshake failed", "error", err)
+		metrics.HandshakeFailures.WithLabelValues(handshakeFailureReason(err)).Inc()
+		peer.Close()
+		return nil, err
+	}
+	// 握手成功后monero传出连接才算建立，将连接加入到node的记录中
+	node.recordOutgoingConnection(peer)
+	// 连接可能在握手后立刻断开，读循环的dropOutgoingConnection已经先执行过
+	select {
+	case <-peer.Done():
+		node.dropOutgoingConnection(conn)
+	default:
+	}
+	peer.logger().Info("outgoing connection established")
+	return peer, nil
+}
+
+// disconnect表示是否在连接建立完成后立刻断开连接
+func (node *Node) EstablishOutgoingConnection(ip string, port uint16, disconnect_immediately bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultHandshakeTimeout)
+	defer cancel()
+	peer, err := node.ConnectPeer(ctx, ip, port)
+	if err != nil {
+		return err
+	}
+	// 是否立刻断开连接
+	if disconnect_immediately {
+		peer.Close() // 立刻断开连接：whitelist attack
+	}
+	// 否则，不断开连接：graylist attack和传入连接占领
 	return nil
 }
 
+// 当前的传入连接
+func (node *Node) InPeers() []*Peer {
+	node.in_peers_lock.Lock()
+	defer node.in_peers_lock.Unlock()
+	peers := make([]*Peer, 0, len(node.in_peers))
+	for _, peer := range node.in_peers {
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// 当前的传出连接
+func (node *Node) OutPeers() []*Peer {
+	node.out_peers_lock.Lock()
+	defer node.out_peers_lock.Unlock()
+	peers := make([]*Peer, 0, len(node.out_peers))
+	for _, peer := range node.out_peers {
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
 // 传入连接建立后的处理
-func (node *Node) recordIncomingConnection(conn net.Conn) {
+func (node *Node) recordIncomingConnection(peer *Peer) {
 	node.in_peers_lock.Lock()
-	node.in_peers[conn] = true
+	if _, ok := node.in_peers[peer.conn]; !ok {
+		node.in_peers[peer.conn] = peer
+		metrics.Peers.WithLabelValues("inbound").Inc()
+	}
 	node.in_peers_lock.Unlock()
 }
 
 // 传出连接建立后的处理
-func (node *Node) recordOutgoingConnection(conn net.Conn) {
+func (node *Node) recordOutgoingConnection(peer *Peer) {
 	node.out_peers_lock.Lock()
-	node.out_peers[conn] = true
+	if _, ok := node.out_peers[peer.conn]; !ok {
+		node.out_peers[peer.conn] = peer
+		metrics.Peers.WithLabelValues("outbound").Inc()
+	}
 	node.out_peers_lock.Unlock()
 }
 
 // 传入连接断开的后处理
 func (node *Node) dropIncommingConnection(conn net.Conn) {
 	node.in_peers_lock.Lock()
-	delete(node.in_peers, conn)
+	// 没有完成握手的连接不在表中，也没有计数
+	if _, ok := node.in_peers[conn]; ok {
+		delete(node.in_peers, conn)
+		metrics.Peers.WithLabelValues("inbound").Dec()
+	}
 	defer conn.Close()
 	node.in_peers_lock.Unlock()
 }
@@ -235,11 +268,32 @@ func (node *Node) dropIncommingConnection(conn net.Conn) {
 // 传出连接断开的后处理
 func (node *Node) dropOutgoingConnection(conn net.Conn) {
 	node.out_peers_lock.Lock()
-	delete(node.out_peers, conn)
+	// 没有完成握手的连接不在表中，也没有计数
+	if _, ok := node.out_peers[conn]; ok {
+		delete(node.out_peers, conn)
+		metrics.Peers.WithLabelValues("outbound").Dec()
+	}
 	defer conn.Close()
 	node.out_peers_lock.Unlock()
 }
 
+// 握手失败原因，作为监控指标的标签
+func handshakeFailureReason(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, ErrPeerClosed), errors.Is(err, io.EOF):
+		return "closed"
+	}
+	var net_err net.Error
+	if errors.As(err, &net_err) {
+		return "network"
+	}
+	return "other"
+}
+
 /*
 =============
 
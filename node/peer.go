@@ -0,0 +1,263 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gomonero/levin"
+	"gomonero/metrics"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 默认的握手超时时间，和monerod的P2P_DEFAULT_HANDSHAKE_INVOKE_TIMEOUT保持一致
+const DefaultHandshakeTimeout = 5 * time.Second
+
+var ErrPeerClosed = errors.New("peer connection closed")
+
+// 与对端节点之间的一条levin连接
+// 连接上只有一个读协程，读到的响应按command分发给等待中的Invoke调用，其余消息交给node处理
+type Peer struct {
+	node     *Node
+	conn     net.Conn
+	outgoing bool
+	peer_id  atomic.Uint64
+
+	write_lock sync.Mutex
+
+	// levin协议的响应没有请求id，同一个command的响应按请求发送的顺序返回，
+	// 所以每个command维护一个先进先出的等待队列
+	pending      map[uint32][]chan *levin.LevinProtocolMessage
+	pending_lock sync.Mutex
+
+	closed     chan struct{}
+	close_once sync.Once
+	close_err  error
+}
+
+func newPeer(node *Node, conn net.Conn, outgoing bool) *Peer {
+	return &Peer{
+		node:     node,
+		conn:     conn,
+		outgoing: outgoing,
+		pending:  make(map[uint32][]chan *levin.LevinProtocolMessage),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (peer *Peer) RemoteAddr() net.Addr {
+	return peer.conn.RemoteAddr()
+}
+
+// 对端的peer_id，握手完成之前为0
+func (peer *Peer) PeerId() uint64 {
+	return peer.peer_id.Load()
+}
+
+func (peer *Peer) IsOutgoing() bool {
+	return peer.outgoing
+}
+
+func (peer *Peer) direction() string {
+	if peer.outgoing {
+		return "outbound"
+	}
+	return "inbound"
+}
+
+// 带有对端地址、peer_id和连接方向属性的logger，peer_id在握手后才确定，所以每次重新生成
+func (peer *Peer) logger() *slog.Logger {
+	return peer.node.logger.With(
+		"peer", peer.conn.RemoteAddr().String(),
+		"peer_id", peer.PeerId(),
+		"direction", peer.direction(),
+	)
+}
+
+// 连接断开后关闭的channel
+func (peer *Peer) Done() <-chan struct{} {
+	return peer.closed
+}
+
+func (peer *Peer) Close() error {
+	peer.shutdown(ErrPeerClosed)
+	return peer.conn.Close()
+}
+
+// 向对端发送一个请求，并等待同一command的下一条响应，返回响应的payload
+// ctx超时或取消、连接断开、对端返回错误码时返回error，请求发出后ctx超时或取消会断开连接
+func (peer *Peer) Invoke(ctx context.Context, command uint32, request map[string]interface{}) (map[string]interface{}, error) {
+	// 还没发送就已取消的请求不影响连接
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// 先构造请求，失败时还没有登记，不影响后面的请求
+	request_msg := levin.LevinProtocolMessage{}
+	if err := request_msg.CreateRequest(command, request); err != nil {
+		return nil, err
+	}
+	// 先登记等待队列，再发送请求，避免响应比登记先到
+	response_chan := make(chan *levin.LevinProtocolMessage, 1)
+	peer.pending_lock.Lock()
+	select {
+	case <-peer.closed:
+		peer.pending_lock.Unlock()
+		return nil, peer.close_err
+	default:
+	}
+	peer.pending[command] = append(peer.pending[command], response_chan)
+	peer.pending_lock.Unlock()
+
+	// 写入失败时可能只写了一部分，数据流已经错位，断开连接
+	if err := peer.send(&request_msg); err != nil {
+		peer.logger().Warn("send request failed, dropping connection", "command", command, "error", err)
+		peer.removePending(command, response_chan)
+		peer.shutdown(err)
+		peer.conn.Close()
+		return nil, err
+	}
+	peer.logger().Debug("request sent", "command", command)
+
+	// levin的响应不带请求id，只能按command先进先出地匹配，
+	// 已发出的请求超时或取消后无法确定对端是否还会响应，队列可能从此错位，所以直接断开连接，由调用者重连
+	select {
+	case response_msg := <-response_chan:
+		if response_msg.GetReturnCode() < 0 {
+			peer.logger().Warn("request failed", "command", command, "return_code", response_msg.GetReturnCode())
+			return nil, fmt.Errorf("levin command %d failed with return code %d", command, response_msg.GetReturnCode())
+		}
+		return response_msg.GetPayload(), nil
+	case <-peer.closed:
+		return nil, peer.close_err
+	case <-ctx.Done():
+		err := fmt.Errorf("levin command %d: no response: %w", command, ctx.Err())
+		peer.logger().Warn("request timed out, dropping connection", "command", command, "error", ctx.Err())
+		peer.shutdown(err)
+		peer.conn.Close()
+		return nil, err
+	}
+}
+
+// 握手，成功后记录对端的peer_id，返回对端的peerlist
+func (peer *Peer) Handshake(ctx context.Context) ([]levin.PeerlistEntry, error) {
+	payload, err := peer.Invoke(ctx, levin.CommandHandshake,
+		levin.HandshakeRequestPayload(peer.node.my_port, peer.node.network_id, peer.node.peer_id))
+	if err != nil {
+		return nil, err
+	}
+	if peer_id, ok := levin.ParsePeerId(payload); ok {
+		peer.peer_id.Store(peer_id)
+	}
+	peerlist := levin.ParsePeerlist(payload)
+	metrics.PeerlistSize.Observe(float64(len(peerlist)))
+	return peerlist, nil
+}
+
+// 询问对端的support_flags
+func (peer *Peer) RequestSupportFlags(ctx context.Context) (uint32, error) {
+	payload, err := peer.Invoke(ctx, levin.CommandRequestSupportFlags, levin.SupportFlagsRequestPayload())
+	if err != nil {
+		return 0, err
+	}
+	support_flags, ok := levin.ParseSupportFlags(payload)
+	if !ok {
+		return 0, errors.New("support_flags missing in response")
+	}
+	return support_flags, nil
+}
+
+// 发送Timed Sync请求，返回对端的peerlist
+func (peer *Peer) TimedSync(ctx context.Context) ([]levin.PeerlistEntry, error) {
+	payload, err := peer.Invoke(ctx, levin.CommandTimedSync, levin.TimedSyncRequestPayload(peer.node.network_id))
+	if err != nil {
+		return nil, err
+	}
+	peerlist := levin.ParsePeerlist(payload)
+	metrics.PeerlistSize.Observe(float64(len(peerlist)))
+	return peerlist, nil
+}
+
+// 发送ping，对端返回pong即成功
+func (peer *Peer) Ping(ctx context.Context) error {
+	_, err := peer.Invoke(ctx, levin.CommandPingPong, nil)
+	return err
+}
+
+// 从等待队列中移除没有发出的请求
+func (peer *Peer) removePending(command uint32, response_chan chan *levin.LevinProtocolMessage) {
+	peer.pending_lock.Lock()
+	defer peer.pending_lock.Unlock()
+	waiters := peer.pending[command]
+	for i, waiter := range waiters {
+		if waiter == response_chan {
+			peer.pending[command] = append(waiters[:i:i], waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// 序列化并发送一条消息，多个协程可能同时写同一个连接，需要加锁
+func (peer *Peer) send(msg *levin.LevinProtocolMessage) error {
+	data_to_send := append(msg.HeaderBytes(), msg.PayloadBytes()...)
+	peer.write_lock.Lock()
+	defer peer.write_lock.Unlock()
+	_, err := peer.conn.Write(data_to_send)
+	if err == nil {
+		metrics.LevinMessages.WithLabelValues(levin.CommandName(msg.GetCommand()), "out").Inc()
+		metrics.LevinBytes.WithLabelValues("out").Add(float64(len(data_to_send)))
+	}
+	return err
+}
+
+// 读循环：连接断开前一直读取消息，响应交给等待的Invoke，其余交给node处理
+func (peer *Peer) run() {
+	for {
+		msg := levin.LevinProtocolMessage{}
+		err := msg.ReadBuffer(peer.conn)
+		if err != nil {
+			peer.logger().Info("disconnect", "reason", err)
+			peer.shutdown(err)
+			return
+		}
+		metrics.LevinMessages.WithLabelValues(levin.CommandName(msg.GetCommand()), "in").Inc()
+		metrics.LevinBytes.WithLabelValues("in").Add(float64(len(msg.HeaderBytes()) + len(msg.PayloadBytes())))
+		if msg.IsResponse() && peer.deliverResponse(&msg) {
+			continue
+		}
+		if err := peer.node.handleMessage(peer, &msg); err != nil {
+			peer.shutdown(err)
+			return
+		}
+	}
+}
+
+// 将响应交给等待队列中最早的Invoke，没有等待者时返回false
+func (peer *Peer) deliverResponse(msg *levin.LevinProtocolMessage) bool {
+	peer.pending_lock.Lock()
+	defer peer.pending_lock.Unlock()
+	queue := peer.pending[msg.GetCommand()]
+	if len(queue) == 0 {
+		return false
+	}
+	response_chan := queue[0]
+	if len(queue) == 1 {
+		delete(peer.pending, msg.GetCommand())
+	} else {
+		peer.pending[msg.GetCommand()] = queue[1:]
+	}
+	response_chan <- msg
+	return true
+}
+
+func (peer *Peer) shutdown(err error) {
+	peer.close_once.Do(func() {
+		peer.pending_lock.Lock()
+		peer.close_err = err
+		peer.pending = make(map[uint32][]chan *levin.LevinProtocolMessage)
+		close(peer.closed)
+		peer.pending_lock.Unlock()
+	})
+}
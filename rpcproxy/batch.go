@@ -0,0 +1,177 @@
+package rpcproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+/*
+=====================
+JSON-RPC批量请求
+=====================
+*/
+
+// 一个批量请求最多包含的调用数，超过时拆成多个批量请求
+const MaxBatchSize = 100
+
+// 批量请求中的一个调用，Params和Result的含义与callJson相同，调用完成后Err为这个调用的错误
+type BatchCall struct {
+	Method string
+	Params interface{}
+	Result interface{}
+	Err    error
+}
+
+// 批量响应中的一个元素，id按原样保留，兼容服务器把id写成数字的情况
+type batchResponse struct {
+	Id     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+var errBatchRejected = errors.New("server rejected json-rpc batch")
+
+// json rpc 批量请求，calls的结果和错误写回各自的BatchCall
+func JsonBatchRequest(ctx context.Context, json_rpc_url string, calls []*BatchCall, rpc_user string, rpc_password string) error {
+	client := sharedRPCClient(json_rpc_url, "", rpc_user, rpc_password)
+	return client.callBatch(ctx, calls)
+}
+
+// 批量调用：一次请求发送多个调用，用id区分各自的响应
+// 服务器不支持批量请求时（如monerod）改为逐个调用，之后这个client不再尝试批量请求
+// 有调用失败时返回所有错误的合并，各调用的错误见BatchCall.Err
+func (client *rpcClient) callBatch(ctx context.Context, calls []*BatchCall) error {
+	for start := 0; start < len(calls); start += MaxBatchSize {
+		chunk := calls[start:min(start+MaxBatchSize, len(calls))]
+		if client.batch_unsupported.Load() {
+			client.callSequential(ctx, chunk)
+			continue
+		}
+		err := client.callBatchChunk(ctx, chunk)
+		if errors.Is(err, errBatchRejected) {
+			client.logger.Info("json-rpc batch not supported, falling back to sequential calls", "url", client.json_rpc_url)
+			client.batch_unsupported.Store(true)
+			client.callSequential(ctx, chunk)
+			continue
+		}
+		if err != nil {
+			for _, call := range chunk {
+				call.Err = err
+			}
+		}
+	}
+
+	errs := []error{}
+	for _, call := range calls {
+		if call.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", call.Method, call.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (client *rpcClient) callSequential(ctx context.Context, calls []*BatchCall) {
+	for _, call := range calls {
+		call.Err = client.callJson(ctx, call.Method, call.Params, call.Result)
+	}
+}
+
+func (client *rpcClient) callBatchChunk(ctx context.Context, calls []*BatchCall) error {
+	requests := make([]jsonRPCRequest, len(calls))
+	retryable := true
+	for i, call := range calls {
+		requests[i] = jsonRPCRequest{
+			Jsonrpc: "2.0",
+			Id:      strconv.Itoa(i),
+			Method:  call.Method,
+			Params:  call.Params,
+		}
+		retryable = retryable && IsIdempotent(call.Method)
+	}
+	json_data, err := json.Marshal(requests)
+	if err != nil {
+		return err
+	}
+
+	var responses []batchResponse
+	err = client.callWithPolicy(ctx, "json", "batch", retryable, func(ctx context.Context) error {
+		body, err := client.post(ctx, client.json_rpc_url, "application/json", json_data)
+		if err != nil {
+			// 不支持批量请求的服务器可能直接返回HTTP 4xx
+			rpc_error := &RPCError{}
+			if errors.As(err, &rpc_error) && rpc_error.HTTPStatus >= 400 && rpc_error.HTTPStatus < 500 && rpc_error.HTTPStatus != 401 {
+				return errBatchRejected
+			}
+			return err
+		}
+		body = bytes.TrimSpace(body)
+		// 返回单个对象（通常是-32700/-32600错误）说明服务器不支持批量请求
+		if len(body) == 0 || body[0] != '[' {
+			return errBatchRejected
+		}
+		responses = nil
+		if err := json.Unmarshal(body, &responses); err != nil {
+			return fmt.Errorf("decode batch response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	by_id := map[string]*batchResponse{}
+	for i := range responses {
+		id := string(bytes.Trim(responses[i].Id, `"`))
+		by_id[id] = &responses[i]
+	}
+	for i, call := range calls {
+		response, ok := by_id[strconv.Itoa(i)]
+		switch {
+		case !ok:
+			call.Err = fmt.Errorf("no response for batch call %d", i)
+		case response.Error != nil:
+			call.Err = response.Error
+		default:
+			call.Err = decodeResult(call.Method, response.Result, call.Result)
+		}
+	}
+	return nil
+}
+
+// 批量调用monerod的JSON-RPC方法，见BatchCall
+func (proxy *DaemonRPCProxy) Batch(ctx context.Context, calls []*BatchCall) error {
+	return proxy.client.callBatch(ctx, calls)
+}
+
+// 批量调用monero-wallet-rpc的方法，见BatchCall
+func (proxy *WalletRPCProxy) Batch(ctx context.Context, calls []*BatchCall) error {
+	return proxy.client.callBatch(ctx, calls)
+}
+
+// 用批量请求获取多个高度的区块头，结果与heights一一对应
+func (proxy *DaemonRPCProxy) GetBlockHeadersByHeight(ctx context.Context, heights []uint64, fill_pow_hash bool) ([]BlockHeader, error) {
+	results := make([]BlockHeaderResult, len(heights))
+	calls := make([]*BatchCall, len(heights))
+	for i, height := range heights {
+		calls[i] = &BatchCall{
+			Method: "get_block_header_by_height",
+			Params: map[string]interface{}{
+				"height":        height,
+				"fill_pow_hash": fill_pow_hash,
+			},
+			Result: &results[i],
+		}
+	}
+	if err := proxy.Batch(ctx, calls); err != nil {
+		return nil, err
+	}
+	headers := make([]BlockHeader, len(heights))
+	for i := range results {
+		headers[i] = results[i].BlockHeader
+	}
+	return headers, nil
+}
@@ -0,0 +1,428 @@
+package rpcproxy
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+/*
+	==================
+	Binary RPC Methods
+	==================
+*/
+
+// .bin接口的请求和响应使用levin的portable storage格式，比json接口快得多，适合批量下载区块
+// hash在portable storage中是原始的32字节，这里统一转换成十六进制字符串，与json接口保持一致
+
+// get_blocks.bin的requested_info
+const (
+	BlocksOnly    = 0
+	BlocksAndPool = 1
+	PoolOnly      = 2
+)
+
+type GetBlocksBinRequest struct {
+	RequestedInfo uint8
+	BlockIds      []string // 短链历史，从高到低，最后一个必须是创世区块的hash
+	StartHeight   uint64
+	Prune         bool
+	NoMinerTx     bool
+	PoolInfoSince uint64
+}
+
+type TxBlobEntry struct {
+	Blob         []byte
+	PrunableHash string // 只有Pruned为true时才有
+}
+
+type BlockCompleteEntry struct {
+	Pruned      bool
+	Block       []byte
+	BlockWeight uint64
+	Txs         []TxBlobEntry
+}
+
+type TxOutputIndices struct {
+	Indices []uint64
+}
+
+// 一个区块中每个交易（包括矿工交易）的输出的全局索引
+type BlockOutputIndices struct {
+	Indices []TxOutputIndices
+}
+
+type PoolTxInfo struct {
+	TxHash          string
+	TxBlob          []byte
+	DoubleSpendSeen bool
+}
+
+type GetBlocksBinResult struct {
+	ResponseBase
+	Blocks                  []BlockCompleteEntry
+	StartHeight             uint64
+	CurrentHeight           uint64
+	OutputIndices           []BlockOutputIndices
+	DaemonTime              uint64
+	PoolInfoExtent          uint8
+	AddedPoolTxs            []PoolTxInfo
+	RemainingAddedPoolTxids []string
+	RemovedPoolTxids        []string
+}
+
+// get_blocks.bin
+func (proxy *DaemonRPCProxy) GetBlocksBin(ctx context.Context, request GetBlocksBinRequest) (GetBlocksBinResult, error) {
+	result := GetBlocksBinResult{}
+	block_ids, err := joinHashes(request.BlockIds)
+	if err != nil {
+		return result, err
+	}
+	params := map[string]interface{}{
+		"requested_info":  request.RequestedInfo,
+		"block_ids":       block_ids,
+		"start_height":    request.StartHeight,
+		"prune":           request.Prune,
+		"no_miner_tx":     request.NoMinerTx,
+		"pool_info_since": request.PoolInfoSince,
+	}
+	response, err := proxy.client.callBinary(ctx, "get_blocks.bin", params)
+	if response == nil {
+		return result, err
+	}
+	result.ResponseBase = sectionResponseBase(response)
+	result.Blocks = parseBlockCompleteEntries(sectionArray(response, "blocks"))
+	result.StartHeight = sectionUint64(response, "start_height")
+	result.CurrentHeight = sectionUint64(response, "current_height")
+	result.DaemonTime = sectionUint64(response, "daemon_time")
+	result.PoolInfoExtent = uint8(sectionUint64(response, "pool_info_extent"))
+	for _, item := range sectionArray(response, "output_indices") {
+		block_indices := BlockOutputIndices{}
+		for _, tx_item := range sectionArray(toSection(item), "indices") {
+			tx_indices := TxOutputIndices{}
+			for _, index := range sectionArray(toSection(tx_item), "indices") {
+				tx_indices.Indices = append(tx_indices.Indices, toUint64(index))
+			}
+			block_indices.Indices = append(block_indices.Indices, tx_indices)
+		}
+		result.OutputIndices = append(result.OutputIndices, block_indices)
+	}
+	for _, item := range sectionArray(response, "added_pool_txs") {
+		pool_tx := toSection(item)
+		result.AddedPoolTxs = append(result.AddedPoolTxs, PoolTxInfo{
+			TxHash:          hex.EncodeToString(sectionBytes(pool_tx, "tx_hash")),
+			TxBlob:          sectionBytes(pool_tx, "tx_blob"),
+			DoubleSpendSeen: sectionBool(pool_tx, "double_spend_seen"),
+		})
+	}
+	result.RemainingAddedPoolTxids = splitHashes(sectionBytes(response, "remaining_added_pool_txids"))
+	result.RemovedPoolTxids = splitHashes(sectionBytes(response, "removed_pool_txids"))
+	return result, err
+}
+
+type GetBlocksByHeightBinResult struct {
+	ResponseBase
+	Blocks []BlockCompleteEntry
+}
+
+// get_blocks_by_height.bin
+func (proxy *DaemonRPCProxy) GetBlocksByHeightBin(ctx context.Context, heights []uint64) (GetBlocksByHeightBinResult, error) {
+	result := GetBlocksByHeightBinResult{}
+	params := map[string]interface{}{
+		"heights": heights,
+	}
+	response, err := proxy.client.callBinary(ctx, "get_blocks_by_height.bin", params)
+	if response == nil {
+		return result, err
+	}
+	result.ResponseBase = sectionResponseBase(response)
+	result.Blocks = parseBlockCompleteEntries(sectionArray(response, "blocks"))
+	return result, err
+}
+
+type GetHashesBinResult struct {
+	ResponseBase
+	MBlockIds     []string
+	StartHeight   uint64
+	CurrentHeight uint64
+}
+
+// get_hashes.bin：block_ids为短链历史，返回从分叉点开始的区块hash
+func (proxy *DaemonRPCProxy) GetHashesBin(ctx context.Context, block_ids []string, start_height uint64) (GetHashesBinResult, error) {
+	result := GetHashesBinResult{}
+	block_ids_blob, err := joinHashes(block_ids)
+	if err != nil {
+		return result, err
+	}
+	params := map[string]interface{}{
+		"block_ids":    block_ids_blob,
+		"start_height": start_height,
+	}
+	response, err := proxy.client.callBinary(ctx, "get_hashes.bin", params)
+	if response == nil {
+		return result, err
+	}
+	result.ResponseBase = sectionResponseBase(response)
+	result.MBlockIds = splitHashes(sectionBytes(response, "m_block_ids"))
+	result.StartHeight = sectionUint64(response, "start_height")
+	result.CurrentHeight = sectionUint64(response, "current_height")
+	return result, err
+}
+
+type GetOIndexesBinResult struct {
+	ResponseBase
+	OIndexes []uint64
+}
+
+// get_o_indexes.bin：交易的每个输出的全局索引
+func (proxy *DaemonRPCProxy) GetOIndexesBin(ctx context.Context, txid string) (GetOIndexesBinResult, error) {
+	result := GetOIndexesBinResult{}
+	txid_blob, err := joinHashes([]string{txid})
+	if err != nil {
+		return result, err
+	}
+	params := map[string]interface{}{
+		"txid": txid_blob,
+	}
+	response, err := proxy.client.callBinary(ctx, "get_o_indexes.bin", params)
+	if response == nil {
+		return result, err
+	}
+	result.ResponseBase = sectionResponseBase(response)
+	for _, index := range sectionArray(response, "o_indexes") {
+		result.OIndexes = append(result.OIndexes, toUint64(index))
+	}
+	return result, err
+}
+
+type GetOutputsOut struct {
+	Amount uint64
+	Index  uint64
+}
+
+type OutKey struct {
+	Key      string
+	Mask     string
+	Unlocked bool
+	Height   uint64
+	Txid     string
+}
+
+type GetOutsBinResult struct {
+	ResponseBase
+	Outs []OutKey
+}
+
+// get_outs.bin
+func (proxy *DaemonRPCProxy) GetOutsBin(ctx context.Context, outputs []GetOutputsOut, get_txid bool) (GetOutsBinResult, error) {
+	result := GetOutsBinResult{}
+	outputs_param := make([]map[string]interface{}, len(outputs))
+	for i, output := range outputs {
+		outputs_param[i] = map[string]interface{}{
+			"amount": output.Amount,
+			"index":  output.Index,
+		}
+	}
+	params := map[string]interface{}{
+		"outputs":  outputs_param,
+		"get_txid": get_txid,
+	}
+	response, err := proxy.client.callBinary(ctx, "get_outs.bin", params)
+	if response == nil {
+		return result, err
+	}
+	result.ResponseBase = sectionResponseBase(response)
+	for _, item := range sectionArray(response, "outs") {
+		out := toSection(item)
+		result.Outs = append(result.Outs, OutKey{
+			Key:      hex.EncodeToString(sectionBytes(out, "key")),
+			Mask:     hex.EncodeToString(sectionBytes(out, "mask")),
+			Unlocked: sectionBool(out, "unlocked"),
+			Height:   sectionUint64(out, "height"),
+			Txid:     hex.EncodeToString(sectionBytes(out, "txid")),
+		})
+	}
+	return result, err
+}
+
+// get_transaction_pool_hashes.bin
+func (proxy *DaemonRPCProxy) GetTransactionPoolHashesBin(ctx context.Context) (GetTransactionPoolHashesResult, error) {
+	result := GetTransactionPoolHashesResult{}
+	response, err := proxy.client.callBinary(ctx, "get_transaction_pool_hashes.bin", map[string]interface{}{})
+	if response == nil {
+		return result, err
+	}
+	result.ResponseBase = sectionResponseBase(response)
+	result.TxHashes = splitHashes(sectionBytes(response, "tx_hashes"))
+	return result, err
+}
+
+// get_output_distribution.bin：compress为true时monerod用varint压缩distribution，体积更小
+func (proxy *DaemonRPCProxy) GetOutputDistributionBin(ctx context.Context, request GetOutputDistributionRequest, compress bool) (GetOutputDistributionResult, error) {
+	result := GetOutputDistributionResult{}
+	params := map[string]interface{}{
+		"amounts":     request.Amounts,
+		"cumulative":  request.Cumulative,
+		"from_height": request.FromHeight,
+		"to_height":   request.ToHeight,
+		"binary":      true,
+		"compress":    compress,
+	}
+	response, err := proxy.client.callBinary(ctx, "get_output_distribution.bin", params)
+	if response == nil {
+		return result, err
+	}
+	result.ResponseBase = sectionResponseBase(response)
+	for _, item := range sectionArray(response, "distributions") {
+		distribution := toSection(item)
+		entry := OutputDistribution{
+			Amount:      sectionUint64(distribution, "amount"),
+			Base:        sectionUint64(distribution, "base"),
+			StartHeight: sectionUint64(distribution, "start_height"),
+		}
+		var decode_err error
+		if compress {
+			entry.Distribution, decode_err = decodeVarintArray(sectionBytes(distribution, "compressed_data"))
+		} else {
+			entry.Distribution, decode_err = decodeUint64Array(sectionBytes(distribution, "distribution"))
+		}
+		if decode_err != nil {
+			return result, decode_err
+		}
+		result.Distributions = append(result.Distributions, entry)
+	}
+	return result, err
+}
+
+/*
+	======================
+	Portable Storage Tools
+	======================
+*/
+
+func parseBlockCompleteEntries(items []interface{}) []BlockCompleteEntry {
+	blocks := make([]BlockCompleteEntry, 0, len(items))
+	for _, item := range items {
+		section := toSection(item)
+		block := BlockCompleteEntry{
+			Pruned:      sectionBool(section, "pruned"),
+			Block:       sectionBytes(section, "block"),
+			BlockWeight: sectionUint64(section, "block_weight"),
+		}
+		// 未修剪的区块中txs是blob数组，修剪后是tx_blob_entry数组
+		for _, tx := range sectionArray(section, "txs") {
+			switch tx := tx.(type) {
+			case []byte:
+				block.Txs = append(block.Txs, TxBlobEntry{Blob: tx})
+			case map[string]interface{}:
+				block.Txs = append(block.Txs, TxBlobEntry{
+					Blob:         sectionBytes(tx, "blob"),
+					PrunableHash: hex.EncodeToString(sectionBytes(tx, "prunable_hash")),
+				})
+			}
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func sectionResponseBase(section map[string]interface{}) ResponseBase {
+	return ResponseBase{
+		Status:    string(sectionBytes(section, "status")),
+		Untrusted: sectionBool(section, "untrusted"),
+	}
+}
+
+func toSection(value interface{}) map[string]interface{} {
+	section, _ := value.(map[string]interface{})
+	return section
+}
+
+// portable storage中的整数可能以任意宽度存储
+func toUint64(value interface{}) uint64 {
+	switch value := value.(type) {
+	case uint64:
+		return value
+	case uint32:
+		return uint64(value)
+	case uint16:
+		return uint64(value)
+	case uint8:
+		return uint64(value)
+	case int64:
+		return uint64(value)
+	case int32:
+		return uint64(value)
+	case int16:
+		return uint64(value)
+	case int8:
+		return uint64(value)
+	}
+	return 0
+}
+
+func sectionUint64(section map[string]interface{}, key string) uint64 {
+	return toUint64(section[key])
+}
+
+func sectionBool(section map[string]interface{}, key string) bool {
+	value, _ := section[key].(bool)
+	return value
+}
+
+func sectionBytes(section map[string]interface{}, key string) []byte {
+	value, _ := section[key].([]byte)
+	return value
+}
+
+func sectionArray(section map[string]interface{}, key string) []interface{} {
+	value, _ := section[key].([]interface{})
+	return value
+}
+
+// 十六进制hash列表拼接成portable storage中的blob
+func joinHashes(hashes []string) (string, error) {
+	blob := make([]byte, 0, len(hashes)*32)
+	for _, hash := range hashes {
+		data, err := hex.DecodeString(hash)
+		if err != nil || len(data) != 32 {
+			return "", fmt.Errorf("invalid hash %q", hash)
+		}
+		blob = append(blob, data...)
+	}
+	return string(blob), nil
+}
+
+// portable storage中拼接在一起的hash拆分成十六进制hash列表
+func splitHashes(blob []byte) []string {
+	hashes := make([]string, 0, len(blob)/32)
+	for i := 0; i+32 <= len(blob); i += 32 {
+		hashes = append(hashes, hex.EncodeToString(blob[i:i+32]))
+	}
+	return hashes
+}
+
+func decodeUint64Array(blob []byte) ([]uint64, error) {
+	if len(blob)%8 != 0 {
+		return nil, fmt.Errorf("invalid uint64 array length %d", len(blob))
+	}
+	values := make([]uint64, len(blob)/8)
+	for i := range values {
+		values[i] = binary.LittleEndian.Uint64(blob[i*8:])
+	}
+	return values, nil
+}
+
+func decodeVarintArray(blob []byte) ([]uint64, error) {
+	values := []uint64{}
+	for len(blob) > 0 {
+		value, n := binary.Uvarint(blob)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid varint in compressed distribution")
+		}
+		values = append(values, value)
+		blob = blob[n:]
+	}
+	return values, nil
+}
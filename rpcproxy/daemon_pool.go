@@ -0,0 +1,329 @@
+package rpcproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+=====================================================
+多个monerod组成的池：健康检查、故障转移、负载均衡和多数读
+=====================================================
+*/
+
+const (
+	DefaultHealthCheckInterval = 30 * time.Second
+	DefaultHealthCheckTimeout  = 5 * time.Second
+	DefaultMaxHeightLag        = 2
+)
+
+var ErrNoDaemon = errors.New("daemon pool: no daemon available")
+
+type DaemonPoolConfig struct {
+	HealthCheckInterval time.Duration // 后台健康检查的间隔
+	HealthCheckTimeout  time.Duration // 单次get_info的超时
+	RequestTimeout      time.Duration // Do中每次尝试的超时，为0时只受调用者的ctx限制
+	// 与最高高度相差不超过MaxHeightLag的同步节点视为同样好，请求在它们之间轮流分配
+	MaxHeightLag uint64
+	Logger       *slog.Logger
+}
+
+// 池中一个daemon的状态
+type DaemonStatus struct {
+	Address      string
+	Checked      bool // 是否已完成过健康检查
+	Healthy      bool
+	Synchronized bool
+	Height       uint64
+	Latency      time.Duration
+	LastError    error
+	LastCheck    time.Time
+}
+
+type poolMember struct {
+	proxy  *DaemonRPCProxy
+	status DaemonStatus
+}
+
+type DaemonPool struct {
+	members []*poolMember
+	lock    sync.RWMutex
+	config  DaemonPoolConfig
+	logger  *slog.Logger
+	next    atomic.Uint64 // 轮询计数
+
+	stop      chan struct{}
+	stop_once sync.Once
+}
+
+func CreateDaemonPool(proxies []*DaemonRPCProxy, config DaemonPoolConfig) *DaemonPool {
+	if config.HealthCheckInterval == 0 {
+		config.HealthCheckInterval = DefaultHealthCheckInterval
+	}
+	if config.HealthCheckTimeout == 0 {
+		config.HealthCheckTimeout = DefaultHealthCheckTimeout
+	}
+	if config.MaxHeightLag == 0 {
+		config.MaxHeightLag = DefaultMaxHeightLag
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	pool := DaemonPool{
+		config: config,
+		logger: logger.With("component", "rpcproxy", "proxy", "daemon_pool"),
+		stop:   make(chan struct{}),
+	}
+	for _, proxy := range proxies {
+		member := poolMember{proxy: proxy}
+		member.status.Address = proxy.client.other_rpc_url
+		pool.members = append(pool.members, &member)
+	}
+	return &pool
+}
+
+// 启动后台健康检查，立即检查一次
+func (pool *DaemonPool) Start() {
+	go func() {
+		ticker := time.NewTicker(pool.config.HealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				select {
+				case <-pool.stop:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			pool.CheckHealth(ctx)
+			cancel()
+			select {
+			case <-pool.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// 停止后台健康检查
+func (pool *DaemonPool) Stop() {
+	pool.stop_once.Do(func() {
+		close(pool.stop)
+	})
+}
+
+// 并发地对所有daemon调用get_info，更新状态
+func (pool *DaemonPool) CheckHealth(ctx context.Context) {
+	wait_group := sync.WaitGroup{}
+	for _, member := range pool.members {
+		wait_group.Add(1)
+		go func(member *poolMember) {
+			defer wait_group.Done()
+			pool.checkMember(ctx, member)
+		}(member)
+	}
+	wait_group.Wait()
+}
+
+func (pool *DaemonPool) checkMember(ctx context.Context, member *poolMember) {
+	ctx, cancel := context.WithTimeout(ctx, pool.config.HealthCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	info, err := member.proxy.GetInfo(ctx)
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	was_healthy := member.status.Healthy || !member.status.Checked
+	member.status.Checked = true
+	member.status.LastCheck = time.Now()
+	member.status.Latency = time.Since(start)
+	member.status.LastError = err
+	if err == nil && info.Offline {
+		member.status.LastError = errors.New("daemon is offline")
+	}
+	member.status.Healthy = member.status.LastError == nil
+	if member.status.Healthy {
+		member.status.Synchronized = info.Synchronized && !info.BusySyncing
+		member.status.Height = info.Height
+	}
+	if was_healthy && !member.status.Healthy {
+		pool.logger.Warn("daemon unhealthy", "daemon", member.status.Address, "error", member.status.LastError)
+	} else if !was_healthy && member.status.Healthy {
+		pool.logger.Info("daemon recovered", "daemon", member.status.Address, "height", member.status.Height)
+	}
+}
+
+// 标记请求失败的daemon，直到下一次健康检查成功前不再优先使用
+func (pool *DaemonPool) markFailed(member *poolMember, err error) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	if member.status.Healthy || !member.status.Checked {
+		pool.logger.Warn("daemon request failed, failing over", "daemon", member.status.Address, "error", err)
+	}
+	member.status.Checked = true
+	member.status.Healthy = false
+	member.status.LastError = err
+}
+
+// 所有daemon的当前状态
+func (pool *DaemonPool) Status() []DaemonStatus {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+	statuses := make([]DaemonStatus, len(pool.members))
+	for i, member := range pool.members {
+		statuses[i] = member.status
+	}
+	return statuses
+}
+
+// 按优先级排列：健康且同步 > 健康未同步 > 未检查 > 不健康；同级按高度降序、延迟升序
+// 高度接近最高的同步节点之间轮流排在最前面
+func (pool *DaemonPool) candidates() []*poolMember {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+	rank := func(status DaemonStatus) int {
+		switch {
+		case status.Healthy && status.Synchronized:
+			return 0
+		case status.Healthy:
+			return 1
+		case !status.Checked:
+			return 2
+		default:
+			return 3
+		}
+	}
+	members := make([]*poolMember, len(pool.members))
+	copy(members, pool.members)
+	sort.SliceStable(members, func(i, j int) bool {
+		a, b := members[i].status, members[j].status
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		if a.Height != b.Height {
+			return a.Height > b.Height
+		}
+		return a.Latency < b.Latency
+	})
+
+	best := 0
+	for best < len(members) && rank(members[best].status) == 0 &&
+		members[best].status.Height+pool.config.MaxHeightLag >= members[0].status.Height {
+		best++
+	}
+	if best > 1 {
+		offset := int(pool.next.Add(1) % uint64(best))
+		rotated := append(append([]*poolMember{}, members[offset:best]...), members[:offset]...)
+		copy(members, rotated)
+	}
+	return members
+}
+
+// 是否应该换一个daemon重试：网络错误、超时、HTTP错误和BUSY等status需要换，JSON-RPC的error对象是请求本身的问题，不换
+func shouldFailover(err error) bool {
+	rpc_error := &RPCError{}
+	if errors.As(err, &rpc_error) {
+		return rpc_error.HTTPStatus != 0 || rpc_error.Status != ""
+	}
+	return true
+}
+
+// 按优先级依次在daemon上执行call，直到成功或遇到不需要换daemon的错误
+func (pool *DaemonPool) Do(ctx context.Context, call func(ctx context.Context, proxy *DaemonRPCProxy) error) error {
+	errs := []error{}
+	for _, member := range pool.candidates() {
+		attempt_ctx, cancel := ctx, context.CancelFunc(func() {})
+		if pool.config.RequestTimeout > 0 {
+			attempt_ctx, cancel = context.WithTimeout(ctx, pool.config.RequestTimeout)
+		}
+		err := call(attempt_ctx, member.proxy)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if !shouldFailover(err) {
+			return err
+		}
+		pool.markFailed(member, err)
+		errs = append(errs, fmt.Errorf("%s: %w", member.status.Address, err))
+	}
+	if len(errs) == 0 {
+		return ErrNoDaemon
+	}
+	return fmt.Errorf("%w: %w", ErrNoDaemon, errors.Join(errs...))
+}
+
+// 多数读中一个daemon的结果
+type QuorumResponse[T comparable] struct {
+	Daemon string
+	Value  T
+	Err    error
+}
+
+type QuorumResult[T comparable] struct {
+	Value        T   // 多数daemon返回的值
+	Agreed       int // 返回Value的daemon数
+	Disagreement bool
+	Responses    []QuorumResponse[T]
+}
+
+// 在优先级最高的n个daemon上并发执行read并比较结果（n<=0时使用所有daemon）
+// 返回出现次数最多的值；成功的daemon之间结果不一致时Disagreement为true
+func QuorumRead[T comparable](ctx context.Context, pool *DaemonPool, n int, read func(ctx context.Context, proxy *DaemonRPCProxy) (T, error)) (QuorumResult[T], error) {
+	members := pool.candidates()
+	if n > 0 && n < len(members) {
+		members = members[:n]
+	}
+	result := QuorumResult[T]{Responses: make([]QuorumResponse[T], len(members))}
+	wait_group := sync.WaitGroup{}
+	for i, member := range members {
+		wait_group.Add(1)
+		go func(i int, member *poolMember) {
+			defer wait_group.Done()
+			value, err := read(ctx, member.proxy)
+			result.Responses[i] = QuorumResponse[T]{Daemon: member.status.Address, Value: value, Err: err}
+		}(i, member)
+	}
+	wait_group.Wait()
+
+	counts := map[T]int{}
+	errs := []error{}
+	for _, response := range result.Responses {
+		if response.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", response.Daemon, response.Err))
+			continue
+		}
+		counts[response.Value]++
+		if counts[response.Value] > result.Agreed {
+			result.Value = response.Value
+			result.Agreed = counts[response.Value]
+		}
+	}
+	if len(counts) == 0 {
+		return result, fmt.Errorf("%w: %w", ErrNoDaemon, errors.Join(errs...))
+	}
+	if len(counts) > 1 {
+		result.Disagreement = true
+		pool.logger.Warn("daemons disagree", "responses", len(result.Responses), "agreed", result.Agreed)
+	}
+	return result, nil
+}
+
+// 比较n个daemon在height处的区块哈希
+func (pool *DaemonPool) BlockHashQuorum(ctx context.Context, height uint64, n int) (QuorumResult[string], error) {
+	return QuorumRead(ctx, pool, n, func(ctx context.Context, proxy *DaemonRPCProxy) (string, error) {
+		return proxy.OnGetBlockHash(ctx, height)
+	})
+}
@@ -1,29 +1,30 @@
 package rpcproxy
 
 import (
-	"log"
-	"strconv"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
 )
 
 type DaemonRPCProxy struct {
-	rpc_ip        string
-	rpc_port      int16
-	rpc_user      string
-	rpc_password  string
-	json_rpc_url  string
-	other_rpc_url string
+	rpc_ip   string
+	rpc_port uint16
+	client   *rpcClient
 }
 
 // 创建DaemonRPC代理，所有的和节点有关的RPC调用都通过它来完成
-func CreateDaemonRPCProxy(rpc_ip string, rpc_port int16, rpc_user string, rpc_password string) DaemonRPCProxy {
+func CreateDaemonRPCProxy(rpc_ip string, rpc_port uint16, rpc_user string, rpc_password string) DaemonRPCProxy {
+	return CreateDaemonRPCProxyWithConfig(rpc_ip, rpc_port, rpc_user, rpc_password, ProxyConfig{})
+}
+
+func CreateDaemonRPCProxyWithConfig(rpc_ip string, rpc_port uint16, rpc_user string, rpc_password string, config ProxyConfig) DaemonRPCProxy {
 	proxy := DaemonRPCProxy{
-		rpc_ip:       rpc_ip,
-		rpc_port:     rpc_port,
-		rpc_user:     rpc_user,
-		rpc_password: rpc_password,
+		rpc_ip:   rpc_ip,
+		rpc_port: rpc_port,
+		client:   newRPCClient(rpc_ip, rpc_port, rpc_user, rpc_password, config, config.logger().With("proxy", "daemon")),
 	}
-	proxy.json_rpc_url = "http://" + rpc_ip + ":" + strconv.Itoa(int(rpc_port)) + "/json_rpc"
-	proxy.other_rpc_url = "http://" + rpc_ip + ":" + strconv.Itoa(int(rpc_port))
+	proxy.client.busy_code = daemonBusyCode
 	return proxy
 }
 
@@ -33,17 +34,522 @@ func CreateDaemonRPCProxy(rpc_ip string, rpc_port int16, rpc_user string, rpc_pa
 	================
 */
 
-// get_block_height
-func (proxy *DaemonRPCProxy) GetBlockCount() int {
-	rpc_method := "get_block_count"
+type GetBlockCountResult struct {
+	ResponseBase
+	Count uint64 `json:"count"`
+}
+
+// get_block_count
+func (proxy *DaemonRPCProxy) GetBlockCount(ctx context.Context) (GetBlockCountResult, error) {
+	result := GetBlockCountResult{}
+	err := proxy.client.callJson(ctx, "get_block_count", nil, &result)
+	return result, err
+}
+
+type BlockHeader struct {
+	BlockSize                 uint64 `json:"block_size"`
+	BlockWeight               uint64 `json:"block_weight"`
+	CumulativeDifficulty      uint64 `json:"cumulative_difficulty"`
+	CumulativeDifficultyTop64 uint64 `json:"cumulative_difficulty_top64"`
+	Depth                     uint64 `json:"depth"`
+	Difficulty                uint64 `json:"difficulty"`
+	DifficultyTop64           uint64 `json:"difficulty_top64"`
+	Hash                      string `json:"hash"`
+	Height                    uint64 `json:"height"`
+	LongTermWeight            uint64 `json:"long_term_weight"`
+	MajorVersion              uint64 `json:"major_version"`
+	MinerTxHash               string `json:"miner_tx_hash"`
+	MinorVersion              uint64 `json:"minor_version"`
+	Nonce                     uint64 `json:"nonce"`
+	NumTxes                   uint64 `json:"num_txes"`
+	OrphanStatus              bool   `json:"orphan_status"`
+	PowHash                   string `json:"pow_hash"`
+	PrevHash                  string `json:"prev_hash"`
+	Reward                    uint64 `json:"reward"`
+	Timestamp                 uint64 `json:"timestamp"`
+	WideCumulativeDifficulty  string `json:"wide_cumulative_difficulty"`
+	WideDifficulty            string `json:"wide_difficulty"`
+}
+
+type GetInfoResult struct {
+	ResponseBase
+	AdjustedTime              uint64 `json:"adjusted_time"`
+	AltBlocksCount            uint64 `json:"alt_blocks_count"`
+	BlockSizeLimit            uint64 `json:"block_size_limit"`
+	BlockSizeMedian           uint64 `json:"block_size_median"`
+	BlockWeightLimit          uint64 `json:"block_weight_limit"`
+	BlockWeightMedian         uint64 `json:"block_weight_median"`
+	BootstrapDaemonAddress    string `json:"bootstrap_daemon_address"`
+	BusySyncing               bool   `json:"busy_syncing"`
+	Credits                   uint64 `json:"credits"`
+	CumulativeDifficulty      uint64 `json:"cumulative_difficulty"`
+	CumulativeDifficultyTop64 uint64 `json:"cumulative_difficulty_top64"`
+	DatabaseSize              uint64 `json:"database_size"`
+	Difficulty                uint64 `json:"difficulty"`
+	DifficultyTop64           uint64 `json:"difficulty_top64"`
+	FreeSpace                 uint64 `json:"free_space"`
+	GreyPeerlistSize          uint64 `json:"grey_peerlist_size"`
+	Height                    uint64 `json:"height"`
+	HeightWithoutBootstrap    uint64 `json:"height_without_bootstrap"`
+	IncomingConnectionsCount  uint64 `json:"incoming_connections_count"`
+	Mainnet                   bool   `json:"mainnet"`
+	Nettype                   string `json:"nettype"`
+	Offline                   bool   `json:"offline"`
+	OutgoingConnectionsCount  uint64 `json:"outgoing_connections_count"`
+	Restricted                bool   `json:"restricted"`
+	RPCConnectionsCount       uint64 `json:"rpc_connections_count"`
+	Stagenet                  bool   `json:"stagenet"`
+	StartTime                 uint64 `json:"start_time"`
+	Synchronized              bool   `json:"synchronized"`
+	Target                    uint64 `json:"target"`
+	TargetHeight              uint64 `json:"target_height"`
+	Testnet                   bool   `json:"testnet"`
+	TopBlockHash              string `json:"top_block_hash"`
+	TopHash                   string `json:"top_hash"`
+	TxCount                   uint64 `json:"tx_count"`
+	TxPoolSize                uint64 `json:"tx_pool_size"`
+	UpdateAvailable           bool   `json:"update_available"`
+	Version                   string `json:"version"`
+	WasBootstrapEverUsed      bool   `json:"was_bootstrap_ever_used"`
+	WhitePeerlistSize         uint64 `json:"white_peerlist_size"`
+	WideCumulativeDifficulty  string `json:"wide_cumulative_difficulty"`
+	WideDifficulty            string `json:"wide_difficulty"`
+}
+
+// get_info
+func (proxy *DaemonRPCProxy) GetInfo(ctx context.Context) (GetInfoResult, error) {
+	result := GetInfoResult{}
+	err := proxy.client.callJson(ctx, "get_info", nil, &result)
+	return result, err
+}
+
+type BlockHeaderResult struct {
+	ResponseBase
+	BlockHeader BlockHeader `json:"block_header"`
+}
+
+// get_last_block_header
+func (proxy *DaemonRPCProxy) GetLastBlockHeader(ctx context.Context, fill_pow_hash bool) (BlockHeaderResult, error) {
+	params := map[string]interface{}{
+		"fill_pow_hash": fill_pow_hash,
+	}
+	result := BlockHeaderResult{}
+	err := proxy.client.callJson(ctx, "get_last_block_header", params, &result)
+	return result, err
+}
+
+type GetBlockHeaderByHashRequest struct {
+	Hash        string   `json:"hash,omitempty"`
+	Hashes      []string `json:"hashes,omitempty"`
+	FillPowHash bool     `json:"fill_pow_hash,omitempty"`
+}
+
+type GetBlockHeaderByHashResult struct {
+	ResponseBase
+	BlockHeader  BlockHeader   `json:"block_header"`
+	BlockHeaders []BlockHeader `json:"block_headers"`
+}
+
+// get_block_header_by_hash：Hash和Hashes可以同时使用，结果分别在BlockHeader和BlockHeaders中
+func (proxy *DaemonRPCProxy) GetBlockHeaderByHash(ctx context.Context, request GetBlockHeaderByHashRequest) (GetBlockHeaderByHashResult, error) {
+	result := GetBlockHeaderByHashResult{}
+	err := proxy.client.callJson(ctx, "get_block_header_by_hash", request, &result)
+	return result, err
+}
+
+// get_block_header_by_height
+func (proxy *DaemonRPCProxy) GetBlockHeaderByHeight(ctx context.Context, height uint64, fill_pow_hash bool) (BlockHeaderResult, error) {
+	params := map[string]interface{}{
+		"height":        height,
+		"fill_pow_hash": fill_pow_hash,
+	}
+	result := BlockHeaderResult{}
+	err := proxy.client.callJson(ctx, "get_block_header_by_height", params, &result)
+	return result, err
+}
+
+type GetBlockHeadersRangeResult struct {
+	ResponseBase
+	Headers []BlockHeader `json:"headers"`
+}
+
+// get_block_headers_range：包含start_height和end_height
+func (proxy *DaemonRPCProxy) GetBlockHeadersRange(ctx context.Context, start_height uint64, end_height uint64, fill_pow_hash bool) (GetBlockHeadersRangeResult, error) {
+	params := map[string]interface{}{
+		"start_height":  start_height,
+		"end_height":    end_height,
+		"fill_pow_hash": fill_pow_hash,
+	}
+	result := GetBlockHeadersRangeResult{}
+	err := proxy.client.callJson(ctx, "get_block_headers_range", params, &result)
+	return result, err
+}
+
+// Hash不为空时按hash查询，否则按Height查询
+type GetBlockRequest struct {
+	Height      uint64 `json:"height"`
+	Hash        string `json:"hash,omitempty"`
+	FillPowHash bool   `json:"fill_pow_hash,omitempty"`
+}
+
+type GetBlockResult struct {
+	ResponseBase
+	Blob        string      `json:"blob"`
+	BlockHeader BlockHeader `json:"block_header"`
+	Json        string      `json:"json"` // 区块的json描述，是一个需要再次解析的字符串
+	MinerTxHash string      `json:"miner_tx_hash"`
+	TxHashes    []string    `json:"tx_hashes"`
+}
+
+// get_block
+func (proxy *DaemonRPCProxy) GetBlock(ctx context.Context, request GetBlockRequest) (GetBlockResult, error) {
+	result := GetBlockResult{}
+	err := proxy.client.callJson(ctx, "get_block", request, &result)
+	return result, err
+}
+
+type Connection struct {
+	Address           string `json:"address"`
+	AddressType       uint8  `json:"address_type"`
+	AvgDownload       uint64 `json:"avg_download"`
+	AvgUpload         uint64 `json:"avg_upload"`
+	ConnectionId      string `json:"connection_id"`
+	CurrentDownload   uint64 `json:"current_download"`
+	CurrentUpload     uint64 `json:"current_upload"`
+	Height            uint64 `json:"height"`
+	Host              string `json:"host"`
+	Incoming          bool   `json:"incoming"`
+	Ip                string `json:"ip"`
+	LiveTime          uint64 `json:"live_time"`
+	LocalIp           bool   `json:"local_ip"`
+	Localhost         bool   `json:"localhost"`
+	PeerId            string `json:"peer_id"`
+	Port              string `json:"port"`
+	PruningSeed       uint32 `json:"pruning_seed"`
+	RecvCount         uint64 `json:"recv_count"`
+	RecvIdleTime      uint64 `json:"recv_idle_time"`
+	RPCCreditsPerHash uint32 `json:"rpc_credits_per_hash"`
+	RPCPort           uint16 `json:"rpc_port"`
+	SendCount         uint64 `json:"send_count"`
+	SendIdleTime      uint64 `json:"send_idle_time"`
+	State             string `json:"state"`
+	SupportFlags      uint32 `json:"support_flags"`
+}
+
+type GetConnectionsResult struct {
+	ResponseBase
+	Connections []Connection `json:"connections"`
+}
+
+// get_connections
+func (proxy *DaemonRPCProxy) GetConnections(ctx context.Context) (GetConnectionsResult, error) {
+	result := GetConnectionsResult{}
+	err := proxy.client.callJson(ctx, "get_connections", nil, &result)
+	return result, err
+}
+
+type HardForkInfoResult struct {
+	ResponseBase
+	EarliestHeight uint64 `json:"earliest_height"`
+	Enabled        bool   `json:"enabled"`
+	State          uint32 `json:"state"`
+	Threshold      uint32 `json:"threshold"`
+	Version        uint8  `json:"version"`
+	Votes          uint32 `json:"votes"`
+	Voting         uint8  `json:"voting"`
+	Window         uint32 `json:"window"`
+}
+
+// hard_fork_info
+func (proxy *DaemonRPCProxy) HardForkInfo(ctx context.Context) (HardForkInfoResult, error) {
+	result := HardForkInfoResult{}
+	err := proxy.client.callJson(ctx, "hard_fork_info", nil, &result)
+	return result, err
+}
+
+type GetFeeEstimateResult struct {
+	ResponseBase
+	Fee              uint64   `json:"fee"`
+	Fees             []uint64 `json:"fees"`
+	QuantizationMask uint64   `json:"quantization_mask"`
+}
+
+// get_fee_estimate：grace_blocks为0时使用monerod的默认值
+func (proxy *DaemonRPCProxy) GetFeeEstimate(ctx context.Context, grace_blocks uint64) (GetFeeEstimateResult, error) {
 	params := map[string]interface{}{}
-	response := JsonMethodRequest(proxy.json_rpc_url, rpc_method, params, proxy.rpc_user, proxy.rpc_password)
-	if result, ok := response["result"].(map[string]interface{}); ok {
-		block_count := int(result["count"].(float64))
-		return block_count
+	if grace_blocks > 0 {
+		params["grace_blocks"] = grace_blocks
 	}
-	log.Println("Error occur when get block count!")
-	return -1
+	result := GetFeeEstimateResult{}
+	err := proxy.client.callJson(ctx, "get_fee_estimate", params, &result)
+	return result, err
+}
+
+type HardFork struct {
+	Height    uint64 `json:"height"`
+	HfVersion uint8  `json:"hf_version"`
+}
+
+type GetVersionResult struct {
+	ResponseBase
+	Version       uint32     `json:"version"`
+	Release       bool       `json:"release"`
+	CurrentHeight uint64     `json:"current_height"`
+	HardForks     []HardFork `json:"hard_forks"`
+}
+
+// get_version：Version的高16位是主版本号，低16位是次版本号
+func (proxy *DaemonRPCProxy) GetVersion(ctx context.Context) (GetVersionResult, error) {
+	result := GetVersionResult{}
+	err := proxy.client.callJson(ctx, "get_version", nil, &result)
+	return result, err
+}
+
+// on_get_block_hash：返回指定高度的区块hash
+func (proxy *DaemonRPCProxy) OnGetBlockHash(ctx context.Context, height uint64) (string, error) {
+	result := ""
+	err := proxy.client.callJson(ctx, "on_get_block_hash", []uint64{height}, &result)
+	return result, err
+}
+
+type GetCoinbaseTxSumResult struct {
+	ResponseBase
+	EmissionAmount      uint64 `json:"emission_amount"`
+	EmissionAmountTop64 uint64 `json:"emission_amount_top64"`
+	FeeAmount           uint64 `json:"fee_amount"`
+	FeeAmountTop64      uint64 `json:"fee_amount_top64"`
+	WideEmissionAmount  string `json:"wide_emission_amount"`
+	WideFeeAmount       string `json:"wide_fee_amount"`
+}
+
+// get_coinbase_tx_sum：统计从height开始的count个区块
+func (proxy *DaemonRPCProxy) GetCoinbaseTxSum(ctx context.Context, height uint64, count uint64) (GetCoinbaseTxSumResult, error) {
+	params := map[string]interface{}{
+		"height": height,
+		"count":  count,
+	}
+	result := GetCoinbaseTxSumResult{}
+	err := proxy.client.callJson(ctx, "get_coinbase_tx_sum", params, &result)
+	return result, err
+}
+
+type GetOutputHistogramRequest struct {
+	Amounts      []uint64 `json:"amounts"`
+	MinCount     uint64   `json:"min_count,omitempty"`
+	MaxCount     uint64   `json:"max_count,omitempty"`
+	Unlocked     bool     `json:"unlocked,omitempty"`
+	RecentCutoff uint64   `json:"recent_cutoff,omitempty"`
+}
+
+type HistogramEntry struct {
+	Amount            uint64 `json:"amount"`
+	TotalInstances    uint64 `json:"total_instances"`
+	UnlockedInstances uint64 `json:"unlocked_instances"`
+	RecentInstances   uint64 `json:"recent_instances"`
+}
+
+type GetOutputHistogramResult struct {
+	ResponseBase
+	Histogram []HistogramEntry `json:"histogram"`
+}
+
+// get_output_histogram
+func (proxy *DaemonRPCProxy) GetOutputHistogram(ctx context.Context, request GetOutputHistogramRequest) (GetOutputHistogramResult, error) {
+	result := GetOutputHistogramResult{}
+	err := proxy.client.callJson(ctx, "get_output_histogram", request, &result)
+	return result, err
+}
+
+type GetOutputDistributionRequest struct {
+	Amounts    []uint64 `json:"amounts"`
+	Cumulative bool     `json:"cumulative,omitempty"`
+	FromHeight uint64   `json:"from_height,omitempty"`
+	ToHeight   uint64   `json:"to_height,omitempty"`
+}
+
+type OutputDistribution struct {
+	Amount       uint64   `json:"amount"`
+	Base         uint64   `json:"base"`
+	Distribution []uint64 `json:"distribution"`
+	StartHeight  uint64   `json:"start_height"`
+}
+
+type GetOutputDistributionResult struct {
+	ResponseBase
+	Distributions []OutputDistribution `json:"distributions"`
+}
+
+// get_output_distribution：json rpc只支持非二进制、非压缩的格式
+func (proxy *DaemonRPCProxy) GetOutputDistribution(ctx context.Context, request GetOutputDistributionRequest) (GetOutputDistributionResult, error) {
+	params := struct {
+		GetOutputDistributionRequest
+		Binary   bool `json:"binary"`
+		Compress bool `json:"compress"`
+	}{GetOutputDistributionRequest: request}
+	result := GetOutputDistributionResult{}
+	err := proxy.client.callJson(ctx, "get_output_distribution", params, &result)
+	return result, err
+}
+
+type TxBacklogEntry struct {
+	Weight     uint64
+	Fee        uint64
+	TimeInPool uint64
+}
+
+type GetTxpoolBacklogResult struct {
+	ResponseBase
+	// monerod把backlog按二进制blob的形式直接写进json字符串，需要用Entries解析
+	Backlog json.RawMessage `json:"backlog"`
+}
+
+// 解析backlog，每个条目是3个小端序的uint64
+func (result *GetTxpoolBacklogResult) Entries() ([]TxBacklogEntry, error) {
+	blob, err := unquoteBinaryString(result.Backlog)
+	if err != nil {
+		return nil, err
+	}
+	if len(blob)%24 != 0 {
+		return nil, fmt.Errorf("invalid backlog length %d", len(blob))
+	}
+	entries := make([]TxBacklogEntry, len(blob)/24)
+	for i := range entries {
+		entries[i].Weight = binary.LittleEndian.Uint64(blob[i*24:])
+		entries[i].Fee = binary.LittleEndian.Uint64(blob[i*24+8:])
+		entries[i].TimeInPool = binary.LittleEndian.Uint64(blob[i*24+16:])
+	}
+	return entries, nil
+}
+
+// get_txpool_backlog
+func (proxy *DaemonRPCProxy) GetTxpoolBacklog(ctx context.Context) (GetTxpoolBacklogResult, error) {
+	result := GetTxpoolBacklogResult{}
+	err := proxy.client.callJson(ctx, "get_txpool_backlog", nil, &result)
+	return result, err
+}
+
+type SyncInfoPeer struct {
+	Info Connection `json:"info"`
+}
+
+type SyncInfoSpan struct {
+	ConnectionId     string `json:"connection_id"`
+	NBlocks          uint64 `json:"nblocks"`
+	Rate             uint32 `json:"rate"`
+	RemoteAddress    string `json:"remote_address"`
+	Size             uint64 `json:"size"`
+	Speed            uint32 `json:"speed"`
+	StartBlockHeight uint64 `json:"start_block_height"`
+}
+
+type SyncInfoResult struct {
+	ResponseBase
+	Height                uint64         `json:"height"`
+	NextNeededPruningSeed uint32         `json:"next_needed_pruning_seed"`
+	Overview              string         `json:"overview"`
+	Peers                 []SyncInfoPeer `json:"peers"`
+	Spans                 []SyncInfoSpan `json:"spans"`
+	TargetHeight          uint64         `json:"target_height"`
+}
+
+// sync_info
+func (proxy *DaemonRPCProxy) SyncInfo(ctx context.Context) (SyncInfoResult, error) {
+	result := SyncInfoResult{}
+	err := proxy.client.callJson(ctx, "sync_info", nil, &result)
+	return result, err
+}
+
+// Host和Ip二选一，Ip为网络字节序的ipv4地址
+type Ban struct {
+	Host    string `json:"host,omitempty"`
+	Ip      uint32 `json:"ip,omitempty"`
+	Ban     bool   `json:"ban"`
+	Seconds uint32 `json:"seconds"`
+}
+
+type GetBansResult struct {
+	ResponseBase
+	Bans []Ban `json:"bans"`
+}
+
+// get_bans
+func (proxy *DaemonRPCProxy) GetBans(ctx context.Context) (GetBansResult, error) {
+	result := GetBansResult{}
+	err := proxy.client.callJson(ctx, "get_bans", nil, &result)
+	return result, err
+}
+
+// set_bans：Ban为false表示解封
+func (proxy *DaemonRPCProxy) SetBans(ctx context.Context, bans []Ban) error {
+	params := map[string]interface{}{
+		"bans": bans,
+	}
+	return proxy.client.callJson(ctx, "set_bans", params, nil)
+}
+
+// flush_txpool：txids为空时清空整个交易池
+func (proxy *DaemonRPCProxy) FlushTxpool(ctx context.Context, txids []string) error {
+	params := map[string]interface{}{}
+	if len(txids) > 0 {
+		params["txids"] = txids
+	}
+	return proxy.client.callJson(ctx, "flush_txpool", params, nil)
+}
+
+type AlternateChain struct {
+	BlockHash            string   `json:"block_hash"`
+	BlockHashes          []string `json:"block_hashes"`
+	Difficulty           uint64   `json:"difficulty"`
+	DifficultyTop64      uint64   `json:"difficulty_top64"`
+	Height               uint64   `json:"height"`
+	Length               uint64   `json:"length"`
+	MainChainParentBlock string   `json:"main_chain_parent_block"`
+	WideDifficulty       string   `json:"wide_difficulty"`
+}
+
+type GetAlternateChainsResult struct {
+	ResponseBase
+	Chains []AlternateChain `json:"chains"`
+}
+
+// get_alternate_chains
+func (proxy *DaemonRPCProxy) GetAlternateChains(ctx context.Context) (GetAlternateChainsResult, error) {
+	result := GetAlternateChainsResult{}
+	err := proxy.client.callJson(ctx, "get_alternate_chains", nil, &result)
+	return result, err
+}
+
+// relay_tx
+func (proxy *DaemonRPCProxy) RelayTx(ctx context.Context, txids []string) error {
+	params := map[string]interface{}{
+		"txids": txids,
+	}
+	return proxy.client.callJson(ctx, "relay_tx", params, nil)
+}
+
+// submit_block：block_blobs为十六进制编码的区块
+func (proxy *DaemonRPCProxy) SubmitBlock(ctx context.Context, block_blobs []string) error {
+	return proxy.client.callJson(ctx, "submit_block", block_blobs, nil)
+}
+
+type GenerateBlocksRequest struct {
+	AmountOfBlocks uint64 `json:"amount_of_blocks"`
+	WalletAddress  string `json:"wallet_address"`
+	PrevBlock      string `json:"prev_block,omitempty"`
+	StartingNonce  uint32 `json:"starting_nonce,omitempty"`
+}
+
+type GenerateBlocksResult struct {
+	ResponseBase
+	Blocks []string `json:"blocks"`
+	Height uint64   `json:"height"`
+}
+
+// generateblocks：只在regtest模式下可用
+func (proxy *DaemonRPCProxy) GenerateBlocks(ctx context.Context, request GenerateBlocksRequest) (GenerateBlocksResult, error) {
+	result := GenerateBlocksResult{}
+	err := proxy.client.callJson(ctx, "generateblocks", request, &result)
+	return result, err
 }
 
 /*
@@ -52,14 +558,358 @@ func (proxy *DaemonRPCProxy) GetBlockCount() int {
 	=================
 */
 
+type GetHeightResult struct {
+	ResponseBase
+	Height uint64 `json:"height"`
+	Hash   string `json:"hash"`
+}
+
 // get_height
-func (proxy *DaemonRPCProxy) GetHeight() int {
-	rpc_method := "get_height"
-	params := map[string]interface{}{}
-	response := OtherMethodRequest(proxy.other_rpc_url, rpc_method, params, proxy.rpc_user, proxy.rpc_password)
-	if result, ok := response["height"].(float64); ok {
-		return int(result)
+func (proxy *DaemonRPCProxy) GetHeight(ctx context.Context) (GetHeightResult, error) {
+	result := GetHeightResult{}
+	err := proxy.client.callOther(ctx, "get_height", nil, &result)
+	return result, err
+}
+
+type GetTransactionsRequest struct {
+	TxsHashes    []string `json:"txs_hashes"`
+	DecodeAsJson bool     `json:"decode_as_json,omitempty"`
+	Prune        bool     `json:"prune,omitempty"`
+	Split        bool     `json:"split,omitempty"`
+}
+
+type TransactionEntry struct {
+	AsHex           string   `json:"as_hex"`
+	AsJson          string   `json:"as_json"` // DecodeAsJson为true时才有，是一个需要再次解析的字符串
+	BlockHeight     uint64   `json:"block_height"`
+	BlockTimestamp  uint64   `json:"block_timestamp"`
+	Confirmations   uint64   `json:"confirmations"`
+	DoubleSpendSeen bool     `json:"double_spend_seen"`
+	InPool          bool     `json:"in_pool"`
+	OutputIndices   []uint64 `json:"output_indices"`
+	PrunableAsHex   string   `json:"prunable_as_hex"`
+	PrunableHash    string   `json:"prunable_hash"`
+	PrunedAsHex     string   `json:"pruned_as_hex"`
+	Relayed         bool     `json:"relayed"`
+	TxHash          string   `json:"tx_hash"`
+}
+
+type GetTransactionsResult struct {
+	ResponseBase
+	MissedTx  []string           `json:"missed_tx"`
+	Txs       []TransactionEntry `json:"txs"`
+	TxsAsHex  []string           `json:"txs_as_hex"`
+	TxsAsJson []string           `json:"txs_as_json"`
+}
+
+// get_transactions
+func (proxy *DaemonRPCProxy) GetTransactions(ctx context.Context, request GetTransactionsRequest) (GetTransactionsResult, error) {
+	result := GetTransactionsResult{}
+	err := proxy.client.callOther(ctx, "get_transactions", request, &result)
+	return result, err
+}
+
+type GetAltBlocksHashesResult struct {
+	ResponseBase
+	BlksHashes []string `json:"blks_hashes"`
+}
+
+// get_alt_blocks_hashes
+func (proxy *DaemonRPCProxy) GetAltBlocksHashes(ctx context.Context) (GetAltBlocksHashesResult, error) {
+	result := GetAltBlocksHashesResult{}
+	err := proxy.client.callOther(ctx, "get_alt_blocks_hashes", nil, &result)
+	return result, err
+}
+
+// is_key_image_spent返回的状态
+const (
+	KeyImageUnspent           = 0
+	KeyImageSpentInBlockchain = 1
+	KeyImageSpentInPool       = 2
+)
+
+type IsKeyImageSpentResult struct {
+	ResponseBase
+	SpentStatus []int `json:"spent_status"`
+}
+
+// is_key_image_spent：SpentStatus与key_images一一对应
+func (proxy *DaemonRPCProxy) IsKeyImageSpent(ctx context.Context, key_images []string) (IsKeyImageSpentResult, error) {
+	params := map[string]interface{}{
+		"key_images": key_images,
+	}
+	result := IsKeyImageSpentResult{}
+	err := proxy.client.callOther(ctx, "is_key_image_spent", params, &result)
+	return result, err
+}
+
+type SendRawTransactionRequest struct {
+	TxAsHex        string `json:"tx_as_hex"`
+	DoNotRelay     bool   `json:"do_not_relay,omitempty"`
+	DoSanityChecks bool   `json:"do_sanity_checks,omitempty"`
+}
+
+type SendRawTransactionResult struct {
+	ResponseBase
+	DoubleSpend       bool   `json:"double_spend"`
+	FeeTooLow         bool   `json:"fee_too_low"`
+	InvalidInput      bool   `json:"invalid_input"`
+	InvalidOutput     bool   `json:"invalid_output"`
+	LowMixin          bool   `json:"low_mixin"`
+	NonzeroUnlockTime bool   `json:"nonzero_unlock_time"`
+	NotRelayed        bool   `json:"not_relayed"`
+	Overspend         bool   `json:"overspend"`
+	Reason            string `json:"reason"`
+	SanityCheckFailed bool   `json:"sanity_check_failed"`
+	TooBig            bool   `json:"too_big"`
+	TooFewOutputs     bool   `json:"too_few_outputs"`
+	TxExtraTooBig     bool   `json:"tx_extra_too_big"`
+}
+
+// send_raw_transaction：交易被拒绝时返回RPCError，result中的各个字段说明了拒绝的原因
+func (proxy *DaemonRPCProxy) SendRawTransaction(ctx context.Context, request SendRawTransactionRequest) (SendRawTransactionResult, error) {
+	result := SendRawTransactionResult{}
+	err := proxy.client.callOther(ctx, "send_raw_transaction", request, &result)
+	return result, err
+}
+
+type SpentKeyImage struct {
+	IdHash    string   `json:"id_hash"`
+	TxsHashes []string `json:"txs_hashes"`
+}
+
+type PoolTransaction struct {
+	BlobSize           uint64 `json:"blob_size"`
+	DoNotRelay         bool   `json:"do_not_relay"`
+	DoubleSpendSeen    bool   `json:"double_spend_seen"`
+	Fee                uint64 `json:"fee"`
+	IdHash             string `json:"id_hash"`
+	KeptByBlock        bool   `json:"kept_by_block"`
+	LastFailedHeight   uint64 `json:"last_failed_height"`
+	LastFailedIdHash   string `json:"last_failed_id_hash"`
+	LastRelayedTime    uint64 `json:"last_relayed_time"`
+	MaxUsedBlockHeight uint64 `json:"max_used_block_height"`
+	MaxUsedBlockIdHash string `json:"max_used_block_id_hash"`
+	ReceiveTime        uint64 `json:"receive_time"`
+	Relayed            bool   `json:"relayed"`
+	TxBlob             string `json:"tx_blob"`
+	TxJson             string `json:"tx_json"`
+	Weight             uint64 `json:"weight"`
+}
+
+type GetTransactionPoolResult struct {
+	ResponseBase
+	SpentKeyImages []SpentKeyImage   `json:"spent_key_images"`
+	Transactions   []PoolTransaction `json:"transactions"`
+}
+
+// get_transaction_pool
+func (proxy *DaemonRPCProxy) GetTransactionPool(ctx context.Context) (GetTransactionPoolResult, error) {
+	result := GetTransactionPoolResult{}
+	err := proxy.client.callOther(ctx, "get_transaction_pool", nil, &result)
+	return result, err
+}
+
+type GetTransactionPoolHashesResult struct {
+	ResponseBase
+	TxHashes []string `json:"tx_hashes"`
+}
+
+// get_transaction_pool_hashes
+func (proxy *DaemonRPCProxy) GetTransactionPoolHashes(ctx context.Context) (GetTransactionPoolHashesResult, error) {
+	result := GetTransactionPoolHashesResult{}
+	err := proxy.client.callOther(ctx, "get_transaction_pool_hashes", nil, &result)
+	return result, err
+}
+
+type PoolStats struct {
+	BytesMax        uint32 `json:"bytes_max"`
+	BytesMed        uint32 `json:"bytes_med"`
+	BytesMin        uint32 `json:"bytes_min"`
+	BytesTotal      uint64 `json:"bytes_total"`
+	FeeTotal        uint64 `json:"fee_total"`
+	Histo98pc       uint64 `json:"histo_98pc"`
+	Num10m          uint32 `json:"num_10m"`
+	NumDoubleSpends uint32 `json:"num_double_spends"`
+	NumFailing      uint32 `json:"num_failing"`
+	NumNotRelayed   uint32 `json:"num_not_relayed"`
+	Oldest          uint64 `json:"oldest"`
+	TxsTotal        uint32 `json:"txs_total"`
+	// monerod把histo按二进制blob的形式写进json，这里保留原始数据
+	Histo json.RawMessage `json:"histo"`
+}
+
+type GetTransactionPoolStatsResult struct {
+	ResponseBase
+	PoolStats PoolStats `json:"pool_stats"`
+}
+
+// get_transaction_pool_stats
+func (proxy *DaemonRPCProxy) GetTransactionPoolStats(ctx context.Context) (GetTransactionPoolStatsResult, error) {
+	result := GetTransactionPoolStatsResult{}
+	err := proxy.client.callOther(ctx, "get_transaction_pool_stats", nil, &result)
+	return result, err
+}
+
+type PeerListEntry struct {
+	Host              string `json:"host"`
+	Id                uint64 `json:"id"`
+	Ip                uint32 `json:"ip"`
+	LastSeen          uint64 `json:"last_seen"`
+	Port              uint16 `json:"port"`
+	PruningSeed       uint32 `json:"pruning_seed"`
+	RPCCreditsPerHash uint32 `json:"rpc_credits_per_hash"`
+	RPCPort           uint16 `json:"rpc_port"`
+}
+
+type GetPeerListResult struct {
+	ResponseBase
+	GrayList  []PeerListEntry `json:"gray_list"`
+	WhiteList []PeerListEntry `json:"white_list"`
+}
+
+// get_peer_list
+func (proxy *DaemonRPCProxy) GetPeerList(ctx context.Context, public_only bool) (GetPeerListResult, error) {
+	params := map[string]interface{}{
+		"public_only": public_only,
+	}
+	result := GetPeerListResult{}
+	err := proxy.client.callOther(ctx, "get_peer_list", params, &result)
+	return result, err
+}
+
+type GetPublicNodesRequest struct {
+	Gray           bool `json:"gray"`
+	White          bool `json:"white"`
+	IncludeBlocked bool `json:"include_blocked,omitempty"`
+}
+
+type PublicNode struct {
+	Host              string `json:"host"`
+	LastSeen          uint64 `json:"last_seen"`
+	RPCCreditsPerHash uint32 `json:"rpc_credits_per_hash"`
+	RPCPort           uint16 `json:"rpc_port"`
+}
+
+type GetPublicNodesResult struct {
+	ResponseBase
+	Gray  []PublicNode `json:"gray"`
+	White []PublicNode `json:"white"`
+}
+
+// get_public_nodes
+func (proxy *DaemonRPCProxy) GetPublicNodes(ctx context.Context, request GetPublicNodesRequest) (GetPublicNodesResult, error) {
+	result := GetPublicNodesResult{}
+	err := proxy.client.callOther(ctx, "get_public_nodes", request, &result)
+	return result, err
+}
+
+// set_log_level：level取值0-4
+func (proxy *DaemonRPCProxy) SetLogLevel(ctx context.Context, level int8) error {
+	params := map[string]interface{}{
+		"level": level,
+	}
+	return proxy.client.callOther(ctx, "set_log_level", params, nil)
+}
+
+type GetNetStatsResult struct {
+	ResponseBase
+	StartTime       uint64 `json:"start_time"`
+	TotalBytesIn    uint64 `json:"total_bytes_in"`
+	TotalBytesOut   uint64 `json:"total_bytes_out"`
+	TotalPacketsIn  uint64 `json:"total_packets_in"`
+	TotalPacketsOut uint64 `json:"total_packets_out"`
+}
+
+// get_net_stats
+func (proxy *DaemonRPCProxy) GetNetStats(ctx context.Context) (GetNetStatsResult, error) {
+	result := GetNetStatsResult{}
+	err := proxy.client.callOther(ctx, "get_net_stats", nil, &result)
+	return result, err
+}
+
+type GetLimitResult struct {
+	ResponseBase
+	LimitDown uint64 `json:"limit_down"`
+	LimitUp   uint64 `json:"limit_up"`
+}
+
+// get_limit：单位为kB/s
+func (proxy *DaemonRPCProxy) GetLimit(ctx context.Context) (GetLimitResult, error) {
+	result := GetLimitResult{}
+	err := proxy.client.callOther(ctx, "get_limit", nil, &result)
+	return result, err
+}
+
+type OutPeersResult struct {
+	ResponseBase
+	OutPeers uint32 `json:"out_peers"`
+}
+
+// out_peers：set为false时只查询当前的传出连接上限
+func (proxy *DaemonRPCProxy) OutPeers(ctx context.Context, out_peers uint32, set bool) (OutPeersResult, error) {
+	params := map[string]interface{}{
+		"out_peers": out_peers,
+		"set":       set,
+	}
+	result := OutPeersResult{}
+	err := proxy.client.callOther(ctx, "out_peers", params, &result)
+	return result, err
+}
+
+type InPeersResult struct {
+	ResponseBase
+	InPeers uint32 `json:"in_peers"`
+}
+
+// in_peers：set为false时只查询当前的传入连接上限
+func (proxy *DaemonRPCProxy) InPeers(ctx context.Context, in_peers uint32, set bool) (InPeersResult, error) {
+	params := map[string]interface{}{
+		"in_peers": in_peers,
+		"set":      set,
+	}
+	result := InPeersResult{}
+	err := proxy.client.callOther(ctx, "in_peers", params, &result)
+	return result, err
+}
+
+type MiningStatusResult struct {
+	ResponseBase
+	Active                    bool   `json:"active"`
+	Address                   string `json:"address"`
+	BgIdleThreshold           uint8  `json:"bg_idle_threshold"`
+	BgIgnoreBattery           bool   `json:"bg_ignore_battery"`
+	BgMinIdleSeconds          uint8  `json:"bg_min_idle_seconds"`
+	BgTarget                  uint8  `json:"bg_target"`
+	BlockReward               uint64 `json:"block_reward"`
+	BlockTarget               uint32 `json:"block_target"`
+	Difficulty                uint64 `json:"difficulty"`
+	DifficultyTop64           uint64 `json:"difficulty_top64"`
+	IsBackgroundMiningEnabled bool   `json:"is_background_mining_enabled"`
+	PowAlgorithm              string `json:"pow_algorithm"`
+	Speed                     uint64 `json:"speed"`
+	ThreadsCount              uint32 `json:"threads_count"`
+	WideDifficulty            string `json:"wide_difficulty"`
+}
+
+// mining_status
+func (proxy *DaemonRPCProxy) MiningStatus(ctx context.Context) (MiningStatusResult, error) {
+	result := MiningStatusResult{}
+	err := proxy.client.callOther(ctx, "mining_status", nil, &result)
+	return result, err
+}
+
+type PopBlocksResult struct {
+	ResponseBase
+	Height uint64 `json:"height"`
+}
+
+// pop_blocks：从链顶移除nblocks个区块，返回新的高度
+func (proxy *DaemonRPCProxy) PopBlocks(ctx context.Context, nblocks uint64) (PopBlocksResult, error) {
+	params := map[string]interface{}{
+		"nblocks": nblocks,
 	}
-	log.Println("Error occur when get block height!")
-	return -1
+	result := PopBlocksResult{}
+	err := proxy.client.callOther(ctx, "pop_blocks", params, &result)
+	return result, err
 }
@@ -0,0 +1,234 @@
+package rpcproxy
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+/*
+==================================================
+HTTP digest认证（RFC 2617），monerod和wallet-rpc的--rpc-login
+==================================================
+*/
+
+// 服务器在401响应的WWW-Authenticate中给出的参数
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+}
+
+// 带digest认证的RoundTripper
+// 缓存上一次的challenge，之后的请求直接带上Authorization并递增nc，nonce过期时再重新认证
+type digestTransport struct {
+	user     string
+	password string
+	base     http.RoundTripper
+
+	lock      sync.Mutex
+	challenge *digestChallenge
+	nc        uint32
+}
+
+func newDigestTransport(user string, password string, base http.RoundTripper) *digestTransport {
+	return &digestTransport{
+		user:     user,
+		password: password,
+		base:     base,
+	}
+}
+
+func (transport *digestTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	transport.lock.Lock()
+	challenge := transport.challenge
+	transport.lock.Unlock()
+
+	first, err := cloneRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		authorization, err := transport.authorize(first, challenge)
+		if err != nil {
+			return nil, err
+		}
+		first.Header.Set("Authorization", authorization)
+	}
+	resp, err := transport.base.RoundTrip(first)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	challenge, ok := parseDigestChallenges(resp.Header.Values("WWW-Authenticate"))
+	if !ok {
+		return resp, nil
+	}
+	retry, err := cloneRequest(request)
+	if err != nil {
+		// body无法重新读取，只能把401返回给调用者
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	transport.lock.Lock()
+	transport.challenge = challenge
+	transport.nc = 0
+	transport.lock.Unlock()
+
+	authorization, err := transport.authorize(retry, challenge)
+	if err != nil {
+		return nil, err
+	}
+	retry.Header.Set("Authorization", authorization)
+	return transport.base.RoundTrip(retry)
+}
+
+// RoundTripper不能修改原请求，复制请求并重新获取body
+func cloneRequest(request *http.Request) (*http.Request, error) {
+	clone := request.Clone(request.Context())
+	if request.Body == nil || request.Body == http.NoBody {
+		return clone, nil
+	}
+	if request.GetBody == nil {
+		return nil, errors.New("digest auth: request body cannot be replayed")
+	}
+	body, err := request.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone.Body = body
+	return clone, nil
+}
+
+// 计算Authorization头
+func (transport *digestTransport) authorize(request *http.Request, challenge *digestChallenge) (string, error) {
+	transport.lock.Lock()
+	transport.nc++
+	nc := fmt.Sprintf("%08x", transport.nc)
+	transport.lock.Unlock()
+
+	cnonce_bytes := make([]byte, 8)
+	if _, err := rand.Read(cnonce_bytes); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(cnonce_bytes)
+	uri := request.URL.RequestURI()
+
+	ha1 := md5Hex(transport.user + ":" + challenge.realm + ":" + transport.password)
+	switch strings.ToUpper(challenge.algorithm) {
+	case "", "MD5":
+	case "MD5-SESS":
+		ha1 = md5Hex(ha1 + ":" + challenge.nonce + ":" + cnonce)
+	default:
+		return "", fmt.Errorf("digest auth: unsupported algorithm %s", challenge.algorithm)
+	}
+	ha2 := md5Hex(request.Method + ":" + uri)
+
+	var response string
+	if challenge.qop != "" {
+		response = md5Hex(ha1 + ":" + challenge.nonce + ":" + nc + ":" + cnonce + ":" + challenge.qop + ":" + ha2)
+	} else {
+		response = md5Hex(ha1 + ":" + challenge.nonce + ":" + ha2)
+	}
+
+	fields := []string{
+		fmt.Sprintf(`username="%s"`, transport.user),
+		fmt.Sprintf(`realm="%s"`, challenge.realm),
+		fmt.Sprintf(`nonce="%s"`, challenge.nonce),
+		fmt.Sprintf(`uri="%s"`, uri),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if challenge.algorithm != "" {
+		fields = append(fields, "algorithm="+challenge.algorithm)
+	}
+	if challenge.qop != "" {
+		fields = append(fields, "qop="+challenge.qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	if challenge.opaque != "" {
+		fields = append(fields, fmt.Sprintf(`opaque="%s"`, challenge.opaque))
+	}
+	return "Digest " + strings.Join(fields, ", "), nil
+}
+
+func md5Hex(data string) string {
+	sum := md5.Sum([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// monerod会返回多个WWW-Authenticate头（MD5和MD5-sess），选择第一个支持的
+func parseDigestChallenges(headers []string) (*digestChallenge, bool) {
+	for _, header := range headers {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		params := parseAuthParams(rest)
+		challenge := digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+		}
+		switch strings.ToUpper(challenge.algorithm) {
+		case "", "MD5", "MD5-SESS":
+		default:
+			continue
+		}
+		// qop可能是"auth,auth-int"，只支持auth
+		for _, qop := range strings.Split(params["qop"], ",") {
+			if strings.TrimSpace(qop) == "auth" {
+				challenge.qop = "auth"
+			}
+		}
+		if params["qop"] != "" && challenge.qop == "" {
+			continue
+		}
+		return &challenge, true
+	}
+	return nil, false
+}
+
+// 解析 key=value, key="quoted, value" 形式的参数
+func parseAuthParams(data string) map[string]string {
+	params := map[string]string{}
+	for len(data) > 0 {
+		data = strings.TrimLeft(data, " \t,")
+		key, rest, found := strings.Cut(data, "=")
+		if !found {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " \t")
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			builder := strings.Builder{}
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				builder.WriteByte(rest[i])
+			}
+			value = builder.String()
+			if i < len(rest) {
+				i++
+			}
+			data = rest[i:]
+		} else {
+			value, data, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+		params[key] = value
+	}
+	return params
+}
@@ -0,0 +1,197 @@
+package rpcproxy
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+/*
+==========================
+RPC代理使用的HTTP客户端
+==========================
+*/
+
+const (
+	DefaultRPCTimeout          = 60 * time.Second
+	DefaultDialTimeout         = 10 * time.Second
+	DefaultMaxIdleConnsPerHost = 8
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// HTTPS配置，对应monerod/wallet-rpc的--rpc-ssl系列参数
+type TLSConfig struct {
+	CAFile   string // 校验服务器证书的CA（PEM），为空时使用系统CA
+	CertFile string // 客户端证书（PEM），对应--rpc-ssl-certificate
+	KeyFile  string // 客户端私钥（PEM）
+
+	// 允许的服务器证书SHA-256指纹，对应--rpc-ssl-allowed-fingerprints，格式为hex，可以带冒号
+	// 设置后只校验指纹，不再校验证书链，用于monerod自动生成的自签名证书
+	AllowedFingerprints []string
+
+	ServerName         string // 为空时使用rpc_ip
+	InsecureSkipVerify bool   // 不校验服务器证书，对应--rpc-ssl-allow-any-cert
+}
+
+// 构建tls.Config
+func (config *TLSConfig) build(server_name string) (*tls.Config, error) {
+	tls_config := &tls.Config{
+		ServerName:         server_name,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.ServerName != "" {
+		tls_config.ServerName = config.ServerName
+	}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read rpc ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", config.CAFile)
+		}
+		tls_config.RootCAs = pool
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load rpc client certificate: %w", err)
+		}
+		tls_config.Certificates = []tls.Certificate{cert}
+	}
+	if len(config.AllowedFingerprints) > 0 {
+		allowed := map[string]bool{}
+		for _, fingerprint := range config.AllowedFingerprints {
+			normalized := strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+			if decoded, err := hex.DecodeString(normalized); err != nil || len(decoded) != sha256.Size {
+				return nil, fmt.Errorf("invalid certificate fingerprint %s", fingerprint)
+			}
+			allowed[normalized] = true
+		}
+		// 自签名证书无法通过证书链校验，跳过默认校验，只比较叶子证书的指纹
+		tls_config.InsecureSkipVerify = true
+		tls_config.VerifyPeerCertificate = func(raw_certs [][]byte, _ [][]*x509.Certificate) error {
+			if len(raw_certs) == 0 {
+				return errors.New("rpc server sent no certificate")
+			}
+			sum := sha256.Sum256(raw_certs[0])
+			if !allowed[hex.EncodeToString(sum[:])] {
+				return fmt.Errorf("rpc server certificate fingerprint %s is not allowed", fingerprintString(sum[:]))
+			}
+			return nil
+		}
+	}
+	return tls_config, nil
+}
+
+// 与monerod输出的格式一致：大写hex，冒号分隔
+func fingerprintString(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// SOCKS5代理，如Tor的127.0.0.1:9050
+type SOCKS5Config struct {
+	Address  string
+	User     string
+	Password string
+}
+
+// 根据ProxyConfig构建Transport，连接池在同一个proxy的所有请求之间共享
+func buildTransport(config ProxyConfig, server_name string) (*http.Transport, error) {
+	dial_timeout := config.DialTimeout
+	if dial_timeout == 0 {
+		dial_timeout = DefaultDialTimeout
+	}
+	max_idle := config.MaxIdleConnsPerHost
+	if max_idle == 0 {
+		max_idle = DefaultMaxIdleConnsPerHost
+	}
+	idle_timeout := config.IdleConnTimeout
+	if idle_timeout == 0 {
+		idle_timeout = DefaultIdleConnTimeout
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   dial_timeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          max_idle * 4,
+		MaxIdleConnsPerHost:   max_idle,
+		IdleConnTimeout:       idle_timeout,
+		TLSHandshakeTimeout:   dial_timeout,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	if config.SOCKS5 != nil {
+		var auth *proxy.Auth
+		if config.SOCKS5.User != "" {
+			auth = &proxy.Auth{User: config.SOCKS5.User, Password: config.SOCKS5.Password}
+		}
+		socks_dialer, err := proxy.SOCKS5("tcp", config.SOCKS5.Address, auth, dialer)
+		if err != nil {
+			return nil, fmt.Errorf("create socks5 dialer: %w", err)
+		}
+		context_dialer, ok := socks_dialer.(proxy.ContextDialer)
+		if !ok {
+			return nil, errors.New("socks5 dialer does not support context")
+		}
+		transport.DialContext = context_dialer.DialContext
+	}
+
+	if config.TLS != nil {
+		tls_config, err := config.TLS.build(server_name)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tls_config
+	}
+	return transport, nil
+}
+
+// 构建http.Client：Transport外层包一层digest认证，rpc_user为空时不认证
+func buildHTTPClient(config ProxyConfig, server_name string, rpc_user string, rpc_password string) (*http.Client, error) {
+	transport, err := buildTransport(config, server_name)
+	if err != nil {
+		return nil, err
+	}
+	return newHTTPClient(transport, config.Timeout, rpc_user, rpc_password), nil
+}
+
+func newHTTPClient(transport http.RoundTripper, timeout time.Duration, rpc_user string, rpc_password string) *http.Client {
+	if timeout == 0 {
+		timeout = DefaultRPCTimeout
+	} else if timeout < 0 {
+		timeout = 0
+	}
+	if rpc_user != "" {
+		transport = newDigestTransport(rpc_user, rpc_password, transport)
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
+
+// JsonMethodRequest和OtherMethodRequest共享的连接池
+var sharedTransport = sync.OnceValue(func() *http.Transport {
+	transport, _ := buildTransport(ProxyConfig{}, "")
+	return transport
+})
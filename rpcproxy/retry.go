@@ -0,0 +1,319 @@
+package rpcproxy
+
+import (
+	"context"
+	"errors"
+	"gomonero/metrics"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+===========================
+RPC调用的重试策略和熔断器
+===========================
+*/
+
+// 重试策略，只重试已知只读方法的暂时性错误（超时、连接被拒绝或断开、HTTP 5xx/429、status为BUSY、表示节点忙的错误码）
+type RetryPolicy struct {
+	MaxAttempts    int // 包括第一次，小于等于1时不重试
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64 // 每次重试后backoff乘以Multiplier
+	Jitter         float64 // 0~1，实际等待时间在[backoff*(1-Jitter), backoff]之间随机
+}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+	}
+}
+
+// 第retry次重试前的等待时间，retry从1开始
+func (policy *RetryPolicy) backoff(retry int) time.Duration {
+	backoff := float64(policy.InitialBackoff)
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	for i := 1; i < retry; i++ {
+		backoff *= multiplier
+		if policy.MaxBackoff > 0 && backoff >= float64(policy.MaxBackoff) {
+			break
+		}
+	}
+	if policy.MaxBackoff > 0 && backoff > float64(policy.MaxBackoff) {
+		backoff = float64(policy.MaxBackoff)
+	}
+	jitter := min(max(policy.Jitter, 0), 1)
+	return time.Duration(backoff * (1 - jitter*rand.Float64()))
+}
+
+// 已知的只读方法，重复发送没有副作用，只有这些方法的暂时性错误会被重试
+// 不在表中的方法（发送交易、创建钱包、multisig等，以及未知的方法）永远不重试；
+// export_outputs、export_key_images等增量导出的方法会移动钱包的导出位置，也不重试
+var idempotentMethods = map[string]bool{
+	// monerod json_rpc
+	"get_block_count":            true,
+	"getblockcount":              true,
+	"on_get_block_hash":          true,
+	"on_getblockhash":            true,
+	"get_last_block_header":      true,
+	"getlastblockheader":         true,
+	"get_block_header_by_hash":   true,
+	"getblockheaderbyhash":       true,
+	"get_block_header_by_height": true,
+	"getblockheaderbyheight":     true,
+	"get_block_headers_range":    true,
+	"getblockheadersrange":       true,
+	"get_block":                  true,
+	"getblock":                   true,
+	"get_connections":            true,
+	"get_info":                   true,
+	"hard_fork_info":             true,
+	"get_bans":                   true,
+	"get_version":                true,
+	"get_fee_estimate":           true,
+	"get_alternate_chains":       true,
+	"sync_info":                  true,
+	"get_txpool_backlog":         true,
+	"get_output_distribution":    true,
+	"get_output_histogram":       true,
+	"get_coinbase_tx_sum":        true,
+	// monerod路径方法
+	"get_height":                  true,
+	"getheight":                   true,
+	"get_transactions":            true,
+	"gettransactions":             true,
+	"get_alt_blocks_hashes":       true,
+	"is_key_image_spent":          true,
+	"get_transaction_pool":        true,
+	"get_transaction_pool_hashes": true,
+	"get_transaction_pool_stats":  true,
+	"get_limit":                   true,
+	"get_net_stats":               true,
+	"get_peer_list":               true,
+	"get_public_nodes":            true,
+	"mining_status":               true,
+	"get_outs":                    true,
+	// monerod .bin方法
+	"get_blocks.bin":                  true,
+	"get_blocks_by_height.bin":        true,
+	"get_hashes.bin":                  true,
+	"get_o_indexes.bin":               true,
+	"get_outs.bin":                    true,
+	"get_transaction_pool_hashes.bin": true,
+	"get_output_distribution.bin":     true,
+	// wallet-rpc
+	"get_balance":              true,
+	"get_address":              true,
+	"get_address_index":        true,
+	"get_accounts":             true,
+	"get_transfers":            true,
+	"get_transfer_by_txid":     true,
+	"incoming_transfers":       true,
+	"query_key":                true,
+	"validate_address":         true,
+	"make_integrated_address":  true,
+	"split_integrated_address": true,
+	"make_uri":                 true,
+	"parse_uri":                true,
+	"get_languages":            true,
+	"is_multisig":              true,
+	"get_tx_key":               true,
+	"check_tx_key":             true,
+	"get_tx_proof":             true,
+	"check_tx_proof":           true,
+	"get_reserve_proof":        true,
+	"check_reserve_proof":      true,
+	"sign":                     true,
+	"verify":                   true,
+	"describe_transfer":        true,
+}
+
+// 方法是否可以安全地重试
+func IsIdempotent(rpc_method string) bool {
+	return idempotentMethods[rpc_method]
+}
+
+// 表示节点忙的JSON-RPC错误码，两边的错误码表不同：
+// monerod的-9是CORE_RPC_ERROR_CODE_CORE_BUSY（如同步中调用on_get_block_hash），wallet-rpc的-9是WRONG_SIGNATURE
+// wallet-rpc在它连接的monerod忙时返回-3（WALLET_RPC_ERROR_CODE_DAEMON_IS_BUSY）
+const (
+	daemonBusyCode = -9
+	walletBusyCode = -3
+)
+
+// 是否是值得重试的暂时性错误，busy_code为0时不按JSON-RPC错误码判断
+func isTransient(err error, busy_code int) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	rpc_error := &RPCError{}
+	if errors.As(err, &rpc_error) {
+		return rpc_error.HTTPStatus >= http.StatusInternalServerError ||
+			rpc_error.HTTPStatus == http.StatusTooManyRequests ||
+			rpc_error.Status == "BUSY" ||
+			(busy_code != 0 && rpc_error.Code == busy_code)
+	}
+	// http.Client返回的*url.Error只看里面的错误：TLS握手失败、证书指纹不匹配、URL无效等重试也不会成功
+	var url_error *url.Error
+	if errors.As(err, &url_error) {
+		// 服务器在响应前关闭连接时是io.EOF
+		return isTransientNetworkError(url_error.Err) || errors.Is(url_error.Err, io.EOF)
+	}
+	// 读取响应body时连接断开
+	return isTransientNetworkError(err)
+}
+
+// 超时、连接被拒绝或重置、连接意外断开
+func isTransientNetworkError(err error) bool {
+	var net_error net.Error
+	if errors.As(err, &net_error) && net_error.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+/* ==== 熔断器 ==== */
+
+var ErrCircuitOpen = errors.New("rpc circuit breaker is open")
+
+// 连续FailureThreshold次暂时性错误后熔断，OpenDuration内的调用直接返回ErrCircuitOpen
+// 之后放行一次试探调用，成功则恢复，失败则继续熔断
+type CircuitBreakerConfig struct {
+	FailureThreshold int
+	OpenDuration     time.Duration
+}
+
+const (
+	circuitClosed = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+type circuitBreaker struct {
+	config  CircuitBreakerConfig
+	backend string
+	logger  *slog.Logger
+
+	lock      sync.Mutex
+	state     int
+	failures  int
+	opened_at time.Time
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig, backend string, logger *slog.Logger) *circuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 5
+	}
+	if config.OpenDuration <= 0 {
+		config.OpenDuration = 30 * time.Second
+	}
+	return &circuitBreaker{config: config, backend: backend, logger: logger}
+}
+
+// 调用前检查是否放行
+func (breaker *circuitBreaker) allow() error {
+	breaker.lock.Lock()
+	defer breaker.lock.Unlock()
+	switch breaker.state {
+	case circuitOpen:
+		if time.Since(breaker.opened_at) < breaker.config.OpenDuration {
+			return ErrCircuitOpen
+		}
+		// 放行一次试探调用，结果返回前的其他调用仍然被拒绝
+		breaker.state = circuitHalfOpen
+		return nil
+	case circuitHalfOpen:
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// 记录一次放行的调用的结果；调用者取消的调用不计入
+func (breaker *circuitBreaker) record(err error, transient bool, canceled bool) {
+	breaker.lock.Lock()
+	defer breaker.lock.Unlock()
+	if canceled {
+		if breaker.state == circuitHalfOpen {
+			breaker.state = circuitOpen
+		}
+		return
+	}
+	if !transient {
+		if breaker.state != circuitClosed {
+			breaker.logger.Info("rpc circuit breaker closed", "backend", breaker.backend)
+			metrics.RPCCircuitOpen.WithLabelValues(breaker.backend).Set(0)
+		}
+		breaker.state = circuitClosed
+		breaker.failures = 0
+		return
+	}
+	breaker.failures++
+	if breaker.state == circuitHalfOpen || breaker.failures >= breaker.config.FailureThreshold {
+		if breaker.state == circuitClosed {
+			breaker.logger.Warn("rpc circuit breaker opened", "backend", breaker.backend, "failures", breaker.failures, "error", err)
+			metrics.RPCCircuitOpen.WithLabelValues(breaker.backend).Set(1)
+		}
+		breaker.state = circuitOpen
+		breaker.opened_at = time.Now()
+	}
+}
+
+// 执行一次RPC调用：经过熔断器，对幂等方法的暂时性错误按策略重试，并记录指标
+func (client *rpcClient) call(ctx context.Context, kind string, rpc_method string, attempt func(ctx context.Context) error) error {
+	return client.callWithPolicy(ctx, kind, rpc_method, IsIdempotent(rpc_method), attempt)
+}
+
+// retryable为false时不重试，用于批量请求等无法只按方法名判断的情况
+func (client *rpcClient) callWithPolicy(ctx context.Context, kind string, rpc_method string, retryable bool, attempt func(ctx context.Context) error) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveRPC(kind, rpc_method, start, err)
+	}()
+
+	max_attempts := 1
+	if client.retry != nil && client.retry.MaxAttempts > 1 && retryable {
+		max_attempts = client.retry.MaxAttempts
+	}
+	for i := 1; ; i++ {
+		if client.breaker != nil {
+			if err = client.breaker.allow(); err != nil {
+				return err
+			}
+		}
+		err = attempt(ctx)
+		transient := isTransient(err, client.busy_code)
+		if client.breaker != nil {
+			client.breaker.record(err, transient, ctx.Err() != nil)
+		}
+		if err == nil || i >= max_attempts || ctx.Err() != nil || !transient {
+			return err
+		}
+
+		backoff := client.retry.backoff(i)
+		client.logger.Debug("retrying rpc call", "method", rpc_method, "attempt", i+1, "backoff", backoff, "error", err)
+		metrics.RPCRetries.WithLabelValues(kind, rpc_method).Inc()
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -2,12 +2,19 @@ package rpcproxy
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"gomonero/levin"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
-
-	digest_auth_client "github.com/xinsnake/go-http-digest-auth-client"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 /*
@@ -16,78 +23,322 @@ import (
 ================================
 */
 
-// json rpc 请求
-func JsonMethodRequest(
-	json_rpc_url string, rpc_method string, params map[string]interface{},
-	rpc_user string, rpc_password string) map[string]interface{} {
+// RPC代理的配置
+// 数值字段为0时使用Default*的默认值
+type ProxyConfig struct {
+	Logger *slog.Logger // 为nil时使用slog.Default()
+
+	Timeout             time.Duration // 单次请求的超时，小于0时不设置超时
+	DialTimeout         time.Duration
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+
+	TLS    *TLSConfig    // 不为nil时使用https
+	SOCKS5 *SOCKS5Config // 不为nil时通过SOCKS5代理连接
+
+	Retry          *RetryPolicy          // 为nil时不重试
+	CircuitBreaker *CircuitBreakerConfig // 为nil时不熔断
+}
 
-	request_data := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      "0",
-		"method":  rpc_method,
-		"params":  params,
+func (config ProxyConfig) logger() *slog.Logger {
+	if config.Logger == nil {
+		return slog.Default().With("component", "rpcproxy")
 	}
-	json_data, err := json.Marshal(request_data)
-	if err != nil {
-		log.Println("Error: ", err)
-		return nil
+	return config.Logger.With("component", "rpcproxy")
+}
+
+// monerod/monero-wallet-rpc返回的错误
+// JSON-RPC的error对象填充Code和Message；响应中status不为OK时填充Status；HTTP层失败时填充HTTPStatus
+type RPCError struct {
+	Code       int    `json:"code"`
+	Message    string `json:"message"`
+	Status     string `json:"-"`
+	HTTPStatus int    `json:"-"`
+}
+
+func (rpc_error *RPCError) Error() string {
+	if rpc_error.HTTPStatus != 0 {
+		return fmt.Sprintf("rpc http status %d: %s", rpc_error.HTTPStatus, rpc_error.Message)
 	}
-	requset, err := http.NewRequest("POST", json_rpc_url, bytes.NewBuffer(json_data))
-	if err != nil {
-		log.Println("Error: ", err)
-		return nil
+	if rpc_error.Status != "" {
+		return "rpc status: " + rpc_error.Status
 	}
-	requset.Header.Set("Content-Type", "application/json")
+	return fmt.Sprintf("rpc error %d: %s", rpc_error.Code, rpc_error.Message)
+}
 
-	client := digest_auth_client.NewTransport(rpc_user, rpc_password)
-	resp, err := client.RoundTrip(requset)
-	if err != nil {
-		log.Println("Error: ", err)
-		return nil
+// monerod响应中常见的公共字段
+type ResponseBase struct {
+	Status    string `json:"status"`
+	Untrusted bool   `json:"untrusted"`
+}
+
+// monerod的status为OK表示成功，BUSY等其他值都表示失败
+const StatusOK = "OK"
+
+type jsonRPCRequest struct {
+	Jsonrpc string      `json:"jsonrpc"`
+	Id      string      `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type jsonRPCResponse struct {
+	Id     string          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// 两个代理共用的RPC传输层
+type rpcClient struct {
+	json_rpc_url  string
+	other_rpc_url string
+	http_client   *http.Client
+	config_err    error // ProxyConfig有误（如证书文件无法读取）时，每次调用都返回这个错误
+	retry         *RetryPolicy
+	breaker       *circuitBreaker
+	busy_code     int // 表示节点忙、可以重试的JSON-RPC错误码，为0时不按错误码重试
+	logger        *slog.Logger
+
+	batch_unsupported atomic.Bool // 服务器拒绝过批量请求
+}
+
+func newRPCClient(rpc_ip string, rpc_port uint16, rpc_user string, rpc_password string, config ProxyConfig, logger *slog.Logger) *rpcClient {
+	scheme := "http"
+	if config.TLS != nil {
+		scheme = "https"
 	}
-	defer resp.Body.Close()
+	base_url := scheme + "://" + net.JoinHostPort(rpc_ip, strconv.Itoa(int(rpc_port)))
+	client := rpcClient{
+		json_rpc_url:  base_url + "/json_rpc",
+		other_rpc_url: base_url,
+		retry:         config.Retry,
+		logger:        logger,
+	}
+	if config.CircuitBreaker != nil {
+		client.breaker = newCircuitBreaker(*config.CircuitBreaker, base_url, logger)
+	}
+	client.http_client, client.config_err = buildHTTPClient(config, rpc_ip, rpc_user, rpc_password)
+	if client.config_err != nil {
+		logger.Error("invalid rpc proxy config", "url", base_url, "error", client.config_err)
+	}
+	return &client
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("Error: ", err)
-		return nil
+// 共享rpcClient的缓存键，密码不同的调用不能共用digest认证的状态
+type sharedClientKey struct {
+	json_rpc_url  string
+	other_rpc_url string
+	rpc_user      string
+	rpc_password  string
+}
+
+var (
+	shared_clients_lock sync.Mutex
+	shared_clients      = map[sharedClientKey]*rpcClient{}
+)
+
+// 供JsonMethodRequest、OtherMethodRequest和JsonBatchRequest使用，共享连接池
+// 同一(url, user)复用同一个rpcClient，digest的challenge只需认证一次，批量请求是否支持也只探测一次
+// 缓存不清理，适用于少量固定的节点；地址经常变化时应创建DaemonRPCProxy/WalletRPCProxy并持有
+func sharedRPCClient(json_rpc_url string, other_rpc_url string, rpc_user string, rpc_password string) *rpcClient {
+	key := sharedClientKey{json_rpc_url, other_rpc_url, rpc_user, rpc_password}
+	shared_clients_lock.Lock()
+	defer shared_clients_lock.Unlock()
+	if client, ok := shared_clients[key]; ok {
+		return client
+	}
+	client := &rpcClient{
+		json_rpc_url:  json_rpc_url,
+		other_rpc_url: other_rpc_url,
+		http_client:   newHTTPClient(sharedTransport(), 0, rpc_user, rpc_password),
+		logger:        ProxyConfig{}.logger(),
 	}
-	response := map[string]interface{}{}
-	json.Unmarshal(body, &response)
-	return response
+	shared_clients[key] = client
+	return client
 }
 
-// other rpc 请求
-func OtherMethodRequest(
-	other_rpc_url string, rpc_method string, params map[string]interface{},
-	rpc_user string, rpc_password string) map[string]interface{} {
+// json rpc 请求，params为nil时不发送params字段，result为nil时丢弃结果
+func JsonMethodRequest(ctx context.Context,
+	json_rpc_url string, rpc_method string, params interface{},
+	rpc_user string, rpc_password string, result interface{}) error {
+	client := sharedRPCClient(json_rpc_url, "", rpc_user, rpc_password)
+	return client.callJson(ctx, rpc_method, params, result)
+}
+
+// other rpc 请求，rpc_method为路径名（如get_height）
+func OtherMethodRequest(ctx context.Context,
+	other_rpc_url string, rpc_method string, params interface{},
+	rpc_user string, rpc_password string, result interface{}) error {
+	client := sharedRPCClient("", other_rpc_url, rpc_user, rpc_password)
+	return client.callOther(ctx, rpc_method, params, result)
+}
 
+func (client *rpcClient) callJson(ctx context.Context, rpc_method string, params interface{}, result interface{}) error {
+	request_data := jsonRPCRequest{
+		Jsonrpc: "2.0",
+		Id:      "0",
+		Method:  rpc_method,
+		Params:  params,
+	}
+	json_data, err := json.Marshal(request_data)
+	if err != nil {
+		return err
+	}
+	return client.call(ctx, "json", rpc_method, func(ctx context.Context) error {
+		body, err := client.post(ctx, client.json_rpc_url, "application/json", json_data)
+		if err != nil {
+			return err
+		}
+		response := jsonRPCResponse{}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return fmt.Errorf("decode %s response: %w", rpc_method, err)
+		}
+		if response.Error != nil {
+			return response.Error
+		}
+		return decodeResult(rpc_method, response.Result, result)
+	})
+}
+
+func (client *rpcClient) callOther(ctx context.Context, rpc_method string, params interface{}, result interface{}) error {
+	if params == nil {
+		params = struct{}{}
+	}
 	json_data, err := json.Marshal(params)
 	if err != nil {
-		log.Println("Error: ", err)
-		return nil
+		return err
 	}
-	requset, err := http.NewRequest("POST", other_rpc_url+"/"+rpc_method, bytes.NewBuffer(json_data))
+	return client.call(ctx, "other", rpc_method, func(ctx context.Context) error {
+		body, err := client.post(ctx, client.other_rpc_url+"/"+rpc_method, "application/json", json_data)
+		if err != nil {
+			return err
+		}
+		return decodeResult(rpc_method, body, result)
+	})
+}
+
+// .bin rpc 请求，请求和响应都是levin的portable storage格式，rpc_method为路径名（如get_blocks.bin）
+func (client *rpcClient) callBinary(ctx context.Context, rpc_method string, params map[string]interface{}) (response map[string]interface{}, err error) {
+	request_data, err := levin.EncodePortableStorage(params)
 	if err != nil {
-		log.Println("Error: ", err)
+		return nil, err
+	}
+	err = client.call(ctx, "binary", rpc_method, func(ctx context.Context) error {
+		body, err := client.post(ctx, client.other_rpc_url+"/"+rpc_method, "application/octet-stream", request_data)
+		if err != nil {
+			return err
+		}
+		response, err = levin.DecodePortableStorage(body)
+		if err != nil {
+			return fmt.Errorf("decode %s response: %w", rpc_method, err)
+		}
+		if status, ok := response["status"].([]byte); ok && string(status) != StatusOK {
+			return &RPCError{Status: string(status)}
+		}
 		return nil
+	})
+	return response, err
+}
+
+// 发送POST请求，返回HTTP 200响应的body
+func (client *rpcClient) post(ctx context.Context, url string, content_type string, request_data []byte) ([]byte, error) {
+	if client.config_err != nil {
+		return nil, client.config_err
 	}
-	requset.Header.Set("Content-Type", "application/json")
+	requset, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(request_data))
+	if err != nil {
+		return nil, err
+	}
+	requset.Header.Set("Content-Type", content_type)
 
-	client := digest_auth_client.NewTransport(rpc_user, rpc_password)
-	resp, err := client.RoundTrip(requset)
+	resp, err := client.http_client.Do(requset)
 	if err != nil {
-		log.Println("Error: ", err)
-		return nil
+		client.logger.Debug("rpc request failed", "url", url, "error", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Println("Error: ", err)
+		return nil, err
+	}
+	client.logger.Debug("rpc request done", "url", url, "status", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return nil, &RPCError{HTTPStatus: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+	return body, nil
+}
+
+// 解析result，result是json对象时检查monerod的status字段
+// status不为OK时result仍会被填充（如send_raw_transaction失败时的原因字段），同时返回RPCError
+func decodeResult(rpc_method string, raw json.RawMessage, result interface{}) error {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
 		return nil
 	}
-	response := map[string]interface{}{}
-	json.Unmarshal(body, &response)
-	return response
+	if result != nil {
+		if err := json.Unmarshal(raw, result); err != nil {
+			return fmt.Errorf("decode %s result: %w", rpc_method, err)
+		}
+	}
+	if raw[0] == '{' {
+		status := struct {
+			Status *string `json:"status"`
+		}{}
+		if err := json.Unmarshal(raw, &status); err != nil {
+			return fmt.Errorf("decode %s result: %w", rpc_method, err)
+		}
+		if status.Status != nil && *status.Status != StatusOK {
+			return &RPCError{Status: *status.Status}
+		}
+	}
+	return nil
+}
+
+// 解析monerod直接写进json字符串的二进制数据
+// 不能使用json.Unmarshal解析成string，因为非UTF-8的字节会被替换成U+FFFD
+func unquoteBinaryString(raw json.RawMessage) ([]byte, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) < 2 || raw[0] != '"' || raw[len(raw)-1] != '"' {
+		return nil, errors.New("binary data is not a json string")
+	}
+	raw = raw[1 : len(raw)-1]
+	data := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' {
+			data = append(data, raw[i])
+			continue
+		}
+		i++
+		if i >= len(raw) {
+			return nil, errors.New("invalid escape in binary data")
+		}
+		switch raw[i] {
+		case '"', '\\', '/':
+			data = append(data, raw[i])
+		case 'b':
+			data = append(data, '\b')
+		case 'f':
+			data = append(data, '\f')
+		case 'n':
+			data = append(data, '\n')
+		case 'r':
+			data = append(data, '\r')
+		case 't':
+			data = append(data, '\t')
+		case 'u':
+			if i+4 >= len(raw) {
+				return nil, errors.New("invalid escape in binary data")
+			}
+			code, err := strconv.ParseUint(string(raw[i+1:i+5]), 16, 16)
+			if err != nil || code > 0xff {
+				return nil, errors.New("invalid escape in binary data")
+			}
+			data = append(data, byte(code))
+			i += 4
+		default:
+			return nil, errors.New("invalid escape in binary data")
+		}
+	}
+	return data, nil
 }
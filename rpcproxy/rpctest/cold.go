@@ -0,0 +1,291 @@
+package rpctest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+/*
+==================================
+冷签名：view-only钱包和离线的完整钱包
+==================================
+*/
+
+const (
+	errWrongKeyImage     = -10
+	errNotEnoughMoney    = -17
+	errBadHex            = -26
+	errWatchOnly         = -29
+	errBadUnsignedTxData = -39
+	errBadSignedTxData   = -40
+)
+
+// view-only钱包构造的交易的手续费
+const ColdSigningFee = 30740000
+
+// fixture钱包的输出金额，view-only钱包export_outputs导出这些输出
+var ColdOutputAmounts = []uint64{2000000000000, 1500000000000, 500000000000}
+
+type coldOutput struct {
+	Key    string `json:"key"`
+	Amount uint64 `json:"amount"`
+}
+
+type coldTransfer struct {
+	TxHash     string              `json:"tx_hash"`
+	KeyImages  []string            `json:"key_images"`
+	Recipients []map[string]uint64 `json:"recipients"`
+	AmountIn   uint64              `json:"amount_in"`
+	Fee        uint64              `json:"fee"`
+	Signed     bool                `json:"signed"`
+}
+
+func coldOutputs() []coldOutput {
+	outputs := make([]coldOutput, len(ColdOutputAmounts))
+	for i, amount := range ColdOutputAmounts {
+		outputs[i] = coldOutput{Key: hex.EncodeToString(sha256Sum([]byte(fmt.Sprintf("output %d", i)))), Amount: amount}
+	}
+	return outputs
+}
+
+// 只有持有私有spend key的钱包才能算出key image
+func coldKeyImage(output_key string) string {
+	return hex.EncodeToString(sha256Sum([]byte("key image " + WalletSpendKey + output_key)))
+}
+
+func coldKeyImageSignature(key_image string) string {
+	return hex.EncodeToString(sha256Sum([]byte("signature " + key_image)))
+}
+
+func encodeHexJson(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return hex.EncodeToString(data)
+}
+
+func decodeHexJson(data_hex string, value interface{}) bool {
+	data, err := hex.DecodeString(data_hex)
+	return err == nil && json.Unmarshal(data, value) == nil
+}
+
+/* ==== view-only钱包 ==== */
+
+// 把钱包变成view-only钱包：export_outputs导出ColdOutputAmounts，import_key_images校验完整钱包导出的key image，
+// transfer返回unsigned_txset（需要先导入key image），submit_transfer只接受sign_transfer签名后的交易，sign_transfer返回-29
+func (server *Server) EnableViewOnly() {
+	var lock sync.Mutex
+	outputs := coldOutputs()
+	exported := 0
+	known_key_images := map[string]bool{}
+
+	server.Handle("export_outputs", func(params json.RawMessage) Response {
+		request := struct {
+			All bool `json:"all"`
+		}{}
+		json.Unmarshal(params, &request)
+		lock.Lock()
+		defer lock.Unlock()
+		start := exported
+		if request.All {
+			start = 0
+		}
+		exported = len(outputs)
+		return Response{Result: map[string]interface{}{"outputs_data_hex": encodeHexJson(outputs[start:])}}
+	})
+	server.Handle("import_key_images", func(params json.RawMessage) Response {
+		request := struct {
+			SignedKeyImages []struct {
+				KeyImage  string `json:"key_image"`
+				Signature string `json:"signature"`
+			} `json:"signed_key_images"`
+			Offset int `json:"offset"`
+		}{}
+		json.Unmarshal(params, &request)
+		if request.Offset+len(request.SignedKeyImages) > len(outputs) {
+			return ErrorResponse(errWrongKeyImage, "offset larger than known outputs")
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		unspent := uint64(0)
+		for i, signed := range request.SignedKeyImages {
+			output := outputs[request.Offset+i]
+			if signed.KeyImage != coldKeyImage(output.Key) || signed.Signature != coldKeyImageSignature(signed.KeyImage) {
+				return ErrorResponse(errWrongKeyImage, fmt.Sprintf("Key image %d is invalid", request.Offset+i))
+			}
+			known_key_images[signed.KeyImage] = true
+			unspent += output.Amount
+		}
+		return Response{Result: map[string]interface{}{"height": DaemonHeight, "spent": 0, "unspent": unspent}}
+	})
+	server.Handle("transfer", func(params json.RawMessage) Response {
+		request := struct {
+			Destinations []struct {
+				Address string `json:"address"`
+				Amount  uint64 `json:"amount"`
+			} `json:"destinations"`
+		}{}
+		json.Unmarshal(params, &request)
+		lock.Lock()
+		defer lock.Unlock()
+		transfer := coldTransfer{Fee: ColdSigningFee}
+		amount := uint64(0)
+		for _, destination := range request.Destinations {
+			transfer.Recipients = append(transfer.Recipients, map[string]uint64{destination.Address: destination.Amount})
+			amount += destination.Amount
+		}
+		// 按顺序选择已知key image的输出，直到足够支付
+		for _, output := range outputs {
+			if transfer.AmountIn >= amount+transfer.Fee {
+				break
+			}
+			key_image := coldKeyImage(output.Key)
+			if known_key_images[key_image] {
+				transfer.KeyImages = append(transfer.KeyImages, key_image)
+				transfer.AmountIn += output.Amount
+			}
+		}
+		if transfer.AmountIn < amount+transfer.Fee {
+			return ErrorResponse(errNotEnoughMoney, "not enough money")
+		}
+		transfer.TxHash = hex.EncodeToString(sha256Sum([]byte(encodeHexJson(transfer))))
+		return Response{Result: map[string]interface{}{
+			"amount": amount, "fee": transfer.Fee, "tx_hash": "", "unsigned_txset": encodeHexJson(transfer),
+		}}
+	})
+	server.Handle("sign_transfer", func(params json.RawMessage) Response {
+		return ErrorResponse(errWatchOnly, "command not supported by watch-only wallet")
+	})
+	server.Handle("submit_transfer", func(params json.RawMessage) Response {
+		request := struct {
+			TxDataHex string `json:"tx_data_hex"`
+		}{}
+		json.Unmarshal(params, &request)
+		transfer := coldTransfer{}
+		if !decodeHexJson(request.TxDataHex, &transfer) || !transfer.Signed {
+			return ErrorResponse(errBadSignedTxData, "Failed to load signed tx data")
+		}
+		return Response{Result: map[string]interface{}{"tx_hash_list": []string{transfer.TxHash}}}
+	})
+}
+
+/* ==== 离线的完整钱包 ==== */
+
+// 把钱包变成离线的完整钱包：import_outputs导入view-only钱包导出的输出，export_key_images为这些输出算出key image，
+// describe_transfer和sign_transfer只接受花费已导入输出的unsigned_txset
+func (server *Server) EnableColdWallet() {
+	var lock sync.Mutex
+	imported := []coldOutput{}
+	exported := 0
+	key_images := map[string]coldOutput{}
+
+	server.Handle("import_outputs", func(params json.RawMessage) Response {
+		request := struct {
+			OutputsDataHex string `json:"outputs_data_hex"`
+		}{}
+		json.Unmarshal(params, &request)
+		outputs := []coldOutput{}
+		if !decodeHexJson(request.OutputsDataHex, &outputs) {
+			return ErrorResponse(errBadHex, "Failed to parse hex.")
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		num_imported := 0
+		for _, output := range outputs {
+			key_image := coldKeyImage(output.Key)
+			if _, ok := key_images[key_image]; !ok {
+				key_images[key_image] = output
+				imported = append(imported, output)
+				num_imported++
+			}
+		}
+		return Response{Result: map[string]interface{}{"num_imported": num_imported}}
+	})
+	server.Handle("export_key_images", func(params json.RawMessage) Response {
+		request := struct {
+			All bool `json:"all"`
+		}{}
+		json.Unmarshal(params, &request)
+		lock.Lock()
+		defer lock.Unlock()
+		start := exported
+		if request.All {
+			start = 0
+		}
+		exported = len(imported)
+		signed := []map[string]string{}
+		for _, output := range imported[start:] {
+			key_image := coldKeyImage(output.Key)
+			signed = append(signed, map[string]string{"key_image": key_image, "signature": coldKeyImageSignature(key_image)})
+		}
+		return Response{Result: map[string]interface{}{"offset": start, "signed_key_images": signed}}
+	})
+	// 解析unsigned_txset，检查花费的输出都已导入
+	load := func(unsigned_txset string) (coldTransfer, *Response) {
+		transfer := coldTransfer{}
+		if !decodeHexJson(unsigned_txset, &transfer) || transfer.Signed {
+			response := ErrorResponse(errBadUnsignedTxData, "cannot load unsigned_txset")
+			return transfer, &response
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		for _, key_image := range transfer.KeyImages {
+			if _, ok := key_images[key_image]; !ok {
+				response := ErrorResponse(errBadUnsignedTxData, "Failed to sign transaction: output not imported")
+				return transfer, &response
+			}
+		}
+		return transfer, nil
+	}
+	server.Handle("describe_transfer", func(params json.RawMessage) Response {
+		request := struct {
+			UnsignedTxset string `json:"unsigned_txset"`
+		}{}
+		json.Unmarshal(params, &request)
+		transfer, failed := load(request.UnsignedTxset)
+		if failed != nil {
+			return *failed
+		}
+		recipients := []map[string]interface{}{}
+		amount_out := uint64(0)
+		for _, recipient := range transfer.Recipients {
+			for address, amount := range recipient {
+				recipients = append(recipients, map[string]interface{}{"address": address, "amount": amount})
+				amount_out += amount
+			}
+		}
+		change := transfer.AmountIn - amount_out - transfer.Fee
+		description := map[string]interface{}{
+			"amount_in": transfer.AmountIn, "amount_out": amount_out + change, "recipients": recipients,
+			"change_address": WalletAddress, "change_amount": change, "fee": transfer.Fee, "ring_size": 16,
+		}
+		return Response{Result: map[string]interface{}{
+			"desc": []interface{}{description},
+			"summary": map[string]interface{}{
+				"amount_in": transfer.AmountIn, "amount_out": amount_out + change, "recipients": recipients,
+				"change_address": WalletAddress, "change_amount": change, "fee": transfer.Fee,
+			},
+		}}
+	})
+	server.Handle("sign_transfer", func(params json.RawMessage) Response {
+		request := struct {
+			UnsignedTxset string `json:"unsigned_txset"`
+			ExportRaw     bool   `json:"export_raw"`
+			GetTxKeys     bool   `json:"get_tx_keys"`
+		}{}
+		json.Unmarshal(params, &request)
+		transfer, failed := load(request.UnsignedTxset)
+		if failed != nil {
+			return *failed
+		}
+		transfer.Signed = true
+		result := map[string]interface{}{"signed_txset": encodeHexJson(transfer), "tx_hash_list": []string{transfer.TxHash}}
+		if request.GetTxKeys {
+			result["tx_key_list"] = []string{hex.EncodeToString(sha256Sum([]byte("tx key " + transfer.TxHash)))}
+		}
+		if request.ExportRaw {
+			result["tx_raw_list"] = []string{encodeHexJson(transfer)}
+		}
+		return Response{Result: result}
+	})
+}
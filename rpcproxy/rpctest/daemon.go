@@ -0,0 +1,84 @@
+package rpctest
+
+import (
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+//go:embed fixtures
+var fixtures embed.FS
+
+// fixture中的区块链：高度为DaemonHeight，最高区块为DaemonHeight-1
+const DaemonHeight = 2712345
+
+// 测试链上height处区块的哈希，在fixture之外的高度上是确定性的伪造值
+func BlockHash(height uint64) string {
+	if height == DaemonHeight-1 {
+		return "4d5bd5e4c7a5f2d4a0a1d1cb8cbbe02c1d8df7b73ec7b4b0e4a6a45a9ff1f3c2"
+	}
+	return hex.EncodeToString(sha256Sum([]byte(fmt.Sprintf("block %d", height))))
+}
+
+// 创建假monerod，加载fixtures/daemon：字段和格式与testnet的monerod一致，数值是手写的，不是录制的
+// 除fixture外，get_block_header_by_height和on_get_block_hash按请求的高度返回
+func CreateDaemon(t testing.TB, config Config) *Server {
+	server := CreateServer(t, config)
+	if err := server.LoadFixtures(fixtures, "fixtures/daemon"); err != nil {
+		fatalf(t, "load daemon fixtures: %v", err)
+	}
+	server.Handle("get_block_header_by_height", func(params json.RawMessage) Response {
+		request := struct {
+			Height uint64 `json:"height"`
+		}{}
+		json.Unmarshal(params, &request)
+		if request.Height >= DaemonHeight {
+			return tooBigHeight(request.Height)
+		}
+		return Response{Result: map[string]interface{}{
+			"block_header": blockHeader(request.Height),
+			"status":       "OK",
+			"untrusted":    false,
+		}}
+	})
+	server.Handle("on_get_block_hash", func(params json.RawMessage) Response {
+		request := []uint64{}
+		if err := json.Unmarshal(params, &request); err != nil || len(request) != 1 {
+			return ErrorResponse(-1, "Wrong parameters, expected height")
+		}
+		if request[0] >= DaemonHeight {
+			return tooBigHeight(request[0])
+		}
+		return Response{Result: BlockHash(request[0])}
+	})
+	return server
+}
+
+func tooBigHeight(height uint64) Response {
+	return ErrorResponse(-2, fmt.Sprintf("Requested block height: %d greater than current top block height: %d", height, DaemonHeight-1))
+}
+
+func blockHeader(height uint64) map[string]interface{} {
+	prev_hash := ""
+	if height > 0 {
+		prev_hash = BlockHash(height - 1)
+	}
+	return map[string]interface{}{
+		"block_size":      1845,
+		"block_weight":    1845,
+		"depth":           DaemonHeight - 1 - height,
+		"difficulty":      318774,
+		"hash":            BlockHash(height),
+		"height":          height,
+		"major_version":   16,
+		"minor_version":   16,
+		"num_txes":        1,
+		"orphan_status":   false,
+		"prev_hash":       prev_hash,
+		"reward":          600000000000,
+		"timestamp":       1760838071 - 120*(DaemonHeight-1-height),
+		"wide_difficulty": "0x4dd36",
+	}
+}
@@ -0,0 +1,59 @@
+package rpctest
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// 校验请求的digest认证，支持MD5和MD5-sess，qop为auth或不带qop
+func (server *Server) checkDigest(r *http.Request) bool {
+	scheme, rest, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Digest") {
+		return false
+	}
+	params := parseAuthParams(rest)
+	if params["username"] != server.config.User || params["realm"] != digestRealm || params["nonce"] != server.nonce {
+		return false
+	}
+	if params["uri"] != r.URL.RequestURI() {
+		return false
+	}
+	ha1 := md5Hex(server.config.User + ":" + digestRealm + ":" + server.config.Password)
+	if strings.EqualFold(params["algorithm"], "MD5-sess") {
+		ha1 = md5Hex(ha1 + ":" + server.nonce + ":" + params["cnonce"])
+	}
+	ha2 := md5Hex(r.Method + ":" + params["uri"])
+	var expected string
+	if params["qop"] == "auth" {
+		expected = md5Hex(ha1 + ":" + server.nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+	} else {
+		expected = md5Hex(ha1 + ":" + server.nonce + ":" + ha2)
+	}
+	return params["response"] == expected
+}
+
+func md5Hex(data string) string {
+	sum := md5.Sum([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// 解析 key=value, key="value" 形式的参数
+func parseAuthParams(data string) map[string]string {
+	params := map[string]string{}
+	for _, field := range strings.Split(data, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found {
+			continue
+		}
+		params[strings.ToLower(key)] = strings.Trim(value, `"`)
+	}
+	return params
+}
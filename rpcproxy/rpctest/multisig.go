@@ -0,0 +1,282 @@
+package rpctest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gomonero/monero/address"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+==================================
+多签钱包：模拟v0.18的密钥交换和签名
+==================================
+*/
+
+// monero-wallet-rpc的错误码
+const (
+	errAlreadyMultisig      = -28
+	errBadMultisigInfo      = -30
+	errNotMultisig          = -31
+	errThresholdNotReached  = -33
+	errBadMultisigTxData    = -34
+	errMultisigSignature    = -35
+	errGenericTransferError = -4
+)
+
+// 一个参与者的多签状态
+// info的格式为"MultisigxV2R<轮次>:<参与者标识>"，只用于检查轮次和参与者，不含真实的密钥
+type multisigWallet struct {
+	lock         sync.Mutex
+	id           string
+	round        int // 已完成的密钥交换轮数，make_multisig为第1轮
+	threshold    int
+	participants []string // 所有参与者的标识，已排序
+	synced       bool     // 已导入其他参与者的export_multisig_info
+	exports      int
+	transfers    int
+}
+
+// 多签交易集，hex编码后作为multisig_txset和tx_data_hex
+type multisigTxset struct {
+	TxHash  string   `json:"tx_hash"`
+	Signers []string `json:"signers"`
+}
+
+// 让钱包支持多签方法：prepare_multisig、make_multisig、exchange_multisig_keys、is_multisig、
+// export_multisig_info、import_multisig_info、sign_multisig、submit_multisig，
+// 以及多签钱包的transfer（返回multisig_txset）和get_address（返回多签地址）
+// 密钥交换共make_multisig加N-M+1轮exchange_multisig_keys；多签建立前is_multisig返回fixture
+func (server *Server) EnableMultisig() {
+	wallet := &multisigWallet{id: fmt.Sprintf("%04x", server.Port())}
+	fixture_is_multisig := server.handlerFor("is_multisig")
+	fixture_transfer := server.handlerFor("transfer")
+	fixture_get_address := server.handlerFor("get_address")
+
+	server.Handle("is_multisig", func(params json.RawMessage) Response {
+		wallet.lock.Lock()
+		defer wallet.lock.Unlock()
+		if wallet.round == 0 {
+			return fixture_is_multisig(params)
+		}
+		return Response{Result: map[string]interface{}{
+			"multisig": true, "kex_is_done": wallet.ready(), "ready": wallet.ready(),
+			"threshold": wallet.threshold, "total": len(wallet.participants),
+		}}
+	})
+	server.Handle("prepare_multisig", func(params json.RawMessage) Response {
+		wallet.lock.Lock()
+		defer wallet.lock.Unlock()
+		if wallet.round > 0 {
+			return ErrorResponse(errAlreadyMultisig, "This wallet is already multisig")
+		}
+		return Response{Result: map[string]interface{}{"multisig_info": wallet.info(1)}}
+	})
+	server.Handle("make_multisig", func(params json.RawMessage) Response {
+		request := struct {
+			MultisigInfo []string `json:"multisig_info"`
+			Threshold    int      `json:"threshold"`
+		}{}
+		json.Unmarshal(params, &request)
+		wallet.lock.Lock()
+		defer wallet.lock.Unlock()
+		if wallet.round > 0 {
+			return ErrorResponse(errAlreadyMultisig, "This wallet is already multisig")
+		}
+		total := len(request.MultisigInfo) + 1
+		if total < 2 || request.Threshold < 2 || request.Threshold > total {
+			return ErrorResponse(errBadMultisigInfo, fmt.Sprintf("Invalid threshold %d of %d", request.Threshold, total))
+		}
+		participants := []string{wallet.id}
+		for _, info := range request.MultisigInfo {
+			id, ok := parseMultisigInfo(info, 1)
+			if !ok || id == wallet.id {
+				return ErrorResponse(errBadMultisigInfo, "Invalid multisig info: "+info)
+			}
+			participants = append(participants, id)
+		}
+		sort.Strings(participants)
+		wallet.round, wallet.threshold, wallet.participants = 1, request.Threshold, participants
+		return Response{Result: map[string]interface{}{"address": "", "multisig_info": wallet.info(2)}}
+	})
+	server.Handle("exchange_multisig_keys", func(params json.RawMessage) Response {
+		request := struct {
+			MultisigInfo []string `json:"multisig_info"`
+		}{}
+		json.Unmarshal(params, &request)
+		wallet.lock.Lock()
+		defer wallet.lock.Unlock()
+		if wallet.round == 0 {
+			return ErrorResponse(errNotMultisig, "This wallet is not multisig")
+		}
+		if wallet.ready() {
+			return ErrorResponse(errAlreadyMultisig, "Multisig wallet is already finalized")
+		}
+		if err := wallet.checkOthers(request.MultisigInfo, func(info string) (string, bool) {
+			return parseMultisigInfo(info, wallet.round+1)
+		}); err != "" {
+			return ErrorResponse(errBadMultisigInfo, err)
+		}
+		wallet.round++
+		if wallet.ready() {
+			return Response{Result: map[string]interface{}{"address": wallet.address(), "multisig_info": ""}}
+		}
+		return Response{Result: map[string]interface{}{"address": "", "multisig_info": wallet.info(wallet.round + 1)}}
+	})
+	server.Handle("get_address", func(params json.RawMessage) Response {
+		wallet.lock.Lock()
+		ready := wallet.ready()
+		wallet.lock.Unlock()
+		if !ready {
+			return fixture_get_address(params)
+		}
+		multisig_address := wallet.address()
+		return Response{Result: map[string]interface{}{
+			"address":   multisig_address,
+			"addresses": []map[string]interface{}{{"address": multisig_address, "address_index": 0, "label": "Primary account", "used": true}},
+		}}
+	})
+
+	/* ==== 花费 ==== */
+
+	server.Handle("export_multisig_info", func(params json.RawMessage) Response {
+		wallet.lock.Lock()
+		defer wallet.lock.Unlock()
+		if !wallet.ready() {
+			return ErrorResponse(errNotMultisig, "This wallet is not multisig")
+		}
+		wallet.exports++
+		return Response{Result: map[string]interface{}{"info": fmt.Sprintf("MultisigInfo:%s:%d", wallet.id, wallet.exports)}}
+	})
+	server.Handle("import_multisig_info", func(params json.RawMessage) Response {
+		request := struct {
+			Info []string `json:"info"`
+		}{}
+		json.Unmarshal(params, &request)
+		wallet.lock.Lock()
+		defer wallet.lock.Unlock()
+		if !wallet.ready() {
+			return ErrorResponse(errNotMultisig, "This wallet is not multisig")
+		}
+		if err := wallet.checkOthers(request.Info, func(info string) (string, bool) {
+			fields := strings.Split(info, ":")
+			if len(fields) != 3 || fields[0] != "MultisigInfo" {
+				return "", false
+			}
+			return fields[1], true
+		}); err != "" {
+			return ErrorResponse(errBadMultisigInfo, err)
+		}
+		wallet.synced = true
+		return Response{Result: map[string]interface{}{"n_outputs": len(request.Info)}}
+	})
+	server.Handle("transfer", func(params json.RawMessage) Response {
+		wallet.lock.Lock()
+		defer wallet.lock.Unlock()
+		if !wallet.ready() {
+			return fixture_transfer(params)
+		}
+		if !wallet.synced {
+			return ErrorResponse(errGenericTransferError, "Multisig info not yet imported, call import_multisig_info first")
+		}
+		wallet.transfers++
+		tx_hash := hex.EncodeToString(sha256Sum([]byte(fmt.Sprintf("multisig tx %s %d", wallet.id, wallet.transfers))))
+		return Response{Result: map[string]interface{}{
+			"amount": 0, "fee": 30740000, "tx_hash": tx_hash,
+			"multisig_txset": encodeHexJson(multisigTxset{TxHash: tx_hash, Signers: []string{wallet.id}}),
+		}}
+	})
+	server.Handle("sign_multisig", func(params json.RawMessage) Response {
+		request := struct {
+			TxDataHex string `json:"tx_data_hex"`
+		}{}
+		json.Unmarshal(params, &request)
+		wallet.lock.Lock()
+		defer wallet.lock.Unlock()
+		if !wallet.ready() {
+			return ErrorResponse(errNotMultisig, "This wallet is not multisig")
+		}
+		txset, ok := decodeMultisigTxset(request.TxDataHex)
+		if !ok {
+			return ErrorResponse(errBadMultisigTxData, "Failed to parse multisig tx data")
+		}
+		for _, signer := range txset.Signers {
+			if signer == wallet.id {
+				return ErrorResponse(errMultisigSignature, "Failed to sign multisig tx: already signed by this wallet")
+			}
+		}
+		txset.Signers = append(txset.Signers, wallet.id)
+		tx_hash_list := []string{}
+		if len(txset.Signers) >= wallet.threshold {
+			tx_hash_list = append(tx_hash_list, txset.TxHash)
+		}
+		return Response{Result: map[string]interface{}{"tx_data_hex": encodeHexJson(txset), "tx_hash_list": tx_hash_list}}
+	})
+	server.Handle("submit_multisig", func(params json.RawMessage) Response {
+		request := struct {
+			TxDataHex string `json:"tx_data_hex"`
+		}{}
+		json.Unmarshal(params, &request)
+		wallet.lock.Lock()
+		defer wallet.lock.Unlock()
+		if !wallet.ready() {
+			return ErrorResponse(errNotMultisig, "This wallet is not multisig")
+		}
+		txset, ok := decodeMultisigTxset(request.TxDataHex)
+		if !ok {
+			return ErrorResponse(errBadMultisigTxData, "Failed to parse multisig tx data")
+		}
+		if len(txset.Signers) < wallet.threshold {
+			return ErrorResponse(errThresholdNotReached, "Not enough signers signed this transaction.")
+		}
+		return Response{Result: map[string]interface{}{"tx_hash_list": []string{txset.TxHash}}}
+	})
+}
+
+// make_multisig之后还需要N-M+1轮exchange_multisig_keys
+func (wallet *multisigWallet) ready() bool {
+	return wallet.round > 0 && wallet.round == len(wallet.participants)-wallet.threshold+2
+}
+
+func (wallet *multisigWallet) info(round int) string {
+	return fmt.Sprintf("MultisigxV2R%d:%s", round, wallet.id)
+}
+
+// 多签地址由所有参与者决定，各参与者得到相同的地址
+func (wallet *multisigWallet) address() string {
+	seed := strings.Join(wallet.participants, ",")
+	multisig_address := address.Address{Network: address.Testnet, Type: address.Standard}
+	copy(multisig_address.SpendKey[:], sha256Sum([]byte("spend "+seed)))
+	copy(multisig_address.ViewKey[:], sha256Sum([]byte("view "+seed)))
+	return multisig_address.String()
+}
+
+// infos必须来自其他每个参与者各一个，parse返回info所属的参与者
+func (wallet *multisigWallet) checkOthers(infos []string, parse func(string) (string, bool)) string {
+	if len(infos) != len(wallet.participants)-1 {
+		return fmt.Sprintf("Expected %d multisig infos, got %d", len(wallet.participants)-1, len(infos))
+	}
+	seen := map[string]bool{wallet.id: true}
+	for _, info := range infos {
+		id, ok := parse(info)
+		index := sort.SearchStrings(wallet.participants, id)
+		if !ok || seen[id] || index == len(wallet.participants) || wallet.participants[index] != id {
+			return "Invalid multisig info: " + info
+		}
+		seen[id] = true
+	}
+	return ""
+}
+
+func parseMultisigInfo(info string, round int) (string, bool) {
+	id, ok := strings.CutPrefix(info, fmt.Sprintf("MultisigxV2R%d:", round))
+	return id, ok && id != ""
+}
+
+func decodeMultisigTxset(tx_data_hex string) (multisigTxset, bool) {
+	txset := multisigTxset{}
+	return txset, decodeHexJson(tx_data_hex, &txset) && txset.TxHash != ""
+}
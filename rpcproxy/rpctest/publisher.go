@@ -0,0 +1,173 @@
+package rpctest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"gomonero/zmtp"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+========================
+假ZMQ发布者：模拟monerod的--zmq-pub
+========================
+*/
+
+type Publisher struct {
+	listener net.Listener
+
+	lock        sync.Mutex
+	subscribers map[*zmtp.Conn][][]byte // 连接 -> 订阅的主题前缀
+	changed     chan struct{}           // 订阅变化时关闭并替换
+}
+
+// 在随机端口上监听，测试结束时自动关闭
+func CreatePublisher(t testing.TB) *Publisher {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fatalf(t, "listen for zmq subscribers: %v", err)
+	}
+	publisher := &Publisher{
+		listener:    listener,
+		subscribers: map[*zmtp.Conn][][]byte{},
+		changed:     make(chan struct{}),
+	}
+	go publisher.accept()
+	if t != nil {
+		t.Cleanup(publisher.Close)
+	}
+	return publisher
+}
+
+// 订阅者使用的地址，如tcp://127.0.0.1:18083
+func (publisher *Publisher) Endpoint() string {
+	return "tcp://" + publisher.listener.Addr().String()
+}
+
+// 关闭监听和所有订阅者的连接
+func (publisher *Publisher) Close() {
+	publisher.listener.Close()
+	publisher.lock.Lock()
+	defer publisher.lock.Unlock()
+	for conn := range publisher.subscribers {
+		conn.Close()
+	}
+}
+
+// 像monerod一样以"topic:json"单帧发布，返回收到消息的订阅者数
+func (publisher *Publisher) Publish(topic string, payload interface{}) (int, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	message := append([]byte(topic+":"), data...)
+	publisher.lock.Lock()
+	defer publisher.lock.Unlock()
+	count := 0
+	for conn, topics := range publisher.subscribers {
+		if !matchTopic(topics, message) {
+			continue
+		}
+		if err := conn.WriteMessage(message); err != nil {
+			conn.Close()
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// 等待至少有一个订阅者订阅了topic，避免发布早于订阅而丢失消息
+func (publisher *Publisher) WaitSubscribed(ctx context.Context, topic string) error {
+	for {
+		publisher.lock.Lock()
+		changed := publisher.changed
+		for _, topics := range publisher.subscribers {
+			if matchTopic(topics, []byte(topic)) {
+				publisher.lock.Unlock()
+				return nil
+			}
+		}
+		publisher.lock.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func matchTopic(topics [][]byte, message []byte) bool {
+	for _, topic := range topics {
+		if bytes.HasPrefix(message, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func (publisher *Publisher) accept() {
+	for {
+		conn, err := publisher.listener.Accept()
+		if err != nil {
+			return
+		}
+		go publisher.serve(conn)
+	}
+}
+
+func (publisher *Publisher) serve(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	zmtp_conn, err := zmtp.Handshake(conn, zmtp.SocketPub, true)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	publisher.update(func() {
+		publisher.subscribers[zmtp_conn] = nil
+	})
+	defer publisher.update(func() {
+		delete(publisher.subscribers, zmtp_conn)
+	})
+	defer zmtp_conn.Close()
+
+	// 订阅消息：0x01 + topic，取消订阅：0x00 + topic
+	for {
+		frames, err := zmtp_conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if len(frames) != 1 || len(frames[0]) == 0 {
+			continue
+		}
+		topic := frames[0][1:]
+		publisher.update(func() {
+			topics := publisher.subscribers[zmtp_conn]
+			switch frames[0][0] {
+			case 0x01:
+				topics = append(topics, topic)
+			case 0x00:
+				for i := range topics {
+					if bytes.Equal(topics[i], topic) {
+						topics = append(topics[:i], topics[i+1:]...)
+						break
+					}
+				}
+			}
+			publisher.subscribers[zmtp_conn] = topics
+		})
+	}
+}
+
+func (publisher *Publisher) update(change func()) {
+	publisher.lock.Lock()
+	defer publisher.lock.Unlock()
+	change()
+	close(publisher.changed)
+	publisher.changed = make(chan struct{})
+}
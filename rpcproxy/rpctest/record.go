@@ -0,0 +1,41 @@
+package rpctest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"gomonero/rpcproxy"
+	"os"
+	"path/filepath"
+)
+
+// 录制fixture：对真实的monerod/wallet-rpc调用calls中的方法（方法名 -> params），把result写入dir/方法名.json
+// 方法名以/开头时按路径接口调用，如"/get_height"
+func RecordFixtures(ctx context.Context, rpc_url string, rpc_user string, rpc_password string, calls map[string]interface{}, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for method, params := range calls {
+		result := json.RawMessage{}
+		var err error
+		name := method
+		if method != "" && method[0] == '/' {
+			name = method[1:]
+			err = rpcproxy.OtherMethodRequest(ctx, rpc_url, name, params, rpc_user, rpc_password, &result)
+		} else {
+			err = rpcproxy.JsonMethodRequest(ctx, rpc_url+"/json_rpc", method, params, rpc_user, rpc_password, &result)
+		}
+		if err != nil {
+			return err
+		}
+		indented := bytes.Buffer{}
+		if err := json.Indent(&indented, result, "", "  "); err != nil {
+			return err
+		}
+		indented.WriteByte('\n')
+		if err := os.WriteFile(filepath.Join(dir, name+".json"), indented.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,529 @@
+// rpctest提供基于httptest的假monerod和假monero-wallet-rpc，以及假的ZMQ发布者，用于不依赖网络的测试
+//
+// CreateDaemon和CreateWallet加载fixtures目录中手写的fixture，可以用Handle、Script等替换或注入响应；
+// 钱包的multisig和冷签名流程由EnableMultisig、EnableViewOnly和EnableColdWallet模拟
+package rpctest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gomonero/levin"
+	"gomonero/rpcproxy"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+==================================
+假RPC服务器：脚本响应、错误注入、fixture
+==================================
+*/
+
+// 一次调用的响应
+// JSON-RPC方法的Result写入result字段，Error写入error字段；其他接口的Result直接作为body
+// Result为[]byte或json.RawMessage时原样写入，其他类型序列化为JSON
+// .bin接口的Result为[]byte时原样写入（用于构造截断或无效的body），为map时编码为portable storage
+type Response struct {
+	Result     interface{}
+	Error      *rpcproxy.RPCError
+	HTTPStatus int           // 不为0时只返回这个HTTP状态码
+	Delay      time.Duration // 返回响应前等待，客户端取消时提前结束
+	Drop       bool          // 不返回响应，直接断开连接
+}
+
+// 返回JSON-RPC error对象
+func ErrorResponse(code int, message string) Response {
+	return Response{Error: &rpcproxy.RPCError{Code: code, Message: message}}
+}
+
+// 返回status不为OK的结果，如BUSY
+func StatusResponse(status string) Response {
+	return Response{Result: map[string]interface{}{"status": status, "untrusted": false}}
+}
+
+// 只返回HTTP状态码
+func HTTPErrorResponse(status int) Response {
+	return Response{HTTPStatus: status}
+}
+
+// 处理一次调用，params为请求的params（JSON-RPC）或body（其他接口）
+type HandlerFunc func(params json.RawMessage) Response
+
+// 处理一次.bin调用
+type BinaryHandlerFunc func(params map[string]interface{}) (map[string]interface{}, error)
+
+// 服务器收到的一次调用
+type Request struct {
+	Path        string // /json_rpc、/get_height、/get_blocks.bin等
+	Method      string // JSON-RPC的method，或去掉/的路径名
+	Params      json.RawMessage
+	ContentType string
+}
+
+type Config struct {
+	User     string // 为空时不认证
+	Password string
+	// monerod不支持JSON-RPC批量请求，默认对数组请求返回-32600
+	SupportBatch bool
+	TLS          bool
+}
+
+const digestRealm = "monero-rpc"
+
+type Server struct {
+	server *httptest.Server
+	config Config
+	nonce  string
+
+	lock            sync.Mutex
+	handlers        map[string]HandlerFunc
+	binary_handlers map[string]BinaryHandlerFunc
+	scripts         map[string][]Response
+	fixtures        map[string]json.RawMessage
+	requests        []Request
+	unauthorized    int
+}
+
+// 初始化失败时结束测试；t为nil时（在测试之外使用）只能panic
+func fatalf(t testing.TB, format string, args ...interface{}) {
+	if t == nil {
+		panic(fmt.Sprintf(format, args...))
+	}
+	t.Helper()
+	t.Fatalf(format, args...)
+}
+
+// 创建空的假服务器，测试结束时自动关闭
+func CreateServer(t testing.TB, config Config) *Server {
+	server := &Server{
+		config:          config,
+		nonce:           strconv.FormatInt(time.Now().UnixNano(), 16),
+		handlers:        map[string]HandlerFunc{},
+		binary_handlers: map[string]BinaryHandlerFunc{},
+		scripts:         map[string][]Response{},
+		fixtures:        map[string]json.RawMessage{},
+	}
+	if config.TLS {
+		server.server = httptest.NewTLSServer(http.HandlerFunc(server.serveHTTP))
+	} else {
+		server.server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	}
+	if t != nil {
+		t.Cleanup(server.Close)
+	}
+	return server
+}
+
+func (server *Server) Close() {
+	server.server.Close()
+}
+
+func (server *Server) URL() string {
+	return server.server.URL
+}
+
+func (server *Server) Host() string {
+	server_url, _ := url.Parse(server.server.URL)
+	host, _, _ := net.SplitHostPort(server_url.Host)
+	return host
+}
+
+func (server *Server) Port() uint16 {
+	server_url, _ := url.Parse(server.server.URL)
+	_, port, _ := net.SplitHostPort(server_url.Host)
+	value, _ := strconv.ParseUint(port, 10, 16)
+	return uint16(value)
+}
+
+// TLS服务器证书的SHA-256指纹，用于TLSConfig.AllowedFingerprints
+func (server *Server) Fingerprint() []byte {
+	certificate := server.server.Certificate()
+	if certificate == nil {
+		return nil
+	}
+	return sha256Sum(certificate.Raw)
+}
+
+// 连接这个服务器的DaemonRPCProxy，使用服务器的用户名和密码
+func (server *Server) DaemonProxy(config rpcproxy.ProxyConfig) *rpcproxy.DaemonRPCProxy {
+	server.fillTLSConfig(&config)
+	proxy := rpcproxy.CreateDaemonRPCProxyWithConfig(server.Host(), server.Port(), server.config.User, server.config.Password, config)
+	return &proxy
+}
+
+// 连接这个服务器的WalletRPCProxy，使用服务器的用户名和密码
+func (server *Server) WalletProxy(config rpcproxy.ProxyConfig) *rpcproxy.WalletRPCProxy {
+	server.fillTLSConfig(&config)
+	proxy := rpcproxy.CreateWalletRPCProxyWithConfig(server.Host(), server.Port(), server.config.User, server.config.Password, config)
+	return &proxy
+}
+
+func (server *Server) fillTLSConfig(config *rpcproxy.ProxyConfig) {
+	if server.config.TLS && config.TLS == nil {
+		config.TLS = &rpcproxy.TLSConfig{AllowedFingerprints: []string{fmt.Sprintf("%x", server.Fingerprint())}}
+	}
+}
+
+/* ==== 设置响应 ==== */
+
+// 设置method的处理函数，method为JSON-RPC方法名或路径名
+func (server *Server) Handle(method string, handler HandlerFunc) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.handlers[method] = handler
+}
+
+// method当前的处理函数或fixture，用于在替换处理函数后仍能回到原来的响应
+func (server *Server) handlerFor(method string) HandlerFunc {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if handler, ok := server.handlers[method]; ok {
+		return handler
+	}
+	if fixture, ok := server.fixtures[method]; ok {
+		return func(json.RawMessage) Response {
+			return Response{Result: fixture}
+		}
+	}
+	return func(json.RawMessage) Response {
+		return ErrorResponse(-32601, "Method not found")
+	}
+}
+
+// method总是返回result
+func (server *Server) SetResult(method string, result interface{}) {
+	server.Handle(method, func(json.RawMessage) Response {
+		return Response{Result: result}
+	})
+}
+
+// 设置.bin接口的处理函数，method如get_blocks.bin
+func (server *Server) HandleBinary(method string, handler BinaryHandlerFunc) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.binary_handlers[method] = handler
+}
+
+// 接下来对method的调用依次返回responses，用完后回到Handle/HandleBinary/fixture的响应
+// 用于注入错误，如Script("get_info", StatusResponse("BUSY"), HTTPErrorResponse(500))
+func (server *Server) Script(method string, responses ...Response) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.scripts[method] = append(server.scripts[method], responses...)
+}
+
+// 从fsys的dir目录读取fixture，文件名（去掉.json）为方法名，内容为result
+func (server *Server) LoadFixtures(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if !json.Valid(data) {
+			return fmt.Errorf("fixture %s is not valid json", entry.Name())
+		}
+		server.fixtures[strings.TrimSuffix(entry.Name(), ".json")] = json.RawMessage(data)
+	}
+	return nil
+}
+
+// 设置一个fixture
+func (server *Server) SetFixture(method string, result json.RawMessage) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.fixtures[method] = result
+}
+
+/* ==== 检查请求 ==== */
+
+// 收到的所有调用
+func (server *Server) Requests() []Request {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	return append([]Request{}, server.requests...)
+}
+
+// 因未认证返回401的次数
+func (server *Server) Unauthorized() int {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	return server.unauthorized
+}
+
+// method被调用的次数
+func (server *Server) Calls(method string) int {
+	count := 0
+	for _, request := range server.Requests() {
+		if request.Method == method {
+			count++
+		}
+	}
+	return count
+}
+
+// method最后一次调用的params，解析到params中
+func (server *Server) LastParams(method string, params interface{}) error {
+	requests := server.Requests()
+	for i := len(requests) - 1; i >= 0; i-- {
+		if requests[i].Method == method {
+			return json.Unmarshal(requests[i].Params, params)
+		}
+	}
+	return fmt.Errorf("method %s was not called", method)
+}
+
+/* ==== 处理请求 ==== */
+
+func (server *Server) record(request Request) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.requests = append(server.requests, request)
+}
+
+// 取出method的下一个脚本响应
+func (server *Server) popScript(method string) (Response, bool) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	script := server.scripts[method]
+	if len(script) == 0 {
+		return Response{}, false
+	}
+	server.scripts[method] = script[1:]
+	return script[0], true
+}
+
+// 按脚本、处理函数、fixture的顺序找到method的响应
+func (server *Server) respond(method string, params json.RawMessage) (Response, bool) {
+	if response, ok := server.popScript(method); ok {
+		return response, true
+	}
+	server.lock.Lock()
+	handler, ok := server.handlers[method]
+	fixture, has_fixture := server.fixtures[method]
+	server.lock.Unlock()
+	if ok {
+		return handler(params), true
+	}
+	if has_fixture {
+		return Response{Result: fixture}, true
+	}
+	return Response{}, false
+}
+
+func (server *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if server.config.User != "" && !server.checkDigest(r) {
+		server.lock.Lock()
+		server.unauthorized++
+		server.lock.Unlock()
+		w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Digest qop="auth",algorithm=MD5,realm="%s",nonce="%s",stale=false`, digestRealm, server.nonce))
+		w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Digest qop="auth",algorithm=MD5-sess,realm="%s",nonce="%s",stale=false`, digestRealm, server.nonce))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	method := strings.TrimPrefix(r.URL.Path, "/")
+	switch {
+	case r.URL.Path == "/json_rpc":
+		server.serveJsonRPC(w, r, body)
+	case strings.HasSuffix(method, ".bin"):
+		server.serveBinary(w, r, method, body)
+	default:
+		server.record(Request{Path: r.URL.Path, Method: method, Params: body, ContentType: r.Header.Get("Content-Type")})
+		response, ok := server.respond(method, body)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		server.write(w, r, response, func() interface{} {
+			return response.Result
+		})
+	}
+}
+
+type jsonRPCRequest struct {
+	Id     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+func (server *Server) serveJsonRPC(w http.ResponseWriter, r *http.Request, body []byte) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		server.serveJsonBatch(w, r, body)
+		return
+	}
+	request := jsonRPCRequest{}
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeJson(w, jsonRPCEnvelope(json.RawMessage("0"), Response{Error: &rpcproxy.RPCError{Code: -32700, Message: "Parse error"}}))
+		return
+	}
+	server.record(Request{Path: r.URL.Path, Method: request.Method, Params: request.Params, ContentType: r.Header.Get("Content-Type")})
+	response, ok := server.respond(request.Method, request.Params)
+	if !ok {
+		response = ErrorResponse(-32601, "Method not found")
+	}
+	server.write(w, r, response, func() interface{} {
+		return jsonRPCEnvelope(request.Id, response)
+	})
+}
+
+// 批量请求：不支持时返回-32600，支持时对各调用分别响应（忽略各调用的HTTPStatus、Delay和Drop）
+func (server *Server) serveJsonBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	if !server.config.SupportBatch {
+		server.record(Request{Path: r.URL.Path, Params: body})
+		writeJson(w, jsonRPCEnvelope(json.RawMessage("0"), Response{Error: &rpcproxy.RPCError{Code: -32600, Message: "Invalid Request"}}))
+		return
+	}
+	requests := []jsonRPCRequest{}
+	if err := json.Unmarshal(body, &requests); err != nil {
+		writeJson(w, jsonRPCEnvelope(json.RawMessage("0"), Response{Error: &rpcproxy.RPCError{Code: -32700, Message: "Parse error"}}))
+		return
+	}
+	envelopes := make([]interface{}, len(requests))
+	for i, request := range requests {
+		server.record(Request{Path: r.URL.Path, Method: request.Method, Params: request.Params})
+		response, ok := server.respond(request.Method, request.Params)
+		if !ok {
+			response = ErrorResponse(-32601, "Method not found")
+		}
+		envelopes[i] = jsonRPCEnvelope(request.Id, response)
+	}
+	writeJson(w, envelopes)
+}
+
+func jsonRPCEnvelope(id json.RawMessage, response Response) interface{} {
+	if len(id) == 0 {
+		id = json.RawMessage(`"0"`)
+	}
+	envelope := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+	}
+	if response.Error != nil {
+		envelope["error"] = response.Error
+	} else {
+		envelope["result"] = resultJson(response.Result)
+	}
+	return envelope
+}
+
+func resultJson(result interface{}) interface{} {
+	switch value := result.(type) {
+	case []byte:
+		return json.RawMessage(value)
+	case nil:
+		return map[string]interface{}{}
+	}
+	return result
+}
+
+// 处理Delay、Drop和HTTPStatus后写入body()的JSON
+func (server *Server) write(w http.ResponseWriter, r *http.Request, response Response, body func() interface{}) {
+	if server.intercept(w, r, response) {
+		return
+	}
+	writeJson(w, resultJson(body()))
+}
+
+// 处理Delay、Drop和HTTPStatus，返回true时响应已经结束
+func (server *Server) intercept(w http.ResponseWriter, r *http.Request, response Response) bool {
+	if response.Delay > 0 {
+		select {
+		case <-time.After(response.Delay):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	if response.Drop {
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				conn.Close()
+				return true
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+	if response.HTTPStatus != 0 {
+		w.WriteHeader(response.HTTPStatus)
+		return true
+	}
+	return false
+}
+
+func writeJson(w http.ResponseWriter, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (server *Server) serveBinary(w http.ResponseWriter, r *http.Request, method string, body []byte) {
+	server.record(Request{Path: "/" + method, Method: method, ContentType: r.Header.Get("Content-Type")})
+	if response, ok := server.popScript(method); ok {
+		if server.intercept(w, r, response) {
+			return
+		}
+		data, ok := response.Result.([]byte)
+		if !ok {
+			section, _ := response.Result.(map[string]interface{})
+			var err error
+			if data, err = levin.EncodePortableStorage(section); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+		return
+	}
+	server.lock.Lock()
+	handler, ok := server.binary_handlers[method]
+	server.lock.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	params, err := levin.DecodePortableStorage(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	response, err := handler(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data, err := levin.EncodePortableStorage(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
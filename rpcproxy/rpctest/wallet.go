@@ -0,0 +1,148 @@
+package rpctest
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// fixture钱包的密钥和地址，使用testnet的地址格式；fixtures/wallet中的余额、转账等数据是手写的
+const (
+	WalletAddress        = "A14oDUCJgEZKzr6T195M1mUrSoD22LGum8jHPfsHA5MtbEuQuC33TDqbyfwtQcFJ1d1DvXtb1RAAZesepCP7xTn6EKqQezR"
+	WalletSubaddress01   = "BcBV4fm9Je7bMv5un7UDgvKM65PQn6ztUdMnE5amHFf4YEpZeWy2cpY4yxA61Rx3hTPvQeLWqhcu5BqcnzbGEsFaH5ztS7j"
+	WalletSubaddress10   = "BbwbRRs8rUuiBeNoMu7iMG5rNyxmLbnMCLneHpwofaYvPzzUzoenu4KgbJT8fRga3GSP2ZPoS7M4giaPauSkdZKZJjk1jeu"
+	WalletIntegrated     = "AAmUEH1oHW5Kzr6T195M1mUrSoD22LGum8jHPfsHA5MtbEuQuC33TDqbyfwtQcFJ1d1DvXtb1RAAZesepCP7xTn6LkSPMZ8ZKHvU1kQTKk"
+	WalletPaymentId      = "1234567890abcdef"
+	WalletPublicSpendKey = "d071848d3fccc67193e76aa913121ca684e9e285b511b82e356f93c2e63373cc"
+	WalletPublicViewKey  = "b4b5defe15b960d11d4860e50ffc080155041420e3b22ee26e0265768e87d776"
+	WalletSpendKey       = "63e61fe2ff08a6fb4047b007a4632c94545c3465f595277f4ad4d249b7996c0c"
+	WalletViewKey        = "ed97c25bc1dc438abee9649447bcfd72381af615094cfb030c8188c89cd64307"
+	WalletSeed           = "flippant drying behind unplugs skydive serving moon getting habitat northern tender invoke oaks speedy dinner ashtray voyage jobs budget null tudor efficient avidly buckets habitat"
+)
+
+// fixture钱包认识的地址，validate_address对其他地址返回valid=false
+var knownAddresses = map[string]map[string]interface{}{
+	WalletAddress:      {"valid": true, "integrated": false, "subaddress": false, "nettype": "testnet"},
+	WalletSubaddress01: {"valid": true, "integrated": false, "subaddress": true, "nettype": "testnet"},
+	WalletSubaddress10: {"valid": true, "integrated": false, "subaddress": true, "nettype": "testnet"},
+	WalletIntegrated:   {"valid": true, "integrated": true, "subaddress": false, "nettype": "testnet"},
+	"9yZhA4eVVjBd6ihbdTTifB2BxDn2UiLKuY79Y13VdxDt7kRzpNkV3HS3XvjcbFEsz2hqUF7dzUSthN6Ea2wF6mpPVbXzsiX": {
+		"valid": true, "integrated": false, "subaddress": false, "nettype": "testnet",
+	},
+}
+
+// fixture钱包每个账户下的地址，按address_index排列
+var accountAddresses = [][]string{
+	{WalletAddress, WalletSubaddress01},
+	{WalletSubaddress10},
+}
+
+// 创建假monero-wallet-rpc，加载fixture钱包
+// 钱包管理类方法（create_wallet、open_wallet、store等）总是成功
+// multisig和冷签名的方法默认只有fixture，需要时用EnableMultisig、EnableViewOnly或EnableColdWallet模拟
+func CreateWallet(t testing.TB, config Config) *Server {
+	server := CreateServer(t, config)
+	if err := server.LoadFixtures(fixtures, "fixtures/wallet"); err != nil {
+		fatalf(t, "load wallet fixtures: %v", err)
+	}
+	for _, method := range []string{
+		"create_wallet", "open_wallet", "close_wallet", "store", "label_address",
+		"rescan_blockchain", "change_wallet_password",
+	} {
+		server.SetResult(method, map[string]interface{}{})
+	}
+	server.Handle("query_key", func(params json.RawMessage) Response {
+		request := struct {
+			KeyType string `json:"key_type"`
+		}{}
+		json.Unmarshal(params, &request)
+		switch request.KeyType {
+		case "view_key":
+			return Response{Result: map[string]string{"key": WalletViewKey}}
+		case "spend_key":
+			return Response{Result: map[string]string{"key": WalletSpendKey}}
+		case "mnemonic":
+			return Response{Result: map[string]string{"key": WalletSeed}}
+		}
+		return ErrorResponse(-29, "key_type "+request.KeyType+" not found")
+	})
+	// 只能恢复fixture钱包
+	server.Handle("restore_deterministic_wallet", func(params json.RawMessage) Response {
+		request := struct {
+			Seed string `json:"seed"`
+		}{}
+		json.Unmarshal(params, &request)
+		if request.Seed != WalletSeed {
+			return ErrorResponse(-1, "Electrum-style word list failed verification")
+		}
+		return Response{Result: map[string]interface{}{
+			"address": WalletAddress, "info": "Wallet has been restored successfully.", "seed": WalletSeed, "was_deprecated": false,
+		}}
+	})
+	server.Handle("get_address", func(params json.RawMessage) Response {
+		request := struct {
+			AccountIndex uint32   `json:"account_index"`
+			AddressIndex []uint32 `json:"address_index"`
+		}{}
+		json.Unmarshal(params, &request)
+		if int(request.AccountIndex) >= len(accountAddresses) {
+			return ErrorResponse(-15, "account index is out of bound")
+		}
+		addresses := accountAddresses[request.AccountIndex]
+		indices := request.AddressIndex
+		if len(indices) == 0 {
+			for i := range addresses {
+				indices = append(indices, uint32(i))
+			}
+		}
+		infos := []map[string]interface{}{}
+		for _, index := range indices {
+			if int(index) >= len(addresses) {
+				return ErrorResponse(-16, "address index is out of bound")
+			}
+			label := ""
+			if request.AccountIndex == 0 && index == 0 {
+				label = "Primary account"
+			}
+			infos = append(infos, map[string]interface{}{
+				"address": addresses[index], "address_index": index, "label": label, "used": true,
+			})
+		}
+		return Response{Result: map[string]interface{}{"address": addresses[0], "addresses": infos}}
+	})
+	// 只认识fixture钱包的主地址和WalletPaymentId
+	server.Handle("make_integrated_address", func(params json.RawMessage) Response {
+		request := struct {
+			StandardAddress string `json:"standard_address"`
+			PaymentId       string `json:"payment_id"`
+		}{}
+		json.Unmarshal(params, &request)
+		if request.StandardAddress != "" && request.StandardAddress != WalletAddress {
+			return ErrorResponse(-2, "Invalid address")
+		}
+		if request.PaymentId != "" && request.PaymentId != WalletPaymentId {
+			return ErrorResponse(-5, "Invalid payment ID")
+		}
+		return Response{Result: map[string]interface{}{"integrated_address": WalletIntegrated, "payment_id": WalletPaymentId}}
+	})
+	server.Handle("split_integrated_address", func(params json.RawMessage) Response {
+		request := struct {
+			IntegratedAddress string `json:"integrated_address"`
+		}{}
+		json.Unmarshal(params, &request)
+		if request.IntegratedAddress != WalletIntegrated {
+			return ErrorResponse(-2, "Invalid address")
+		}
+		return Response{Result: map[string]interface{}{"is_subaddress": false, "payment_id": WalletPaymentId, "standard_address": WalletAddress}}
+	})
+	server.Handle("validate_address", func(params json.RawMessage) Response {
+		request := struct {
+			Address string `json:"address"`
+		}{}
+		json.Unmarshal(params, &request)
+		if result, ok := knownAddresses[request.Address]; ok {
+			return Response{Result: result}
+		}
+		return Response{Result: map[string]interface{}{"valid": false}}
+	})
+	return server
+}
@@ -0,0 +1,444 @@
+package rpcproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gomonero/zmtp"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+/*
+=============================================
+订阅monerod的新区块和新交易：ZMQ优先，不可用时退回轮询
+=============================================
+*/
+
+// monerod --zmq-pub发布的主题
+const (
+	TopicMinimalChainMain = "json-minimal-chain_main"
+	TopicFullChainMain    = "json-full-chain_main"
+	TopicMinimalTxpoolAdd = "json-minimal-txpool_add"
+	TopicFullTxpoolAdd    = "json-full-txpool_add"
+)
+
+const (
+	DefaultPollInterval     = 10 * time.Second
+	DefaultZMQRetryInterval = 30 * time.Second
+	DefaultZMQDialTimeout   = 5 * time.Second
+	DefaultEventBuffer      = 64
+)
+
+// 轮询发现高度跳跃时最多补发的区块数
+const maxPollBackfill = 100
+
+// 事件来源
+const (
+	EventSourceZMQ  = "zmq"
+	EventSourcePoll = "poll"
+)
+
+// 新区块（主链上）
+// minimal主题和轮询带Hash；full主题带完整的区块JSON，但没有Hash
+type BlockEvent struct {
+	Height    uint64
+	Hash      string
+	PrevHash  string
+	Timestamp uint64          // full主题和轮询时有
+	TxHashes  []string        // 只有full主题时有
+	Block     json.RawMessage // 只有full主题时有
+}
+
+// 进入交易池的新交易
+// minimal主题带Hash、大小和手续费；轮询只有Hash；full主题带完整的交易JSON，但没有Hash
+type TxEvent struct {
+	Hash     string
+	BlobSize uint64
+	Weight   uint64
+	Fee      uint64
+	Tx       json.RawMessage // 只有full主题时有
+}
+
+// Block和Tx中恰有一个不为nil
+type Event struct {
+	Source string
+	Block  *BlockEvent
+	Tx     *TxEvent
+}
+
+type SubscriberConfig struct {
+	ZMQEndpoint      string        // monerod的--zmq-pub地址，如tcp://127.0.0.1:18083，为空时只轮询
+	Full             bool          // 订阅json-full-*主题，事件带完整的区块和交易JSON
+	PollInterval     time.Duration // 轮询get_last_block_header和get_transaction_pool_hashes的间隔
+	ZMQRetryInterval time.Duration // ZMQ断开后，轮询多久再重新连接ZMQ
+	ZMQDialTimeout   time.Duration
+	Buffer           int // 事件channel的容量
+	Logger           *slog.Logger
+}
+
+type Subscriber struct {
+	daemon *DaemonRPCProxy // 轮询用，为nil时不轮询
+	config SubscriberConfig
+	logger *slog.Logger
+	events chan Event
+
+	lock    sync.Mutex
+	started bool
+	stopped bool
+	stop    chan struct{}
+	done    chan struct{} // 后台goroutine退出，或没有启动时Stop后关闭
+
+	// 已知的链顶，ZMQ事件和轮询共同维护，用于切换到轮询后补发错过的区块
+	has_tip  bool
+	tip      uint64
+	tip_hash string
+	// 上次轮询到的交易池
+	pool_hashes map[string]struct{}
+}
+
+func CreateSubscriber(daemon *DaemonRPCProxy, config SubscriberConfig) *Subscriber {
+	if config.PollInterval == 0 {
+		config.PollInterval = DefaultPollInterval
+	}
+	if config.ZMQRetryInterval == 0 {
+		config.ZMQRetryInterval = DefaultZMQRetryInterval
+	}
+	if config.ZMQDialTimeout == 0 {
+		config.ZMQDialTimeout = DefaultZMQDialTimeout
+	}
+	if config.Buffer == 0 {
+		config.Buffer = DefaultEventBuffer
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Subscriber{
+		daemon: daemon,
+		config: config,
+		logger: logger.With("component", "rpcproxy", "proxy", "subscriber"),
+		events: make(chan Event, config.Buffer),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// 事件channel，Stop后被关闭
+func (subscriber *Subscriber) Events() <-chan Event {
+	return subscriber.events
+}
+
+// 在后台开始订阅，重复调用或Stop后调用无效
+func (subscriber *Subscriber) Start() {
+	subscriber.lock.Lock()
+	defer subscriber.lock.Unlock()
+	if subscriber.started || subscriber.stopped {
+		return
+	}
+	subscriber.started = true
+	go subscriber.run()
+}
+
+// 停止订阅，等待后台goroutine退出；没有Start时直接关闭事件channel
+func (subscriber *Subscriber) Stop() {
+	subscriber.lock.Lock()
+	if !subscriber.stopped {
+		subscriber.stopped = true
+		close(subscriber.stop)
+		if !subscriber.started {
+			close(subscriber.events)
+			close(subscriber.done)
+		}
+	}
+	subscriber.lock.Unlock()
+	<-subscriber.done
+}
+
+func (subscriber *Subscriber) run() {
+	defer close(subscriber.done)
+	defer close(subscriber.events)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-subscriber.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		if subscriber.config.ZMQEndpoint != "" {
+			err := subscriber.runZMQ(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			subscriber.logger.Warn("zmq subscription failed, falling back to polling", "endpoint", subscriber.config.ZMQEndpoint, "error", err)
+		}
+		// 轮询，直到该重新连接ZMQ
+		var retry <-chan time.Time
+		if subscriber.config.ZMQEndpoint != "" {
+			retry = time.After(subscriber.config.ZMQRetryInterval)
+		}
+		if !subscriber.pollUntil(ctx, retry) {
+			return
+		}
+	}
+}
+
+// 发送事件，ctx结束时返回false
+func (subscriber *Subscriber) emit(ctx context.Context, event Event) bool {
+	select {
+	case subscriber.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+/* ==== ZMQ ==== */
+
+func (subscriber *Subscriber) runZMQ(ctx context.Context) error {
+	dial_ctx, cancel := context.WithTimeout(ctx, subscriber.config.ZMQDialTimeout)
+	conn, err := zmtp.Dial(dial_ctx, subscriber.config.ZMQEndpoint, zmtp.SocketSub)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// ctx结束时关闭连接，使ReadMessage返回
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	topics := []string{TopicMinimalChainMain, TopicMinimalTxpoolAdd}
+	if subscriber.config.Full {
+		topics = []string{TopicFullChainMain, TopicFullTxpoolAdd}
+	}
+	for _, topic := range topics {
+		if err := conn.Subscribe(topic); err != nil {
+			return err
+		}
+	}
+	subscriber.logger.Info("zmq subscription established", "endpoint", subscriber.config.ZMQEndpoint, "topics", topics)
+
+	for {
+		frames, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		// monerod把主题和JSON放在同一帧中：topic:json
+		topic, payload, found := bytes.Cut(bytes.Join(frames, nil), []byte(":"))
+		if !found {
+			subscriber.logger.Warn("malformed zmq message", "size", len(payload))
+			continue
+		}
+		events, err := subscriber.parseZMQ(string(topic), payload)
+		if err != nil {
+			subscriber.logger.Warn("malformed zmq payload", "topic", string(topic), "error", err)
+			continue
+		}
+		for _, event := range events {
+			if !subscriber.emit(ctx, event) {
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (subscriber *Subscriber) parseZMQ(topic string, payload []byte) ([]Event, error) {
+	events := []Event{}
+	switch topic {
+	case TopicMinimalChainMain:
+		chain := struct {
+			FirstHeight uint64   `json:"first_height"`
+			FirstPrevId string   `json:"first_prev_id"`
+			Ids         []string `json:"ids"`
+		}{}
+		if err := json.Unmarshal(payload, &chain); err != nil {
+			return nil, err
+		}
+		prev_hash := chain.FirstPrevId
+		for i, id := range chain.Ids {
+			block := BlockEvent{Height: chain.FirstHeight + uint64(i), Hash: id, PrevHash: prev_hash}
+			subscriber.setTip(block.Height, block.Hash)
+			events = append(events, Event{Source: EventSourceZMQ, Block: &block})
+			prev_hash = id
+		}
+	case TopicFullChainMain:
+		blocks := []json.RawMessage{}
+		if err := json.Unmarshal(payload, &blocks); err != nil {
+			return nil, err
+		}
+		for _, raw := range blocks {
+			full := struct {
+				Timestamp uint64 `json:"timestamp"`
+				PrevId    string `json:"prev_id"`
+				MinerTx   struct {
+					Inputs []struct {
+						Gen *struct {
+							Height uint64 `json:"height"`
+						} `json:"gen"`
+					} `json:"inputs"`
+				} `json:"miner_tx"`
+				TxHashes []string `json:"tx_hashes"`
+			}{}
+			if err := json.Unmarshal(raw, &full); err != nil {
+				return nil, err
+			}
+			// 高度取自coinbase交易的gen输入
+			if len(full.MinerTx.Inputs) != 1 || full.MinerTx.Inputs[0].Gen == nil {
+				return nil, fmt.Errorf("block without coinbase gen input")
+			}
+			block := BlockEvent{
+				Height:    full.MinerTx.Inputs[0].Gen.Height,
+				PrevHash:  full.PrevId,
+				Timestamp: full.Timestamp,
+				TxHashes:  full.TxHashes,
+				Block:     raw,
+			}
+			subscriber.setTip(block.Height, "")
+			events = append(events, Event{Source: EventSourceZMQ, Block: &block})
+		}
+	case TopicMinimalTxpoolAdd:
+		txs := []struct {
+			Id       string `json:"id"`
+			BlobSize uint64 `json:"blob_size"`
+			Weight   uint64 `json:"weight"`
+			Fee      uint64 `json:"fee"`
+		}{}
+		if err := json.Unmarshal(payload, &txs); err != nil {
+			return nil, err
+		}
+		for _, tx := range txs {
+			events = append(events, Event{Source: EventSourceZMQ, Tx: &TxEvent{Hash: tx.Id, BlobSize: tx.BlobSize, Weight: tx.Weight, Fee: tx.Fee}})
+		}
+	case TopicFullTxpoolAdd:
+		txs := []json.RawMessage{}
+		if err := json.Unmarshal(payload, &txs); err != nil {
+			return nil, err
+		}
+		for _, raw := range txs {
+			full := struct {
+				Ringct struct {
+					Fee uint64 `json:"fee"`
+				} `json:"ringct"`
+			}{}
+			if err := json.Unmarshal(raw, &full); err != nil {
+				return nil, err
+			}
+			events = append(events, Event{Source: EventSourceZMQ, Tx: &TxEvent{Fee: full.Ringct.Fee, Tx: raw}})
+		}
+	default:
+		return nil, fmt.Errorf("unexpected topic %q", topic)
+	}
+	return events, nil
+}
+
+func (subscriber *Subscriber) setTip(height uint64, hash string) {
+	subscriber.has_tip = true
+	subscriber.tip = height
+	subscriber.tip_hash = hash
+}
+
+/* ==== 轮询 ==== */
+
+// 每隔PollInterval轮询一次，直到until触发（返回true）或ctx结束（返回false）
+func (subscriber *Subscriber) pollUntil(ctx context.Context, until <-chan time.Time) bool {
+	ticker := time.NewTicker(subscriber.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		if subscriber.daemon != nil {
+			if err := subscriber.poll(ctx); err != nil && ctx.Err() == nil {
+				subscriber.logger.Warn("poll failed", "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-until:
+			return true
+		case <-ticker.C:
+		}
+	}
+}
+
+func (subscriber *Subscriber) poll(ctx context.Context) error {
+	if err := subscriber.pollChain(ctx); err != nil {
+		return err
+	}
+	return subscriber.pollTxpool(ctx)
+}
+
+// 链顶变化时发送新区块，高度跳跃时补发中间的区块
+// 第一次轮询只记录链顶，不发送事件
+func (subscriber *Subscriber) pollChain(ctx context.Context) error {
+	result, err := subscriber.daemon.GetLastBlockHeader(ctx, false)
+	if err != nil {
+		return err
+	}
+	top := result.BlockHeader
+	if !subscriber.has_tip {
+		subscriber.setTip(top.Height, top.Hash)
+		return nil
+	}
+	if top.Height == subscriber.tip && (subscriber.tip_hash == "" || top.Hash == subscriber.tip_hash) {
+		return nil
+	}
+
+	headers := []BlockHeader{}
+	// 高度降低或同高度的哈希不同是链重组，只发送新的链顶
+	if top.Height > subscriber.tip+1 {
+		first := subscriber.tip + 1
+		if top.Height-first > maxPollBackfill {
+			first = top.Height - maxPollBackfill
+		}
+		heights := []uint64{}
+		for height := first; height < top.Height; height++ {
+			heights = append(heights, height)
+		}
+		headers, err = subscriber.daemon.GetBlockHeadersByHeight(ctx, heights, false)
+		if err != nil {
+			return err
+		}
+	}
+	headers = append(headers, top)
+	for _, header := range headers {
+		block := BlockEvent{Height: header.Height, Hash: header.Hash, PrevHash: header.PrevHash, Timestamp: header.Timestamp}
+		if !subscriber.emit(ctx, Event{Source: EventSourcePoll, Block: &block}) {
+			return ctx.Err()
+		}
+	}
+	subscriber.setTip(top.Height, top.Hash)
+	return nil
+}
+
+// 发送交易池中新出现的交易，第一次轮询只记录交易池
+func (subscriber *Subscriber) pollTxpool(ctx context.Context) error {
+	result, err := subscriber.daemon.GetTransactionPoolHashes(ctx)
+	if err != nil {
+		return err
+	}
+	pool_hashes := make(map[string]struct{}, len(result.TxHashes))
+	for _, hash := range result.TxHashes {
+		pool_hashes[hash] = struct{}{}
+	}
+	previous := subscriber.pool_hashes
+	subscriber.pool_hashes = pool_hashes
+	if previous == nil {
+		return nil
+	}
+	for _, hash := range result.TxHashes {
+		if _, ok := previous[hash]; ok {
+			continue
+		}
+		if !subscriber.emit(ctx, Event{Source: EventSourcePoll, Tx: &TxEvent{Hash: hash}}) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
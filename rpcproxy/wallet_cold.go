@@ -0,0 +1,233 @@
+package rpcproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gomonero/monero"
+)
+
+/*
+	============
+	Cold Signing
+	============
+*/
+
+// export_outputs：all为false时只导出上次导出后新增的输出
+func (proxy *WalletRPCProxy) ExportOutputs(ctx context.Context, all bool) (string, error) {
+	params := map[string]interface{}{
+		"all": all,
+	}
+	result := struct {
+		OutputsDataHex string `json:"outputs_data_hex"`
+	}{}
+	err := proxy.client.callJson(ctx, "export_outputs", params, &result)
+	return result.OutputsDataHex, err
+}
+
+// import_outputs：返回导入的输出数
+func (proxy *WalletRPCProxy) ImportOutputs(ctx context.Context, outputs_data_hex string) (uint64, error) {
+	params := map[string]interface{}{
+		"outputs_data_hex": outputs_data_hex,
+	}
+	result := struct {
+		NumImported uint64 `json:"num_imported"`
+	}{}
+	err := proxy.client.callJson(ctx, "import_outputs", params, &result)
+	return result.NumImported, err
+}
+
+type SignedKeyImage struct {
+	KeyImage  string `json:"key_image"`
+	Signature string `json:"signature"`
+}
+
+// export_key_images：all为false时只导出上次导出后新增的key image
+func (proxy *WalletRPCProxy) ExportKeyImages(ctx context.Context, all bool) ([]SignedKeyImage, error) {
+	params := map[string]interface{}{
+		"all": all,
+	}
+	result := struct {
+		SignedKeyImages []SignedKeyImage `json:"signed_key_images"`
+	}{}
+	err := proxy.client.callJson(ctx, "export_key_images", params, &result)
+	return result.SignedKeyImages, err
+}
+
+type ImportKeyImagesResult struct {
+	Height  uint64        `json:"height"`
+	Spent   monero.Amount `json:"spent"`
+	Unspent monero.Amount `json:"unspent"`
+}
+
+// import_key_images：offset为signed_key_images中第一个key image对应的输出序号，导入全部时为0
+func (proxy *WalletRPCProxy) ImportKeyImages(ctx context.Context, signed_key_images []SignedKeyImage, offset uint32) (ImportKeyImagesResult, error) {
+	params := map[string]interface{}{
+		"signed_key_images": signed_key_images,
+	}
+	if offset > 0 {
+		params["offset"] = offset
+	}
+	result := ImportKeyImagesResult{}
+	err := proxy.client.callJson(ctx, "import_key_images", params, &result)
+	return result, err
+}
+
+type TransferRecipient struct {
+	Address string        `json:"address"`
+	Amount  monero.Amount `json:"amount"`
+}
+
+// 待签名交易的内容
+type TransferDescription struct {
+	AmountIn      monero.Amount       `json:"amount_in"`
+	AmountOut     monero.Amount       `json:"amount_out"`
+	Recipients    []TransferRecipient `json:"recipients"`
+	ChangeAddress string              `json:"change_address"`
+	ChangeAmount  monero.Amount       `json:"change_amount"`
+	Fee           monero.Amount       `json:"fee"`
+	PaymentId     string              `json:"payment_id"`
+	RingSize      uint32              `json:"ring_size"`
+	UnlockTime    uint64              `json:"unlock_time"`
+	DummyOutputs  uint32              `json:"dummy_outputs"`
+	Extra         string              `json:"extra"`
+}
+
+type TransferSummary struct {
+	AmountIn      monero.Amount       `json:"amount_in"`
+	AmountOut     monero.Amount       `json:"amount_out"`
+	Recipients    []TransferRecipient `json:"recipients"`
+	ChangeAddress string              `json:"change_address"`
+	ChangeAmount  monero.Amount       `json:"change_amount"`
+	Fee           monero.Amount       `json:"fee"`
+}
+
+type DescribeTransferResult struct {
+	Desc    []TransferDescription `json:"desc"`
+	Summary TransferSummary       `json:"summary"`
+}
+
+// describe_transfer：unsigned_txset为view-only钱包transfer的结果
+func (proxy *WalletRPCProxy) DescribeTransfer(ctx context.Context, unsigned_txset string) (DescribeTransferResult, error) {
+	params := map[string]interface{}{
+		"unsigned_txset": unsigned_txset,
+	}
+	result := DescribeTransferResult{}
+	err := proxy.client.callJson(ctx, "describe_transfer", params, &result)
+	return result, err
+}
+
+type SignTransferResult struct {
+	SignedTxset string   `json:"signed_txset"`
+	TxHashList  []string `json:"tx_hash_list"`
+	TxRawList   []string `json:"tx_raw_list"`
+	TxKeyList   []string `json:"tx_key_list"`
+}
+
+// sign_transfer：export_raw为true时同时返回交易的原始数据
+func (proxy *WalletRPCProxy) SignTransfer(ctx context.Context, unsigned_txset string, export_raw bool, get_tx_keys bool) (SignTransferResult, error) {
+	params := map[string]interface{}{
+		"unsigned_txset": unsigned_txset,
+		"export_raw":     export_raw,
+		"get_tx_keys":    get_tx_keys,
+	}
+	result := SignTransferResult{}
+	err := proxy.client.callJson(ctx, "sign_transfer", params, &result)
+	return result, err
+}
+
+// submit_transfer：tx_data_hex为sign_transfer返回的signed_txset
+func (proxy *WalletRPCProxy) SubmitTransfer(ctx context.Context, tx_data_hex string) ([]string, error) {
+	params := map[string]interface{}{
+		"tx_data_hex": tx_data_hex,
+	}
+	result := struct {
+		TxHashList []string `json:"tx_hash_list"`
+	}{}
+	err := proxy.client.callJson(ctx, "submit_transfer", params, &result)
+	return result.TxHashList, err
+}
+
+/*
+	=================
+	Cold Signing Flow
+	=================
+*/
+
+// 在view-only钱包（联网）和完整钱包（离线）之间搬运数据，完成一次冷签名的花费
+type ColdSigningFlow struct {
+	ViewOnly *WalletRPCProxy
+	Cold     *WalletRPCProxy
+	// 签名前检查交易内容，返回错误时放弃签名；为nil时不检查
+	Approve func(DescribeTransferResult) error
+}
+
+var ErrTransferNotApproved = errors.New("cold signing: transfer not approved")
+
+type ColdTransferResult struct {
+	Description DescribeTransferResult
+	TxHashList  []string
+	TxKeyList   []string
+}
+
+// view-only钱包的输出导入完整钱包，完整钱包算出的key image再导回view-only钱包
+// 之后view-only钱包才能知道哪些输出已花费，并构造待签名交易
+func (flow *ColdSigningFlow) SyncOutputs(ctx context.Context) (ImportKeyImagesResult, error) {
+	outputs, err := flow.ViewOnly.ExportOutputs(ctx, true)
+	if err != nil {
+		return ImportKeyImagesResult{}, fmt.Errorf("export_outputs from view-only wallet: %w", err)
+	}
+	if _, err := flow.Cold.ImportOutputs(ctx, outputs); err != nil {
+		return ImportKeyImagesResult{}, fmt.Errorf("import_outputs into cold wallet: %w", err)
+	}
+	key_images, err := flow.Cold.ExportKeyImages(ctx, true)
+	if err != nil {
+		return ImportKeyImagesResult{}, fmt.Errorf("export_key_images from cold wallet: %w", err)
+	}
+	result, err := flow.ViewOnly.ImportKeyImages(ctx, key_images, 0)
+	if err != nil {
+		return ImportKeyImagesResult{}, fmt.Errorf("import_key_images into view-only wallet: %w", err)
+	}
+	return result, nil
+}
+
+// 同步输出 -> view-only钱包构造交易 -> 完整钱包检查并签名 -> view-only钱包广播
+func (flow *ColdSigningFlow) Transfer(ctx context.Context, request TransferRequest) (ColdTransferResult, error) {
+	if _, err := flow.SyncOutputs(ctx); err != nil {
+		return ColdTransferResult{}, err
+	}
+	unsigned, err := flow.ViewOnly.Transfer(ctx, request)
+	if err != nil {
+		return ColdTransferResult{}, fmt.Errorf("transfer on view-only wallet: %w", err)
+	}
+	if unsigned.UnsignedTxset == "" {
+		return ColdTransferResult{}, errors.New("cold signing: view-only wallet returned no unsigned_txset")
+	}
+	return flow.SignAndSubmit(ctx, unsigned.UnsignedTxset)
+}
+
+// 由完整钱包签名unsigned_txset，再由view-only钱包广播
+func (flow *ColdSigningFlow) SignAndSubmit(ctx context.Context, unsigned_txset string) (ColdTransferResult, error) {
+	result := ColdTransferResult{}
+	description, err := flow.Cold.DescribeTransfer(ctx, unsigned_txset)
+	if err != nil {
+		return result, fmt.Errorf("describe_transfer on cold wallet: %w", err)
+	}
+	result.Description = description
+	if flow.Approve != nil {
+		if err := flow.Approve(description); err != nil {
+			return result, fmt.Errorf("%w: %w", ErrTransferNotApproved, err)
+		}
+	}
+	signed, err := flow.Cold.SignTransfer(ctx, unsigned_txset, false, true)
+	if err != nil {
+		return result, fmt.Errorf("sign_transfer on cold wallet: %w", err)
+	}
+	result.TxKeyList = signed.TxKeyList
+	tx_hash_list, err := flow.ViewOnly.SubmitTransfer(ctx, signed.SignedTxset)
+	if err != nil {
+		return result, fmt.Errorf("submit_transfer on view-only wallet: %w", err)
+	}
+	result.TxHashList = tx_hash_list
+	return result, nil
+}
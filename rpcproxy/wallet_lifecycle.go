@@ -0,0 +1,258 @@
+package rpcproxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gomonero/monero/address"
+	"gomonero/monero/mnemonic"
+)
+
+/*
+	================
+	Wallet Lifecycle
+	================
+*/
+
+// monero-wallet-rpc同一时间只打开一个钱包，create_wallet等方法会关闭当前钱包并打开新钱包
+// 钱包文件位于monero-wallet-rpc的--wallet-dir下
+
+const DefaultWalletLanguage = "English"
+
+// create_wallet：language为空时使用English
+func (proxy *WalletRPCProxy) CreateWallet(ctx context.Context, filename string, password string, language string) error {
+	if language == "" {
+		language = DefaultWalletLanguage
+	}
+	params := map[string]interface{}{
+		"filename": filename,
+		"password": password,
+		"language": language,
+	}
+	return proxy.client.callJson(ctx, "create_wallet", params, nil)
+}
+
+// open_wallet
+func (proxy *WalletRPCProxy) OpenWallet(ctx context.Context, filename string, password string) error {
+	params := map[string]interface{}{
+		"filename": filename,
+		"password": password,
+	}
+	return proxy.client.callJson(ctx, "open_wallet", params, nil)
+}
+
+// close_wallet：关闭前monero-wallet-rpc会保存钱包
+func (proxy *WalletRPCProxy) CloseWallet(ctx context.Context) error {
+	return proxy.client.callJson(ctx, "close_wallet", nil, nil)
+}
+
+type RestoreDeterministicWalletRequest struct {
+	Filename        string `json:"filename"`
+	Password        string `json:"password"`
+	Seed            string `json:"seed"`
+	RestoreHeight   uint64 `json:"restore_height,omitempty"`
+	Language        string `json:"language,omitempty"`
+	SeedOffset      string `json:"seed_offset,omitempty"`
+	AutosaveCurrent bool   `json:"autosave_current"`
+}
+
+type RestoreDeterministicWalletResult struct {
+	Address       string `json:"address"`
+	Info          string `json:"info"`
+	Seed          string `json:"seed"`
+	WasDeprecated bool   `json:"was_deprecated"`
+}
+
+// restore_deterministic_wallet：从25个助记词恢复钱包
+func (proxy *WalletRPCProxy) RestoreDeterministicWallet(ctx context.Context, request RestoreDeterministicWalletRequest) (RestoreDeterministicWalletResult, error) {
+	result := RestoreDeterministicWalletResult{}
+	err := proxy.client.callJson(ctx, "restore_deterministic_wallet", request, &result)
+	return result, err
+}
+
+// spendkey为空时生成只读（view-only）钱包
+type GenerateFromKeysRequest struct {
+	Filename        string `json:"filename"`
+	Password        string `json:"password"`
+	Address         string `json:"address"`
+	Spendkey        string `json:"spendkey,omitempty"`
+	Viewkey         string `json:"viewkey"`
+	RestoreHeight   uint64 `json:"restore_height,omitempty"`
+	AutosaveCurrent bool   `json:"autosave_current"`
+}
+
+type GenerateFromKeysResult struct {
+	Address string `json:"address"`
+	Info    string `json:"info"`
+}
+
+// generate_from_keys
+func (proxy *WalletRPCProxy) GenerateFromKeys(ctx context.Context, request GenerateFromKeysRequest) (GenerateFromKeysResult, error) {
+	result := GenerateFromKeysResult{}
+	err := proxy.client.callJson(ctx, "generate_from_keys", request, &result)
+	return result, err
+}
+
+// change_wallet_password：修改当前打开的钱包的密码
+func (proxy *WalletRPCProxy) ChangeWalletPassword(ctx context.Context, old_password string, new_password string) error {
+	params := map[string]interface{}{
+		"old_password": old_password,
+		"new_password": new_password,
+	}
+	return proxy.client.callJson(ctx, "change_wallet_password", params, nil)
+}
+
+// query_key的封装
+func (proxy *WalletRPCProxy) QueryMnemonic(ctx context.Context) (string, error) {
+	return proxy.QueryKey(ctx, KeyTypeMnemonic)
+}
+
+func (proxy *WalletRPCProxy) QueryViewKey(ctx context.Context) (string, error) {
+	return proxy.QueryKey(ctx, KeyTypeViewKey)
+}
+
+func (proxy *WalletRPCProxy) QuerySpendKey(ctx context.Context) (string, error) {
+	return proxy.QueryKey(ctx, KeyTypeSpendKey)
+}
+
+type GetLanguagesResult struct {
+	Languages      []string `json:"languages"`
+	LanguagesLocal []string `json:"languages_local"`
+}
+
+// get_languages：助记词支持的语言
+func (proxy *WalletRPCProxy) GetLanguages(ctx context.Context) (GetLanguagesResult, error) {
+	result := GetLanguagesResult{}
+	err := proxy.client.callJson(ctx, "get_languages", nil, &result)
+	return result, err
+}
+
+/*
+	=======================
+	Experiment Provisioning
+	=======================
+*/
+
+// ProvisionWallets创建的钱包
+type ProvisionedWallet struct {
+	Proxy    *WalletRPCProxy
+	Filename string
+	Password string
+	Address  string
+}
+
+// 切换到这个钱包（关闭Proxy上当前打开的钱包）
+func (wallet *ProvisionedWallet) Open(ctx context.Context) error {
+	return wallet.Proxy.OpenWallet(ctx, wallet.Filename, wallet.Password)
+}
+
+type ProvisionConfig struct {
+	Prefix   string // 钱包文件名前缀，为空时使用experiment
+	Password string
+	Language string
+	// 不为空时用助记词恢复钱包而不是新建，第i个钱包使用Seeds[i]，数量不能少于n
+	// 助记词先在本地解码校验，只支持英文助记词，其他语言返回mnemonic.ErrUnsupportedLanguage
+	Seeds         []string
+	RestoreHeight uint64
+}
+
+// 为实验创建n个新钱包，第i个钱包创建在proxies[i%len(proxies)]上
+// 文件名为"前缀-随机串-序号"，避免与已有的钱包冲突
+// 返回后每个proxy上打开的是最后一个在它上面创建的钱包；每个proxy一个钱包时可以直接并行使用
+// 中途失败时关闭已创建的钱包并返回错误
+func ProvisionWallets(ctx context.Context, proxies []*WalletRPCProxy, n int, config ProvisionConfig) ([]ProvisionedWallet, error) {
+	if len(proxies) == 0 {
+		return nil, errors.New("provision wallets: no wallet rpc proxy")
+	}
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = "experiment"
+	}
+	if len(config.Seeds) > 0 && len(config.Seeds) < n {
+		return nil, fmt.Errorf("provision wallets: %d seeds for %d wallets", len(config.Seeds), n)
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	run_id := hex.EncodeToString(suffix)
+
+	wallets := make([]ProvisionedWallet, 0, n)
+	for i := 0; i < n; i++ {
+		wallet := ProvisionedWallet{
+			Proxy:    proxies[i%len(proxies)],
+			Filename: fmt.Sprintf("%s-%s-%d", prefix, run_id, i),
+			Password: config.Password,
+		}
+		if len(config.Seeds) > 0 {
+			address, err := restoreWallet(ctx, wallet, config.Seeds[i], config.RestoreHeight)
+			if err != nil {
+				// 恢复后地址不符时钱包已经打开，一并关闭
+				TeardownWallets(context.WithoutCancel(ctx), append(wallets, wallet))
+				return nil, fmt.Errorf("provision wallet %s: %w", wallet.Filename, err)
+			}
+			wallet.Address = address
+			wallets = append(wallets, wallet)
+			continue
+		}
+		if err := wallet.Proxy.CreateWallet(ctx, wallet.Filename, wallet.Password, config.Language); err != nil {
+			TeardownWallets(context.WithoutCancel(ctx), wallets)
+			return nil, fmt.Errorf("provision wallet %s: %w", wallet.Filename, err)
+		}
+		address, err := wallet.Proxy.GetAddress(ctx, 0, nil)
+		if err != nil {
+			wallets = append(wallets, wallet)
+			TeardownWallets(context.WithoutCancel(ctx), wallets)
+			return nil, fmt.Errorf("provision wallet %s: %w", wallet.Filename, err)
+		}
+		wallet.Address = address.Address
+		wallets = append(wallets, wallet)
+	}
+	return wallets, nil
+}
+
+// 先离线解码助记词，恢复后检查wallet-rpc返回的地址与助记词派生的密钥一致
+func restoreWallet(ctx context.Context, wallet ProvisionedWallet, seed string, restore_height uint64) (string, error) {
+	spend_key, list, err := mnemonic.Decode(seed)
+	if err != nil {
+		return "", err
+	}
+	keys := mnemonic.DeriveKeys(spend_key)
+	result, err := wallet.Proxy.RestoreDeterministicWallet(ctx, RestoreDeterministicWalletRequest{
+		Filename:      wallet.Filename,
+		Password:      wallet.Password,
+		Seed:          seed,
+		RestoreHeight: restore_height,
+		Language:      list.Name,
+	})
+	if err != nil {
+		return "", err
+	}
+	restored, err := address.Parse(result.Address)
+	if err != nil {
+		return "", err
+	}
+	if restored.SpendKey != keys.SpendPublicKey || restored.ViewKey != keys.ViewPublicKey {
+		return "", fmt.Errorf("restored address %s does not match the seed", result.Address)
+	}
+	return result.Address, nil
+}
+
+// 关闭wallets所在的每个proxy上当前打开的钱包
+// monero-wallet-rpc没有删除钱包的接口，钱包文件仍保留在--wallet-dir下，需要时由调用者清理
+func TeardownWallets(ctx context.Context, wallets []ProvisionedWallet) error {
+	closed := map[*WalletRPCProxy]bool{}
+	errs := []error{}
+	for _, wallet := range wallets {
+		if closed[wallet.Proxy] {
+			continue
+		}
+		closed[wallet.Proxy] = true
+		if err := wallet.Proxy.CloseWallet(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("close wallet on %s: %w", wallet.Proxy.client.other_rpc_url, err))
+		}
+	}
+	return errors.Join(errs...)
+}
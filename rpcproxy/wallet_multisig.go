@@ -0,0 +1,274 @@
+package rpcproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+/*
+	========
+	Multisig
+	========
+*/
+
+// v0.18起需要先在钱包中开启enable-multisig-experimental，否则prepare_multisig会返回错误
+
+type IsMultisigResult struct {
+	Multisig  bool   `json:"multisig"`
+	KexIsDone bool   `json:"kex_is_done"`
+	Ready     bool   `json:"ready"`
+	Threshold uint32 `json:"threshold"`
+	Total     uint32 `json:"total"`
+}
+
+// is_multisig
+func (proxy *WalletRPCProxy) IsMultisig(ctx context.Context) (IsMultisigResult, error) {
+	result := IsMultisigResult{}
+	err := proxy.client.callJson(ctx, "is_multisig", nil, &result)
+	return result, err
+}
+
+// prepare_multisig：返回需要发送给其他参与者的multisig_info
+func (proxy *WalletRPCProxy) PrepareMultisig(ctx context.Context) (string, error) {
+	result := struct {
+		MultisigInfo string `json:"multisig_info"`
+	}{}
+	err := proxy.client.callJson(ctx, "prepare_multisig", nil, &result)
+	return result.MultisigInfo, err
+}
+
+// make_multisig和exchange_multisig_keys的结果
+// 密钥交换完成前Address为空，MultisigInfo需要发送给其他参与者进行下一轮交换
+type MultisigKexResult struct {
+	Address      string `json:"address"`
+	MultisigInfo string `json:"multisig_info"`
+}
+
+// make_multisig：multisig_info为其他参与者prepare_multisig的结果
+func (proxy *WalletRPCProxy) MakeMultisig(ctx context.Context, multisig_info []string, threshold uint32, password string) (MultisigKexResult, error) {
+	params := map[string]interface{}{
+		"multisig_info": multisig_info,
+		"threshold":     threshold,
+		"password":      password,
+	}
+	result := MultisigKexResult{}
+	err := proxy.client.callJson(ctx, "make_multisig", params, &result)
+	return result, err
+}
+
+// exchange_multisig_keys：multisig_info为其他参与者上一轮的结果
+func (proxy *WalletRPCProxy) ExchangeMultisigKeys(ctx context.Context, multisig_info []string, password string) (MultisigKexResult, error) {
+	params := map[string]interface{}{
+		"multisig_info": multisig_info,
+		"password":      password,
+	}
+	result := MultisigKexResult{}
+	err := proxy.client.callJson(ctx, "exchange_multisig_keys", params, &result)
+	return result, err
+}
+
+// export_multisig_info：花费前各参与者需要互相导入对方的info
+func (proxy *WalletRPCProxy) ExportMultisigInfo(ctx context.Context) (string, error) {
+	result := struct {
+		Info string `json:"info"`
+	}{}
+	err := proxy.client.callJson(ctx, "export_multisig_info", nil, &result)
+	return result.Info, err
+}
+
+// import_multisig_info：返回导入的输出数
+func (proxy *WalletRPCProxy) ImportMultisigInfo(ctx context.Context, info []string) (uint64, error) {
+	params := map[string]interface{}{
+		"info": info,
+	}
+	result := struct {
+		NOutputs uint64 `json:"n_outputs"`
+	}{}
+	err := proxy.client.callJson(ctx, "import_multisig_info", params, &result)
+	return result.NOutputs, err
+}
+
+type SignMultisigResult struct {
+	TxDataHex  string   `json:"tx_data_hex"`
+	TxHashList []string `json:"tx_hash_list"`
+}
+
+// sign_multisig：tx_data_hex为transfer返回的multisig_txset或上一个签名者的结果
+func (proxy *WalletRPCProxy) SignMultisig(ctx context.Context, tx_data_hex string) (SignMultisigResult, error) {
+	params := map[string]interface{}{
+		"tx_data_hex": tx_data_hex,
+	}
+	result := SignMultisigResult{}
+	err := proxy.client.callJson(ctx, "sign_multisig", params, &result)
+	return result, err
+}
+
+// submit_multisig：广播签名足够的交易
+func (proxy *WalletRPCProxy) SubmitMultisig(ctx context.Context, tx_data_hex string) ([]string, error) {
+	params := map[string]interface{}{
+		"tx_data_hex": tx_data_hex,
+	}
+	result := struct {
+		TxHashList []string `json:"tx_hash_list"`
+	}{}
+	err := proxy.client.callJson(ctx, "submit_multisig", params, &result)
+	return result.TxHashList, err
+}
+
+/*
+	====================
+	Multisig Coordinator
+	====================
+*/
+
+// 在多个WalletRPCProxy（每个proxy打开一个参与者的钱包）之间完成M-of-N多签的建立和花费
+type MultisigCoordinator struct {
+	wallets   []*WalletRPCProxy
+	threshold uint32
+	password  string
+}
+
+func CreateMultisigCoordinator(wallets []*WalletRPCProxy, threshold uint32, password string) (*MultisigCoordinator, error) {
+	if len(wallets) < 2 {
+		return nil, errors.New("multisig needs at least 2 wallets")
+	}
+	if threshold < 2 || int(threshold) > len(wallets) {
+		return nil, fmt.Errorf("invalid multisig threshold %d of %d", threshold, len(wallets))
+	}
+	coordinator := MultisigCoordinator{
+		wallets:   wallets,
+		threshold: threshold,
+		password:  password,
+	}
+	return &coordinator, nil
+}
+
+// 除第i个参与者之外其他参与者的info
+func othersInfo(infos []string, i int) []string {
+	others := make([]string, 0, len(infos)-1)
+	others = append(others, infos[:i]...)
+	return append(others, infos[i+1:]...)
+}
+
+// 建立多签钱包：prepare_multisig -> make_multisig -> 若干轮exchange_multisig_keys
+// 每一轮都把其他参与者上一轮的multisig_info交给各钱包，直到所有钱包都ready，返回多签地址
+func (coordinator *MultisigCoordinator) Setup(ctx context.Context) (string, error) {
+	infos := make([]string, len(coordinator.wallets))
+	for i, wallet := range coordinator.wallets {
+		info, err := wallet.PrepareMultisig(ctx)
+		if err != nil {
+			return "", fmt.Errorf("prepare_multisig on wallet %d: %w", i, err)
+		}
+		infos[i] = info
+	}
+
+	next_infos := make([]string, len(coordinator.wallets))
+	for i, wallet := range coordinator.wallets {
+		result, err := wallet.MakeMultisig(ctx, othersInfo(infos, i), coordinator.threshold, coordinator.password)
+		if err != nil {
+			return "", fmt.Errorf("make_multisig on wallet %d: %w", i, err)
+		}
+		next_infos[i] = result.MultisigInfo
+	}
+	infos = next_infos
+
+	// v0.18的密钥交换共N-M+1轮，之后还有一轮校验；make_multisig算第一轮
+	max_rounds := len(coordinator.wallets) - int(coordinator.threshold) + 1
+	for round := 0; ; round++ {
+		address, ready, err := coordinator.readyAddress(ctx)
+		if err != nil {
+			return "", err
+		}
+		if ready {
+			return address, nil
+		}
+		if round >= max_rounds {
+			return "", fmt.Errorf("multisig key exchange not done after %d rounds", round+1)
+		}
+		next_infos := make([]string, len(coordinator.wallets))
+		for i, wallet := range coordinator.wallets {
+			result, err := wallet.ExchangeMultisigKeys(ctx, othersInfo(infos, i), coordinator.password)
+			if err != nil {
+				return "", fmt.Errorf("exchange_multisig_keys round %d on wallet %d: %w", round+1, i, err)
+			}
+			next_infos[i] = result.MultisigInfo
+		}
+		infos = next_infos
+	}
+}
+
+// 所有钱包都ready时返回多签地址，并检查各钱包的地址一致
+func (coordinator *MultisigCoordinator) readyAddress(ctx context.Context) (string, bool, error) {
+	address := ""
+	for i, wallet := range coordinator.wallets {
+		status, err := wallet.IsMultisig(ctx)
+		if err != nil {
+			return "", false, fmt.Errorf("is_multisig on wallet %d: %w", i, err)
+		}
+		if !status.Ready {
+			return "", false, nil
+		}
+		wallet_address, err := wallet.GetAddress(ctx, 0, nil)
+		if err != nil {
+			return "", false, fmt.Errorf("get_address on wallet %d: %w", i, err)
+		}
+		if address != "" && wallet_address.Address != address {
+			return "", false, fmt.Errorf("wallet %d has multisig address %s, expected %s", i, wallet_address.Address, address)
+		}
+		address = wallet_address.Address
+	}
+	return address, true, nil
+}
+
+// 各参与者互相导入export_multisig_info，花费或查看余额前需要执行
+func (coordinator *MultisigCoordinator) SyncInfo(ctx context.Context) error {
+	infos := make([]string, len(coordinator.wallets))
+	for i, wallet := range coordinator.wallets {
+		info, err := wallet.ExportMultisigInfo(ctx)
+		if err != nil {
+			return fmt.Errorf("export_multisig_info on wallet %d: %w", i, err)
+		}
+		infos[i] = info
+	}
+	for i, wallet := range coordinator.wallets {
+		if _, err := wallet.ImportMultisigInfo(ctx, othersInfo(infos, i)); err != nil {
+			return fmt.Errorf("import_multisig_info on wallet %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// 由第initiator个参与者发起转账，再依次由后续参与者签名，凑够threshold个签名后提交，返回交易哈希
+func (coordinator *MultisigCoordinator) Transfer(ctx context.Context, initiator int, destinations []Destination) ([]string, error) {
+	if initiator < 0 || initiator >= len(coordinator.wallets) {
+		return nil, fmt.Errorf("invalid multisig initiator %d", initiator)
+	}
+	if err := coordinator.SyncInfo(ctx); err != nil {
+		return nil, err
+	}
+	transfer, err := coordinator.wallets[initiator].Transfer(ctx, TransferRequest{Destinations: destinations})
+	if err != nil {
+		return nil, fmt.Errorf("transfer on wallet %d: %w", initiator, err)
+	}
+	return coordinator.SignAndSubmit(ctx, transfer.MultisigTxset, initiator)
+}
+
+// multisig_txset已由第initiator个参与者签名，依次由其后的threshold-1个参与者签名并提交
+func (coordinator *MultisigCoordinator) SignAndSubmit(ctx context.Context, multisig_txset string, initiator int) ([]string, error) {
+	tx_data_hex := multisig_txset
+	signer := initiator
+	for signed := uint32(1); signed < coordinator.threshold; signed++ {
+		signer = (signer + 1) % len(coordinator.wallets)
+		result, err := coordinator.wallets[signer].SignMultisig(ctx, tx_data_hex)
+		if err != nil {
+			return nil, fmt.Errorf("sign_multisig on wallet %d: %w", signer, err)
+		}
+		tx_data_hex = result.TxDataHex
+	}
+	tx_hash_list, err := coordinator.wallets[signer].SubmitMultisig(ctx, tx_data_hex)
+	if err != nil {
+		return nil, fmt.Errorf("submit_multisig on wallet %d: %w", signer, err)
+	}
+	return tx_hash_list, nil
+}
@@ -1,82 +1,749 @@
 package rpcproxy
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"strconv"
+	"gomonero/monero"
+	"gomonero/monero/address"
 )
 
 type WalletRPCProxy struct {
-	rpc_ip        string
-	rpc_port      int16
-	rpc_user      string
-	rpc_password  string
-	json_rpc_url  string
-	other_rpc_url string
+	rpc_ip   string
+	rpc_port uint16
+	client   *rpcClient
 }
 
 // Transfer函数接收的destination数组的元素
 type Destination struct {
-	Amount  uint64
-	Address string
+	Amount  monero.Amount `json:"amount"`
+	Address string        `json:"address"`
 }
 
-func CreateWalletRPCProxy(rpc_ip string, rpc_port int16, rpc_user string, rpc_password string) WalletRPCProxy {
+// 子地址的索引，Major为账户索引，Minor为账户内的地址索引
+type SubaddressIndex struct {
+	Major uint32 `json:"major"`
+	Minor uint32 `json:"minor"`
+}
+
+func CreateWalletRPCProxy(rpc_ip string, rpc_port uint16, rpc_user string, rpc_password string) WalletRPCProxy {
+	return CreateWalletRPCProxyWithConfig(rpc_ip, rpc_port, rpc_user, rpc_password, ProxyConfig{})
+}
+
+func CreateWalletRPCProxyWithConfig(rpc_ip string, rpc_port uint16, rpc_user string, rpc_password string, config ProxyConfig) WalletRPCProxy {
 	proxy := WalletRPCProxy{
-		rpc_ip:       rpc_ip,
-		rpc_port:     rpc_port,
-		rpc_user:     rpc_user,
-		rpc_password: rpc_password,
+		rpc_ip:   rpc_ip,
+		rpc_port: rpc_port,
+		client:   newRPCClient(rpc_ip, rpc_port, rpc_user, rpc_password, config, config.logger().With("proxy", "wallet")),
 	}
-	proxy.json_rpc_url = "http://" + rpc_ip + ":" + strconv.Itoa(int(rpc_port)) + "/json_rpc"
-	proxy.other_rpc_url = "http://" + rpc_ip + ":" + strconv.Itoa(int(rpc_port))
+	proxy.client.busy_code = walletBusyCode
 	return proxy
 }
 
-// get_balance: 除第一个参数外，其余参数均可以设置为nil或false
-func (proxy *WalletRPCProxy) GetBalance(
-	account_index uint, address_indices []uint, all_acounts bool, strict bool) (balance int64, unlocked_balance int64) {
+/*
+	===============
+	Balance/Address
+	===============
+*/
+
+// get_balance：除AccountIndex外，其余字段均可以不设置
+type GetBalanceRequest struct {
+	AccountIndex   uint32   `json:"account_index"`
+	AddressIndices []uint32 `json:"address_indices,omitempty"`
+	AllAccounts    bool     `json:"all_accounts,omitempty"`
+	Strict         bool     `json:"strict,omitempty"`
+}
+
+type SubaddressBalance struct {
+	AccountIndex      uint32        `json:"account_index"`
+	AddressIndex      uint32        `json:"address_index"`
+	Address           string        `json:"address"`
+	Balance           monero.Amount `json:"balance"`
+	UnlockedBalance   monero.Amount `json:"unlocked_balance"`
+	Label             string        `json:"label"`
+	NumUnspentOutputs uint64        `json:"num_unspent_outputs"`
+	TimeToUnlock      uint64        `json:"time_to_unlock"`
+	BlocksToUnlock    uint64        `json:"blocks_to_unlock"`
+}
+
+type GetBalanceResult struct {
+	Balance              monero.Amount       `json:"balance"`
+	UnlockedBalance      monero.Amount       `json:"unlocked_balance"`
+	MultisigImportNeeded bool                `json:"multisig_import_needed"`
+	TimeToUnlock         uint64              `json:"time_to_unlock"`
+	BlocksToUnlock       uint64              `json:"blocks_to_unlock"`
+	PerSubaddress        []SubaddressBalance `json:"per_subaddress"`
+}
+
+// get_balance
+func (proxy *WalletRPCProxy) GetBalance(ctx context.Context, request GetBalanceRequest) (GetBalanceResult, error) {
+	result := GetBalanceResult{}
+	err := proxy.client.callJson(ctx, "get_balance", request, &result)
+	return result, err
+}
+
+type AddressInfo struct {
+	Address      string `json:"address"`
+	Label        string `json:"label"`
+	AddressIndex uint32 `json:"address_index"`
+	Used         bool   `json:"used"`
+}
+
+type GetAddressResult struct {
+	Address   string        `json:"address"`
+	Addresses []AddressInfo `json:"addresses"`
+}
 
-	rpc_method := "get_balance"
+// get_address：address_index为空时返回账户下的所有地址
+func (proxy *WalletRPCProxy) GetAddress(ctx context.Context, account_index uint32, address_index []uint32) (GetAddressResult, error) {
 	params := map[string]interface{}{
 		"account_index": account_index,
-		"all_accounts":  all_acounts,
-		"strict":        strict,
-	}
-	// if address_indices != nil {
-	// 	params["address_indices"] = address_indices
-	// }
-	response := JsonMethodRequest(proxy.json_rpc_url, rpc_method, params, proxy.rpc_user, proxy.rpc_password)
-	if result, ok := response["result"].(map[string]interface{}); ok {
-		balance := int64(result["balance"].(float64))
-		unlocked_balance := int64(result["unlocked_balance"].(float64))
-		return balance, unlocked_balance
-	}
-	log.Println("Error occur when get balance!")
-	log.Println("response:", response)
-	return -1, -1
-}
-
-func (proxy *WalletRPCProxy) Transfer(
-	destinations []map[string]interface{}, account_index uint, subaddr_indices []uint, get_tx_key bool,
-	do_not_relay bool, get_tx_hex bool, get_tx_metadata bool) map[string]interface{} {
-
-	rpc_method := "transfer"
-	params := map[string]interface{}{
-		"destinations":    destinations,
-		"account_index":   account_index,
-		"subaddr_indices": subaddr_indices,
-		"get_tx_key":      get_tx_key,
-		"do_not_relay":    do_not_relay,
-		"get_tx_hex":      get_tx_hex,
-		"get_tx_metadata": get_tx_metadata,
-	}
-	response := JsonMethodRequest(proxy.json_rpc_url, rpc_method, params, proxy.rpc_user, proxy.rpc_password)
-	if result, ok := response["result"].(map[string]interface{}); ok {
-		fmt.Println(result)
-		return result
-	}
-	log.Println("Error occur when transfer!")
-	log.Println("response:", response)
+	}
+	if len(address_index) > 0 {
+		params["address_index"] = address_index
+	}
+	result := GetAddressResult{}
+	err := proxy.client.callJson(ctx, "get_address", params, &result)
+	return result, err
+}
+
+// get_address_index
+func (proxy *WalletRPCProxy) GetAddressIndex(ctx context.Context, address string) (SubaddressIndex, error) {
+	params := map[string]interface{}{
+		"address": address,
+	}
+	result := struct {
+		Index SubaddressIndex `json:"index"`
+	}{}
+	err := proxy.client.callJson(ctx, "get_address_index", params, &result)
+	return result.Index, err
+}
+
+type CreateAddressResult struct {
+	Address        string   `json:"address"`
+	AddressIndex   uint32   `json:"address_index"`
+	Addresses      []string `json:"addresses"`
+	AddressIndices []uint32 `json:"address_indices"`
+}
+
+// create_address：count为0时创建一个地址
+func (proxy *WalletRPCProxy) CreateAddress(ctx context.Context, account_index uint32, label string, count uint32) (CreateAddressResult, error) {
+	params := map[string]interface{}{
+		"account_index": account_index,
+		"label":         label,
+	}
+	if count > 0 {
+		params["count"] = count
+	}
+	result := CreateAddressResult{}
+	err := proxy.client.callJson(ctx, "create_address", params, &result)
+	return result, err
+}
+
+// label_address
+func (proxy *WalletRPCProxy) LabelAddress(ctx context.Context, index SubaddressIndex, label string) error {
+	params := map[string]interface{}{
+		"index": index,
+		"label": label,
+	}
+	return proxy.client.callJson(ctx, "label_address", params, nil)
+}
+
+type SubaddressAccount struct {
+	AccountIndex    uint32        `json:"account_index"`
+	Balance         monero.Amount `json:"balance"`
+	BaseAddress     string        `json:"base_address"`
+	Label           string        `json:"label"`
+	Tag             string        `json:"tag"`
+	UnlockedBalance monero.Amount `json:"unlocked_balance"`
+}
+
+type GetAccountsResult struct {
+	SubaddressAccounts   []SubaddressAccount `json:"subaddress_accounts"`
+	TotalBalance         monero.Amount       `json:"total_balance"`
+	TotalUnlockedBalance monero.Amount       `json:"total_unlocked_balance"`
+}
+
+// get_accounts：tag为空时返回所有账户
+func (proxy *WalletRPCProxy) GetAccounts(ctx context.Context, tag string, strict_balances bool) (GetAccountsResult, error) {
+	params := map[string]interface{}{
+		"strict_balances": strict_balances,
+	}
+	if tag != "" {
+		params["tag"] = tag
+	}
+	result := GetAccountsResult{}
+	err := proxy.client.callJson(ctx, "get_accounts", params, &result)
+	return result, err
+}
+
+type CreateAccountResult struct {
+	AccountIndex uint32 `json:"account_index"`
+	Address      string `json:"address"`
+}
+
+// create_account
+func (proxy *WalletRPCProxy) CreateAccount(ctx context.Context, label string) (CreateAccountResult, error) {
+	params := map[string]interface{}{
+		"label": label,
+	}
+	result := CreateAccountResult{}
+	err := proxy.client.callJson(ctx, "create_account", params, &result)
+	return result, err
+}
+
+// get_height：钱包已同步到的高度
+func (proxy *WalletRPCProxy) GetHeight(ctx context.Context) (uint64, error) {
+	result := struct {
+		Height uint64 `json:"height"`
+	}{}
+	err := proxy.client.callJson(ctx, "get_height", nil, &result)
+	return result.Height, err
+}
+
+/*
+	=========
+	Transfers
+	=========
+*/
+
+// incoming_transfers的transfer_type
+const (
+	TransferTypeAll         = "all"
+	TransferTypeAvailable   = "available"
+	TransferTypeUnavailable = "unavailable"
+)
+
+type IncomingTransfer struct {
+	Amount       monero.Amount   `json:"amount"`
+	BlockHeight  uint64          `json:"block_height"`
+	Frozen       bool            `json:"frozen"`
+	GlobalIndex  uint64          `json:"global_index"`
+	KeyImage     string          `json:"key_image"`
+	Pubkey       string          `json:"pubkey"`
+	Spent        bool            `json:"spent"`
+	SubaddrIndex SubaddressIndex `json:"subaddr_index"`
+	TxHash       string          `json:"tx_hash"`
+	Unlocked     bool            `json:"unlocked"`
+}
+
+// incoming_transfers
+func (proxy *WalletRPCProxy) IncomingTransfers(ctx context.Context, transfer_type string, account_index uint32, subaddr_indices []uint32) ([]IncomingTransfer, error) {
+	params := map[string]interface{}{
+		"transfer_type": transfer_type,
+		"account_index": account_index,
+	}
+	if len(subaddr_indices) > 0 {
+		params["subaddr_indices"] = subaddr_indices
+	}
+	result := struct {
+		Transfers []IncomingTransfer `json:"transfers"`
+	}{}
+	err := proxy.client.callJson(ctx, "incoming_transfers", params, &result)
+	return result.Transfers, err
+}
+
+type GetTransfersRequest struct {
+	In             bool     `json:"in,omitempty"`
+	Out            bool     `json:"out,omitempty"`
+	Pending        bool     `json:"pending,omitempty"`
+	Failed         bool     `json:"failed,omitempty"`
+	Pool           bool     `json:"pool,omitempty"`
+	FilterByHeight bool     `json:"filter_by_height,omitempty"`
+	MinHeight      uint64   `json:"min_height,omitempty"`
+	MaxHeight      uint64   `json:"max_height,omitempty"`
+	AccountIndex   uint32   `json:"account_index"`
+	SubaddrIndices []uint32 `json:"subaddr_indices,omitempty"`
+	AllAccounts    bool     `json:"all_accounts,omitempty"`
+}
+
+type TransferEntry struct {
+	Address                         string            `json:"address"`
+	Amount                          monero.Amount     `json:"amount"`
+	Amounts                         []monero.Amount   `json:"amounts"`
+	Confirmations                   uint64            `json:"confirmations"`
+	Destinations                    []Destination     `json:"destinations"`
+	DoubleSpendSeen                 bool              `json:"double_spend_seen"`
+	Fee                             monero.Amount     `json:"fee"`
+	Height                          uint64            `json:"height"`
+	Locked                          bool              `json:"locked"`
+	Note                            string            `json:"note"`
+	PaymentId                       string            `json:"payment_id"`
+	SubaddrIndex                    SubaddressIndex   `json:"subaddr_index"`
+	SubaddrIndices                  []SubaddressIndex `json:"subaddr_indices"`
+	SuggestedConfirmationsThreshold uint64            `json:"suggested_confirmations_threshold"`
+	Timestamp                       uint64            `json:"timestamp"`
+	Txid                            string            `json:"txid"`
+	Type                            string            `json:"type"`
+	UnlockTime                      uint64            `json:"unlock_time"`
+}
+
+type GetTransfersResult struct {
+	In      []TransferEntry `json:"in"`
+	Out     []TransferEntry `json:"out"`
+	Pending []TransferEntry `json:"pending"`
+	Failed  []TransferEntry `json:"failed"`
+	Pool    []TransferEntry `json:"pool"`
+}
+
+// get_transfers
+func (proxy *WalletRPCProxy) GetTransfers(ctx context.Context, request GetTransfersRequest) (GetTransfersResult, error) {
+	result := GetTransfersResult{}
+	err := proxy.client.callJson(ctx, "get_transfers", request, &result)
+	return result, err
+}
+
+type GetTransferByTxidResult struct {
+	Transfer  TransferEntry   `json:"transfer"`
+	Transfers []TransferEntry `json:"transfers"`
+}
+
+// get_transfer_by_txid
+func (proxy *WalletRPCProxy) GetTransferByTxid(ctx context.Context, txid string, account_index uint32) (GetTransferByTxidResult, error) {
+	params := map[string]interface{}{
+		"txid":          txid,
+		"account_index": account_index,
+	}
+	result := GetTransferByTxidResult{}
+	err := proxy.client.callJson(ctx, "get_transfer_by_txid", params, &result)
+	return result, err
+}
+
+// transfer和transfer_split的参数
+type TransferRequest struct {
+	Destinations   []Destination `json:"destinations"`
+	AccountIndex   uint32        `json:"account_index"`
+	SubaddrIndices []uint32      `json:"subaddr_indices,omitempty"`
+	Priority       uint32        `json:"priority,omitempty"`
+	RingSize       uint32        `json:"ring_size,omitempty"`
+	UnlockTime     uint64        `json:"unlock_time,omitempty"`
+	PaymentId      string        `json:"payment_id,omitempty"`
+	GetTxKey       bool          `json:"get_tx_key,omitempty"`
+	GetTxKeys      bool          `json:"get_tx_keys,omitempty"`
+	DoNotRelay     bool          `json:"do_not_relay,omitempty"`
+	GetTxHex       bool          `json:"get_tx_hex,omitempty"`
+	GetTxMetadata  bool          `json:"get_tx_metadata,omitempty"`
+}
+
+type KeyImageList struct {
+	KeyImages []string `json:"key_images"`
+}
+
+type TransferResult struct {
+	Amount         monero.Amount `json:"amount"`
+	Fee            monero.Amount `json:"fee"`
+	MultisigTxset  string        `json:"multisig_txset"`
+	TxBlob         string        `json:"tx_blob"`
+	TxHash         string        `json:"tx_hash"`
+	TxKey          string        `json:"tx_key"`
+	TxMetadata     string        `json:"tx_metadata"`
+	UnsignedTxset  string        `json:"unsigned_txset"`
+	Weight         uint64        `json:"weight"`
+	SpentKeyImages KeyImageList  `json:"spent_key_images"`
+}
+
+// transfer
+func (proxy *WalletRPCProxy) Transfer(ctx context.Context, request TransferRequest) (TransferResult, error) {
+	result := TransferResult{}
+	if err := checkDestinations(request.Destinations); err != nil {
+		return result, err
+	}
+	err := proxy.client.callJson(ctx, "transfer", request, &result)
+	return result, err
+}
+
+// 发送前离线校验地址的格式和checksum，网络类型由wallet-rpc检查
+func checkAddress(encoded string) error {
+	if err := address.Validate(encoded); err != nil {
+		return fmt.Errorf("invalid address %q: %w", encoded, err)
+	}
 	return nil
 }
+
+func checkDestinations(destinations []Destination) error {
+	for _, destination := range destinations {
+		if err := checkAddress(destination.Address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transfer_split、sweep_all和sweep_dust可能生成多笔交易，结果按交易一一对应
+type TransferSplitResult struct {
+	AmountList         []monero.Amount `json:"amount_list"`
+	FeeList            []monero.Amount `json:"fee_list"`
+	WeightList         []uint64        `json:"weight_list"`
+	MultisigTxset      string          `json:"multisig_txset"`
+	TxBlobList         []string        `json:"tx_blob_list"`
+	TxHashList         []string        `json:"tx_hash_list"`
+	TxKeyList          []string        `json:"tx_key_list"`
+	TxMetadataList     []string        `json:"tx_metadata_list"`
+	UnsignedTxset      string          `json:"unsigned_txset"`
+	SpentKeyImagesList []KeyImageList  `json:"spent_key_images_list"`
+}
+
+// transfer_split
+func (proxy *WalletRPCProxy) TransferSplit(ctx context.Context, request TransferRequest) (TransferSplitResult, error) {
+	result := TransferSplitResult{}
+	if err := checkDestinations(request.Destinations); err != nil {
+		return result, err
+	}
+	err := proxy.client.callJson(ctx, "transfer_split", request, &result)
+	return result, err
+}
+
+type SweepAllRequest struct {
+	Address           string        `json:"address"`
+	AccountIndex      uint32        `json:"account_index"`
+	SubaddrIndices    []uint32      `json:"subaddr_indices,omitempty"`
+	SubaddrIndicesAll bool          `json:"subaddr_indices_all,omitempty"`
+	Priority          uint32        `json:"priority,omitempty"`
+	RingSize          uint32        `json:"ring_size,omitempty"`
+	Outputs           uint64        `json:"outputs,omitempty"`
+	UnlockTime        uint64        `json:"unlock_time,omitempty"`
+	GetTxKeys         bool          `json:"get_tx_keys,omitempty"`
+	BelowAmount       monero.Amount `json:"below_amount,omitempty"`
+	DoNotRelay        bool          `json:"do_not_relay,omitempty"`
+	GetTxHex          bool          `json:"get_tx_hex,omitempty"`
+	GetTxMetadata     bool          `json:"get_tx_metadata,omitempty"`
+}
+
+// sweep_all
+func (proxy *WalletRPCProxy) SweepAll(ctx context.Context, request SweepAllRequest) (TransferSplitResult, error) {
+	result := TransferSplitResult{}
+	if err := checkAddress(request.Address); err != nil {
+		return result, err
+	}
+	err := proxy.client.callJson(ctx, "sweep_all", request, &result)
+	return result, err
+}
+
+type SweepSingleRequest struct {
+	Address       string `json:"address"`
+	KeyImage      string `json:"key_image"`
+	Priority      uint32 `json:"priority,omitempty"`
+	RingSize      uint32 `json:"ring_size,omitempty"`
+	Outputs       uint64 `json:"outputs,omitempty"`
+	UnlockTime    uint64 `json:"unlock_time,omitempty"`
+	GetTxKey      bool   `json:"get_tx_key,omitempty"`
+	DoNotRelay    bool   `json:"do_not_relay,omitempty"`
+	GetTxHex      bool   `json:"get_tx_hex,omitempty"`
+	GetTxMetadata bool   `json:"get_tx_metadata,omitempty"`
+}
+
+// sweep_single
+func (proxy *WalletRPCProxy) SweepSingle(ctx context.Context, request SweepSingleRequest) (TransferResult, error) {
+	result := TransferResult{}
+	if err := checkAddress(request.Address); err != nil {
+		return result, err
+	}
+	err := proxy.client.callJson(ctx, "sweep_single", request, &result)
+	return result, err
+}
+
+type SweepDustRequest struct {
+	GetTxKeys     bool `json:"get_tx_keys,omitempty"`
+	DoNotRelay    bool `json:"do_not_relay,omitempty"`
+	GetTxHex      bool `json:"get_tx_hex,omitempty"`
+	GetTxMetadata bool `json:"get_tx_metadata,omitempty"`
+}
+
+// sweep_dust
+func (proxy *WalletRPCProxy) SweepDust(ctx context.Context, request SweepDustRequest) (TransferSplitResult, error) {
+	result := TransferSplitResult{}
+	err := proxy.client.callJson(ctx, "sweep_dust", request, &result)
+	return result, err
+}
+
+// relay_tx：hex为do_not_relay时返回的tx_metadata
+func (proxy *WalletRPCProxy) RelayTx(ctx context.Context, hex string) (string, error) {
+	params := map[string]interface{}{
+		"hex": hex,
+	}
+	result := struct {
+		TxHash string `json:"tx_hash"`
+	}{}
+	err := proxy.client.callJson(ctx, "relay_tx", params, &result)
+	return result.TxHash, err
+}
+
+/*
+	===============
+	Wallet Maintain
+	===============
+*/
+
+// store：将钱包保存到文件
+func (proxy *WalletRPCProxy) Store(ctx context.Context) error {
+	return proxy.client.callJson(ctx, "store", nil, nil)
+}
+
+type RefreshResult struct {
+	BlocksFetched uint64 `json:"blocks_fetched"`
+	ReceivedMoney bool   `json:"received_money"`
+}
+
+// refresh：start_height为0时从钱包当前高度开始
+func (proxy *WalletRPCProxy) Refresh(ctx context.Context, start_height uint64) (RefreshResult, error) {
+	params := map[string]interface{}{}
+	if start_height > 0 {
+		params["start_height"] = start_height
+	}
+	result := RefreshResult{}
+	err := proxy.client.callJson(ctx, "refresh", params, &result)
+	return result, err
+}
+
+// rescan_blockchain：hard为true时同时清除已知的key image等信息
+func (proxy *WalletRPCProxy) RescanBlockchain(ctx context.Context, hard bool) error {
+	params := map[string]interface{}{
+		"hard": hard,
+	}
+	return proxy.client.callJson(ctx, "rescan_blockchain", params, nil)
+}
+
+// query_key的key_type
+const (
+	KeyTypeMnemonic = "mnemonic"
+	KeyTypeViewKey  = "view_key"
+	KeyTypeSpendKey = "spend_key"
+)
+
+// query_key
+func (proxy *WalletRPCProxy) QueryKey(ctx context.Context, key_type string) (string, error) {
+	params := map[string]interface{}{
+		"key_type": key_type,
+	}
+	result := struct {
+		Key string `json:"key"`
+	}{}
+	err := proxy.client.callJson(ctx, "query_key", params, &result)
+	return result.Key, err
+}
+
+/*
+	======
+	Proofs
+	======
+*/
+
+// get_tx_key
+func (proxy *WalletRPCProxy) GetTxKey(ctx context.Context, txid string) (string, error) {
+	params := map[string]interface{}{
+		"txid": txid,
+	}
+	result := struct {
+		TxKey string `json:"tx_key"`
+	}{}
+	err := proxy.client.callJson(ctx, "get_tx_key", params, &result)
+	return result.TxKey, err
+}
+
+type CheckTxKeyResult struct {
+	Confirmations uint64        `json:"confirmations"`
+	InPool        bool          `json:"in_pool"`
+	Received      monero.Amount `json:"received"`
+}
+
+// check_tx_key
+func (proxy *WalletRPCProxy) CheckTxKey(ctx context.Context, txid string, tx_key string, address string) (CheckTxKeyResult, error) {
+	params := map[string]interface{}{
+		"txid":    txid,
+		"tx_key":  tx_key,
+		"address": address,
+	}
+	result := CheckTxKeyResult{}
+	err := proxy.client.callJson(ctx, "check_tx_key", params, &result)
+	return result, err
+}
+
+// get_tx_proof
+func (proxy *WalletRPCProxy) GetTxProof(ctx context.Context, txid string, address string, message string) (string, error) {
+	params := map[string]interface{}{
+		"txid":    txid,
+		"address": address,
+		"message": message,
+	}
+	result := struct {
+		Signature string `json:"signature"`
+	}{}
+	err := proxy.client.callJson(ctx, "get_tx_proof", params, &result)
+	return result.Signature, err
+}
+
+type CheckTxProofResult struct {
+	Confirmations uint64        `json:"confirmations"`
+	Good          bool          `json:"good"`
+	InPool        bool          `json:"in_pool"`
+	Received      monero.Amount `json:"received"`
+}
+
+// check_tx_proof
+func (proxy *WalletRPCProxy) CheckTxProof(ctx context.Context, txid string, address string, message string, signature string) (CheckTxProofResult, error) {
+	params := map[string]interface{}{
+		"txid":      txid,
+		"address":   address,
+		"message":   message,
+		"signature": signature,
+	}
+	result := CheckTxProofResult{}
+	err := proxy.client.callJson(ctx, "check_tx_proof", params, &result)
+	return result, err
+}
+
+// all为true时证明整个钱包的余额，否则证明account_index账户中至少有amount
+type GetReserveProofRequest struct {
+	All          bool          `json:"all"`
+	AccountIndex uint32        `json:"account_index"`
+	Amount       monero.Amount `json:"amount"`
+	Message      string        `json:"message,omitempty"`
+}
+
+// get_reserve_proof
+func (proxy *WalletRPCProxy) GetReserveProof(ctx context.Context, request GetReserveProofRequest) (string, error) {
+	result := struct {
+		Signature string `json:"signature"`
+	}{}
+	err := proxy.client.callJson(ctx, "get_reserve_proof", request, &result)
+	return result.Signature, err
+}
+
+type CheckReserveProofResult struct {
+	Good  bool          `json:"good"`
+	Spent monero.Amount `json:"spent"`
+	Total monero.Amount `json:"total"`
+}
+
+// check_reserve_proof
+func (proxy *WalletRPCProxy) CheckReserveProof(ctx context.Context, address string, message string, signature string) (CheckReserveProofResult, error) {
+	params := map[string]interface{}{
+		"address":   address,
+		"message":   message,
+		"signature": signature,
+	}
+	result := CheckReserveProofResult{}
+	err := proxy.client.callJson(ctx, "check_reserve_proof", params, &result)
+	return result, err
+}
+
+// sign：使用主地址的spend key对data签名
+func (proxy *WalletRPCProxy) Sign(ctx context.Context, data string) (string, error) {
+	params := map[string]interface{}{
+		"data": data,
+	}
+	result := struct {
+		Signature string `json:"signature"`
+	}{}
+	err := proxy.client.callJson(ctx, "sign", params, &result)
+	return result.Signature, err
+}
+
+// verify
+func (proxy *WalletRPCProxy) Verify(ctx context.Context, data string, address string, signature string) (bool, error) {
+	params := map[string]interface{}{
+		"data":      data,
+		"address":   address,
+		"signature": signature,
+	}
+	result := struct {
+		Good bool `json:"good"`
+	}{}
+	err := proxy.client.callJson(ctx, "verify", params, &result)
+	return result.Good, err
+}
+
+/*
+	=====================
+	URI/Address Utilities
+	=====================
+*/
+
+type PaymentURI struct {
+	Address       string        `json:"address"`
+	Amount        monero.Amount `json:"amount,omitempty"`
+	PaymentId     string        `json:"payment_id,omitempty"`
+	RecipientName string        `json:"recipient_name,omitempty"`
+	TxDescription string        `json:"tx_description,omitempty"`
+}
+
+// make_uri
+func (proxy *WalletRPCProxy) MakeURI(ctx context.Context, uri PaymentURI) (string, error) {
+	result := struct {
+		Uri string `json:"uri"`
+	}{}
+	err := proxy.client.callJson(ctx, "make_uri", uri, &result)
+	return result.Uri, err
+}
+
+// parse_uri
+func (proxy *WalletRPCProxy) ParseURI(ctx context.Context, uri string) (PaymentURI, error) {
+	params := map[string]interface{}{
+		"uri": uri,
+	}
+	result := struct {
+		Uri PaymentURI `json:"uri"`
+	}{}
+	err := proxy.client.callJson(ctx, "parse_uri", params, &result)
+	return result.Uri, err
+}
+
+type ValidateAddressResult struct {
+	Valid            bool   `json:"valid"`
+	Integrated       bool   `json:"integrated"`
+	Subaddress       bool   `json:"subaddress"`
+	Nettype          string `json:"nettype"`
+	OpenaliasAddress string `json:"openalias_address"`
+}
+
+// validate_address：any_net_type为false时只接受钱包所在网络的地址
+func (proxy *WalletRPCProxy) ValidateAddress(ctx context.Context, address string, any_net_type bool, allow_openalias bool) (ValidateAddressResult, error) {
+	params := map[string]interface{}{
+		"address":         address,
+		"any_net_type":    any_net_type,
+		"allow_openalias": allow_openalias,
+	}
+	result := ValidateAddressResult{}
+	err := proxy.client.callJson(ctx, "validate_address", params, &result)
+	return result, err
+}
+
+type MakeIntegratedAddressResult struct {
+	IntegratedAddress string `json:"integrated_address"`
+	PaymentId         string `json:"payment_id"`
+}
+
+// make_integrated_address：standard_address为空时使用钱包的主地址，payment_id为空时随机生成
+func (proxy *WalletRPCProxy) MakeIntegratedAddress(ctx context.Context, standard_address string, payment_id string) (MakeIntegratedAddressResult, error) {
+	result := MakeIntegratedAddressResult{}
+	params := map[string]interface{}{}
+	if standard_address != "" {
+		if err := checkAddress(standard_address); err != nil {
+			return result, err
+		}
+		params["standard_address"] = standard_address
+	}
+	if payment_id != "" {
+		params["payment_id"] = payment_id
+	}
+	err := proxy.client.callJson(ctx, "make_integrated_address", params, &result)
+	return result, err
+}
+
+type SplitIntegratedAddressResult struct {
+	IsSubaddress    bool   `json:"is_subaddress"`
+	PaymentId       string `json:"payment_id"`
+	StandardAddress string `json:"standard_address"`
+}
+
+// split_integrated_address
+func (proxy *WalletRPCProxy) SplitIntegratedAddress(ctx context.Context, integrated_address string) (SplitIntegratedAddressResult, error) {
+	result := SplitIntegratedAddressResult{}
+	if err := checkAddress(integrated_address); err != nil {
+		return result, err
+	}
+	params := map[string]interface{}{
+		"integrated_address": integrated_address,
+	}
+	err := proxy.client.callJson(ctx, "split_integrated_address", params, &result)
+	return result, err
+}
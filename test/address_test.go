@@ -0,0 +1,152 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"gomonero/monero/address"
+	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"testing"
+)
+
+func Test_Base58_RoundTrip(t *testing.T) {
+	for size := 0; size <= 20; size++ {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(0xff - i)
+		}
+		decoded, err := address.DecodeBase58(address.EncodeBase58(data))
+		if err != nil || string(decoded) != string(data) {
+			t.Errorf("round trip of %d bytes failed: %v", size, err)
+		}
+	}
+	// 4个字符不是任何块长度编码后的长度；"zzz"超出2字节块的范围
+	for _, encoded := range []string{"1111", "zzz", "0OIl"} {
+		if _, err := address.DecodeBase58(encoded); !errors.Is(err, address.ErrInvalidBase58) {
+			t.Errorf("expected invalid base58 for %q, got %v", encoded, err)
+		}
+	}
+}
+
+func Test_Address_Parse(t *testing.T) {
+	cases := []struct {
+		encoded      string
+		address_type address.Type
+		payment_id   string
+	}{
+		{rpctest.WalletAddress, address.Standard, ""},
+		{rpctest.WalletSubaddress01, address.Subaddress, ""},
+		{rpctest.WalletIntegrated, address.Integrated, rpctest.WalletPaymentId},
+	}
+	for _, c := range cases {
+		parsed, err := address.Parse(c.encoded)
+		if err != nil {
+			t.Fatalf("Parse(%s) failed: %v", c.encoded, err)
+		}
+		if parsed.Network != address.Testnet || parsed.Type != c.address_type || parsed.PaymentIdHex() != c.payment_id {
+			t.Errorf("unexpected address %s: %s %s %s", c.encoded, parsed.Network, parsed.Type, parsed.PaymentIdHex())
+		}
+		if parsed.String() != c.encoded {
+			t.Errorf("re-encoding %s gave %s", c.encoded, parsed.String())
+		}
+	}
+
+	parsed, _ := address.Parse(rpctest.WalletAddress)
+	if parsed.SpendKeyHex() != rpctest.WalletPublicSpendKey || parsed.ViewKeyHex() != rpctest.WalletPublicViewKey {
+		t.Errorf("unexpected keys %s %s", parsed.SpendKeyHex(), parsed.ViewKeyHex())
+	}
+	// 同一对公钥在主网上的地址
+	parsed.Network = address.Mainnet
+	if parsed.String() != "49XFjDY3PsTKzr6T195M1mUrSoD22LGum8jHPfsHA5MtbEuQuC33TDqbyfwtQcFJ1d1DvXtb1RAAZesepCP7xTn6ER9A7Cr" {
+		t.Errorf("unexpected mainnet address %s", parsed.String())
+	}
+	if _, err := address.ParseForNetwork(rpctest.WalletAddress, address.Mainnet); !errors.Is(err, address.ErrWrongNetwork) {
+		t.Errorf("expected wrong network error, got %v", err)
+	}
+}
+
+func Test_Address_Invalid(t *testing.T) {
+	// 改动最后一个字符，checksum不再匹配
+	tampered := []byte(rpctest.WalletAddress)
+	tampered[len(tampered)-1] = 'S'
+	if err := address.Validate(string(tampered)); !errors.Is(err, address.ErrChecksum) {
+		t.Errorf("expected checksum error, got %v", err)
+	}
+	if err := address.Validate(rpctest.WalletAddress[:90]); err == nil {
+		t.Errorf("expected error for truncated address")
+	}
+	if err := address.Validate(""); err == nil {
+		t.Errorf("expected error for empty address")
+	}
+}
+
+func Test_Address_GenerateSubaddress(t *testing.T) {
+	view_key, spend_public_key := hexKey(t, rpctest.WalletViewKey), hexKey(t, rpctest.WalletPublicSpendKey)
+	wallet := rpctest.CreateWallet(t, rpctest.Config{})
+	proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{})
+	// 与monero-wallet-rpc的get_address比对
+	for _, account := range []uint32{0, 1} {
+		result, err := proxy.GetAddress(context.Background(), account, nil)
+		if err != nil || len(result.Addresses) == 0 {
+			t.Fatalf("GetAddress(%d) failed: %v", account, err)
+		}
+		for _, info := range result.Addresses {
+			generated, err := address.GenerateSubaddress(address.Testnet, view_key, spend_public_key, account, info.AddressIndex)
+			if err != nil || generated.String() != info.Address {
+				t.Errorf("subaddress (%d, %d): generated %s, wallet returned %s: %v", account, info.AddressIndex, generated, info.Address, err)
+			}
+		}
+	}
+	primary, _ := address.GenerateSubaddress(address.Testnet, view_key, spend_public_key, 0, 0)
+	if primary.Type != address.Standard || primary.ViewKeyHex() != rpctest.WalletPublicViewKey {
+		t.Errorf("unexpected primary address %+v", primary)
+	}
+	subaddress, _ := address.GenerateSubaddress(address.Mainnet, view_key, spend_public_key, 0, 1)
+	if parsed, err := address.Parse(subaddress.String()); err != nil || parsed.Network != address.Mainnet || parsed.Type != address.Subaddress {
+		t.Errorf("unexpected mainnet subaddress %s: %v", subaddress, err)
+	}
+	if _, err := address.GenerateSubaddress(address.Testnet, [address.KeySize]byte{0: 0xff, 31: 0xff}, spend_public_key, 0, 1); err == nil {
+		t.Errorf("expected error for unreduced view key")
+	}
+}
+
+func Test_Address_Integrated(t *testing.T) {
+	wallet := rpctest.CreateWallet(t, rpctest.Config{})
+	proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{})
+	result, err := proxy.MakeIntegratedAddress(context.Background(), rpctest.WalletAddress, rpctest.WalletPaymentId)
+	if err != nil {
+		t.Fatalf("MakeIntegratedAddress failed: %v", err)
+	}
+
+	// 与monero-wallet-rpc的make_integrated_address比对
+	primary, _ := address.Parse(rpctest.WalletAddress)
+	payment_id, err := address.ParsePaymentId(rpctest.WalletPaymentId)
+	if err != nil {
+		t.Fatalf("ParsePaymentId failed: %v", err)
+	}
+	integrated, err := primary.Integrated(payment_id)
+	if err != nil || integrated.String() != result.IntegratedAddress || integrated.PaymentIdHex() != result.PaymentId {
+		t.Errorf("generated %s, wallet returned %s: %v", integrated, result.IntegratedAddress, err)
+	}
+	split, err := proxy.SplitIntegratedAddress(context.Background(), integrated.String())
+	if err != nil || split.StandardAddress != integrated.Standard().String() || split.PaymentId != rpctest.WalletPaymentId {
+		t.Errorf("unexpected split %+v: %v", split, err)
+	}
+
+	subaddress, _ := address.Parse(rpctest.WalletSubaddress01)
+	if _, err := subaddress.Integrated(payment_id); !errors.Is(err, address.ErrNotStandard) {
+		t.Errorf("expected ErrNotStandard, got %v", err)
+	}
+	if _, err := integrated.Integrated(payment_id); !errors.Is(err, address.ErrNotStandard) {
+		t.Errorf("expected ErrNotStandard, got %v", err)
+	}
+	for _, bad := range []string{"", "1234", "1234567890abcdeg", "1234567890abcdef00"} {
+		if _, err := address.ParsePaymentId(bad); err == nil {
+			t.Errorf("expected error for payment id %q", bad)
+		}
+	}
+	// 发送前离线校验地址
+	if _, err := proxy.MakeIntegratedAddress(context.Background(), rpctest.WalletAddress[:90]+"1111", ""); err == nil || wallet.Calls("make_integrated_address") != 1 {
+		t.Errorf("expected offline validation error, got %v", err)
+	}
+}
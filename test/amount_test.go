@@ -0,0 +1,122 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gomonero/monero"
+	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"gomonero/web"
+	"math"
+	"testing"
+)
+
+func Test_Amount_Parse(t *testing.T) {
+	cases := map[string]monero.Amount{
+		"1.5":                   1500000000000,
+		"0.000000000001":        1,
+		"1":                     monero.XMR,
+		".25":                   250000000000,
+		"18446744.073709551615": math.MaxUint64,
+	}
+	for xmr, expected := range cases {
+		amount, err := monero.ParseAmount(xmr)
+		if err != nil || amount != expected {
+			t.Errorf("ParseAmount(%q) = %d, %v, expected %d", xmr, amount, err, expected)
+		}
+	}
+	for _, xmr := range []string{"", ".", "-1", "1e3", "0.0000000000001", "1,5"} {
+		if _, err := monero.ParseAmount(xmr); !errors.Is(err, monero.ErrInvalidAmount) {
+			t.Errorf("expected invalid amount for %q, got %v", xmr, err)
+		}
+	}
+	if _, err := monero.ParseAmount("18446744.073709551616"); !errors.Is(err, monero.ErrAmountOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+}
+
+func Test_Amount_Format(t *testing.T) {
+	cases := map[monero.Amount]string{
+		0:             "0",
+		1:             "0.000000000001",
+		1500000000000: "1.5",
+		monero.XMR:    "1",
+	}
+	for amount, expected := range cases {
+		if amount.String() != expected {
+			t.Errorf("%d formatted as %s, expected %s", uint64(amount), amount.String(), expected)
+		}
+	}
+	if monero.Amount(1500000000000).StringFixed() != "1.500000000000" {
+		t.Errorf("unexpected fixed format %s", monero.Amount(1500000000000).StringFixed())
+	}
+}
+
+func Test_Amount_Arithmetic(t *testing.T) {
+	if _, err := monero.Amount(math.MaxUint64).Add(1); !errors.Is(err, monero.ErrAmountOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+	if _, err := monero.Amount(1).Sub(2); !errors.Is(err, monero.ErrAmountUnderflow) {
+		t.Errorf("expected underflow, got %v", err)
+	}
+	if _, err := monero.XMR.Mul(math.MaxUint32); !errors.Is(err, monero.ErrAmountOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+	total, err := monero.SumAmounts(monero.XMR, monero.XMR/2)
+	if err != nil || total != 1500000000000 {
+		t.Errorf("unexpected sum %d, %v", total, err)
+	}
+}
+
+func Test_Amount_JSON(t *testing.T) {
+	value := struct {
+		Atomic monero.Amount    `json:"atomic"`
+		XMR    monero.AmountXMR `json:"xmr"`
+	}{monero.XMR / 2, (monero.XMR / 2).XMR()}
+	data, _ := json.Marshal(value)
+	if string(data) != `{"atomic":500000000000,"xmr":"0.5"}` {
+		t.Errorf("unexpected json %s", data)
+	}
+
+	// 整数和XMR字符串都可以解析
+	destinations := []rpcproxy.Destination{}
+	err := json.Unmarshal([]byte(`[{"amount":500000000000,"address":"a"},{"amount":"0.5","address":"b"}]`), &destinations)
+	if err != nil || destinations[0].Amount != destinations[1].Amount {
+		t.Errorf("unexpected destinations %+v, %v", destinations, err)
+	}
+
+	// null保留原值
+	value.Atomic, value.XMR = monero.XMR, monero.XMR.XMR()
+	if err := json.Unmarshal([]byte(`{"atomic":null,"xmr":null}`), &value); err != nil || value.Atomic != monero.XMR || value.XMR != monero.XMR.XMR() {
+		t.Errorf("null should leave the amount unchanged, got %+v, %v", value, err)
+	}
+	if err := json.Unmarshal([]byte(`{"atomic":nul}`), &value); err == nil {
+		t.Errorf("expected error for invalid json")
+	}
+}
+
+func Test_Web_GetWalletBalance(t *testing.T) {
+	wallet := rpctest.CreateWallet(t, rpctest.Config{})
+	wallet_proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{})
+	result, _ := wallet_proxy.GetBalance(context.Background(), rpcproxy.GetBalanceRequest{})
+
+	for _, xmr := range []bool{true, false} {
+		balance, err := web.GetWalletBalance(context.Background(), wallet_proxy, 0, xmr)
+		if err != nil {
+			t.Fatalf("GetWalletBalance failed: %v", err)
+		}
+		data, _ := json.Marshal(balance)
+		decoded := struct {
+			Balance interface{} `json:"balance"`
+		}{}
+		json.Unmarshal(data, &decoded)
+		expected := interface{}(float64(result.Balance))
+		if xmr {
+			expected = result.Balance.String()
+		}
+		if decoded.Balance != expected {
+			t.Errorf("xmr=%t: unexpected balance %v in %s", xmr, decoded.Balance, data)
+		}
+	}
+}
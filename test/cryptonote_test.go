@@ -0,0 +1,395 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"gomonero/monero/crypto"
+	"gomonero/monero/cryptonote"
+	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"gomonero/web"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testdata/cryptonote中block_testnet_genesis和testnet_开头的是链上的数据，testnet_的交易id见testnet.json
+// synthetic_开头的区块和交易是离线生成的：字段布局与链上一致，但密钥、承诺和签名是随机字节，
+// 只用于测试解析和逐字节的序列化往返，不能用来验证签名或金额
+func loadBlob(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/cryptonote/" + name + ".hex")
+	if err != nil {
+		t.Fatalf("read fixture %s: %v", name, err)
+	}
+	blob, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatalf("decode fixture %s: %v", name, err)
+	}
+	return blob
+}
+
+func Test_Cryptonote_GenesisBlock(t *testing.T) {
+	blob := loadBlob(t, "block_testnet_genesis")
+	block, err := cryptonote.ParseBlock(blob)
+	if err != nil {
+		t.Fatalf("ParseBlock failed: %v", err)
+	}
+	if block.MajorVersion != 1 || block.Nonce != 10001 || len(block.TxHashes) != 0 {
+		t.Errorf("unexpected header: %+v", block.BlockHeader)
+	}
+	miner_tx := block.MinerTx
+	if !miner_tx.IsCoinbase() || miner_tx.Version != 1 || miner_tx.UnlockTime != 60 {
+		t.Errorf("unexpected miner tx: %+v", miner_tx)
+	}
+	if len(miner_tx.Outputs) != 1 || miner_tx.Outputs[0].Amount != 17592186044415 || miner_tx.Outputs[0].Type != cryptonote.TxOutToKey {
+		t.Errorf("unexpected genesis output: %+v", miner_tx.Outputs)
+	}
+	serialized, err := block.Serialize()
+	if err != nil || !bytes.Equal(serialized, blob) {
+		t.Errorf("genesis block did not round trip: %v", err)
+	}
+}
+
+func Test_Cryptonote_Block(t *testing.T) {
+	blob := loadBlob(t, "synthetic_block_v16")
+	block, err := cryptonote.ParseBlock(blob)
+	if err != nil {
+		t.Fatalf("ParseBlock failed: %v", err)
+	}
+	if block.MajorVersion != 16 || block.Timestamp != 1760838071 || len(block.TxHashes) != 3 {
+		t.Errorf("unexpected block: %+v", block.BlockHeader)
+	}
+	miner_tx := block.MinerTx
+	if miner_tx.Inputs[0].Height != 2712344 || miner_tx.RctSignatures == nil || miner_tx.RctSignatures.Type != cryptonote.RctTypeNull {
+		t.Errorf("unexpected miner tx: %+v", miner_tx)
+	}
+	if output := miner_tx.Outputs[0]; output.Type != cryptonote.TxOutToTaggedKey || output.ViewTag != 0x5a || output.Amount != 600000000000 {
+		t.Errorf("unexpected coinbase output: %+v", output)
+	}
+	serialized, err := block.Serialize()
+	if err != nil || !bytes.Equal(serialized, blob) {
+		t.Errorf("block did not round trip: %v", err)
+	}
+}
+
+func Test_Cryptonote_Transactions(t *testing.T) {
+	cases := []struct {
+		name     string
+		version  uint64
+		rct_type byte
+		rings    []int
+		tagged   bool
+		pruned   bool
+	}{
+		{"synthetic_tx_v1", 1, 0, []int{3, 2}, false, false},
+		{"synthetic_tx_rct1", 2, cryptonote.RctTypeFull, []int{4}, false, false},
+		{"synthetic_tx_rct2", 2, cryptonote.RctTypeSimple, []int{5, 5}, false, false},
+		{"synthetic_tx_rct3", 2, cryptonote.RctTypeBulletproof, []int{11}, false, false},
+		{"synthetic_tx_rct4", 2, cryptonote.RctTypeBulletproof2, []int{11}, false, false},
+		{"synthetic_tx_rct5", 2, cryptonote.RctTypeCLSAG, []int{11, 11}, false, false},
+		{"synthetic_tx_rct6", 2, cryptonote.RctTypeBulletproofPlus, []int{16, 16}, true, false},
+		{"synthetic_tx_rct6_pruned", 2, cryptonote.RctTypeBulletproofPlus, []int{16, 16}, true, true},
+	}
+	for _, c := range cases {
+		blob := loadBlob(t, c.name)
+		tx, err := cryptonote.ParseTransaction(blob)
+		if err != nil {
+			t.Fatalf("%s: ParseTransaction failed: %v", c.name, err)
+		}
+		if tx.Version != c.version || tx.Pruned != c.pruned || len(tx.Inputs) != len(c.rings) {
+			t.Errorf("%s: unexpected transaction version %d pruned %t", c.name, tx.Version, tx.Pruned)
+		}
+		for i, ring := range c.rings {
+			if tx.Inputs[i].RingSize() != ring {
+				t.Errorf("%s: input %d has ring size %d, expected %d", c.name, i, tx.Inputs[i].RingSize(), ring)
+			}
+		}
+		for _, output := range tx.Outputs {
+			if (output.Type == cryptonote.TxOutToTaggedKey) != c.tagged {
+				t.Errorf("%s: unexpected output type %d", c.name, output.Type)
+			}
+		}
+		if c.version == 2 {
+			rct := tx.RctSignatures
+			if rct.Type != c.rct_type || rct.TxnFee != 30660000 || (rct.Prunable == nil) != c.pruned {
+				t.Errorf("%s: unexpected rct signatures type %d fee %d", c.name, rct.Type, rct.TxnFee)
+			}
+		}
+		serialized, err := tx.Serialize()
+		if err != nil || !bytes.Equal(serialized, blob) {
+			t.Errorf("%s: transaction did not round trip: %v", c.name, err)
+		}
+	}
+
+	tx, _ := cryptonote.ParseTransaction(loadBlob(t, "synthetic_tx_rct6"))
+	prunable := tx.RctSignatures.Prunable
+	if len(prunable.BulletproofsPlus) != 1 || len(prunable.CLSAGs) != 2 || len(prunable.CLSAGs[0].S) != 16 || len(prunable.PseudoOuts) != 2 {
+		t.Errorf("unexpected prunable data: %d bpp, %d clsags", len(prunable.BulletproofsPlus), len(prunable.CLSAGs))
+	}
+	// 剪枝后的交易是完整交易的前缀
+	pruned, _ := tx.SerializeRctBase()
+	if !bytes.HasPrefix(loadBlob(t, "synthetic_tx_rct6"), append(tx.SerializePrefix(), pruned...)) {
+		t.Errorf("prefix and rct base are not a prefix of the blob")
+	}
+}
+
+// testnet.json中的一项，由Test_RecordTestnetBlobs录制
+type testnetBlob struct {
+	Id           string `json:"id"` // 交易id或区块哈希
+	Height       uint64 `json:"height"`
+	PrunableHash string `json:"prunable_hash,omitempty"` // 只有剪枝的交易有
+}
+
+const testnetManifest = "testdata/cryptonote/testnet.json"
+
+// 读取testnet.json，还没有录制时跳过测试
+func loadTestnetManifest(t *testing.T) map[string]testnetBlob {
+	t.Helper()
+	data, err := os.ReadFile(testnetManifest)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skip("no testnet blobs recorded, run Test_RecordTestnetBlobs against a testnet monerod")
+	}
+	manifest := map[string]testnetBlob{}
+	if err == nil {
+		err = json.Unmarshal(data, &manifest)
+	}
+	if err != nil {
+		t.Fatalf("read %s: %v", testnetManifest, err)
+	}
+	return manifest
+}
+
+func Test_Cryptonote_TestnetTransactions(t *testing.T) {
+	manifest := loadTestnetManifest(t)
+	cases := []struct {
+		name     string
+		version  uint64
+		rct_type byte
+		tagged   bool
+		pruned   bool
+	}{
+		{"tx_v1", 1, 0, false, false},
+		{"tx_rct1", 2, cryptonote.RctTypeFull, false, false},
+		{"tx_rct2", 2, cryptonote.RctTypeSimple, false, false},
+		{"tx_rct3", 2, cryptonote.RctTypeBulletproof, false, false},
+		{"tx_rct4", 2, cryptonote.RctTypeBulletproof2, false, false},
+		{"tx_rct5", 2, cryptonote.RctTypeCLSAG, false, false},
+		{"tx_rct6", 2, cryptonote.RctTypeBulletproofPlus, true, false},
+		{"tx_rct6_pruned", 2, cryptonote.RctTypeBulletproofPlus, true, true},
+	}
+	for _, c := range cases {
+		if _, ok := manifest[c.name]; !ok {
+			t.Errorf("%s: not recorded in %s", c.name, testnetManifest)
+			continue
+		}
+		blob := loadBlob(t, "testnet_"+c.name)
+		tx, err := cryptonote.ParseTransaction(blob)
+		if err != nil {
+			t.Errorf("%s: ParseTransaction failed: %v", c.name, err)
+			continue
+		}
+		if tx.Version != c.version || tx.Pruned != c.pruned || tx.IsCoinbase() {
+			t.Errorf("%s: unexpected transaction version %d pruned %t", c.name, tx.Version, tx.Pruned)
+		}
+		if c.version == 2 && tx.RctSignatures.Type != c.rct_type {
+			t.Errorf("%s: unexpected rct type %d", c.name, tx.RctSignatures.Type)
+		}
+		for _, output := range tx.Outputs {
+			if (output.Type == cryptonote.TxOutToTaggedKey) != c.tagged {
+				t.Errorf("%s: unexpected output type %d", c.name, output.Type)
+			}
+		}
+		serialized, err := tx.Serialize()
+		if err != nil || !bytes.Equal(serialized, blob) {
+			t.Errorf("%s: transaction did not round trip: %v", c.name, err)
+		}
+	}
+}
+
+// 交易哈希和区块哈希与链上的id一致
+func Test_Cryptonote_TestnetHashes(t *testing.T) {
+	manifest := loadTestnetManifest(t)
+	for _, name := range []string{"tx_v1", "tx_rct1", "tx_rct2", "tx_rct3", "tx_rct4", "tx_rct5", "tx_rct6"} {
+		expected, ok := manifest[name]
+		if !ok {
+			t.Errorf("%s: not recorded in %s", name, testnetManifest)
+			continue
+		}
+		tx, err := cryptonote.ParseTransaction(loadBlob(t, "testnet_"+name))
+		if err != nil {
+			t.Errorf("%s: ParseTransaction failed: %v", name, err)
+			continue
+		}
+		if hash, err := tx.Hash(); err != nil || hash.String() != expected.Id {
+			t.Errorf("%s: computed hash %s, on-chain id %s: %v", name, hash, expected.Id, err)
+		}
+	}
+
+	// 剪枝的交易用monerod给出的prunable_hash计算哈希，完整交易的PrunableHash与之相同
+	expected, ok := manifest["tx_rct6_pruned"]
+	if !ok {
+		t.Fatalf("tx_rct6_pruned: not recorded in %s", testnetManifest)
+	}
+	pruned, err := cryptonote.ParseTransaction(loadBlob(t, "testnet_tx_rct6_pruned"))
+	if err != nil {
+		t.Fatalf("ParseTransaction failed: %v", err)
+	}
+	prunable_hash := cryptonote.Hash{}
+	if err := prunable_hash.UnmarshalText([]byte(expected.PrunableHash)); err != nil {
+		t.Fatalf("bad prunable_hash %q: %v", expected.PrunableHash, err)
+	}
+	if hash, err := pruned.HashWithPrunableHash(prunable_hash); err != nil || hash.String() != expected.Id {
+		t.Errorf("pruned: computed hash %s, on-chain id %s: %v", hash, expected.Id, err)
+	}
+	full, _ := cryptonote.ParseTransaction(loadBlob(t, "testnet_tx_rct6"))
+	if hash, err := full.PrunableHash(); err != nil || hash != prunable_hash {
+		t.Errorf("computed prunable hash %s, monerod returned %s: %v", hash, prunable_hash, err)
+	}
+
+	// 非创世区块：哈希与链上一致，并且包含tx_rct6
+	expected, ok = manifest["block"]
+	if !ok {
+		t.Fatalf("block: not recorded in %s", testnetManifest)
+	}
+	block_hash := cryptonote.Hash{}
+	if err := block_hash.UnmarshalText([]byte(expected.Id)); err != nil {
+		t.Fatalf("bad block hash %q: %v", expected.Id, err)
+	}
+	block, err := cryptonote.VerifyBlock(loadBlob(t, "testnet_block"), block_hash)
+	if err != nil {
+		t.Fatalf("VerifyBlock failed: %v", err)
+	}
+	if block.MinerTx.Inputs[0].Height != expected.Height || !slices.ContainsFunc(block.TxHashes, func(hash cryptonote.Hash) bool {
+		return hash.String() == manifest["tx_rct6"].Id
+	}) {
+		t.Errorf("block at %d does not contain %s", expected.Height, manifest["tx_rct6"].Id)
+	}
+	if _, err := cryptonote.VerifyBlock(loadBlob(t, "testnet_block"), cryptonote.Hash{}); !errors.Is(err, cryptonote.ErrHashMismatch) {
+		t.Errorf("expected hash mismatch, got %v", err)
+	}
+}
+
+func Test_Cryptonote_Malformed(t *testing.T) {
+	blob := loadBlob(t, "synthetic_tx_rct5")
+	// 截断的数据
+	if _, err := cryptonote.ParseTransaction(blob[:len(blob)-1]); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected unexpected EOF, got %v", err)
+	}
+	if _, err := cryptonote.ParseTransaction(append(append([]byte{}, blob...), 0)); !errors.Is(err, cryptonote.ErrTrailingData) {
+		t.Errorf("expected trailing data error, got %v", err)
+	}
+	// 非规范的varint：版本号2编码为0x82 0x00
+	if _, err := cryptonote.ParseTransaction(append([]byte{0x82, 0x00}, blob[1:]...)); err == nil {
+		t.Errorf("expected error for non-canonical varint")
+	}
+	// 伪造的巨大输入数
+	if _, err := cryptonote.ParseTransaction([]byte{0x02, 0x00, 0xff, 0xff, 0xff, 0xff, 0x0f}); err == nil {
+		t.Errorf("expected error for huge input count")
+	}
+}
+
+func Test_Web_GetExplorerBlock(t *testing.T) {
+	blob := loadBlob(t, "synthetic_block_v16")
+	parsed, _ := cryptonote.ParseBlock(blob)
+	hash, _ := parsed.Hash()
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon.SetResult("get_block", map[string]interface{}{
+		"blob":         hex.EncodeToString(blob),
+		"block_header": map[string]interface{}{"height": 2712344, "hash": hash.String()},
+		"status":       "OK",
+	})
+	block, err := web.GetExplorerBlock(context.Background(), daemon.DaemonProxy(rpcproxy.ProxyConfig{}), "2712344")
+	if err != nil || block.Height != 2712344 || len(block.Block.TxHashes) != 3 {
+		t.Fatalf("GetExplorerBlock failed: %v", err)
+	}
+	if _, ok := block.MinerTxExtra.TxPubKey(); !ok {
+		t.Errorf("expected tx pubkey in miner tx extra %+v", block.MinerTxExtra)
+	}
+	request := rpcproxy.GetBlockRequest{}
+	daemon.LastParams("get_block", &request)
+	if request.Height != 2712344 {
+		t.Errorf("unexpected get_block params %+v", request)
+	}
+	if _, err := web.GetExplorerBlock(context.Background(), daemon.DaemonProxy(rpcproxy.ProxyConfig{}), "tip"); err == nil {
+		t.Errorf("expected error for bad block id")
+	}
+
+	// monerod返回的哈希与区块不符
+	daemon.SetResult("get_block", map[string]interface{}{
+		"blob":         hex.EncodeToString(blob),
+		"block_header": map[string]interface{}{"height": 2712344, "hash": rpctest.BlockHash(2712344)},
+		"status":       "OK",
+	})
+	if _, err := web.GetExplorerBlock(context.Background(), daemon.DaemonProxy(rpcproxy.ProxyConfig{}), "2712344"); !errors.Is(err, cryptonote.ErrHashMismatch) {
+		t.Errorf("expected hash mismatch, got %v", err)
+	}
+}
+
+func Test_Cryptonote_BlockHash(t *testing.T) {
+	// levin握手中使用的创世区块哈希
+	genesis, _ := cryptonote.ParseBlock(loadBlob(t, "block_testnet_genesis"))
+	hash, err := genesis.Hash()
+	if err != nil || hash.String() != "48ca7cd3c8de5b6a4d53d2861fbdaedca141553559f9be9520068053cda8430b" {
+		t.Errorf("unexpected testnet genesis hash %s: %v", hash, err)
+	}
+	// 主网的创世区块只有nonce不同
+	genesis.Nonce = 10000
+	if hash, _ = genesis.Hash(); hash.String() != "418015bb9ae982a1975da7d79277c2705727a56894ba0fb246adaabb1f4632e3" {
+		t.Errorf("unexpected mainnet genesis hash %s", hash)
+	}
+	if tx_hash, _ := genesis.MinerTx.Hash(); tx_hash.String() != "c88ce9783b4f11190d7b9c17a69c1c52200f9faaee8e98dd07e6811175177139" {
+		t.Errorf("unexpected genesis coinbase hash %s", tx_hash)
+	}
+}
+
+func Test_Cryptonote_TreeHash(t *testing.T) {
+	hashes := [][32]byte{}
+	for i := 0; i < 5; i++ {
+		hashes = append(hashes, crypto.Keccak256([]byte{byte(i)}))
+	}
+	pair := func(a, b [32]byte) [32]byte {
+		return crypto.Keccak256(a[:], b[:])
+	}
+	if crypto.TreeHash(hashes[:1]) != hashes[0] || crypto.TreeHash(hashes[:2]) != pair(hashes[0], hashes[1]) {
+		t.Errorf("unexpected tree hash for 1 or 2 hashes")
+	}
+	// 3个哈希：末尾两个先合并
+	if crypto.TreeHash(hashes[:3]) != pair(hashes[0], pair(hashes[1], hashes[2])) {
+		t.Errorf("unexpected tree hash for 3 hashes")
+	}
+	if crypto.TreeHash(hashes[:4]) != pair(pair(hashes[0], hashes[1]), pair(hashes[2], hashes[3])) {
+		t.Errorf("unexpected tree hash for 4 hashes")
+	}
+	if crypto.TreeHash(hashes) != pair(pair(hashes[0], hashes[1]), pair(hashes[2], pair(hashes[3], hashes[4]))) {
+		t.Errorf("unexpected tree hash for 5 hashes")
+	}
+}
+
+func Test_Cryptonote_TransactionHash(t *testing.T) {
+	full, _ := cryptonote.ParseTransaction(loadBlob(t, "synthetic_tx_rct6"))
+	pruned, _ := cryptonote.ParseTransaction(loadBlob(t, "synthetic_tx_rct6_pruned"))
+	full_hash, err := full.Hash()
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	// 剪枝后的交易需要另外给出prunable哈希
+	if _, err := pruned.Hash(); !errors.Is(err, cryptonote.ErrPrunedTransaction) {
+		t.Errorf("expected pruned error, got %v", err)
+	}
+	prunable_hash, _ := full.PrunableHash()
+	if pruned_hash, _ := pruned.HashWithPrunableHash(prunable_hash); pruned_hash != full_hash {
+		t.Errorf("pruned hash %s does not match full hash %s", pruned_hash, full_hash)
+	}
+	// v1交易的哈希是整个blob的Keccak
+	v1_blob := loadBlob(t, "synthetic_tx_v1")
+	v1, _ := cryptonote.ParseTransaction(v1_blob)
+	if hash, _ := v1.Hash(); hash != cryptonote.Hash(crypto.Keccak256(v1_blob)) {
+		t.Errorf("unexpected v1 hash %s", hash)
+	}
+}
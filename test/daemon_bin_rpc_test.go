@@ -0,0 +1,215 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"gomonero/levin"
+	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"strings"
+	"testing"
+)
+
+func hashBytes(t *testing.T, hashes ...string) string {
+	t.Helper()
+	data := []byte{}
+	for _, hash := range hashes {
+		bytes, err := hex.DecodeString(hash)
+		if err != nil {
+			t.Fatalf("invalid hash %s", hash)
+		}
+		data = append(data, bytes...)
+	}
+	return string(data)
+}
+
+// 检查.bin请求的路径和Content-Type
+func checkBinaryRequest(t *testing.T, daemon *rpctest.Server, method string) {
+	t.Helper()
+	requests := daemon.Requests()
+	if len(requests) == 0 {
+		t.Fatalf("%s was not called", method)
+	}
+	request := requests[len(requests)-1]
+	if request.Path != "/"+method || request.ContentType != "application/octet-stream" {
+		t.Errorf("%s: got path %q, content type %q", method, request.Path, request.ContentType)
+	}
+}
+
+func Test_DaemonRPCProxy_GetBlocksBin(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	ctx := context.Background()
+
+	block_blob := loadBlob(t, "synthetic_block_v16")
+	pool_tx_hash := rpctest.BlockHash(3)
+	var request_params map[string]interface{}
+	daemon.HandleBinary("get_blocks.bin", func(params map[string]interface{}) (map[string]interface{}, error) {
+		request_params = params
+		return map[string]interface{}{
+			"blocks": []interface{}{map[string]interface{}{
+				"block":        string(block_blob),
+				"block_weight": uint64(1845),
+				"txs":          []string{"tx0", "tx1"},
+			}},
+			"start_height":   uint64(100),
+			"current_height": uint64(rpctest.DaemonHeight),
+			"output_indices": []interface{}{map[string]interface{}{"indices": []interface{}{
+				map[string]interface{}{"indices": []uint64{7}},
+			}}},
+			"daemon_time":      uint64(1760838071),
+			"pool_info_extent": uint64(1),
+			"added_pool_txs": []interface{}{map[string]interface{}{
+				"tx_hash": hashBytes(t, pool_tx_hash), "tx_blob": "pool tx", "double_spend_seen": true,
+			}},
+			"removed_pool_txids": hashBytes(t, rpctest.BlockHash(4), rpctest.BlockHash(5)),
+			"status":             "OK",
+		}, nil
+	})
+	result, err := daemon_proxy.GetBlocksBin(ctx, rpcproxy.GetBlocksBinRequest{
+		RequestedInfo: rpcproxy.BlocksAndPool,
+		BlockIds:      []string{rpctest.BlockHash(99), rpctest.BlockHash(0)},
+		StartHeight:   100,
+		Prune:         true,
+	})
+	if err != nil {
+		t.Fatalf("GetBlocksBin failed: %v", err)
+	}
+	checkBinaryRequest(t, daemon, "get_blocks.bin")
+	if request_params["start_height"] != uint64(100) || request_params["requested_info"] != uint8(rpcproxy.BlocksAndPool) ||
+		request_params["prune"] != true || !bytes.Equal(request_params["block_ids"].([]byte), []byte(hashBytes(t, rpctest.BlockHash(99), rpctest.BlockHash(0)))) {
+		t.Errorf("get_blocks.bin params: %v", request_params)
+	}
+	if result.Status != rpcproxy.StatusOK || result.StartHeight != 100 || result.CurrentHeight != rpctest.DaemonHeight || result.PoolInfoExtent != 1 {
+		t.Errorf("GetBlocksBin: got %+v", result.ResponseBase)
+	}
+	if len(result.Blocks) != 1 || !bytes.Equal(result.Blocks[0].Block, block_blob) || len(result.Blocks[0].Txs) != 2 || string(result.Blocks[0].Txs[1].Blob) != "tx1" {
+		t.Errorf("GetBlocksBin blocks: got %+v", result.Blocks)
+	}
+	if len(result.OutputIndices) != 1 || result.OutputIndices[0].Indices[0].Indices[0] != 7 {
+		t.Errorf("GetBlocksBin output indices: got %+v", result.OutputIndices)
+	}
+	if len(result.AddedPoolTxs) != 1 || result.AddedPoolTxs[0].TxHash != pool_tx_hash || !result.AddedPoolTxs[0].DoubleSpendSeen {
+		t.Errorf("GetBlocksBin pool txs: got %+v", result.AddedPoolTxs)
+	}
+	if len(result.RemovedPoolTxids) != 2 || result.RemovedPoolTxids[1] != rpctest.BlockHash(5) {
+		t.Errorf("GetBlocksBin removed txids: got %v", result.RemovedPoolTxids)
+	}
+
+	// BlockIds不是十六进制时不发送请求
+	calls := daemon.Calls("get_blocks.bin")
+	if _, err := daemon_proxy.GetBlocksBin(ctx, rpcproxy.GetBlocksBinRequest{BlockIds: []string{"not hex"}}); err == nil || daemon.Calls("get_blocks.bin") != calls {
+		t.Errorf("GetBlocksBin with invalid block id: %v", err)
+	}
+}
+
+func Test_DaemonRPCProxy_GetOIndexesBin(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	txid := rpctest.BlockHash(1)
+	var request_params map[string]interface{}
+	daemon.HandleBinary("get_o_indexes.bin", func(params map[string]interface{}) (map[string]interface{}, error) {
+		request_params = params
+		return map[string]interface{}{"o_indexes": []uint64{11, 12, 13}, "status": "OK"}, nil
+	})
+	result, err := daemon_proxy.GetOIndexesBin(context.Background(), txid)
+	if err != nil || len(result.OIndexes) != 3 || result.OIndexes[2] != 13 {
+		t.Fatalf("GetOIndexesBin: got %+v, %v", result, err)
+	}
+	checkBinaryRequest(t, daemon, "get_o_indexes.bin")
+	if string(request_params["txid"].([]byte)) != hashBytes(t, txid) {
+		t.Errorf("get_o_indexes.bin params: %v", request_params)
+	}
+}
+
+func Test_DaemonRPCProxy_GetOutsBin(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	var request_params map[string]interface{}
+	daemon.HandleBinary("get_outs.bin", func(params map[string]interface{}) (map[string]interface{}, error) {
+		request_params = params
+		return map[string]interface{}{
+			"outs": []interface{}{map[string]interface{}{
+				"key":      hashBytes(t, rpctest.BlockHash(10)),
+				"mask":     hashBytes(t, rpctest.BlockHash(11)),
+				"unlocked": true,
+				"height":   uint64(100),
+				"txid":     hashBytes(t, rpctest.BlockHash(12)),
+			}},
+			"status": "OK",
+		}, nil
+	})
+	result, err := daemon_proxy.GetOutsBin(context.Background(), []rpcproxy.GetOutputsOut{{Amount: 0, Index: 42}}, true)
+	if err != nil || len(result.Outs) != 1 {
+		t.Fatalf("GetOutsBin: got %+v, %v", result, err)
+	}
+	checkBinaryRequest(t, daemon, "get_outs.bin")
+	out := result.Outs[0]
+	if out.Key != rpctest.BlockHash(10) || out.Mask != rpctest.BlockHash(11) || !out.Unlocked || out.Height != 100 || out.Txid != rpctest.BlockHash(12) {
+		t.Errorf("GetOutsBin: got %+v", out)
+	}
+	outputs, _ := request_params["outputs"].([]interface{})
+	if len(outputs) != 1 || outputs[0].(map[string]interface{})["index"] != uint64(42) || request_params["get_txid"] != true {
+		t.Errorf("get_outs.bin params: %v", request_params)
+	}
+}
+
+func Test_DaemonRPCProxy_GetTransactionPoolHashesBin(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	daemon.HandleBinary("get_transaction_pool_hashes.bin", func(params map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"tx_hashes": hashBytes(t, rpctest.BlockHash(1), rpctest.BlockHash(2)), "status": "OK"}, nil
+	})
+	result, err := daemon_proxy.GetTransactionPoolHashesBin(context.Background())
+	if err != nil || len(result.TxHashes) != 2 || result.TxHashes[0] != rpctest.BlockHash(1) {
+		t.Fatalf("GetTransactionPoolHashesBin: got %+v, %v", result, err)
+	}
+	checkBinaryRequest(t, daemon, "get_transaction_pool_hashes.bin")
+}
+
+func Test_DaemonRPCProxy_BinaryErrors(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	ctx := context.Background()
+	valid, err := levin.EncodePortableStorage(map[string]interface{}{"o_indexes": []uint64{1, 2, 3}, "status": "OK"})
+	if err != nil {
+		t.Fatalf("EncodePortableStorage failed: %v", err)
+	}
+
+	calls := []struct {
+		method string
+		call   func() error
+	}{
+		{"get_blocks.bin", func() error {
+			_, err := daemon_proxy.GetBlocksBin(ctx, rpcproxy.GetBlocksBinRequest{BlockIds: []string{rpctest.BlockHash(0)}})
+			return err
+		}},
+		{"get_o_indexes.bin", func() error { _, err := daemon_proxy.GetOIndexesBin(ctx, rpctest.BlockHash(1)); return err }},
+		{"get_outs.bin", func() error {
+			_, err := daemon_proxy.GetOutsBin(ctx, []rpcproxy.GetOutputsOut{{Index: 1}}, false)
+			return err
+		}},
+		{"get_transaction_pool_hashes.bin", func() error { _, err := daemon_proxy.GetTransactionPoolHashesBin(ctx); return err }},
+	}
+	for _, call := range calls {
+		// status不为OK
+		daemon.Script(call.method, rpctest.Response{Result: map[string]interface{}{"status": "BUSY"}})
+		rpc_error := &rpcproxy.RPCError{}
+		if err := call.call(); !errors.As(err, &rpc_error) || rpc_error.Status != "BUSY" {
+			t.Errorf("%s: expected BUSY status error, got %v", call.method, err)
+		}
+		// 截断的body和不是portable storage的body
+		for _, body := range [][]byte{valid[:len(valid)-3], []byte(`{"status": "OK"}`)} {
+			daemon.Script(call.method, rpctest.Response{Result: body})
+			if err := call.call(); err == nil || !strings.Contains(err.Error(), "decode "+call.method+" response") {
+				t.Errorf("%s with body %q: expected decode error, got %v", call.method, body, err)
+			}
+		}
+		daemon.Script(call.method, rpctest.HTTPErrorResponse(500))
+		if err := call.call(); err == nil {
+			t.Errorf("%s: expected error for HTTP 500", call.method)
+		}
+	}
+}
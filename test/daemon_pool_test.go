@@ -0,0 +1,70 @@
+package test
+
+import (
+	"context"
+	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"testing"
+)
+
+// 只改写get_info和on_get_block_hash的假monerod
+func poolDaemon(t *testing.T, height uint64, synchronized bool, block_hash string) *rpctest.Server {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon.SetResult("get_info", map[string]interface{}{"height": height, "synchronized": synchronized, "status": "OK"})
+	daemon.SetResult("on_get_block_hash", block_hash)
+	return daemon
+}
+
+func Test_DaemonPool_Failover(t *testing.T) {
+	synced := poolDaemon(t, 100, true, "aa")
+	syncing := poolDaemon(t, 50, false, "aa")
+
+	synced_proxy := synced.DaemonProxy(rpcproxy.ProxyConfig{})
+	syncing_proxy := syncing.DaemonProxy(rpcproxy.ProxyConfig{})
+	pool := rpcproxy.CreateDaemonPool([]*rpcproxy.DaemonRPCProxy{syncing_proxy, synced_proxy}, rpcproxy.DaemonPoolConfig{})
+	pool.CheckHealth(context.Background())
+
+	// 优先使用已同步的节点
+	height := uint64(0)
+	err := pool.Do(context.Background(), func(ctx context.Context, proxy *rpcproxy.DaemonRPCProxy) error {
+		info, err := proxy.GetInfo(ctx)
+		height = info.Height
+		return err
+	})
+	if err != nil || height != 100 {
+		t.Fatalf("expected synced daemon, got height %d, err %v", height, err)
+	}
+
+	// 已同步的节点宕机后转移到另一个节点
+	synced.Close()
+	err = pool.Do(context.Background(), func(ctx context.Context, proxy *rpcproxy.DaemonRPCProxy) error {
+		info, err := proxy.GetInfo(ctx)
+		height = info.Height
+		return err
+	})
+	if err != nil || height != 50 {
+		t.Fatalf("expected failover, got height %d, err %v", height, err)
+	}
+	for _, status := range pool.Status() {
+		if status.Height == 100 && status.Healthy {
+			t.Errorf("failed daemon still marked healthy")
+		}
+	}
+}
+
+func Test_DaemonPool_BlockHashQuorum(t *testing.T) {
+	hashes := []string{"aa", "aa", "bb"}
+	proxies := []*rpcproxy.DaemonRPCProxy{}
+	for _, hash := range hashes {
+		proxies = append(proxies, poolDaemon(t, 100, true, hash).DaemonProxy(rpcproxy.ProxyConfig{}))
+	}
+	pool := rpcproxy.CreateDaemonPool(proxies, rpcproxy.DaemonPoolConfig{})
+
+	result, err := pool.BlockHashQuorum(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatalf("BlockHashQuorum failed: %v", err)
+	}
+	if result.Value != "aa" || result.Agreed != 2 || !result.Disagreement {
+		t.Errorf("unexpected quorum result: %+v", result)
+	}
+}
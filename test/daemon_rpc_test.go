@@ -1,34 +1,496 @@
 package test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
 	"testing"
 )
 
 func Test_DaemonRPCProxy_GetBlockCount(t *testing.T) {
-	daemon_proxy := rpcproxy.CreateDaemonRPCProxy(
-		"43.138.89.105",
-		28081,
-		"pengzy1008",
-		"123456",
-	)
-	block_count := daemon_proxy.GetBlockCount()
-	expected := (block_count != -1)
-	if !expected {
-		t.Errorf("Test_DaemonRPCProxy_GetBlockCount failed!")
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{User: "pengzy1008", Password: "123456"})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	result, err := daemon_proxy.GetBlockCount(context.Background())
+	if err != nil || result.Count != rpctest.DaemonHeight {
+		t.Errorf("Test_DaemonRPCProxy_GetBlockCount failed: %v", err)
 	}
 }
 
 func Test_DaemonRPCProxy_GetHeight(t *testing.T) {
-	daemon_proxy := rpcproxy.CreateDaemonRPCProxy(
-		"43.138.89.105",
-		28081,
-		"pengzy1008",
-		"123456",
-	)
-	block_height := daemon_proxy.GetHeight()
-	expected := (block_height != -1)
-	if !expected {
-		t.Errorf("Test_DaemonRPCProxy_GetBlockCount failed!")
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{User: "pengzy1008", Password: "123456"})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	result, err := daemon_proxy.GetHeight(context.Background())
+	if err != nil || result.Height != rpctest.DaemonHeight {
+		t.Errorf("Test_DaemonRPCProxy_GetHeight failed: %v", err)
+	}
+}
+
+func Test_DaemonRPCProxy_GetBlockHeaderByHeight(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	result, err := daemon_proxy.GetBlockHeaderByHeight(context.Background(), 100, false)
+	if err != nil || result.BlockHeader.Hash != rpctest.BlockHash(100) {
+		t.Fatalf("GetBlockHeaderByHeight failed: %v", err)
+	}
+
+	// 超过最高区块时返回JSON-RPC error
+	_, err = daemon_proxy.GetBlockHeaderByHeight(context.Background(), rpctest.DaemonHeight, false)
+	rpc_error := &rpcproxy.RPCError{}
+	if !errors.As(err, &rpc_error) || rpc_error.Code != -2 {
+		t.Errorf("expected rpc error -2, got %v", err)
+	}
+}
+
+func Test_DaemonRPCProxy_StatusError(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon.Script("get_info", rpctest.StatusResponse("BUSY"))
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+
+	_, err := daemon_proxy.GetInfo(context.Background())
+	rpc_error := &rpcproxy.RPCError{}
+	if !errors.As(err, &rpc_error) || rpc_error.Status != "BUSY" {
+		t.Fatalf("expected BUSY status error, got %v", err)
+	}
+	info, err := daemon_proxy.GetInfo(context.Background())
+	if err != nil || !info.Testnet || info.Height != rpctest.DaemonHeight {
+		t.Errorf("GetInfo failed after script: %v", err)
+	}
+}
+
+func Test_RPCError(t *testing.T) {
+	var err error = &rpcproxy.RPCError{Code: -2, Message: "Too big height"}
+	rpc_error := &rpcproxy.RPCError{}
+	if !errors.As(err, &rpc_error) || rpc_error.Code != -2 {
+		t.Errorf("errors.As failed for RPCError")
+	}
+	if err.Error() != "rpc error -2: Too big height" {
+		t.Errorf("unexpected error message: %s", err.Error())
+	}
+	err = &rpcproxy.RPCError{Status: "BUSY"}
+	if err.Error() != "rpc status: BUSY" {
+		t.Errorf("unexpected error message: %s", err.Error())
+	}
+}
+
+func Test_GetTxpoolBacklogResult_Entries(t *testing.T) {
+	// 一个条目：weight=1500, fee=30000000, time_in_pool=7
+	// monerod对控制字符使用\u转义，其余字节原样写入
+	result := rpcproxy.GetTxpoolBacklogResult{
+		Backlog: []byte("\"\xdc\\u0005\\u0000\\u0000\\u0000\\u0000\\u0000\\u0000" +
+			"\x80\xc3\xc9\\u0001\\u0000\\u0000\\u0000\\u0000" +
+			"\\u0007\\u0000\\u0000\\u0000\\u0000\\u0000\\u0000\\u0000\""),
+	}
+	entries, err := result.Entries()
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Weight != 1500 || entries[0].Fee != 30000000 || entries[0].TimeInPool != 7 {
+		t.Errorf("unexpected backlog entries: %+v", entries)
+	}
+}
+
+func Test_DaemonRPCProxy_FixtureMethods(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	ctx := context.Background()
+
+	header, err := daemon_proxy.GetLastBlockHeader(ctx, true)
+	if err != nil || header.BlockHeader.Height != rpctest.DaemonHeight-1 || header.BlockHeader.Hash != rpctest.BlockHash(rpctest.DaemonHeight-1) {
+		t.Errorf("GetLastBlockHeader: got %+v, %v", header.BlockHeader, err)
+	}
+	params := map[string]interface{}{}
+	if err := daemon.LastParams("get_last_block_header", &params); err != nil || params["fill_pow_hash"] != true {
+		t.Errorf("get_last_block_header params: %v, %v", params, err)
+	}
+
+	hard_fork, err := daemon_proxy.HardForkInfo(ctx)
+	if err != nil || hard_fork.Version != 16 || !hard_fork.Enabled || hard_fork.EarliestHeight != 1983520 {
+		t.Errorf("HardForkInfo: got %+v, %v", hard_fork, err)
+	}
+	version, err := daemon_proxy.GetVersion(ctx)
+	if err != nil || version.Version>>16 != 3 || len(version.HardForks) != 16 || version.HardForks[15].HfVersion != 16 {
+		t.Errorf("GetVersion: got %+v, %v", version, err)
+	}
+	sync_info, err := daemon_proxy.SyncInfo(ctx)
+	if err != nil || sync_info.Height != rpctest.DaemonHeight || len(sync_info.Peers) != 0 {
+		t.Errorf("SyncInfo: got %+v, %v", sync_info, err)
+	}
+
+	fee, err := daemon_proxy.GetFeeEstimate(ctx, 10)
+	if err != nil || fee.Fee != 20000 || len(fee.Fees) != 4 || fee.QuantizationMask != 10000 {
+		t.Errorf("GetFeeEstimate: got %+v, %v", fee, err)
+	}
+	params = map[string]interface{}{}
+	if err := daemon.LastParams("get_fee_estimate", &params); err != nil || params["grace_blocks"] != float64(10) {
+		t.Errorf("get_fee_estimate params: %v, %v", params, err)
+	}
+	// grace_blocks为0时不发送，使用monerod的默认值
+	daemon_proxy.GetFeeEstimate(ctx, 0)
+	params = map[string]interface{}{}
+	if err := daemon.LastParams("get_fee_estimate", &params); err != nil || len(params) != 0 {
+		t.Errorf("get_fee_estimate default params: %v, %v", params, err)
+	}
+
+	hash, err := daemon_proxy.OnGetBlockHash(ctx, 100)
+	if err != nil || hash != rpctest.BlockHash(100) {
+		t.Errorf("OnGetBlockHash: got %s, %v", hash, err)
+	}
+	heights := []uint64{}
+	if err := daemon.LastParams("on_get_block_hash", &heights); err != nil || len(heights) != 1 || heights[0] != 100 {
+		t.Errorf("on_get_block_hash params: %v, %v", heights, err)
+	}
+	_, err = daemon_proxy.OnGetBlockHash(ctx, rpctest.DaemonHeight)
+	rpc_error := &rpcproxy.RPCError{}
+	if !errors.As(err, &rpc_error) || rpc_error.Code != -2 {
+		t.Errorf("OnGetBlockHash above the top block: expected rpc error -2, got %v", err)
+	}
+}
+
+func Test_DaemonRPCProxy_BlockMethods(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	ctx := context.Background()
+
+	daemon.SetResult("get_block_header_by_hash", map[string]interface{}{
+		"block_header":  map[string]interface{}{"hash": rpctest.BlockHash(100), "height": 100},
+		"block_headers": []map[string]interface{}{{"hash": rpctest.BlockHash(101), "height": 101}},
+		"status":        "OK",
+	})
+	by_hash, err := daemon_proxy.GetBlockHeaderByHash(ctx, rpcproxy.GetBlockHeaderByHashRequest{
+		Hash:   rpctest.BlockHash(100),
+		Hashes: []string{rpctest.BlockHash(101)},
+	})
+	if err != nil || by_hash.BlockHeader.Height != 100 || len(by_hash.BlockHeaders) != 1 || by_hash.BlockHeaders[0].Height != 101 {
+		t.Errorf("GetBlockHeaderByHash: got %+v, %v", by_hash, err)
+	}
+	hash_request := rpcproxy.GetBlockHeaderByHashRequest{}
+	if err := daemon.LastParams("get_block_header_by_hash", &hash_request); err != nil ||
+		hash_request.Hash != rpctest.BlockHash(100) || len(hash_request.Hashes) != 1 || hash_request.FillPowHash {
+		t.Errorf("get_block_header_by_hash params: %+v, %v", hash_request, err)
+	}
+
+	daemon.Handle("get_block_headers_range", func(params json.RawMessage) rpctest.Response {
+		request := struct {
+			StartHeight uint64 `json:"start_height"`
+			EndHeight   uint64 `json:"end_height"`
+		}{}
+		json.Unmarshal(params, &request)
+		headers := []map[string]interface{}{}
+		for height := request.StartHeight; height <= request.EndHeight; height++ {
+			headers = append(headers, map[string]interface{}{"hash": rpctest.BlockHash(height), "height": height})
+		}
+		return rpctest.Response{Result: map[string]interface{}{"headers": headers, "status": "OK"}}
+	})
+	headers, err := daemon_proxy.GetBlockHeadersRange(ctx, 10, 12, false)
+	if err != nil || len(headers.Headers) != 3 || headers.Headers[2].Hash != rpctest.BlockHash(12) {
+		t.Errorf("GetBlockHeadersRange: got %+v, %v", headers, err)
+	}
+
+	daemon.SetResult("get_block", map[string]interface{}{
+		"blob":          "1010",
+		"block_header":  map[string]interface{}{"height": 100},
+		"json":          `{"major_version": 16}`,
+		"miner_tx_hash": rpctest.BlockHash(1),
+		"tx_hashes":     []string{rpctest.BlockHash(2)},
+		"status":        "OK",
+	})
+	block, err := daemon_proxy.GetBlock(ctx, rpcproxy.GetBlockRequest{Hash: rpctest.BlockHash(100)})
+	if err != nil || block.Blob != "1010" || block.BlockHeader.Height != 100 || len(block.TxHashes) != 1 {
+		t.Errorf("GetBlock: got %+v, %v", block, err)
+	}
+	block_request := map[string]interface{}{}
+	if err := daemon.LastParams("get_block", &block_request); err != nil || block_request["hash"] != rpctest.BlockHash(100) {
+		t.Errorf("get_block params: %v, %v", block_request, err)
+	}
+
+	daemon.SetResult("get_alternate_chains", map[string]interface{}{
+		"chains": []map[string]interface{}{{"block_hash": rpctest.BlockHash(7), "height": 7, "length": 2, "block_hashes": []string{rpctest.BlockHash(7), rpctest.BlockHash(6)}}},
+		"status": "OK",
+	})
+	chains, err := daemon_proxy.GetAlternateChains(ctx)
+	if err != nil || len(chains.Chains) != 1 || chains.Chains[0].Length != 2 || len(chains.Chains[0].BlockHashes) != 2 {
+		t.Errorf("GetAlternateChains: got %+v, %v", chains, err)
+	}
+
+	// submit_block的参数是区块blob的数组，不是对象
+	daemon.SetResult("submit_block", map[string]interface{}{"status": "OK"})
+	if err := daemon_proxy.SubmitBlock(ctx, []string{"0102"}); err != nil {
+		t.Errorf("SubmitBlock failed: %v", err)
+	}
+	blobs := []string{}
+	if err := daemon.LastParams("submit_block", &blobs); err != nil || len(blobs) != 1 || blobs[0] != "0102" {
+		t.Errorf("submit_block params: %v, %v", blobs, err)
+	}
+	daemon.Script("submit_block", rpctest.ErrorResponse(-7, "Block not accepted"))
+	rpc_error := &rpcproxy.RPCError{}
+	if err := daemon_proxy.SubmitBlock(ctx, []string{"0102"}); !errors.As(err, &rpc_error) || rpc_error.Code != -7 {
+		t.Errorf("SubmitBlock rejected: expected rpc error -7, got %v", err)
+	}
+
+	daemon.SetResult("generateblocks", map[string]interface{}{"blocks": []string{rpctest.BlockHash(1)}, "height": 2, "status": "OK"})
+	generated, err := daemon_proxy.GenerateBlocks(ctx, rpcproxy.GenerateBlocksRequest{AmountOfBlocks: 1, WalletAddress: rpctest.WalletAddress})
+	if err != nil || generated.Height != 2 || len(generated.Blocks) != 1 {
+		t.Errorf("GenerateBlocks: got %+v, %v", generated, err)
+	}
+	generate_request := rpcproxy.GenerateBlocksRequest{}
+	if err := daemon.LastParams("generateblocks", &generate_request); err != nil || generate_request.AmountOfBlocks != 1 || generate_request.WalletAddress != rpctest.WalletAddress {
+		t.Errorf("generateblocks params: %+v, %v", generate_request, err)
+	}
+}
+
+func Test_DaemonRPCProxy_OutputMethods(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	ctx := context.Background()
+
+	daemon.SetResult("get_coinbase_tx_sum", map[string]interface{}{
+		"emission_amount": 1200000000000, "fee_amount": 30000000,
+		"wide_emission_amount": "0x1176592e000", "wide_fee_amount": "0x1c9c380", "status": "OK",
+	})
+	sum, err := daemon_proxy.GetCoinbaseTxSum(ctx, 100, 2)
+	if err != nil || sum.EmissionAmount != 1200000000000 || sum.FeeAmount != 30000000 {
+		t.Errorf("GetCoinbaseTxSum: got %+v, %v", sum, err)
+	}
+	sum_request := map[string]uint64{}
+	if err := daemon.LastParams("get_coinbase_tx_sum", &sum_request); err != nil || sum_request["height"] != 100 || sum_request["count"] != 2 {
+		t.Errorf("get_coinbase_tx_sum params: %v, %v", sum_request, err)
+	}
+
+	daemon.SetResult("get_output_histogram", map[string]interface{}{
+		"histogram": []map[string]interface{}{{"amount": 0, "total_instances": 9000, "unlocked_instances": 8990, "recent_instances": 10}},
+		"status":    "OK",
+	})
+	histogram, err := daemon_proxy.GetOutputHistogram(ctx, rpcproxy.GetOutputHistogramRequest{Amounts: []uint64{0}, Unlocked: true})
+	if err != nil || len(histogram.Histogram) != 1 || histogram.Histogram[0].TotalInstances != 9000 {
+		t.Errorf("GetOutputHistogram: got %+v, %v", histogram, err)
+	}
+	histogram_request := rpcproxy.GetOutputHistogramRequest{}
+	if err := daemon.LastParams("get_output_histogram", &histogram_request); err != nil || len(histogram_request.Amounts) != 1 || !histogram_request.Unlocked {
+		t.Errorf("get_output_histogram params: %+v, %v", histogram_request, err)
+	}
+
+	daemon.SetResult("get_output_distribution", map[string]interface{}{
+		"distributions": []map[string]interface{}{{"amount": 0, "base": 0, "distribution": []uint64{3, 5, 2}, "start_height": 100}},
+		"status":        "OK",
+	})
+	distribution, err := daemon_proxy.GetOutputDistribution(ctx, rpcproxy.GetOutputDistributionRequest{Amounts: []uint64{0}, FromHeight: 100, ToHeight: 102})
+	if err != nil || len(distribution.Distributions) != 1 || len(distribution.Distributions[0].Distribution) != 3 {
+		t.Errorf("GetOutputDistribution: got %+v, %v", distribution, err)
+	}
+	// json rpc只能请求非二进制、非压缩的格式
+	distribution_request := map[string]interface{}{}
+	if err := daemon.LastParams("get_output_distribution", &distribution_request); err != nil ||
+		distribution_request["binary"] != false || distribution_request["compress"] != false || distribution_request["from_height"] != float64(100) {
+		t.Errorf("get_output_distribution params: %v, %v", distribution_request, err)
+	}
+
+	// 同Test_GetTxpoolBacklogResult_Entries，经过HTTP后仍然能解析
+	daemon.SetResult("get_txpool_backlog", json.RawMessage("{\"backlog\": \"\xdc\\u0005\\u0000\\u0000\\u0000\\u0000\\u0000\\u0000"+
+		"\x80\xc3\xc9\\u0001\\u0000\\u0000\\u0000\\u0000"+
+		"\\u0007\\u0000\\u0000\\u0000\\u0000\\u0000\\u0000\\u0000\", \"status\": \"OK\"}"))
+	backlog, err := daemon_proxy.GetTxpoolBacklog(ctx)
+	if err != nil {
+		t.Fatalf("GetTxpoolBacklog failed: %v", err)
+	}
+	entries, err := backlog.Entries()
+	if err != nil || len(entries) != 1 || entries[0].Fee != 30000000 {
+		t.Errorf("GetTxpoolBacklog entries: got %+v, %v", entries, err)
+	}
+}
+
+func Test_DaemonRPCProxy_NodeMethods(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	ctx := context.Background()
+
+	daemon.SetResult("get_connections", map[string]interface{}{
+		"connections": []map[string]interface{}{{"address": "127.0.0.1:28080", "height": 2712344, "incoming": false, "peer_id": "0a1b2c3d4e5f6071", "state": "normal"}},
+		"status":      "OK",
+	})
+	connections, err := daemon_proxy.GetConnections(ctx)
+	if err != nil || len(connections.Connections) != 1 || connections.Connections[0].Address != "127.0.0.1:28080" {
+		t.Errorf("GetConnections: got %+v, %v", connections, err)
+	}
+
+	daemon.SetResult("set_bans", map[string]interface{}{"status": "OK"})
+	if err := daemon_proxy.SetBans(ctx, []rpcproxy.Ban{{Host: "192.0.2.1", Ban: true, Seconds: 3600}}); err != nil {
+		t.Errorf("SetBans failed: %v", err)
+	}
+	bans_request := struct {
+		Bans []rpcproxy.Ban `json:"bans"`
+	}{}
+	if err := daemon.LastParams("set_bans", &bans_request); err != nil || len(bans_request.Bans) != 1 || bans_request.Bans[0].Host != "192.0.2.1" || !bans_request.Bans[0].Ban {
+		t.Errorf("set_bans params: %+v, %v", bans_request, err)
+	}
+	daemon.SetResult("get_bans", map[string]interface{}{
+		"bans":   []map[string]interface{}{{"host": "192.0.2.1", "ip": 16908480, "seconds": 3599}},
+		"status": "OK",
+	})
+	bans, err := daemon_proxy.GetBans(ctx)
+	if err != nil || len(bans.Bans) != 1 || bans.Bans[0].Seconds != 3599 {
+		t.Errorf("GetBans: got %+v, %v", bans, err)
+	}
+
+	daemon.SetResult("flush_txpool", map[string]interface{}{"status": "OK"})
+	if err := daemon_proxy.FlushTxpool(ctx, []string{rpctest.BlockHash(1)}); err != nil {
+		t.Errorf("FlushTxpool failed: %v", err)
+	}
+	flush_request := map[string][]string{}
+	if err := daemon.LastParams("flush_txpool", &flush_request); err != nil || len(flush_request["txids"]) != 1 {
+		t.Errorf("flush_txpool params: %v, %v", flush_request, err)
+	}
+	// txids为空时清空整个交易池，不发送txids
+	daemon_proxy.FlushTxpool(ctx, nil)
+	flush_request = map[string][]string{}
+	if err := daemon.LastParams("flush_txpool", &flush_request); err != nil || len(flush_request) != 0 {
+		t.Errorf("flush_txpool params without txids: %v, %v", flush_request, err)
+	}
+
+	daemon.SetResult("relay_tx", map[string]interface{}{"status": "OK"})
+	if err := daemon_proxy.RelayTx(ctx, []string{rpctest.BlockHash(2)}); err != nil {
+		t.Errorf("RelayTx failed: %v", err)
+	}
+
+	// 没有结果的方法也要检查status
+	for _, call := range []struct {
+		method string
+		call   func() error
+	}{
+		{"set_bans", func() error { return daemon_proxy.SetBans(ctx, nil) }},
+		{"flush_txpool", func() error { return daemon_proxy.FlushTxpool(ctx, nil) }},
+		{"relay_tx", func() error { return daemon_proxy.RelayTx(ctx, []string{rpctest.BlockHash(2)}) }},
+	} {
+		daemon.Script(call.method, rpctest.StatusResponse("Failed"))
+		rpc_error := &rpcproxy.RPCError{}
+		if err := call.call(); !errors.As(err, &rpc_error) || rpc_error.Status != "Failed" {
+			t.Errorf("%s: expected status error, got %v", call.method, err)
+		}
+	}
+}
+
+// 最后一次调用method的请求路径
+func lastRequestPath(server *rpctest.Server, method string) string {
+	requests := server.Requests()
+	for i := len(requests) - 1; i >= 0; i-- {
+		if requests[i].Method == method {
+			return requests[i].Path
+		}
+	}
+	return ""
+}
+
+func Test_DaemonRPCProxy_PathMethods(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	ctx := context.Background()
+	tx_hash := rpctest.BlockHash(1)
+
+	daemon.SetResult("get_transactions", map[string]interface{}{
+		"txs": []map[string]interface{}{{
+			"as_hex": "0200", "block_height": 100, "confirmations": 7, "in_pool": false,
+			"output_indices": []uint64{11, 12}, "tx_hash": tx_hash,
+		}},
+		"missed_tx": []string{rpctest.BlockHash(2)},
+		"status":    "OK",
+	})
+	txs, err := daemon_proxy.GetTransactions(ctx, rpcproxy.GetTransactionsRequest{TxsHashes: []string{tx_hash, rpctest.BlockHash(2)}, Prune: true})
+	if err != nil || len(txs.Txs) != 1 || txs.Txs[0].TxHash != tx_hash || len(txs.Txs[0].OutputIndices) != 2 || len(txs.MissedTx) != 1 {
+		t.Errorf("GetTransactions: got %+v, %v", txs, err)
+	}
+	txs_request := map[string]interface{}{}
+	if err := daemon.LastParams("get_transactions", &txs_request); err != nil ||
+		len(txs_request["txs_hashes"].([]interface{})) != 2 || txs_request["prune"] != true || txs_request["decode_as_json"] != nil {
+		t.Errorf("get_transactions body: %v, %v", txs_request, err)
+	}
+
+	daemon.SetResult("get_alt_blocks_hashes", map[string]interface{}{"blks_hashes": []string{rpctest.BlockHash(5)}, "status": "OK"})
+	alt, err := daemon_proxy.GetAltBlocksHashes(ctx)
+	if err != nil || len(alt.BlksHashes) != 1 || alt.BlksHashes[0] != rpctest.BlockHash(5) {
+		t.Errorf("GetAltBlocksHashes: got %+v, %v", alt, err)
+	}
+
+	daemon.SetResult("is_key_image_spent", map[string]interface{}{
+		"spent_status": []int{rpcproxy.KeyImageUnspent, rpcproxy.KeyImageSpentInBlockchain, rpcproxy.KeyImageSpentInPool},
+		"status":       "OK",
+	})
+	key_images := []string{rpctest.BlockHash(10), rpctest.BlockHash(11), rpctest.BlockHash(12)}
+	spent, err := daemon_proxy.IsKeyImageSpent(ctx, key_images)
+	if err != nil || len(spent.SpentStatus) != 3 || spent.SpentStatus[2] != rpcproxy.KeyImageSpentInPool {
+		t.Errorf("IsKeyImageSpent: got %+v, %v", spent, err)
+	}
+	spent_request := struct {
+		KeyImages []string `json:"key_images"`
+	}{}
+	if err := daemon.LastParams("is_key_image_spent", &spent_request); err != nil || len(spent_request.KeyImages) != 3 || spent_request.KeyImages[1] != key_images[1] {
+		t.Errorf("is_key_image_spent body: %+v, %v", spent_request, err)
+	}
+
+	daemon.SetResult("send_raw_transaction", map[string]interface{}{"not_relayed": true, "status": "OK"})
+	sent, err := daemon_proxy.SendRawTransaction(ctx, rpcproxy.SendRawTransactionRequest{TxAsHex: "0200", DoNotRelay: true})
+	if err != nil || !sent.NotRelayed {
+		t.Errorf("SendRawTransaction: got %+v, %v", sent, err)
+	}
+	send_request := rpcproxy.SendRawTransactionRequest{}
+	if err := daemon.LastParams("send_raw_transaction", &send_request); err != nil || send_request.TxAsHex != "0200" || !send_request.DoNotRelay {
+		t.Errorf("send_raw_transaction body: %+v, %v", send_request, err)
+	}
+	// 被拒绝时status为Failed，result中仍然带有拒绝的原因
+	daemon.Script("send_raw_transaction", rpctest.Response{Result: map[string]interface{}{
+		"double_spend": true, "reason": "double spend", "status": "Failed",
+	}})
+	rejected, err := daemon_proxy.SendRawTransaction(ctx, rpcproxy.SendRawTransactionRequest{TxAsHex: "0200"})
+	rpc_error := &rpcproxy.RPCError{}
+	if !errors.As(err, &rpc_error) || rpc_error.Status != "Failed" || !rejected.DoubleSpend || rejected.Reason != "double spend" {
+		t.Errorf("rejected SendRawTransaction: got %+v, %v", rejected, err)
+	}
+
+	daemon.SetResult("get_limit", map[string]interface{}{"limit_down": 8192, "limit_up": 2048, "status": "OK"})
+	limit, err := daemon_proxy.GetLimit(ctx)
+	if err != nil || limit.LimitDown != 8192 || limit.LimitUp != 2048 {
+		t.Errorf("GetLimit: got %+v, %v", limit, err)
+	}
+
+	daemon.SetResult("get_peer_list", map[string]interface{}{
+		"white_list": []map[string]interface{}{{"host": "192.0.2.1", "id": 42, "port": 28080, "last_seen": 1760838071}},
+		"gray_list":  []map[string]interface{}{{"host": "192.0.2.2", "id": 43, "port": 28080}},
+		"status":     "OK",
+	})
+	peers, err := daemon_proxy.GetPeerList(ctx, true)
+	if err != nil || len(peers.WhiteList) != 1 || peers.WhiteList[0].Port != 28080 || len(peers.GrayList) != 1 {
+		t.Errorf("GetPeerList: got %+v, %v", peers, err)
+	}
+	peers_request := map[string]interface{}{}
+	if err := daemon.LastParams("get_peer_list", &peers_request); err != nil || peers_request["public_only"] != true {
+		t.Errorf("get_peer_list body: %v, %v", peers_request, err)
+	}
+
+	// 这些接口不经过/json_rpc，而是各自的路径
+	for _, method := range []string{"get_transactions", "get_alt_blocks_hashes", "is_key_image_spent", "send_raw_transaction", "get_limit", "get_peer_list"} {
+		if path := lastRequestPath(daemon, method); path != "/"+method {
+			t.Errorf("%s: request path %q", method, path)
+		}
+	}
+
+	// status不为OK时返回RPCError
+	for _, call := range []struct {
+		method string
+		call   func() error
+	}{
+		{"get_transactions", func() error {
+			_, err := daemon_proxy.GetTransactions(ctx, rpcproxy.GetTransactionsRequest{})
+			return err
+		}},
+		{"get_alt_blocks_hashes", func() error { _, err := daemon_proxy.GetAltBlocksHashes(ctx); return err }},
+		{"is_key_image_spent", func() error { _, err := daemon_proxy.IsKeyImageSpent(ctx, key_images); return err }},
+		{"get_limit", func() error { _, err := daemon_proxy.GetLimit(ctx); return err }},
+		{"get_peer_list", func() error { _, err := daemon_proxy.GetPeerList(ctx, false); return err }},
+	} {
+		daemon.Script(call.method, rpctest.StatusResponse("BUSY"))
+		rpc_error := &rpcproxy.RPCError{}
+		if err := call.call(); !errors.As(err, &rpc_error) || rpc_error.Status != "BUSY" {
+			t.Errorf("%s: expected BUSY status error, got %v", call.method, err)
+		}
 	}
 }
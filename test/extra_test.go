@@ -0,0 +1,138 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"gomonero/monero/cryptonote"
+	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"gomonero/web"
+	"strings"
+	"testing"
+)
+
+func Test_Cryptonote_ExtraFixtures(t *testing.T) {
+	// coinbase：交易公钥 + 8字节padding
+	coinbase, _ := cryptonote.ParseTransaction(loadBlob(t, "synthetic_coinbase_v2"))
+	extra := coinbase.ParseExtra()
+	if extra.Error != "" || len(extra.Fields) != 2 || extra.Fields[1].Type != "padding" || extra.Fields[1].PaddingSize != 8 {
+		t.Fatalf("unexpected coinbase extra %+v", extra)
+	}
+	if key, ok := extra.TxPubKey(); !ok || !bytes.Equal(key[:], coinbase.Extra[1:33]) {
+		t.Errorf("unexpected tx pubkey %s", key)
+	}
+
+	// 交易公钥 + 加密的payment id
+	tx, _ := cryptonote.ParseTransaction(loadBlob(t, "synthetic_tx_rct6"))
+	extra = tx.ParseExtra()
+	payment_id, ok := extra.EncryptedPaymentId()
+	if extra.Error != "" || !ok || len(payment_id) != 8 {
+		t.Fatalf("unexpected tx extra %+v", extra)
+	}
+	if _, ok := extra.PaymentId(); ok {
+		t.Errorf("unexpected unencrypted payment id")
+	}
+}
+
+func Test_Cryptonote_Extra(t *testing.T) {
+	key := "01" + strings.Repeat("11", 32)
+	tests := []struct {
+		name     string
+		extra    string
+		types    []string
+		unparsed string
+	}{
+		{"empty", "", []string{}, ""},
+		{"pubkey", key, []string{"tx_pubkey"}, ""},
+		{"additional pubkeys", key + "0402" + strings.Repeat("22", 32) + strings.Repeat("33", 32), []string{"tx_pubkey", "additional_pubkeys"}, ""},
+		{"payment id", "022100" + strings.Repeat("44", 32) + key, []string{"nonce", "tx_pubkey"}, ""},
+		{"arbitrary nonce", "0203616263" + key, []string{"nonce", "tx_pubkey"}, ""},
+		{"merge mining", "032105" + strings.Repeat("55", 32) + key, []string{"merge_mining", "tx_pubkey"}, ""},
+		{"minergate", key + "de0401020304", []string{"tx_pubkey", "minergate"}, ""},
+		{"padding", key + "000000", []string{"tx_pubkey", "padding"}, ""},
+		// 格式错误：已解析的字段保留，其余放入unparsed
+		{"unknown tag", key + "7f0102", []string{"tx_pubkey"}, "7f0102"},
+		{"truncated pubkey", key + "011111", []string{"tx_pubkey"}, "011111"},
+		{"non-zero padding", key + "000001", []string{"tx_pubkey"}, "000001"},
+		{"nonce too long", "02ff02" + strings.Repeat("00", 300), []string{}, "02ff02" + strings.Repeat("00", 300)},
+		{"bad merge mining", "03020101" + key, []string{}, "03020101" + key},
+		{"additional pubkeys overflow", "04ff01" + strings.Repeat("22", 32), []string{}, "04ff01" + strings.Repeat("22", 32)},
+	}
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.extra)
+		extra := cryptonote.ParseExtra(data)
+		types := []string{}
+		for _, field := range extra.Fields {
+			types = append(types, field.Type)
+		}
+		if strings.Join(types, ",") != strings.Join(test.types, ",") {
+			t.Errorf("%s: unexpected fields %v", test.name, types)
+		}
+		if hex.EncodeToString(extra.Unparsed) != test.unparsed || (test.unparsed != "") != (extra.Error != "") {
+			t.Errorf("%s: unexpected unparsed %x (%s)", test.name, extra.Unparsed, extra.Error)
+		}
+	}
+
+	data, _ := hex.DecodeString("032105" + strings.Repeat("55", 32) + "022100" + strings.Repeat("44", 32) + key + "0401" + strings.Repeat("22", 32))
+	extra := cryptonote.ParseExtra(data)
+	if extra.Fields[0].MergeMiningDepth != 5 || extra.Fields[0].MergeMiningRoot.String() != strings.Repeat("55", 32) {
+		t.Errorf("unexpected merge mining tag %+v", extra.Fields[0])
+	}
+	if payment_id, ok := extra.PaymentId(); !ok || hex.EncodeToString(payment_id) != strings.Repeat("44", 32) {
+		t.Errorf("unexpected payment id %x", payment_id)
+	}
+	if keys := extra.AdditionalPubKeys(); len(keys) != 1 || keys[0].String() != strings.Repeat("22", 32) {
+		t.Errorf("unexpected additional pubkeys %v", keys)
+	}
+}
+
+func Test_Web_GetExplorerTransaction(t *testing.T) {
+	blob := loadBlob(t, "synthetic_tx_rct6")
+	tx, _ := cryptonote.ParseTransaction(blob)
+	hash, _ := tx.Hash()
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon.SetResult("get_transactions", map[string]interface{}{
+		"txs":    []map[string]interface{}{{"as_hex": hex.EncodeToString(blob), "tx_hash": hash.String(), "block_height": 2712300, "confirmations": 45}},
+		"status": "OK",
+	})
+	proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	result, err := web.GetExplorerTransaction(context.Background(), proxy, hash.String())
+	if err != nil || result.BlockHeight != 2712300 {
+		t.Fatalf("GetExplorerTransaction failed: %v", err)
+	}
+	if _, ok := result.Extra.EncryptedPaymentId(); !ok {
+		t.Errorf("expected encrypted payment id in %+v", result.Extra)
+	}
+	request := rpcproxy.GetTransactionsRequest{}
+	daemon.LastParams("get_transactions", &request)
+	if len(request.TxsHashes) != 1 || request.TxsHashes[0] != hash.String() {
+		t.Errorf("unexpected get_transactions params %+v", request)
+	}
+
+	// 剪枝节点：用prunable_hash计算交易哈希
+	pruned := tx
+	pruned.Pruned = true
+	pruned_blob, _ := pruned.Serialize()
+	prunable_hash, _ := tx.PrunableHash()
+	daemon.SetResult("get_transactions", map[string]interface{}{
+		"txs":    []map[string]interface{}{{"pruned_as_hex": hex.EncodeToString(pruned_blob), "prunable_hash": prunable_hash.String(), "tx_hash": hash.String(), "in_pool": true}},
+		"status": "OK",
+	})
+	if result, err = web.GetExplorerTransaction(context.Background(), proxy, hash.String()); err != nil || !result.InPool {
+		t.Errorf("GetExplorerTransaction on pruned tx failed: %v", err)
+	}
+
+	if _, err := web.GetExplorerTransaction(context.Background(), proxy, "abc"); err == nil {
+		t.Errorf("expected error for bad tx hash")
+	}
+	other := rpctest.BlockHash(1)
+	if _, err := web.GetExplorerTransaction(context.Background(), proxy, other); !errors.Is(err, cryptonote.ErrHashMismatch) {
+		t.Errorf("expected hash mismatch, got %v", err)
+	}
+	daemon.SetResult("get_transactions", map[string]interface{}{"missed_tx": []string{other}, "status": "OK"})
+	if _, err := web.GetExplorerTransaction(context.Background(), proxy, other); err == nil {
+		t.Errorf("expected error for missing tx")
+	}
+}
@@ -0,0 +1,61 @@
+package test
+
+import (
+	"gomonero/levin"
+	"testing"
+)
+
+func Test_PortableStorageRoundTrip(t *testing.T) {
+	heights := make([]uint64, 100)
+	for i := range heights {
+		heights[i] = uint64(i) * 1000
+	}
+	section := map[string]interface{}{
+		"prune":        true,
+		"start_height": uint64(1234567),
+		"ratio":        float64(0.5),
+		"block_ids":    string(make([]byte, 320)),
+		"heights":      heights,
+		"txs":          []string{"a", "bc"},
+		"outputs": []map[string]interface{}{
+			{"amount": uint64(0), "index": uint64(7)},
+		},
+	}
+	data, err := levin.EncodePortableStorage(section)
+	if err != nil {
+		t.Fatalf("EncodePortableStorage failed: %v", err)
+	}
+	decoded, err := levin.DecodePortableStorage(data)
+	if err != nil {
+		t.Fatalf("DecodePortableStorage failed: %v", err)
+	}
+	if decoded["prune"] != true || decoded["start_height"] != uint64(1234567) || decoded["ratio"] != float64(0.5) {
+		t.Errorf("unexpected scalar values: %v", decoded)
+	}
+	if len(decoded["block_ids"].([]byte)) != 320 {
+		t.Errorf("unexpected blob length %d", len(decoded["block_ids"].([]byte)))
+	}
+	decoded_heights := decoded["heights"].([]interface{})
+	if len(decoded_heights) != 100 || decoded_heights[99] != uint64(99000) {
+		t.Errorf("unexpected heights: %v", decoded_heights)
+	}
+	txs := decoded["txs"].([]interface{})
+	if len(txs) != 2 || string(txs[1].([]byte)) != "bc" {
+		t.Errorf("unexpected string array: %v", txs)
+	}
+	outputs := decoded["outputs"].([]interface{})
+	if len(outputs) != 1 || outputs[0].(map[string]interface{})["index"] != uint64(7) {
+		t.Errorf("unexpected section array: %v", outputs)
+	}
+}
+
+func Test_PortableStorageMalformed(t *testing.T) {
+	data, _ := levin.EncodePortableStorage(map[string]interface{}{"heights": []uint64{1, 2, 3}})
+	// 截断的数据应该返回错误而不是panic
+	if _, err := levin.DecodePortableStorage(data[:len(data)-4]); err == nil {
+		t.Errorf("DecodePortableStorage should fail on truncated data")
+	}
+	if _, err := levin.DecodePortableStorage([]byte{0x01, 0x11}); err == nil {
+		t.Errorf("DecodePortableStorage should fail on short data")
+	}
+}
@@ -0,0 +1,211 @@
+package test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"gomonero/monero/address"
+	"gomonero/monero/mnemonic"
+	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"strings"
+	"testing"
+)
+
+func Test_Mnemonic_WordList(t *testing.T) {
+	words := mnemonic.English.Words
+	if len(words) != 1626 {
+		t.Fatalf("unexpected word count %d", len(words))
+	}
+	prefixes := map[string]bool{}
+	for i, word := range words {
+		if i > 0 && words[i-1] >= word {
+			t.Errorf("word list not sorted at %q", word)
+		}
+		if prefixes[word[:3]] {
+			t.Errorf("duplicate prefix %q", word[:3])
+		}
+		prefixes[word[:3]] = true
+	}
+	if list, err := mnemonic.FindWordList("English"); err != nil || list != mnemonic.English {
+		t.Errorf("FindWordList failed: %v", err)
+	}
+	if _, err := mnemonic.FindWordList("Klingon"); !errors.Is(err, mnemonic.ErrUnknownList) {
+		t.Errorf("expected ErrUnknownList, got %v", err)
+	}
+	// monero支持的其他语言没有单词表
+	for _, name := range []string{"Lojban", "Deutsch", "German", "日本語", "简体中文 (中国)"} {
+		if _, err := mnemonic.FindWordList(name); !errors.Is(err, mnemonic.ErrUnsupportedLanguage) {
+			t.Errorf("FindWordList(%q): expected ErrUnsupportedLanguage, got %v", name, err)
+		}
+	}
+}
+
+func Test_Mnemonic_Seed(t *testing.T) {
+	// fixture钱包
+	keys, err := mnemonic.SeedToKeys(rpctest.WalletSeed)
+	if err != nil {
+		t.Fatalf("SeedToKeys failed: %v", err)
+	}
+	if hex.EncodeToString(keys.SpendSecretKey[:]) != rpctest.WalletSpendKey || hex.EncodeToString(keys.ViewSecretKey[:]) != rpctest.WalletViewKey {
+		t.Errorf("unexpected keys %x %x", keys.SpendSecretKey, keys.ViewSecretKey)
+	}
+	if keys.Address(address.Testnet).String() != rpctest.WalletAddress {
+		t.Errorf("unexpected address %s", keys.Address(address.Testnet))
+	}
+	if seed := keys.Seed(mnemonic.English); seed != rpctest.WalletSeed {
+		t.Errorf("unexpected seed %q", seed)
+	}
+
+	// monero-ts测试钱包的助记词和私有view key
+	seed := "silk mocked cucumber lettuce hope adrenalin aching lush roles fuel revamp baptism wrist long tender teardrop midst pastry pigment equip frying inbound pinched ravine frying"
+	if keys, err = mnemonic.SeedToKeys(seed); err != nil || hex.EncodeToString(keys.ViewSecretKey[:]) != "198820da9166ee114203eb38c29e00b0e8fc7df508aa632d56ead849093d3808" {
+		t.Errorf("unexpected view key %x: %v", keys.ViewSecretKey, err)
+	}
+
+	// 只比较前缀，不区分大小写，24个词时没有校验词
+	words := strings.Fields(rpctest.WalletSeed)
+	prefixes := []string{}
+	for _, word := range words {
+		prefixes = append(prefixes, strings.ToUpper(word[:3]))
+	}
+	for _, variant := range []string{strings.Join(prefixes, " "), strings.Join(words[:24], "  "), "\n" + rpctest.WalletSeed + "\n"} {
+		if key, list, err := mnemonic.Decode(variant); err != nil || list != mnemonic.English || hex.EncodeToString(key[:]) != rpctest.WalletSpendKey {
+			t.Errorf("decoding %q failed: %v", variant, err)
+		}
+	}
+
+	wrong_checksum := strings.Join(append(words[:24:24], "zoom"), " ")
+	unknown_word := strings.Replace(rpctest.WalletSeed, "flippant", "xylophone", 1)
+	tests := []struct {
+		seed string
+		err  error
+	}{
+		{"", mnemonic.ErrWordCount},
+		{strings.Join(words[:13], " "), mnemonic.ErrWordCount},
+		{wrong_checksum, mnemonic.ErrChecksum},
+		{unknown_word, mnemonic.ErrUnknownWord},
+		{strings.Join(words[1:], " "), nil},
+		// 三个词的组合超出32位
+		{strings.Repeat("abbey zoom zones ", 8), mnemonic.ErrInvalidSeed},
+		// 不是英文的助记词
+		{strings.Repeat("あいこくしん あいさつ あいだ ", 8) + "あいこくしん", mnemonic.ErrUnsupportedLanguage},
+		{strings.Repeat("ábaco abdomen abeja ", 8) + "ábaco", mnemonic.ErrUnsupportedLanguage},
+	}
+	for _, test := range tests {
+		if _, _, err := mnemonic.Decode(test.seed); !errors.Is(err, test.err) {
+			t.Errorf("Decode(%q): expected %v, got %v", test.seed, test.err, err)
+		}
+	}
+}
+
+func Test_Mnemonic_Generate(t *testing.T) {
+	seed, keys, err := mnemonic.Generate(mnemonic.English)
+	if err != nil || len(strings.Fields(seed)) != mnemonic.SeedWords {
+		t.Fatalf("Generate failed: %q: %v", seed, err)
+	}
+	restored, err := mnemonic.SeedToKeys(seed)
+	if err != nil || restored != keys {
+		t.Errorf("round trip failed: %v", err)
+	}
+	generated, _ := address.GenerateSubaddress(address.Mainnet, keys.ViewSecretKey, keys.SpendPublicKey, 0, 0)
+	if generated != keys.Address(address.Mainnet) {
+		t.Errorf("primary address mismatch")
+	}
+}
+
+// 多字节单词的前缀按字符（rune）而不是字节截取，与monero的日语、中文等单词表一致
+// 这里没有monero的这些单词表，用构造的单词表检查：所有单词的第一个字符相同，按字节截取前缀会全部冲突
+func Test_Mnemonic_MultibytePrefix(t *testing.T) {
+	words := make([]string, 1626)
+	for i := range words {
+		// 第二、三个字符唯一确定单词，第四个字符之后的部分解码时被忽略
+		words[i] = "あ" + string(rune(0x4e00+i/64)) + string(rune(0x3041+i%64)) + "ごと"
+	}
+	list := &mnemonic.WordList{Name: "Multibyte", PrefixLen: 3, Words: words}
+	spend_key, _ := hex.DecodeString(rpctest.WalletSpendKey)
+	key := [32]byte(spend_key)
+
+	seed := list.Encode(key)
+	if decoded, err := list.Decode(seed); err != nil || decoded != key {
+		t.Fatalf("decoding %q failed: %v", seed, err)
+	}
+	// 只给出前3个字符
+	fields := strings.Fields(seed)
+	prefixes := []string{}
+	for _, word := range fields {
+		prefixes = append(prefixes, string([]rune(word)[:3]))
+	}
+	if decoded, err := list.Decode(strings.Join(prefixes, " ")); err != nil || decoded != key {
+		t.Errorf("decoding prefixes failed: %v", err)
+	}
+	// 校验词由多字节的前缀计算
+	checksum := 0
+	for checksum < len(words) && strings.HasPrefix(words[checksum], prefixes[24]) {
+		checksum++
+	}
+	wrong := strings.Join(append(fields[:24:24], words[checksum]), " ")
+	if _, err := list.Decode(wrong); !errors.Is(err, mnemonic.ErrChecksum) {
+		t.Errorf("expected ErrChecksum, got %v", err)
+	}
+	// 前缀不完整的词不认识
+	if _, err := list.Decode(strings.Replace(seed, fields[0], string([]rune(fields[0])[:2]), 1)); !errors.Is(err, mnemonic.ErrUnknownWord) {
+		t.Errorf("expected ErrUnknownWord, got %v", err)
+	}
+
+	// 单字符的单词（如monero的中文单词表，PrefixLen为1）
+	chinese := make([]string, 1626)
+	for i := range chinese {
+		chinese[i] = string(rune(0x4e00 + i))
+	}
+	chinese_list := &mnemonic.WordList{Name: "SingleRune", PrefixLen: 1, Words: chinese}
+	if decoded, err := chinese_list.Decode(chinese_list.Encode(key)); err != nil || decoded != key {
+		t.Errorf("decoding single-rune seed failed: %v", err)
+	}
+}
+
+func Test_WalletRPCProxy_ProvisionWalletsFromSeeds(t *testing.T) {
+	wallet := rpctest.CreateWallet(t, rpctest.Config{})
+	proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{})
+	if seed, err := proxy.QueryMnemonic(context.Background()); err != nil || seed != rpctest.WalletSeed {
+		t.Errorf("unexpected mnemonic %q: %v", seed, err)
+	}
+
+	provisioned, err := rpcproxy.ProvisionWallets(context.Background(), []*rpcproxy.WalletRPCProxy{proxy}, 1, rpcproxy.ProvisionConfig{
+		Seeds:         []string{rpctest.WalletSeed},
+		RestoreHeight: 2700000,
+	})
+	if err != nil || provisioned[0].Address != rpctest.WalletAddress {
+		t.Fatalf("ProvisionWallets failed: %v", err)
+	}
+	request := rpcproxy.RestoreDeterministicWalletRequest{}
+	wallet.LastParams("restore_deterministic_wallet", &request)
+	if request.Language != "English" || request.RestoreHeight != 2700000 || wallet.Calls("create_wallet") != 0 {
+		t.Errorf("unexpected restore request %+v", request)
+	}
+
+	// 助记词不合法时不调用wallet-rpc
+	if _, err := rpcproxy.ProvisionWallets(context.Background(), []*rpcproxy.WalletRPCProxy{proxy}, 1, rpcproxy.ProvisionConfig{
+		Seeds: []string{strings.Replace(rpctest.WalletSeed, "flippant", "xylophone", 1)},
+	}); !errors.Is(err, mnemonic.ErrUnknownWord) || wallet.Calls("restore_deterministic_wallet") != 1 {
+		t.Errorf("expected ErrUnknownWord, got %v", err)
+	}
+	if _, err := rpcproxy.ProvisionWallets(context.Background(), []*rpcproxy.WalletRPCProxy{proxy}, 1, rpcproxy.ProvisionConfig{
+		Seeds: []string{strings.Repeat("ábaco abdomen abeja ", 8) + "ábaco"},
+	}); !errors.Is(err, mnemonic.ErrUnsupportedLanguage) || wallet.Calls("restore_deterministic_wallet") != 1 {
+		t.Errorf("expected ErrUnsupportedLanguage, got %v", err)
+	}
+	if _, err := rpcproxy.ProvisionWallets(context.Background(), []*rpcproxy.WalletRPCProxy{proxy}, 2, rpcproxy.ProvisionConfig{
+		Seeds: []string{rpctest.WalletSeed},
+	}); err == nil {
+		t.Errorf("expected error for too few seeds")
+	}
+
+	// wallet-rpc返回的地址与助记词不符
+	wallet.SetResult("restore_deterministic_wallet", map[string]interface{}{"address": rpctest.WalletSubaddress01})
+	if _, err := rpcproxy.ProvisionWallets(context.Background(), []*rpcproxy.WalletRPCProxy{proxy}, 1, rpcproxy.ProvisionConfig{
+		Seeds: []string{rpctest.WalletSeed},
+	}); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected address mismatch, got %v", err)
+	}
+}
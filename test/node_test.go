@@ -1,11 +1,19 @@
 package test
 
 import (
+	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"gomonero/levin"
+	"gomonero/metrics"
 	"gomonero/node"
 	"math/big"
+	"net"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func Test_NodeAcceptIncomingConnection(t *testing.T) {
@@ -30,3 +38,169 @@ func Test_Peerlist(t *testing.T) {
 		fmt.Println(random_num.Uint64())
 	}
 }
+
+func Test_PeerInvoke(t *testing.T) {
+	server := node.CreateNode("testnet", 38181)
+	server.Start()
+	defer server.Stop()
+	client := node.CreateNode("testnet", 38182)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	peer, err := client.ConnectPeer(ctx, "127.0.0.1", 38181)
+	if err != nil {
+		t.Fatalf("ConnectPeer failed: %v", err)
+	}
+	defer peer.Close()
+	if peer.PeerId() == 0 {
+		t.Errorf("peer_id not recorded after handshake")
+	}
+
+	support_flags, err := peer.RequestSupportFlags(ctx)
+	if err != nil || support_flags != 1 {
+		t.Errorf("RequestSupportFlags: got %d, %v", support_flags, err)
+	}
+	peerlist, err := peer.TimedSync(ctx)
+	if err != nil || len(peerlist) != levin.MaxPeerlistEntryNum {
+		t.Errorf("TimedSync: got %d entries, %v", len(peerlist), err)
+	}
+	if err := peer.Ping(ctx); err != nil {
+		t.Errorf("Ping failed: %v", err)
+	}
+
+	canceled, cancel_now := context.WithCancel(context.Background())
+	cancel_now()
+	if _, err := peer.Invoke(canceled, levin.CommandPingPong, nil); err == nil {
+		t.Errorf("Invoke with canceled context should fail")
+	}
+	// 还没发出的请求被取消不影响连接
+	if err := peer.Ping(ctx); err != nil {
+		t.Errorf("Ping after canceled Invoke failed: %v", err)
+	}
+	// 无法序列化的请求不登记等待，同一命令的下一个请求仍然拿到自己的响应
+	if _, err := peer.Invoke(ctx, levin.CommandPingPong, map[string]interface{}{"bad": struct{}{}}); err == nil {
+		t.Errorf("Invoke with unsupported value should fail")
+	}
+	if err := peer.Ping(ctx); err != nil {
+		t.Errorf("Ping after failed CreateRequest failed: %v", err)
+	}
+
+	// server不响应不支持的命令，已发出的请求超时后连接被断开，避免后面的请求错拿响应
+	short, cancel_short := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel_short()
+	if _, err := peer.Invoke(short, 9999, map[string]interface{}{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Invoke without response: got %v, expected deadline exceeded", err)
+	}
+	select {
+	case <-peer.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("peer not closed after Invoke timed out")
+	}
+	if err := peer.Ping(ctx); err == nil {
+		t.Errorf("Ping on dropped peer should fail")
+	}
+	redialed, err := client.ConnectPeer(ctx, "127.0.0.1", 38181)
+	if err != nil {
+		t.Fatalf("redial failed: %v", err)
+	}
+	defer redialed.Close()
+	if err := redialed.Ping(ctx); err != nil {
+		t.Errorf("Ping after redial failed: %v", err)
+	}
+	// 等连接都从client中移除，不影响后面测试中的连接数
+	redialed.Close()
+	waitOutPeers(t, client, 0)
+}
+
+func waitOutPeers(t *testing.T, n *node.Node, expected int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for len(n.OutPeers()) != expected && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := len(n.OutPeers()); got != expected {
+		t.Fatalf("outgoing connections: got %d, expected %d", got, expected)
+	}
+}
+
+func Test_NodeMetrics(t *testing.T) {
+	server := node.CreateNode("testnet", 38183)
+	server.Start()
+	defer server.Stop()
+	client := node.CreateNode("testnet", 38184)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	outbound := testutil.ToFloat64(metrics.Peers.WithLabelValues("outbound"))
+	peer, err := client.ConnectPeer(ctx, "127.0.0.1", 38183)
+	if err != nil {
+		t.Fatalf("ConnectPeer failed: %v", err)
+	}
+	defer peer.Close()
+
+	pings_out := testutil.ToFloat64(metrics.LevinMessages.WithLabelValues("ping", "out"))
+	if err := peer.Ping(ctx); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	// client和server在同一个进程中，请求和响应各计一次out
+	if got := testutil.ToFloat64(metrics.LevinMessages.WithLabelValues("ping", "out")); got != pings_out+2 {
+		t.Errorf("ping out messages: got %v, expected %v", got, pings_out+2)
+	}
+	if got := testutil.ToFloat64(metrics.Peers.WithLabelValues("outbound")); got != outbound+1 {
+		t.Errorf("outbound peers: got %v, expected %v", got, outbound+1)
+	}
+	// 另一个Node的连接不会覆盖这个Node的计数
+	other := node.CreateNode("testnet", 38185)
+	other_peer, err := other.ConnectPeer(ctx, "127.0.0.1", 38183)
+	if err != nil {
+		t.Fatalf("ConnectPeer failed: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.Peers.WithLabelValues("outbound")); got != outbound+2 {
+		t.Errorf("outbound peers with two nodes: got %v, expected %v", got, outbound+2)
+	}
+	other_peer.Close()
+	waitOutPeers(t, other, 0)
+	if got := testutil.ToFloat64(metrics.Peers.WithLabelValues("outbound")); got != outbound+1 {
+		t.Errorf("outbound peers after close: got %v, expected %v", got, outbound+1)
+	}
+
+	// 握手中的连接不计数
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	handshake_ctx, cancel_handshake := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel_handshake()
+	handshake_err := make(chan error, 1)
+	go func() {
+		_, err := client.ConnectPeer(handshake_ctx, "127.0.0.1", uint16(listener.Addr().(*net.TCPAddr).Port))
+		handshake_err <- err
+	}()
+	silent := <-accepted
+	defer silent.Close()
+	// 给ConnectPeer时间发出握手请求
+	time.Sleep(50 * time.Millisecond)
+	if got := testutil.ToFloat64(metrics.Peers.WithLabelValues("outbound")); got != outbound+1 || len(client.OutPeers()) != 1 {
+		t.Errorf("outbound peers during handshake: got %v, expected %v", got, outbound+1)
+	}
+	if err := <-handshake_err; err == nil {
+		t.Errorf("handshake with a silent peer should fail")
+	}
+	if got := testutil.ToFloat64(metrics.Peers.WithLabelValues("outbound")); got != outbound+1 {
+		t.Errorf("outbound peers after failed handshake: got %v, expected %v", got, outbound+1)
+	}
+
+	if _, err := client.ConnectPeer(ctx, "127.0.0.1", 1); err == nil {
+		t.Errorf("ConnectPeer to closed port should fail")
+	}
+	if testutil.ToFloat64(metrics.HandshakeFailures.WithLabelValues("dial")) < 1 {
+		t.Errorf("handshake failure not counted")
+	}
+}
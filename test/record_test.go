@@ -0,0 +1,300 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"gomonero/monero/address"
+	"gomonero/monero/cryptonote"
+	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+/*
+====================
+录制和检查真实的RPC响应
+====================
+*/
+
+// 录制的响应写入testdata/recorded，由Test_RecordedFixtures检查
+// 录制需要testnet的monerod和monero-wallet-rpc（--wallet-dir可写），地址如http://127.0.0.1:28081：
+//
+//	GOMONERO_RECORD_DAEMON=... GOMONERO_RECORD_WALLET=... go test ./test -run Test_RecordFixtures
+//
+// --rpc-login的用户名和密码从GOMONERO_RECORD_USER和GOMONERO_RECORD_PASSWORD读取
+const recordedDir = "testdata/recorded"
+
+// 与rpctest/fixtures/daemon对应的方法，以/开头的是路径接口
+var recordDaemonCalls = map[string]interface{}{
+	"get_block_count":              nil,
+	"get_fee_estimate":             nil,
+	"get_info":                     nil,
+	"get_last_block_header":        nil,
+	"get_version":                  nil,
+	"hard_fork_info":               nil,
+	"sync_info":                    nil,
+	"/get_height":                  nil,
+	"/get_transaction_pool_hashes": nil,
+}
+
+func Test_RecordFixtures(t *testing.T) {
+	daemon_url, wallet_url := os.Getenv("GOMONERO_RECORD_DAEMON"), os.Getenv("GOMONERO_RECORD_WALLET")
+	if daemon_url == "" && wallet_url == "" {
+		t.Skip("GOMONERO_RECORD_DAEMON and GOMONERO_RECORD_WALLET not set")
+	}
+	user, password := os.Getenv("GOMONERO_RECORD_USER"), os.Getenv("GOMONERO_RECORD_PASSWORD")
+	ctx := context.Background()
+
+	if daemon_url != "" {
+		if err := rpctest.RecordFixtures(ctx, daemon_url, user, password, recordDaemonCalls, filepath.Join(recordedDir, "daemon")); err != nil {
+			t.Fatalf("record daemon: %v", err)
+		}
+	}
+	if wallet_url == "" {
+		return
+	}
+
+	// 在wallet-rpc上恢复fixture钱包，补齐fixture中的地址(0, 1)和账户1，录制地址相关的响应
+	call := func(method string, params interface{}) {
+		t.Helper()
+		if err := rpcproxy.JsonMethodRequest(ctx, wallet_url+"/json_rpc", method, params, user, password, nil); err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	call("restore_deterministic_wallet", rpcproxy.RestoreDeterministicWalletRequest{
+		Filename: "gomonero-fixture-" + hex.EncodeToString(suffix),
+		Seed:     rpctest.WalletSeed,
+		Language: rpcproxy.DefaultWalletLanguage,
+	})
+	defer call("close_wallet", nil)
+	call("create_address", map[string]interface{}{"account_index": 0})
+	call("create_account", map[string]interface{}{})
+
+	wallet_calls := map[string]interface{}{
+		"get_address":   map[string]interface{}{"account_index": 0, "address_index": []uint32{0, 1}},
+		"get_languages": nil,
+		"make_integrated_address": map[string]interface{}{
+			"standard_address": rpctest.WalletAddress, "payment_id": rpctest.WalletPaymentId,
+		},
+	}
+	if err := rpctest.RecordFixtures(ctx, wallet_url, user, password, wallet_calls, filepath.Join(recordedDir, "wallet")); err != nil {
+		t.Fatalf("record wallet: %v", err)
+	}
+	account1_calls := map[string]interface{}{
+		"get_address": map[string]interface{}{"account_index": 1},
+	}
+	if err := rpctest.RecordFixtures(ctx, wallet_url, user, password, account1_calls, filepath.Join(recordedDir, "wallet", "account1")); err != nil {
+		t.Fatalf("record wallet account 1: %v", err)
+	}
+}
+
+// 用录制的响应检查解码和本地的地址派生；没有录制时跳过
+func Test_RecordedFixtures(t *testing.T) {
+	recorded := os.DirFS(recordedDir)
+	ctx := context.Background()
+
+	if _, err := os.Stat(filepath.Join(recordedDir, "daemon")); err == nil {
+		daemon := rpctest.CreateServer(t, rpctest.Config{})
+		if err := daemon.LoadFixtures(recorded, "daemon"); err != nil {
+			t.Fatalf("load recorded daemon responses: %v", err)
+		}
+		proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+		info, err := proxy.GetInfo(ctx)
+		if err != nil || info.Status != rpcproxy.StatusOK || info.Nettype != "testnet" || !info.Testnet || info.Height == 0 {
+			t.Errorf("GetInfo: got %+v, %v", info, err)
+		}
+		header, err := proxy.GetLastBlockHeader(ctx, false)
+		if err != nil || header.BlockHeader.Hash == "" || header.BlockHeader.Height+1 < info.Height {
+			t.Errorf("GetLastBlockHeader: got %+v, %v", header, err)
+		}
+		if count, err := proxy.GetBlockCount(ctx); err != nil || count.Count < info.Height {
+			t.Errorf("GetBlockCount: got %+v, %v", count, err)
+		}
+		if height, err := proxy.GetHeight(ctx); err != nil || height.Height < info.Height {
+			t.Errorf("GetHeight: got %+v, %v", height, err)
+		}
+		if version, err := proxy.GetVersion(ctx); err != nil || version.Version == 0 || len(version.HardForks) == 0 {
+			t.Errorf("GetVersion: got %+v, %v", version, err)
+		}
+		if fork, err := proxy.HardForkInfo(ctx); err != nil || fork.Version < 16 {
+			t.Errorf("HardForkInfo: got %+v, %v", fork, err)
+		}
+		if fee, err := proxy.GetFeeEstimate(ctx, 0); err != nil || fee.Fee == 0 {
+			t.Errorf("GetFeeEstimate: got %+v, %v", fee, err)
+		}
+		if _, err := proxy.SyncInfo(ctx); err != nil {
+			t.Errorf("SyncInfo: %v", err)
+		}
+		if _, err := proxy.GetTransactionPoolHashes(ctx); err != nil {
+			t.Errorf("GetTransactionPoolHashes: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(recordedDir, "wallet")); err != nil {
+		t.Skip("no recorded wallet-rpc responses, run Test_RecordFixtures against a testnet wallet-rpc")
+	}
+	// 本地派生的子地址与真实wallet-rpc返回的一致
+	view_key, spend_public_key := hexKey(t, rpctest.WalletViewKey), hexKey(t, rpctest.WalletPublicSpendKey)
+	for account, dir := range []string{"wallet", "wallet/account1"} {
+		wallet := rpctest.CreateServer(t, rpctest.Config{})
+		if err := wallet.LoadFixtures(recorded, dir); err != nil {
+			t.Fatalf("load recorded wallet responses: %v", err)
+		}
+		proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{})
+		result, err := proxy.GetAddress(ctx, uint32(account), nil)
+		if err != nil || len(result.Addresses) == 0 {
+			t.Fatalf("GetAddress(%d) failed: %v", account, err)
+		}
+		for _, info := range result.Addresses {
+			generated, err := address.GenerateSubaddress(address.Testnet, view_key, spend_public_key, uint32(account), info.AddressIndex)
+			if err != nil || generated.String() != info.Address {
+				t.Errorf("subaddress (%d, %d): generated %s, wallet-rpc returned %s: %v", account, info.AddressIndex, generated, info.Address, err)
+			}
+		}
+		if account != 0 {
+			continue
+		}
+		integrated, err := proxy.MakeIntegratedAddress(ctx, rpctest.WalletAddress, rpctest.WalletPaymentId)
+		if err != nil || integrated.IntegratedAddress != rpctest.WalletIntegrated {
+			t.Errorf("make_integrated_address: wallet-rpc returned %+v, expected %s: %v", integrated, rpctest.WalletIntegrated, err)
+		}
+	}
+}
+
+/* ==== 链上的交易 ==== */
+
+// 每种交易从引入它的硬分叉高度开始向后找，最多找testnetScanBlocks个区块
+var testnetTxTargets = []struct {
+	name       string
+	hf_version uint8
+	rct_type   byte
+}{
+	{"tx_v1", 1, cryptonote.RctTypeNull},
+	{"tx_rct1", 4, cryptonote.RctTypeFull},
+	{"tx_rct2", 4, cryptonote.RctTypeSimple},
+	{"tx_rct3", 8, cryptonote.RctTypeBulletproof},
+	{"tx_rct4", 10, cryptonote.RctTypeBulletproof2},
+	{"tx_rct5", 13, cryptonote.RctTypeCLSAG},
+	{"tx_rct6", 15, cryptonote.RctTypeBulletproofPlus},
+}
+
+const testnetScanBlocks = 20000
+
+// 录制testnet上每种交易各一个，写入testdata/cryptonote/testnet_<名字>.hex，交易id写入testnet.json
+// tx_rct6另外录制剪枝后的版本tx_rct6_pruned，以及包含它的区块block
+func Test_RecordTestnetBlobs(t *testing.T) {
+	daemon_url := os.Getenv("GOMONERO_RECORD_DAEMON")
+	if daemon_url == "" {
+		t.Skip("GOMONERO_RECORD_DAEMON not set")
+	}
+	parsed, err := url.Parse(daemon_url)
+	if err != nil {
+		t.Fatalf("bad GOMONERO_RECORD_DAEMON: %v", err)
+	}
+	port, err := strconv.ParseUint(parsed.Port(), 10, 16)
+	if err != nil {
+		t.Fatalf("bad GOMONERO_RECORD_DAEMON port: %v", err)
+	}
+	proxy := rpcproxy.CreateDaemonRPCProxy(parsed.Hostname(), uint16(port), os.Getenv("GOMONERO_RECORD_USER"), os.Getenv("GOMONERO_RECORD_PASSWORD"))
+	ctx := context.Background()
+
+	info, err := proxy.GetInfo(ctx)
+	if err != nil || info.Nettype != "testnet" {
+		t.Fatalf("GOMONERO_RECORD_DAEMON must be a testnet monerod: %+v, %v", info, err)
+	}
+	version, err := proxy.GetVersion(ctx)
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	manifest := map[string]testnetBlob{}
+	for _, target := range testnetTxTargets {
+		start := uint64(1)
+		for _, fork := range version.HardForks {
+			if fork.HfVersion >= target.hf_version {
+				start = max(fork.Height, 1)
+				break
+			}
+		}
+		entry, height, found := findTestnetTx(t, proxy, start, min(start+testnetScanBlocks, info.Height), target.rct_type)
+		if !found {
+			t.Errorf("%s: no rct type %d transaction in %d blocks from height %d", target.name, target.rct_type, testnetScanBlocks, start)
+			continue
+		}
+		writeTestnetBlob(t, target.name, entry.AsHex)
+		manifest[target.name] = testnetBlob{Id: entry.TxHash, Height: height}
+		if target.rct_type != cryptonote.RctTypeBulletproofPlus {
+			continue
+		}
+		result, err := proxy.GetTransactions(ctx, rpcproxy.GetTransactionsRequest{TxsHashes: []string{entry.TxHash}, Prune: true})
+		if err != nil || len(result.Txs) != 1 {
+			t.Fatalf("get pruned %s failed: %v", entry.TxHash, err)
+		}
+		pruned := result.Txs[0]
+		pruned_hex := pruned.PrunedAsHex
+		if pruned_hex == "" {
+			pruned_hex = pruned.AsHex
+		}
+		writeTestnetBlob(t, target.name+"_pruned", pruned_hex)
+		manifest[target.name+"_pruned"] = testnetBlob{Id: entry.TxHash, Height: height, PrunableHash: pruned.PrunableHash}
+
+		block, err := proxy.GetBlock(ctx, rpcproxy.GetBlockRequest{Height: height})
+		if err != nil {
+			t.Fatalf("GetBlock(%d) failed: %v", height, err)
+		}
+		writeTestnetBlob(t, "block", block.Blob)
+		manifest["block"] = testnetBlob{Id: block.BlockHeader.Hash, Height: height}
+	}
+
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := os.WriteFile(testnetManifest, append(data, '\n'), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+}
+
+// 在[start, end)中找第一个非coinbase、RingCT类型为rct_type的交易（RctTypeNull表示v1交易）
+func findTestnetTx(t *testing.T, proxy rpcproxy.DaemonRPCProxy, start uint64, end uint64, rct_type byte) (rpcproxy.TransactionEntry, uint64, bool) {
+	t.Helper()
+	ctx := context.Background()
+	for height := start; height < end; height++ {
+		block, err := proxy.GetBlock(ctx, rpcproxy.GetBlockRequest{Height: height})
+		if err != nil {
+			t.Fatalf("GetBlock(%d) failed: %v", height, err)
+		}
+		if len(block.TxHashes) == 0 {
+			continue
+		}
+		result, err := proxy.GetTransactions(ctx, rpcproxy.GetTransactionsRequest{TxsHashes: block.TxHashes})
+		if err != nil {
+			t.Fatalf("GetTransactions at %d failed: %v", height, err)
+		}
+		for _, entry := range result.Txs {
+			tx, err := cryptonote.ParseTransactionHex(entry.AsHex)
+			if err != nil {
+				t.Fatalf("parse %s failed: %v", entry.TxHash, err)
+			}
+			if tx.Version == 1 && rct_type == cryptonote.RctTypeNull {
+				return entry, height, true
+			}
+			if tx.Version == 2 && tx.RctSignatures.Type == rct_type {
+				return entry, height, true
+			}
+		}
+	}
+	return rpcproxy.TransactionEntry{}, 0, false
+}
+
+func writeTestnetBlob(t *testing.T, name string, blob_hex string) {
+	t.Helper()
+	path := filepath.Join("testdata/cryptonote", "testnet_"+name+".hex")
+	if err := os.WriteFile(path, []byte(blob_hex+"\n"), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
@@ -0,0 +1,243 @@
+package test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_RPCClient_DigestAuth(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{User: "pengzy1008", Password: "123456"})
+
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	for i := 0; i < 3; i++ {
+		result, err := daemon_proxy.GetHeight(context.Background())
+		if err != nil || result.Height != rpctest.DaemonHeight {
+			t.Fatalf("GetHeight failed: %v", err)
+		}
+	}
+	// challenge被缓存，只有第一次请求需要认证
+	if daemon.Unauthorized() != 1 {
+		t.Errorf("expected 1 challenge, got %d", daemon.Unauthorized())
+	}
+
+	wrong_proxy := rpcproxy.CreateDaemonRPCProxy(daemon.Host(), daemon.Port(), "pengzy1008", "wrong")
+	_, err := wrong_proxy.GetHeight(context.Background())
+	rpc_error := &rpcproxy.RPCError{}
+	if !errors.As(err, &rpc_error) || rpc_error.HTTPStatus != http.StatusUnauthorized {
+		t.Errorf("expected 401 RPCError, got %v", err)
+	}
+}
+
+func Test_RPCClient_SharedClientDigestAuth(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{User: "pengzy1008", Password: "123456"})
+	ctx := context.Background()
+
+	// 同一(url, user)复用同一个client，challenge只需认证一次
+	for i := 0; i < 3; i++ {
+		result := rpcproxy.GetBlockCountResult{}
+		if err := rpcproxy.JsonMethodRequest(ctx, daemon.URL()+"/json_rpc", "get_block_count", nil, "pengzy1008", "123456", &result); err != nil {
+			t.Fatalf("JsonMethodRequest failed: %v", err)
+		}
+		height := rpcproxy.GetHeightResult{}
+		if err := rpcproxy.OtherMethodRequest(ctx, daemon.URL(), "get_height", nil, "pengzy1008", "123456", &height); err != nil || height.Height != rpctest.DaemonHeight {
+			t.Fatalf("OtherMethodRequest failed: %v", err)
+		}
+		calls := []*rpcproxy.BatchCall{{Method: "get_block_count", Result: &rpcproxy.GetBlockCountResult{}}}
+		if err := rpcproxy.JsonBatchRequest(ctx, daemon.URL()+"/json_rpc", calls, "pengzy1008", "123456"); err != nil {
+			t.Fatalf("JsonBatchRequest failed: %v", err)
+		}
+	}
+	// JsonMethodRequest和JsonBatchRequest共用一个client，OtherMethodRequest另用一个
+	if daemon.Unauthorized() != 2 {
+		t.Errorf("expected 2 challenges, got %d", daemon.Unauthorized())
+	}
+
+	// 密码不同的调用不复用已认证的client
+	err := rpcproxy.JsonMethodRequest(ctx, daemon.URL()+"/json_rpc", "get_block_count", nil, "pengzy1008", "wrong", nil)
+	rpc_error := &rpcproxy.RPCError{}
+	if !errors.As(err, &rpc_error) || rpc_error.HTTPStatus != http.StatusUnauthorized {
+		t.Errorf("expected 401 RPCError, got %v", err)
+	}
+}
+
+func Test_RPCClient_TLSFingerprint(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{TLS: true})
+
+	// DaemonProxy自动固定服务器证书的指纹
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+	if _, err := daemon_proxy.GetHeight(context.Background()); err != nil {
+		t.Fatalf("GetHeight with pinned fingerprint failed: %v", err)
+	}
+
+	sum := daemon.Fingerprint()
+	sum[0] ^= 0xff
+	wrong_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{
+		TLS: &rpcproxy.TLSConfig{AllowedFingerprints: []string{hex.EncodeToString(sum)}},
+	})
+	if _, err := wrong_proxy.GetHeight(context.Background()); err == nil {
+		t.Errorf("expected fingerprint mismatch error")
+	}
+}
+
+func Test_RPCClient_Retry(t *testing.T) {
+	retry := &rpcproxy.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2}
+
+	// 前两次返回BUSY
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon.Script("get_block_count", rpctest.StatusResponse("BUSY"), rpctest.StatusResponse("BUSY"))
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{Retry: retry})
+	result, err := daemon_proxy.GetBlockCount(context.Background())
+	if err != nil || result.Count != rpctest.DaemonHeight || daemon.Calls("get_block_count") != 3 {
+		t.Fatalf("expected success after 2 retries, got %v after %d requests", err, daemon.Calls("get_block_count"))
+	}
+
+	// 非幂等的方法不重试
+	wallet := rpctest.CreateWallet(t, rpctest.Config{})
+	wallet.Script("transfer", rpctest.HTTPErrorResponse(http.StatusInternalServerError))
+	wallet_proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{Retry: retry})
+	_, err = wallet_proxy.Transfer(context.Background(), rpcproxy.TransferRequest{})
+	if err == nil || wallet.Calls("transfer") != 1 {
+		t.Errorf("transfer must not be retried, got %d requests", wallet.Calls("transfer"))
+	}
+	// 只重试已知的只读方法：增量导出和未知的方法也不重试
+	wallet.Script("export_outputs", rpctest.HTTPErrorResponse(http.StatusInternalServerError))
+	if _, err := wallet_proxy.ExportOutputs(context.Background(), false); err == nil || wallet.Calls("export_outputs") != 1 {
+		t.Errorf("export_outputs must not be retried, got %d requests", wallet.Calls("export_outputs"))
+	}
+	if !rpcproxy.IsIdempotent("get_balance") || rpcproxy.IsIdempotent("sweep_all") || rpcproxy.IsIdempotent("unknown_method") {
+		t.Errorf("unexpected IsIdempotent results")
+	}
+
+	// 响应前断开连接时重试
+	daemon.Script("get_info", rpctest.Response{Drop: true})
+	if _, err := daemon_proxy.GetInfo(context.Background()); err != nil || daemon.Calls("get_info") != 2 {
+		t.Errorf("expected success after a dropped connection, got %v after %d requests", err, daemon.Calls("get_info"))
+	}
+
+	// monerod的-9表示core忙（如同步中），重试
+	daemon.SetResult("on_get_block_hash", rpctest.BlockHash(1))
+	daemon.Script("on_get_block_hash", rpctest.ErrorResponse(-9, "Core is busy"))
+	if hash, err := daemon_proxy.OnGetBlockHash(context.Background(), 1); err != nil || hash != rpctest.BlockHash(1) || daemon.Calls("on_get_block_hash") != 2 {
+		t.Errorf("expected success after core busy, got %v after %d requests", err, daemon.Calls("on_get_block_hash"))
+	}
+	// wallet-rpc的-9是签名错误，不重试；-3表示wallet-rpc连接的monerod忙，重试
+	wallet.Script("verify", rpctest.ErrorResponse(-9, "Invalid signature"))
+	if _, err := wallet_proxy.Verify(context.Background(), "data", rpctest.WalletAddress, "bad"); err == nil || wallet.Calls("verify") != 1 {
+		t.Errorf("wrong signature must not be retried, got %v after %d requests", err, wallet.Calls("verify"))
+	}
+	wallet.Script("get_balance", rpctest.ErrorResponse(-3, "daemon is busy"))
+	if _, err := wallet_proxy.GetBalance(context.Background(), rpcproxy.GetBalanceRequest{}); err != nil || wallet.Calls("get_balance") != 2 {
+		t.Errorf("expected success after daemon busy, got %v after %d requests", err, wallet.Calls("get_balance"))
+	}
+}
+
+// 用FailureThreshold为1的熔断器区分暂时性错误：只有暂时性错误会让下一次调用返回ErrCircuitOpen
+func Test_RPCClient_RetryNetworkErrors(t *testing.T) {
+	breaker := &rpcproxy.CircuitBreakerConfig{FailureThreshold: 1, OpenDuration: time.Minute}
+
+	// 证书指纹不匹配不是暂时性错误
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{TLS: true})
+	sum := daemon.Fingerprint()
+	sum[0] ^= 0xff
+	wrong_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{
+		TLS:            &rpcproxy.TLSConfig{AllowedFingerprints: []string{hex.EncodeToString(sum)}},
+		CircuitBreaker: breaker,
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := wrong_proxy.GetHeight(context.Background()); err == nil || errors.Is(err, rpcproxy.ErrCircuitOpen) {
+			t.Errorf("fingerprint mismatch %d: expected a non-transient error, got %v", i, err)
+		}
+	}
+
+	// 无效的URL不是暂时性错误
+	invalid_proxy := rpcproxy.CreateDaemonRPCProxyWithConfig("bad host", 18081, "", "", rpcproxy.ProxyConfig{CircuitBreaker: breaker})
+	for i := 0; i < 2; i++ {
+		if _, err := invalid_proxy.GetHeight(context.Background()); err == nil || errors.Is(err, rpcproxy.ErrCircuitOpen) {
+			t.Errorf("invalid url %d: expected a non-transient error, got %v", i, err)
+		}
+	}
+
+	// 连接被拒绝是暂时性错误
+	closed := rpctest.CreateDaemon(t, rpctest.Config{})
+	closed_proxy := closed.DaemonProxy(rpcproxy.ProxyConfig{CircuitBreaker: breaker})
+	closed.Close()
+	if _, err := closed_proxy.GetHeight(context.Background()); err == nil || errors.Is(err, rpcproxy.ErrCircuitOpen) {
+		t.Fatalf("expected connection refused, got %v", err)
+	}
+	if _, err := closed_proxy.GetHeight(context.Background()); !errors.Is(err, rpcproxy.ErrCircuitOpen) {
+		t.Errorf("connection refused should open the circuit, got %v", err)
+	}
+}
+
+func Test_RPCClient_CircuitBreaker(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	failure := rpctest.HTTPErrorResponse(http.StatusInternalServerError)
+	daemon.Script("get_height", failure, failure, failure)
+
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{
+		CircuitBreaker: &rpcproxy.CircuitBreakerConfig{FailureThreshold: 2, OpenDuration: 50 * time.Millisecond},
+	})
+	for i := 0; i < 2; i++ {
+		daemon_proxy.GetHeight(context.Background())
+	}
+	if _, err := daemon_proxy.GetHeight(context.Background()); !errors.Is(err, rpcproxy.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if daemon.Calls("get_height") != 2 {
+		t.Errorf("open circuit must not reach the server, got %d requests", daemon.Calls("get_height"))
+	}
+
+	// 熔断时间过后放行一次试探调用
+	time.Sleep(60 * time.Millisecond)
+	daemon_proxy.GetHeight(context.Background())
+	if daemon.Calls("get_height") != 3 {
+		t.Errorf("expected a half-open trial request, got %d requests", daemon.Calls("get_height"))
+	}
+}
+
+func Test_RPCClient_Batch(t *testing.T) {
+	heights := []uint64{10, 11, 12, 13}
+	for _, support_batch := range []bool{true, false} {
+		// 不支持批量时像monerod一样对数组请求返回-32600，客户端退回逐个调用
+		daemon := rpctest.CreateDaemon(t, rpctest.Config{SupportBatch: support_batch})
+		daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{})
+
+		headers, err := daemon_proxy.GetBlockHeadersByHeight(context.Background(), heights, false)
+		if err != nil {
+			t.Fatalf("GetBlockHeadersByHeight (batch=%t) failed: %v", support_batch, err)
+		}
+		for i, header := range headers {
+			if header.Height != heights[i] || header.Hash != rpctest.BlockHash(heights[i]) {
+				t.Errorf("header %d has height %d, expected %d", i, header.Height, heights[i])
+			}
+		}
+		// 被拒绝的批量请求记录为空方法名
+		rejected := 0
+		if !support_batch {
+			rejected = 1
+		}
+		if daemon.Calls("") != rejected || daemon.Calls("get_block_header_by_height") != len(heights) {
+			t.Errorf("batch=%t: unexpected requests %+v", support_batch, daemon.Requests())
+		}
+	}
+}
+
+func Test_RPCClient_Timeout(t *testing.T) {
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon.Script("get_info", rpctest.Response{Delay: time.Second}, rpctest.Response{Drop: true})
+	daemon_proxy := daemon.DaemonProxy(rpcproxy.ProxyConfig{Timeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := daemon_proxy.GetInfo(context.Background()); err == nil || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("expected timeout, got %v after %v", err, time.Since(start))
+	}
+	if _, err := daemon_proxy.GetInfo(context.Background()); err == nil {
+		t.Errorf("expected error on dropped connection")
+	}
+}
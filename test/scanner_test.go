@@ -0,0 +1,323 @@
+package test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"gomonero/monero"
+	"gomonero/monero/address"
+	"gomonero/monero/crypto"
+	"gomonero/monero/cryptonote"
+	"gomonero/monero/scanner"
+	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"testing"
+)
+
+func hexKey(t *testing.T, key string) [crypto.KeySize]byte {
+	t.Helper()
+	data, err := hex.DecodeString(key)
+	if err != nil || len(data) != crypto.KeySize {
+		t.Fatalf("bad key %q", key)
+	}
+	return [crypto.KeySize]byte(data)
+}
+
+func Test_Crypto_Keys(t *testing.T) {
+	view_key, spend_key := hexKey(t, rpctest.WalletViewKey), hexKey(t, rpctest.WalletSpendKey)
+	if public, err := crypto.SecretKeyToPublicKey(view_key); err != nil || hex.EncodeToString(public[:]) != rpctest.WalletPublicViewKey {
+		t.Errorf("unexpected public view key %x: %v", public, err)
+	}
+	if public, err := crypto.SecretKeyToPublicKey(spend_key); err != nil || hex.EncodeToString(public[:]) != rpctest.WalletPublicSpendKey {
+		t.Errorf("unexpected public spend key %x: %v", public, err)
+	}
+	if _, err := crypto.SecretKeyToPublicKey([crypto.KeySize]byte{0: 0xff, 31: 0xff}); !errors.Is(err, crypto.ErrInvalidSecretKey) {
+		t.Errorf("expected invalid secret key, got %v", err)
+	}
+
+	// 子地址的spend公钥与monero-wallet-rpc生成的子地址一致
+	for _, test := range []struct {
+		major, minor uint32
+		address      string
+	}{{0, 0, rpctest.WalletAddress}, {0, 1, rpctest.WalletSubaddress01}, {1, 0, rpctest.WalletSubaddress10}} {
+		expected, _ := address.Parse(test.address)
+		key, err := crypto.SubaddressSpendPublicKey(view_key, hexKey(t, rpctest.WalletPublicSpendKey), test.major, test.minor)
+		if err != nil || key != expected.SpendKey {
+			t.Errorf("unexpected spend key for subaddress (%d, %d): %x", test.major, test.minor, key)
+		}
+	}
+
+	// 8*a*(r*G) == 8*r*(a*G)
+	tx_key := crypto.HashToScalar([]byte("tx key"))
+	tx_pub_key, _ := crypto.SecretKeyToPublicKey(tx_key)
+	receiver, _ := crypto.GenerateKeyDerivation(tx_pub_key, view_key)
+	sender, _ := crypto.GenerateKeyDerivation(hexKey(t, rpctest.WalletPublicViewKey), tx_key)
+	if receiver != sender {
+		t.Errorf("key derivations differ: %x %x", receiver, sender)
+	}
+	output_key, _ := crypto.DerivePublicKey(receiver, 3, hexKey(t, rpctest.WalletPublicSpendKey))
+	if spend, _ := crypto.DeriveSubaddressPublicKey(output_key, receiver, 3); spend != hexKey(t, rpctest.WalletPublicSpendKey) {
+		t.Errorf("DeriveSubaddressPublicKey did not invert DerivePublicKey")
+	}
+
+	// monero单元测试中的view tag
+	derivation := hexKey(t, "0fc47054f355ced4d67de73bfa12e4c78ff19089548fffa7d07a674741860f97")
+	for index, expected := range map[uint64]byte{0: 0x76, 1: 0xd6, 2: 0x87, 3: 0x1b, 12: 0xd6, 13: 0xe9, 14: 0x12, 15: 0x26} {
+		if tag := crypto.DeriveViewTag(derivation, index); tag != expected {
+			t.Errorf("view tag for index %d: %02x, expected %02x", index, tag, expected)
+		}
+	}
+
+	shared_secret := crypto.DerivationToScalar(receiver, 0)
+	if amount, _ := crypto.DecodeCompactAmount(crypto.EncodeCompactAmount(123456789, shared_secret), shared_secret); amount != 123456789 {
+		t.Errorf("unexpected compact amount %d", amount)
+	}
+	mask := crypto.HashToScalar([]byte("mask"))
+	encrypted_mask, encrypted_amount, _ := crypto.EncodeAmount(987654321, mask, shared_secret)
+	if amount, decoded_mask, err := crypto.DecodeAmount(encrypted_mask, encrypted_amount, shared_secret); err != nil || amount != 987654321 || decoded_mask != mask {
+		t.Errorf("unexpected amount %d: %v", amount, err)
+	}
+	// 金额为0、mask为1的承诺就是G
+	one := [crypto.KeySize]byte{0: 1}
+	if commitment, _ := crypto.Commit(0, one); hex.EncodeToString(commitment[:]) != "5866666666666666666666666666666666666666666666666666666666666666" {
+		t.Errorf("unexpected commitment %x", commitment)
+	}
+}
+
+// 把tx的第index个输出改成发给spend_public_key的输出，derivation由接收方的view key计算
+func payOutput(t *testing.T, tx *cryptonote.Transaction, index int, derivation [crypto.KeySize]byte, spend_public_key [crypto.KeySize]byte, amount uint64) {
+	t.Helper()
+	output := &tx.Outputs[index]
+	output.Key, _ = crypto.DerivePublicKey(derivation, uint64(index), spend_public_key)
+	output.ViewTag = crypto.DeriveViewTag(derivation, uint64(index))
+	if tx.RctSignatures == nil || tx.RctSignatures.Type == cryptonote.RctTypeNull {
+		output.Amount = amount
+		return
+	}
+	shared_secret := crypto.DerivationToScalar(derivation, uint64(index))
+	ecdh := &tx.RctSignatures.EcdhInfo[index]
+	var mask [crypto.KeySize]byte
+	switch tx.RctSignatures.Type {
+	case cryptonote.RctTypeFull, cryptonote.RctTypeSimple, cryptonote.RctTypeBulletproof:
+		mask = crypto.HashToScalar([]byte("mask"))
+		ecdh.Mask, ecdh.Amount, _ = crypto.EncodeAmount(amount, mask, shared_secret)
+	default:
+		ecdh.Amount = cryptonote.Key{}
+		encrypted := crypto.EncodeCompactAmount(amount, shared_secret)
+		copy(ecdh.Amount[:], encrypted[:])
+		_, mask = crypto.DecodeCompactAmount(encrypted, shared_secret)
+	}
+	tx.RctSignatures.OutPk[index], _ = crypto.Commit(amount, mask)
+}
+
+// 替换交易的extra：交易公钥和额外公钥
+func setTxKeys(tx *cryptonote.Transaction, tx_pub_key [crypto.KeySize]byte, additional_pub_keys ...[crypto.KeySize]byte) {
+	tx.Extra = append([]byte{cryptonote.ExtraTagPubKey}, tx_pub_key[:]...)
+	if len(additional_pub_keys) > 0 {
+		tx.Extra = append(tx.Extra, cryptonote.ExtraTagAdditionalPubKeys, byte(len(additional_pub_keys)))
+		for _, key := range additional_pub_keys {
+			tx.Extra = append(tx.Extra, key[:]...)
+		}
+	}
+}
+
+func txKeyPair(seed string) ([crypto.KeySize]byte, [crypto.KeySize]byte) {
+	tx_key := crypto.HashToScalar([]byte(seed))
+	tx_pub_key, _ := crypto.SecretKeyToPublicKey(tx_key)
+	return tx_key, tx_pub_key
+}
+
+func Test_Scanner_Transaction(t *testing.T) {
+	view_key, spend_public_key := hexKey(t, rpctest.WalletViewKey), hexKey(t, rpctest.WalletPublicSpendKey)
+	subaddress_key, _ := crypto.SubaddressSpendPublicKey(view_key, spend_public_key, 0, 1)
+
+	// 输出0发给主地址，输出1通过额外公钥发给子地址(0, 1)
+	tx, _ := cryptonote.ParseTransaction(loadBlob(t, "synthetic_tx_rct6"))
+	_, tx_pub_key := txKeyPair("tx key")
+	_, additional0 := txKeyPair("additional 0")
+	_, additional1 := txKeyPair("additional 1")
+	setTxKeys(&tx, tx_pub_key, additional0, additional1)
+	derivation, _ := crypto.GenerateKeyDerivation(tx_pub_key, view_key)
+	payOutput(t, &tx, 0, derivation, spend_public_key, uint64(monero.XMR*3/2))
+	derivation, _ = crypto.GenerateKeyDerivation(additional1, view_key)
+	payOutput(t, &tx, 1, derivation, subaddress_key, 42)
+	hash, _ := tx.Hash()
+
+	wallet, err := scanner.CreateScanner(rpctest.WalletViewKey, rpctest.WalletPublicSpendKey)
+	if err != nil {
+		t.Fatalf("CreateScanner failed: %v", err)
+	}
+	outputs, err := wallet.ScanTransaction(&tx, hash)
+	if err != nil || len(outputs) != 1 {
+		t.Fatalf("expected 1 output without subaddresses, got %+v: %v", outputs, err)
+	}
+	if outputs[0].Amount != monero.XMR*3/2 || outputs[0].TxHash != hash || outputs[0].TxPubKey != tx_pub_key || outputs[0].Key != tx.Outputs[0].Key {
+		t.Errorf("unexpected output %+v", outputs[0])
+	}
+
+	wallet.AddSubaddresses(scanner.SubaddressIndex{Major: 0, Minor: 1}, scanner.SubaddressIndex{Major: 1, Minor: 0})
+	outputs, err = wallet.ScanTransaction(&tx, hash)
+	if err != nil || len(outputs) != 2 {
+		t.Fatalf("expected 2 outputs, got %+v: %v", outputs, err)
+	}
+	if outputs[1].Amount != 42 || outputs[1].Subaddress != (scanner.SubaddressIndex{Major: 0, Minor: 1}) || outputs[1].TxPubKey != additional1 {
+		t.Errorf("unexpected subaddress output %+v", outputs[1])
+	}
+
+	// 剪枝后的交易仍然可以扫描
+	pruned := tx
+	pruned.Pruned = true
+	blob, _ := pruned.Serialize()
+	pruned, _ = cryptonote.ParseTransaction(blob)
+	if outputs, err := wallet.ScanTransaction(&pruned, hash); err != nil || len(outputs) != 2 {
+		t.Errorf("scanning pruned tx failed: %+v: %v", outputs, err)
+	}
+
+	// 其他钱包扫描不到
+	other_view_key, _ := txKeyPair("other view key")
+	other, _ := scanner.CreateScanner(hex.EncodeToString(other_view_key[:]), rpctest.WalletPublicSpendKey)
+	if outputs, err := other.ScanTransaction(&tx, hash); err != nil || len(outputs) != 0 {
+		t.Errorf("unexpected outputs for another wallet %+v: %v", outputs, err)
+	}
+
+	// view tag匹配但金额与承诺不符：标记为Invalid，不影响另一个输出
+	tx.RctSignatures.EcdhInfo[0].Amount[0] ^= 1
+	outputs, err = wallet.ScanTransaction(&tx, hash)
+	if err != nil || len(outputs) != 2 {
+		t.Fatalf("expected 2 outputs with a corrupted ecdhInfo, got %+v: %v", outputs, err)
+	}
+	if !outputs[0].Invalid || !errors.Is(outputs[0].InvalidReason, scanner.ErrCommitmentMismatch) || outputs[0].Amount != 0 {
+		t.Errorf("expected an invalid output with commitment mismatch, got %+v", outputs[0])
+	}
+	if outputs[1].Invalid || outputs[1].Amount != 42 {
+		t.Errorf("unexpected subaddress output %+v", outputs[1])
+	}
+
+	if _, err := scanner.CreateScanner("abcd", rpctest.WalletPublicSpendKey); err == nil {
+		t.Errorf("expected error for bad view key")
+	}
+}
+
+func Test_Scanner_LegacyTransactions(t *testing.T) {
+	view_key, spend_public_key := hexKey(t, rpctest.WalletViewKey), hexKey(t, rpctest.WalletPublicSpendKey)
+	wallet, _ := scanner.CreateScanner(rpctest.WalletViewKey, rpctest.WalletPublicSpendKey)
+	_, tx_pub_key := txKeyPair("tx key")
+	derivation, _ := crypto.GenerateKeyDerivation(tx_pub_key, view_key)
+
+	// 旧格式的ecdhInfo、明文金额的v1交易和coinbase
+	for _, name := range []string{"synthetic_tx_rct1", "synthetic_tx_rct3", "synthetic_tx_v1", "synthetic_coinbase_v2"} {
+		tx, _ := cryptonote.ParseTransaction(loadBlob(t, name))
+		setTxKeys(&tx, tx_pub_key)
+		payOutput(t, &tx, 0, derivation, spend_public_key, 7000)
+		outputs, err := wallet.ScanTransaction(&tx, cryptonote.Hash{})
+		if err != nil || len(outputs) != 1 || outputs[0].Amount != 7000 || outputs[0].Coinbase != tx.IsCoinbase() {
+			t.Errorf("%s: unexpected outputs %+v: %v", name, outputs, err)
+		}
+	}
+}
+
+func Test_Scanner_ScanBlocks(t *testing.T) {
+	view_key, spend_public_key := hexKey(t, rpctest.WalletViewKey), hexKey(t, rpctest.WalletPublicSpendKey)
+	_, tx_pub_key := txKeyPair("tx key")
+	derivation, _ := crypto.GenerateKeyDerivation(tx_pub_key, view_key)
+
+	tx, _ := cryptonote.ParseTransaction(loadBlob(t, "synthetic_tx_rct6"))
+	setTxKeys(&tx, tx_pub_key)
+	payOutput(t, &tx, 1, derivation, spend_public_key, 5000)
+	tx_hash, _ := tx.Hash()
+	tx_blob, _ := tx.Serialize()
+
+	block, _ := cryptonote.ParseBlock(loadBlob(t, "synthetic_block_v16"))
+	setTxKeys(&block.MinerTx, tx_pub_key)
+	payOutput(t, &block.MinerTx, 0, derivation, spend_public_key, uint64(monero.XMR*6/10))
+	block.TxHashes = []cryptonote.Hash{tx_hash}
+	block_blob, _ := block.Serialize()
+
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon.HandleBinary("get_blocks.bin", func(params map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{
+			"blocks":         []interface{}{map[string]interface{}{"block": string(block_blob), "txs": []string{string(tx_blob)}}},
+			"start_height":   uint64(2712344),
+			"current_height": uint64(2712345),
+			"output_indices": []interface{}{map[string]interface{}{"indices": []interface{}{
+				map[string]interface{}{"indices": []uint64{100}},
+				map[string]interface{}{"indices": []uint64{101, 102}},
+			}}},
+			"status": "OK",
+		}, nil
+	})
+	wallet, _ := scanner.CreateScanner(rpctest.WalletViewKey, rpctest.WalletPublicSpendKey)
+	outputs, next_height, err := wallet.ScanBlocks(context.Background(), daemon.DaemonProxy(rpcproxy.ProxyConfig{}), rpcproxy.GetBlocksBinRequest{
+		BlockIds:    []string{rpctest.BlockHash(0)},
+		StartHeight: 2712344,
+	})
+	if err != nil || len(outputs) != 2 || next_height != 2712345 {
+		t.Fatalf("ScanBlocks failed: %+v, %d: %v", outputs, next_height, err)
+	}
+	if !outputs[0].Coinbase || outputs[0].Amount != monero.XMR*6/10 || outputs[0].GlobalIndex != 100 || outputs[0].Height != 2712344 {
+		t.Errorf("unexpected coinbase output %+v", outputs[0])
+	}
+	if outputs[1].TxHash != tx_hash || outputs[1].OutputIndex != 1 || outputs[1].GlobalIndex != 102 || outputs[1].Amount != 5000 {
+		t.Errorf("unexpected output %+v", outputs[1])
+	}
+}
+
+// 没有网络，无法取得真实的testnet交易，用synthetic的交易构造两个发给钱包的输出，篡改其中一个的ecdhInfo
+func Test_Scanner_ScanBlocksCommitmentMismatch(t *testing.T) {
+	view_key, spend_public_key := hexKey(t, rpctest.WalletViewKey), hexKey(t, rpctest.WalletPublicSpendKey)
+	_, tx_pub_key := txKeyPair("tx key")
+	derivation, _ := crypto.GenerateKeyDerivation(tx_pub_key, view_key)
+
+	tx, _ := cryptonote.ParseTransaction(loadBlob(t, "synthetic_tx_rct6"))
+	setTxKeys(&tx, tx_pub_key)
+	payOutput(t, &tx, 0, derivation, spend_public_key, 4000)
+	payOutput(t, &tx, 1, derivation, spend_public_key, 5000)
+	tx.RctSignatures.EcdhInfo[0].Amount[0] ^= 1
+	tx_hash, _ := tx.Hash()
+	tx_blob, _ := tx.Serialize()
+
+	block, _ := cryptonote.ParseBlock(loadBlob(t, "synthetic_block_v16"))
+	setTxKeys(&block.MinerTx, tx_pub_key)
+	payOutput(t, &block.MinerTx, 0, derivation, spend_public_key, uint64(monero.XMR*6/10))
+	block.TxHashes = []cryptonote.Hash{tx_hash}
+	block_blob, _ := block.Serialize()
+	// 第二个区块没有交易，只有发给钱包的coinbase
+	next_block, _ := cryptonote.ParseBlock(loadBlob(t, "synthetic_block_v16"))
+	setTxKeys(&next_block.MinerTx, tx_pub_key)
+	payOutput(t, &next_block.MinerTx, 0, derivation, spend_public_key, uint64(monero.XMR*6/10))
+	next_block.TxHashes = nil
+	next_block_blob, _ := next_block.Serialize()
+
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon.HandleBinary("get_blocks.bin", func(params map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{
+			"blocks": []interface{}{
+				map[string]interface{}{"block": string(block_blob), "txs": []string{string(tx_blob)}},
+				map[string]interface{}{"block": string(next_block_blob)},
+			},
+			"start_height":   uint64(2712343),
+			"current_height": uint64(2712345),
+			"status":         "OK",
+		}, nil
+	})
+	wallet, _ := scanner.CreateScanner(rpctest.WalletViewKey, rpctest.WalletPublicSpendKey)
+	outputs, next_height, err := wallet.ScanBlocks(context.Background(), daemon.DaemonProxy(rpcproxy.ProxyConfig{}), rpcproxy.GetBlocksBinRequest{
+		BlockIds:    []string{rpctest.BlockHash(0)},
+		StartHeight: 2712343,
+	})
+	if err != nil || len(outputs) != 4 || next_height != 2712345 {
+		t.Fatalf("ScanBlocks with a corrupted output failed: %+v, %d: %v", outputs, next_height, err)
+	}
+	if outputs[0].Invalid || !outputs[0].Coinbase || outputs[0].Amount != monero.XMR*6/10 {
+		t.Errorf("unexpected coinbase output %+v", outputs[0])
+	}
+	if !outputs[1].Invalid || !errors.Is(outputs[1].InvalidReason, scanner.ErrCommitmentMismatch) || outputs[1].OutputIndex != 0 || outputs[1].TxHash != tx_hash {
+		t.Errorf("expected the corrupted output to be invalid, got %+v", outputs[1])
+	}
+	if outputs[2].Invalid || outputs[2].OutputIndex != 1 || outputs[2].Amount != 5000 {
+		t.Errorf("unexpected output %+v", outputs[2])
+	}
+	if outputs[3].Invalid || outputs[3].Height != 2712344 || outputs[3].Amount != monero.XMR*6/10 {
+		t.Errorf("unexpected output in the next block %+v", outputs[3])
+	}
+}
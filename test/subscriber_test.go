@@ -0,0 +1,149 @@
+package test
+
+import (
+	"context"
+	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"testing"
+	"time"
+)
+
+func nextEvent(t *testing.T, subscriber *rpcproxy.Subscriber) rpcproxy.Event {
+	t.Helper()
+	select {
+	case event := <-subscriber.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for event")
+	}
+	return rpcproxy.Event{}
+}
+
+func waitSubscribed(t *testing.T, publisher *rpctest.Publisher, topic string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := publisher.WaitSubscribed(ctx, topic); err != nil {
+		t.Fatalf("subscriber did not subscribe to %s: %v", topic, err)
+	}
+}
+
+func Test_Subscriber_ZMQ(t *testing.T) {
+	publisher := rpctest.CreatePublisher(t)
+	subscriber := rpcproxy.CreateSubscriber(nil, rpcproxy.SubscriberConfig{ZMQEndpoint: publisher.Endpoint()})
+	subscriber.Start()
+	defer subscriber.Stop()
+	waitSubscribed(t, publisher, rpcproxy.TopicMinimalTxpoolAdd)
+
+	publisher.Publish(rpcproxy.TopicMinimalChainMain, map[string]interface{}{
+		"first_height":  100,
+		"first_prev_id": "aa",
+		"ids":           []string{"bb", "cc"},
+	})
+	for i, hash := range []string{"bb", "cc"} {
+		event := nextEvent(t, subscriber)
+		if event.Block == nil || event.Source != rpcproxy.EventSourceZMQ || event.Block.Height != uint64(100+i) || event.Block.Hash != hash {
+			t.Fatalf("unexpected block event %d: %+v", i, event.Block)
+		}
+	}
+
+	publisher.Publish(rpcproxy.TopicMinimalTxpoolAdd, []map[string]interface{}{
+		{"id": "dd", "blob_size": 1500, "weight": 1500, "fee": 30660000},
+	})
+	event := nextEvent(t, subscriber)
+	if event.Tx == nil || event.Tx.Hash != "dd" || event.Tx.Fee != 30660000 {
+		t.Errorf("unexpected tx event: %+v", event.Tx)
+	}
+}
+
+func Test_Subscriber_ZMQFull(t *testing.T) {
+	publisher := rpctest.CreatePublisher(t)
+	subscriber := rpcproxy.CreateSubscriber(nil, rpcproxy.SubscriberConfig{ZMQEndpoint: publisher.Endpoint(), Full: true})
+	subscriber.Start()
+	defer subscriber.Stop()
+	waitSubscribed(t, publisher, rpcproxy.TopicFullChainMain)
+
+	publisher.Publish(rpcproxy.TopicFullChainMain, []map[string]interface{}{{
+		"major_version": 16,
+		"minor_version": 16,
+		"timestamp":     1760838071,
+		"prev_id":       "aa",
+		"nonce":         1348172,
+		"miner_tx": map[string]interface{}{
+			"version":     2,
+			"unlock_time": 2712404,
+			"inputs":      []interface{}{map[string]interface{}{"gen": map[string]interface{}{"height": 2712344}}},
+		},
+		"tx_hashes": []string{"dd"},
+	}})
+	event := nextEvent(t, subscriber)
+	if event.Block == nil || event.Block.Height != 2712344 || event.Block.PrevHash != "aa" || len(event.Block.TxHashes) != 1 || len(event.Block.Block) == 0 {
+		t.Errorf("unexpected full block event: %+v", event.Block)
+	}
+}
+
+func Test_Subscriber_PollFallback(t *testing.T) {
+	// ZMQ端口不可用
+	publisher := rpctest.CreatePublisher(t)
+	endpoint := publisher.Endpoint()
+	publisher.Close()
+
+	// 第一次轮询时链顶低2个区块、交易池为空
+	daemon := rpctest.CreateDaemon(t, rpctest.Config{})
+	daemon.Script("get_last_block_header", rpctest.Response{Result: map[string]interface{}{
+		"block_header": map[string]interface{}{"height": rpctest.DaemonHeight - 3, "hash": rpctest.BlockHash(rpctest.DaemonHeight - 3)},
+		"status":       "OK",
+	}})
+	daemon.Script("get_transaction_pool_hashes", rpctest.Response{Result: map[string]interface{}{"tx_hashes": []string{}, "status": "OK"}})
+
+	subscriber := rpcproxy.CreateSubscriber(daemon.DaemonProxy(rpcproxy.ProxyConfig{}), rpcproxy.SubscriberConfig{
+		ZMQEndpoint:  endpoint,
+		PollInterval: 20 * time.Millisecond,
+	})
+	subscriber.Start()
+	defer subscriber.Stop()
+
+	for _, height := range []uint64{rpctest.DaemonHeight - 2, rpctest.DaemonHeight - 1} {
+		event := nextEvent(t, subscriber)
+		if event.Block == nil || event.Source != rpcproxy.EventSourcePoll || event.Block.Height != height || event.Block.Hash != rpctest.BlockHash(height) {
+			t.Fatalf("unexpected block event: %+v", event.Block)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if event := nextEvent(t, subscriber); event.Tx == nil || event.Tx.Hash == "" {
+			t.Fatalf("unexpected tx event: %+v", event)
+		}
+	}
+}
+
+func Test_Subscriber_Stop(t *testing.T) {
+	// 没有Start时Stop不阻塞，事件channel被关闭，之后Start无效
+	subscriber := rpcproxy.CreateSubscriber(nil, rpcproxy.SubscriberConfig{})
+	stopped := make(chan struct{})
+	go func() {
+		subscriber.Stop()
+		subscriber.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Stop without Start blocked")
+	}
+	subscriber.Start()
+	if _, ok := <-subscriber.Events(); ok {
+		t.Errorf("events channel should be closed after Stop")
+	}
+
+	// 重复Start只启动一个goroutine，Stop后事件channel被关闭
+	publisher := rpctest.CreatePublisher(t)
+	subscriber = rpcproxy.CreateSubscriber(nil, rpcproxy.SubscriberConfig{ZMQEndpoint: publisher.Endpoint()})
+	subscriber.Start()
+	subscriber.Start()
+	waitSubscribed(t, publisher, rpcproxy.TopicMinimalTxpoolAdd)
+	subscriber.Stop()
+	subscriber.Stop()
+	if _, ok := <-subscriber.Events(); ok {
+		t.Errorf("events channel should be closed after Stop")
+	}
+}
@@ -0,0 +1,214 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"gomonero/monero"
+	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"strings"
+	"testing"
+)
+
+// 联网的view-only钱包和离线的完整钱包
+func coldSigningWallets(t *testing.T) (*rpctest.Server, *rpctest.Server, *rpcproxy.ColdSigningFlow) {
+	view_only := rpctest.CreateWallet(t, rpctest.Config{})
+	view_only.EnableViewOnly()
+	cold := rpctest.CreateWallet(t, rpctest.Config{})
+	cold.EnableColdWallet()
+	flow := &rpcproxy.ColdSigningFlow{
+		ViewOnly: view_only.WalletProxy(rpcproxy.ProxyConfig{}),
+		Cold:     cold.WalletProxy(rpcproxy.ProxyConfig{}),
+	}
+	return view_only, cold, flow
+}
+
+func coldBalance() monero.Amount {
+	balance := monero.Amount(0)
+	for _, amount := range rpctest.ColdOutputAmounts {
+		balance += monero.Amount(amount)
+	}
+	return balance
+}
+
+func Test_WalletRPCProxy_ColdSigning(t *testing.T) {
+	_, _, flow := coldSigningWallets(t)
+	ctx := context.Background()
+	rpc_error := &rpcproxy.RPCError{}
+
+	outputs, err := flow.ViewOnly.ExportOutputs(ctx, true)
+	if err != nil || outputs == "" {
+		t.Fatalf("ExportOutputs: got %q, %v", outputs, err)
+	}
+	if n, err := flow.Cold.ImportOutputs(ctx, outputs); err != nil || n != uint64(len(rpctest.ColdOutputAmounts)) {
+		t.Fatalf("ImportOutputs: got %d, %v", n, err)
+	}
+	// 再次导入同样的输出时没有新增
+	if n, err := flow.Cold.ImportOutputs(ctx, outputs); err != nil || n != 0 {
+		t.Errorf("ImportOutputs again: got %d, %v", n, err)
+	}
+	if _, err := flow.Cold.ImportOutputs(ctx, "not hex"); !errors.As(err, &rpc_error) || rpc_error.Code != -26 {
+		t.Errorf("ImportOutputs with bad hex: expected rpc error -26, got %v", err)
+	}
+	// all为false时只导出新增的输出
+	if incremental, err := flow.ViewOnly.ExportOutputs(ctx, false); err != nil || incremental == outputs {
+		t.Errorf("incremental ExportOutputs: got %q, %v", incremental, err)
+	}
+
+	key_images, err := flow.Cold.ExportKeyImages(ctx, true)
+	if err != nil || len(key_images) != len(rpctest.ColdOutputAmounts) || key_images[0].Signature == "" {
+		t.Fatalf("ExportKeyImages: got %+v, %v", key_images, err)
+	}
+	// 只导入后两个key image，offset为1
+	imported, err := flow.ViewOnly.ImportKeyImages(ctx, key_images[1:], 1)
+	if err != nil || imported.Unspent != monero.Amount(rpctest.ColdOutputAmounts[1]+rpctest.ColdOutputAmounts[2]) || imported.Height != rpctest.DaemonHeight {
+		t.Errorf("ImportKeyImages with offset: got %+v, %v", imported, err)
+	}
+	// offset不对时签名校验失败
+	if _, err := flow.ViewOnly.ImportKeyImages(ctx, key_images[1:], 0); !errors.As(err, &rpc_error) || rpc_error.Code != -10 {
+		t.Errorf("ImportKeyImages with wrong offset: expected rpc error -10, got %v", err)
+	}
+	tampered := append([]rpcproxy.SignedKeyImage{}, key_images...)
+	tampered[0].Signature = strings.Repeat("0", 64)
+	if _, err := flow.ViewOnly.ImportKeyImages(ctx, tampered, 0); !errors.As(err, &rpc_error) || rpc_error.Code != -10 {
+		t.Errorf("ImportKeyImages with bad signature: expected rpc error -10, got %v", err)
+	}
+	imported, err = flow.ViewOnly.ImportKeyImages(ctx, key_images, 0)
+	if err != nil || imported.Unspent != coldBalance() || imported.Spent != 0 {
+		t.Fatalf("ImportKeyImages: got %+v, %v", imported, err)
+	}
+
+	unsigned, err := flow.ViewOnly.Transfer(ctx, rpcproxy.TransferRequest{Destinations: []rpcproxy.Destination{{Amount: 3 * monero.XMR, Address: rpctest.WalletSubaddress01}}})
+	if err != nil || unsigned.UnsignedTxset == "" || unsigned.TxHash != "" {
+		t.Fatalf("Transfer on view-only wallet: got %+v, %v", unsigned, err)
+	}
+	description, err := flow.Cold.DescribeTransfer(ctx, unsigned.UnsignedTxset)
+	if err != nil || len(description.Desc) != 1 || len(description.Summary.Recipients) != 1 {
+		t.Fatalf("DescribeTransfer: got %+v, %v", description, err)
+	}
+	summary := description.Summary
+	if summary.Recipients[0].Address != rpctest.WalletSubaddress01 || summary.Recipients[0].Amount != 3*monero.XMR ||
+		summary.Fee != rpctest.ColdSigningFee || summary.AmountIn != summary.AmountOut+summary.Fee ||
+		summary.ChangeAmount != summary.AmountIn-3*monero.XMR-summary.Fee {
+		t.Errorf("DescribeTransfer summary: got %+v", summary)
+	}
+
+	// view-only钱包不能签名
+	if _, err := flow.ViewOnly.SignTransfer(ctx, unsigned.UnsignedTxset, false, false); !errors.As(err, &rpc_error) || rpc_error.Code != -29 {
+		t.Errorf("SignTransfer on view-only wallet: expected rpc error -29, got %v", err)
+	}
+	signed, err := flow.Cold.SignTransfer(ctx, unsigned.UnsignedTxset, true, true)
+	if err != nil || signed.SignedTxset == "" || len(signed.TxHashList) != 1 || len(signed.TxKeyList) != 1 || len(signed.TxRawList) != 1 {
+		t.Fatalf("SignTransfer: got %+v, %v", signed, err)
+	}
+	if _, err := flow.Cold.SignTransfer(ctx, signed.SignedTxset, false, false); !errors.As(err, &rpc_error) || rpc_error.Code != -39 {
+		t.Errorf("SignTransfer with a signed txset: expected rpc error -39, got %v", err)
+	}
+	// 未签名的交易不能提交
+	if _, err := flow.ViewOnly.SubmitTransfer(ctx, unsigned.UnsignedTxset); !errors.As(err, &rpc_error) || rpc_error.Code != -40 {
+		t.Errorf("SubmitTransfer with an unsigned txset: expected rpc error -40, got %v", err)
+	}
+	tx_hash_list, err := flow.ViewOnly.SubmitTransfer(ctx, signed.SignedTxset)
+	if err != nil || len(tx_hash_list) != 1 || tx_hash_list[0] != signed.TxHashList[0] {
+		t.Errorf("SubmitTransfer: got %v, %v", tx_hash_list, err)
+	}
+
+	// 超过余额
+	if _, err := flow.ViewOnly.Transfer(ctx, rpcproxy.TransferRequest{Destinations: []rpcproxy.Destination{{Amount: coldBalance(), Address: rpctest.WalletSubaddress01}}}); !errors.As(err, &rpc_error) || rpc_error.Code != -17 {
+		t.Errorf("Transfer above the balance: expected rpc error -17, got %v", err)
+	}
+}
+
+func Test_ColdSigningFlow(t *testing.T) {
+	view_only, cold, flow := coldSigningWallets(t)
+	ctx := context.Background()
+	approved := rpcproxy.DescribeTransferResult{}
+	flow.Approve = func(description rpcproxy.DescribeTransferResult) error {
+		approved = description
+		return nil
+	}
+	result, err := flow.Transfer(ctx, rpcproxy.TransferRequest{Destinations: []rpcproxy.Destination{{Amount: monero.XMR, Address: rpctest.WalletSubaddress01}}})
+	if err != nil || len(result.TxHashList) != 1 || len(result.TxKeyList) != 1 {
+		t.Fatalf("ColdSigningFlow.Transfer: got %+v, %v", result, err)
+	}
+	if len(approved.Summary.Recipients) != 1 || approved.Summary.Recipients[0].Amount != monero.XMR || result.Description.Summary.Fee != rpctest.ColdSigningFee {
+		t.Errorf("approved description: got %+v", approved)
+	}
+	for server, methods := range map[*rpctest.Server][]string{
+		view_only: {"export_outputs", "import_key_images", "transfer", "submit_transfer"},
+		cold:      {"import_outputs", "export_key_images", "describe_transfer", "sign_transfer"},
+	} {
+		for _, method := range methods {
+			if server.Calls(method) != 1 {
+				t.Errorf("%s called %d times", method, server.Calls(method))
+			}
+		}
+	}
+	sign_request := map[string]interface{}{}
+	if err := cold.LastParams("sign_transfer", &sign_request); err != nil || sign_request["get_tx_keys"] != true || sign_request["export_raw"] != false {
+		t.Errorf("sign_transfer params: %v, %v", sign_request, err)
+	}
+
+	balance, err := flow.SyncOutputs(ctx)
+	if err != nil || balance.Unspent != coldBalance() {
+		t.Errorf("SyncOutputs: got %+v, %v", balance, err)
+	}
+}
+
+func Test_ColdSigningFlow_Errors(t *testing.T) {
+	ctx := context.Background()
+	request := rpcproxy.TransferRequest{Destinations: []rpcproxy.Destination{{Amount: monero.XMR, Address: rpctest.WalletSubaddress01}}}
+
+	// 流程中每一步失败时都停下，并在错误中说明失败的步骤
+	for _, test := range []struct {
+		cold    bool
+		method  string
+		message string
+		after   []string // 不应再被调用的方法
+	}{
+		{false, "export_outputs", "export_outputs from view-only wallet", []string{"import_outputs"}},
+		{true, "import_outputs", "import_outputs into cold wallet", []string{"export_key_images"}},
+		{true, "export_key_images", "export_key_images from cold wallet", []string{"import_key_images"}},
+		{false, "import_key_images", "import_key_images into view-only wallet", []string{"transfer"}},
+		{false, "transfer", "transfer on view-only wallet", []string{"describe_transfer"}},
+		{true, "describe_transfer", "describe_transfer on cold wallet", []string{"sign_transfer"}},
+		{true, "sign_transfer", "sign_transfer on cold wallet", []string{"submit_transfer"}},
+		{false, "submit_transfer", "submit_transfer on view-only wallet", nil},
+	} {
+		view_only, cold, flow := coldSigningWallets(t)
+		server := view_only
+		if test.cold {
+			server = cold
+		}
+		server.Script(test.method, rpctest.ErrorResponse(-1, "injected failure"))
+		_, err := flow.Transfer(ctx, request)
+		rpc_error := &rpcproxy.RPCError{}
+		if !errors.As(err, &rpc_error) || rpc_error.Message != "injected failure" || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%s failure: got %v", test.method, err)
+		}
+		for _, method := range test.after {
+			if view_only.Calls(method)+cold.Calls(method) != 0 {
+				t.Errorf("%s called after %s failed", method, test.method)
+			}
+		}
+	}
+
+	// 审批拒绝时不签名
+	_, cold, flow := coldSigningWallets(t)
+	rejection := errors.New("amount too large")
+	flow.Approve = func(rpcproxy.DescribeTransferResult) error { return rejection }
+	result, err := flow.Transfer(ctx, request)
+	if !errors.Is(err, rpcproxy.ErrTransferNotApproved) || !errors.Is(err, rejection) || cold.Calls("sign_transfer") != 0 {
+		t.Errorf("rejected transfer: got %v", err)
+	}
+	if len(result.Description.Desc) != 1 {
+		t.Errorf("rejected transfer should still return the description: %+v", result.Description)
+	}
+
+	// view-only钱包没有返回unsigned_txset
+	view_only, _, flow := coldSigningWallets(t)
+	view_only.Script("transfer", rpctest.Response{Result: map[string]interface{}{"tx_hash": rpctest.BlockHash(1)}})
+	if _, err := flow.Transfer(ctx, request); err == nil || !strings.Contains(err.Error(), "no unsigned_txset") {
+		t.Errorf("transfer without unsigned_txset: got %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"gomonero/monero"
+	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"strings"
+	"testing"
+)
+
+// n个开启了多签的假钱包，以及连接它们的proxy
+func multisigWallets(t *testing.T, n int) ([]*rpctest.Server, []*rpcproxy.WalletRPCProxy) {
+	servers := make([]*rpctest.Server, n)
+	proxies := make([]*rpcproxy.WalletRPCProxy, n)
+	for i := range servers {
+		servers[i] = rpctest.CreateWallet(t, rpctest.Config{})
+		servers[i].EnableMultisig()
+		proxies[i] = servers[i].WalletProxy(rpcproxy.ProxyConfig{})
+	}
+	return servers, proxies
+}
+
+func Test_WalletRPCProxy_Multisig(t *testing.T) {
+	_, wallets := multisigWallets(t, 2)
+	ctx := context.Background()
+
+	// 多签建立前is_multisig返回fixture
+	status, err := wallets[0].IsMultisig(ctx)
+	if err != nil || status.Multisig || status.Ready {
+		t.Fatalf("IsMultisig before setup: got %+v, %v", status, err)
+	}
+	infos := make([]string, 2)
+	for i, wallet := range wallets {
+		if infos[i], err = wallet.PrepareMultisig(ctx); err != nil || !strings.HasPrefix(infos[i], "MultisigxV2R1") {
+			t.Fatalf("PrepareMultisig on wallet %d: got %q, %v", i, infos[i], err)
+		}
+	}
+	kex := make([]rpcproxy.MultisigKexResult, 2)
+	for i, wallet := range wallets {
+		if kex[i], err = wallet.MakeMultisig(ctx, []string{infos[1-i]}, 2, "secret"); err != nil || kex[i].Address != "" || kex[i].MultisigInfo == "" {
+			t.Fatalf("MakeMultisig on wallet %d: got %+v, %v", i, kex[i], err)
+		}
+	}
+	if status, err := wallets[0].IsMultisig(ctx); err != nil || !status.Multisig || status.Ready || status.Threshold != 2 || status.Total != 2 {
+		t.Errorf("IsMultisig during key exchange: got %+v, %v", status, err)
+	}
+	// 2-of-2只需要一轮exchange_multisig_keys
+	for i, wallet := range wallets {
+		result, err := wallet.ExchangeMultisigKeys(ctx, []string{kex[1-i].MultisigInfo}, "secret")
+		if err != nil || result.Address == "" {
+			t.Fatalf("ExchangeMultisigKeys on wallet %d: got %+v, %v", i, result, err)
+		}
+	}
+	if status, err := wallets[1].IsMultisig(ctx); err != nil || !status.Ready || !status.KexIsDone {
+		t.Errorf("IsMultisig after key exchange: got %+v, %v", status, err)
+	}
+
+	exported := make([]string, 2)
+	for i, wallet := range wallets {
+		if exported[i], err = wallet.ExportMultisigInfo(ctx); err != nil || exported[i] == "" {
+			t.Fatalf("ExportMultisigInfo on wallet %d: got %q, %v", i, exported[i], err)
+		}
+	}
+	if n, err := wallets[0].ImportMultisigInfo(ctx, []string{exported[1]}); err != nil || n != 1 {
+		t.Errorf("ImportMultisigInfo: got %d, %v", n, err)
+	}
+	// 不能导入自己的info
+	rpc_error := &rpcproxy.RPCError{}
+	if _, err := wallets[1].ImportMultisigInfo(ctx, []string{exported[1]}); !errors.As(err, &rpc_error) || rpc_error.Code != -30 {
+		t.Errorf("ImportMultisigInfo with own info: expected rpc error -30, got %v", err)
+	}
+
+	transfer, err := wallets[0].Transfer(ctx, rpcproxy.TransferRequest{Destinations: []rpcproxy.Destination{{Amount: monero.XMR, Address: rpctest.WalletAddress}}})
+	if err != nil || transfer.MultisigTxset == "" {
+		t.Fatalf("Transfer on multisig wallet: got %+v, %v", transfer, err)
+	}
+	// 只有发起者的签名时不能提交
+	if _, err := wallets[0].SubmitMultisig(ctx, transfer.MultisigTxset); !errors.As(err, &rpc_error) || rpc_error.Code != -33 {
+		t.Errorf("SubmitMultisig with one signature: expected rpc error -33, got %v", err)
+	}
+	if _, err := wallets[0].SignMultisig(ctx, transfer.MultisigTxset); !errors.As(err, &rpc_error) || rpc_error.Code != -35 {
+		t.Errorf("SignMultisig twice by the initiator: expected rpc error -35, got %v", err)
+	}
+	signed, err := wallets[1].SignMultisig(ctx, transfer.MultisigTxset)
+	if err != nil || len(signed.TxHashList) != 1 || signed.TxHashList[0] != transfer.TxHash {
+		t.Fatalf("SignMultisig: got %+v, %v", signed, err)
+	}
+	tx_hash_list, err := wallets[1].SubmitMultisig(ctx, signed.TxDataHex)
+	if err != nil || len(tx_hash_list) != 1 || tx_hash_list[0] != transfer.TxHash {
+		t.Errorf("SubmitMultisig: got %v, %v", tx_hash_list, err)
+	}
+	if _, err := wallets[1].SubmitMultisig(ctx, "not hex"); !errors.As(err, &rpc_error) || rpc_error.Code != -34 {
+		t.Errorf("SubmitMultisig with bad tx data: expected rpc error -34, got %v", err)
+	}
+}
+
+func Test_MultisigCoordinator(t *testing.T) {
+	for _, test := range []struct {
+		n         int
+		threshold uint32
+	}{
+		{2, 2},
+		{3, 2},
+		{3, 3},
+	} {
+		servers, wallets := multisigWallets(t, test.n)
+		ctx := context.Background()
+		coordinator, err := rpcproxy.CreateMultisigCoordinator(wallets, test.threshold, "secret")
+		if err != nil {
+			t.Fatalf("CreateMultisigCoordinator(%d of %d) failed: %v", test.threshold, test.n, err)
+		}
+		address, err := coordinator.Setup(ctx)
+		if err != nil || address == "" {
+			t.Fatalf("Setup %d of %d: got %q, %v", test.threshold, test.n, address, err)
+		}
+		// make_multisig之后还需要N-M+1轮exchange_multisig_keys
+		for i, server := range servers {
+			if server.Calls("make_multisig") != 1 || server.Calls("exchange_multisig_keys") != test.n-int(test.threshold)+1 {
+				t.Errorf("%d of %d wallet %d: %d make_multisig, %d exchange_multisig_keys calls", test.threshold, test.n, i,
+					server.Calls("make_multisig"), server.Calls("exchange_multisig_keys"))
+			}
+		}
+
+		if err := coordinator.SyncInfo(ctx); err != nil {
+			t.Fatalf("SyncInfo %d of %d failed: %v", test.threshold, test.n, err)
+		}
+		for i, server := range servers {
+			infos := struct {
+				Info []string `json:"info"`
+			}{}
+			if err := server.LastParams("import_multisig_info", &infos); err != nil || len(infos.Info) != test.n-1 {
+				t.Errorf("%d of %d wallet %d imported %v, %v", test.threshold, test.n, i, infos.Info, err)
+			}
+		}
+
+		// 由最后一个参与者发起，签名从头绕回
+		initiator := test.n - 1
+		tx_hash_list, err := coordinator.Transfer(ctx, initiator, []rpcproxy.Destination{{Amount: monero.XMR, Address: rpctest.WalletAddress}})
+		if err != nil || len(tx_hash_list) != 1 {
+			t.Fatalf("Transfer %d of %d: got %v, %v", test.threshold, test.n, tx_hash_list, err)
+		}
+		signers := 0
+		for _, server := range servers {
+			signers += server.Calls("sign_multisig")
+		}
+		if signers != int(test.threshold)-1 || servers[0].Calls("sign_multisig") != 1 {
+			t.Errorf("%d of %d: %d sign_multisig calls", test.threshold, test.n, signers)
+		}
+	}
+}
+
+func Test_MultisigCoordinator_Errors(t *testing.T) {
+	ctx := context.Background()
+	_, wallets := multisigWallets(t, 3)
+	if _, err := rpcproxy.CreateMultisigCoordinator(wallets[:1], 1, ""); err == nil {
+		t.Errorf("CreateMultisigCoordinator with one wallet should fail")
+	}
+	if _, err := rpcproxy.CreateMultisigCoordinator(wallets, 4, ""); err == nil {
+		t.Errorf("CreateMultisigCoordinator with threshold above the wallet count should fail")
+	}
+
+	// 密钥交换的一轮中途失败：wallet 0已完成这一轮，wallet 2不再继续
+	servers, wallets := multisigWallets(t, 3)
+	servers[1].Script("exchange_multisig_keys", rpctest.ErrorResponse(-30, "Invalid multisig info"))
+	coordinator, _ := rpcproxy.CreateMultisigCoordinator(wallets, 2, "")
+	_, err := coordinator.Setup(ctx)
+	rpc_error := &rpcproxy.RPCError{}
+	if !errors.As(err, &rpc_error) || rpc_error.Code != -30 || !strings.Contains(err.Error(), "exchange_multisig_keys round 1 on wallet 1") {
+		t.Errorf("Setup with a failing round: got %v", err)
+	}
+	if servers[0].Calls("exchange_multisig_keys") != 1 || servers[2].Calls("exchange_multisig_keys") != 0 {
+		t.Errorf("unexpected exchange_multisig_keys calls after wallet 1 failed")
+	}
+	// make_multisig失败
+	servers, wallets = multisigWallets(t, 2)
+	servers[0].Script("make_multisig", rpctest.ErrorResponse(-28, "This wallet is already multisig"))
+	coordinator, _ = rpcproxy.CreateMultisigCoordinator(wallets, 2, "")
+	if _, err := coordinator.Setup(ctx); !errors.As(err, &rpc_error) || rpc_error.Code != -28 || !strings.Contains(err.Error(), "make_multisig on wallet 0") {
+		t.Errorf("Setup with a failing make_multisig: got %v", err)
+	}
+	// 各钱包的多签地址不一致
+	servers, wallets = multisigWallets(t, 2)
+	servers[1].Script("get_address", rpctest.Response{Result: map[string]interface{}{"address": rpctest.WalletAddress}})
+	coordinator, _ = rpcproxy.CreateMultisigCoordinator(wallets, 2, "")
+	if _, err := coordinator.Setup(ctx); err == nil || !strings.Contains(err.Error(), "wallet 1 has multisig address") {
+		t.Errorf("Setup with mismatched addresses: got %v", err)
+	}
+
+	// 签名失败
+	servers, wallets = multisigWallets(t, 2)
+	coordinator, _ = rpcproxy.CreateMultisigCoordinator(wallets, 2, "")
+	if _, err := coordinator.Setup(ctx); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	servers[1].Script("sign_multisig", rpctest.ErrorResponse(-35, "Failed to sign multisig tx"))
+	_, err = coordinator.Transfer(ctx, 0, []rpcproxy.Destination{{Amount: monero.XMR, Address: rpctest.WalletAddress}})
+	if !errors.As(err, &rpc_error) || rpc_error.Code != -35 || !strings.Contains(err.Error(), "sign_multisig on wallet 1") {
+		t.Errorf("Transfer with a failing signer: got %v", err)
+	}
+	if servers[1].Calls("submit_multisig") != 0 {
+		t.Errorf("submit_multisig called after a failed signature")
+	}
+	// SignAndSubmit不接受无效的txset
+	if _, err := coordinator.SignAndSubmit(ctx, "not hex", 0); !errors.As(err, &rpc_error) || rpc_error.Code != -34 {
+		t.Errorf("SignAndSubmit with bad txset: got %v", err)
+	}
+	// 没有同步multisig info时不能发起转账
+	servers, wallets = multisigWallets(t, 2)
+	coordinator, _ = rpcproxy.CreateMultisigCoordinator(wallets, 2, "")
+	if _, err := coordinator.Setup(ctx); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	servers[0].Script("import_multisig_info", rpctest.ErrorResponse(-30, "Invalid multisig info"))
+	if _, err := coordinator.Transfer(ctx, 0, []rpcproxy.Destination{{Amount: monero.XMR, Address: rpctest.WalletAddress}}); err == nil ||
+		!strings.Contains(err.Error(), "import_multisig_info on wallet 0") || servers[0].Calls("transfer") != 0 {
+		t.Errorf("Transfer with a failing SyncInfo: got %v", err)
+	}
+	if _, err := wallets[0].Transfer(ctx, rpcproxy.TransferRequest{Destinations: []rpcproxy.Destination{{Amount: monero.XMR, Address: rpctest.WalletAddress}}}); !errors.As(err, &rpc_error) || rpc_error.Code != -4 {
+		t.Errorf("Transfer before import_multisig_info: expected rpc error -4, got %v", err)
+	}
+}
@@ -1,40 +1,497 @@
 package test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gomonero/monero"
+	"gomonero/monero/address"
 	"gomonero/rpcproxy"
+	"gomonero/rpcproxy/rpctest"
+	"reflect"
 	"testing"
 )
 
 func Test_WalletRPCProxy_GetBalance(t *testing.T) {
-	wallet_proxy := rpcproxy.CreateWalletRPCProxy(
-		"127.0.0.1",
-		28088,
-		"pengzy1008",
-		"123456",
-	)
-	balance, unlocked_balance := wallet_proxy.GetBalance(0, nil, false, false)
-	expected := !(balance == -1 || unlocked_balance == -1)
-	if !expected {
-		t.Errorf("Test_WalletRPCProxy_GetBalance failed!")
+	wallet := rpctest.CreateWallet(t, rpctest.Config{User: "pengzy1008", Password: "123456"})
+	wallet_proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{})
+	result, err := wallet_proxy.GetBalance(context.Background(), rpcproxy.GetBalanceRequest{AccountIndex: 0, AddressIndices: []uint32{1}})
+	if err != nil || result.Balance == 0 || len(result.PerSubaddress) == 0 {
+		t.Fatalf("Test_WalletRPCProxy_GetBalance failed! %v", err)
+	}
+
+	params := rpcproxy.GetBalanceRequest{}
+	wallet.LastParams("get_balance", &params)
+	if len(params.AddressIndices) != 1 || params.AddressIndices[0] != 1 {
+		t.Errorf("address_indices not sent: %+v", params)
 	}
 }
 
 func Test_WalletRPCProxy_Transfer(t *testing.T) {
-	wallet_proxy := rpcproxy.CreateWalletRPCProxy(
-		"127.0.0.1",
-		28088,
-		"pengzy1008",
-		"123456",
-	)
-	destinations := []map[string]interface{}{
-		{
-			"amount":  uint64(100000000000),
-			"address": "9yZhA4eVVjBd6ihbdTTifB2BxDn2UiLKuY79Y13VdxDt7kRzpNkV3HS3XvjcbFEsz2hqUF7dzUSthN6Ea2wF6mpPVbXzsiX",
+	wallet := rpctest.CreateWallet(t, rpctest.Config{User: "pengzy1008", Password: "123456"})
+	wallet_proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{})
+	request := rpcproxy.TransferRequest{
+		Destinations: []rpcproxy.Destination{
+			{
+				Amount:  monero.XMR / 10,
+				Address: "9yZhA4eVVjBd6ihbdTTifB2BxDn2UiLKuY79Y13VdxDt7kRzpNkV3HS3XvjcbFEsz2hqUF7dzUSthN6Ea2wF6mpPVbXzsiX",
+			},
 		},
+		AccountIndex:  0,
+		GetTxHex:      true,
+		GetTxMetadata: true,
+	}
+	result, err := wallet_proxy.Transfer(context.Background(), request)
+	if err != nil || result.TxHash == "" {
+		t.Errorf("Test_WalletRPCProxy_Transfer failed! %v", err)
+	}
+}
+
+func Test_WalletRPCProxy_ProvisionWallets(t *testing.T) {
+	wallets := []*rpcproxy.WalletRPCProxy{
+		rpctest.CreateWallet(t, rpctest.Config{}).WalletProxy(rpcproxy.ProxyConfig{}),
+		rpctest.CreateWallet(t, rpctest.Config{}).WalletProxy(rpcproxy.ProxyConfig{}),
+	}
+	provisioned, err := rpcproxy.ProvisionWallets(context.Background(), wallets, 3, rpcproxy.ProvisionConfig{Prefix: "test"})
+	if err != nil || len(provisioned) != 3 {
+		t.Fatalf("ProvisionWallets failed: %v", err)
+	}
+	if provisioned[2].Proxy != wallets[0] || provisioned[0].Address != rpctest.WalletAddress {
+		t.Errorf("unexpected provisioned wallet: %+v", provisioned[2])
+	}
+	if err := rpcproxy.TeardownWallets(context.Background(), provisioned); err != nil {
+		t.Errorf("TeardownWallets failed: %v", err)
+	}
+}
+
+func Test_WalletRPCProxy_TransferInvalidAddress(t *testing.T) {
+	wallet := rpctest.CreateWallet(t, rpctest.Config{})
+	wallet_proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{})
+	request := rpcproxy.TransferRequest{
+		Destinations: []rpcproxy.Destination{{Amount: 1, Address: rpctest.WalletAddress[:94] + "1"}},
+	}
+	if _, err := wallet_proxy.Transfer(context.Background(), request); !errors.Is(err, address.ErrChecksum) {
+		t.Errorf("expected checksum error, got %v", err)
+	}
+	// 地址不合法时不调用wallet-rpc
+	if wallet.Calls("transfer") != 0 {
+		t.Errorf("invalid transfer reached wallet-rpc")
+	}
+}
+
+// method最后一次请求的params与expected（JSON）完全相同，用于检查字段名和omitempty
+func checkParams(t *testing.T, server *rpctest.Server, method string, expected string) {
+	t.Helper()
+	params, want := map[string]interface{}{}, map[string]interface{}{}
+	if err := server.LastParams(method, &params); err != nil {
+		t.Errorf("%s params: %v", method, err)
+		return
+	}
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatalf("bad expected params %s: %v", expected, err)
+	}
+	if !reflect.DeepEqual(params, want) {
+		got, _ := json.Marshal(params)
+		t.Errorf("%s params: got %s, expected %s", method, got, expected)
+	}
+}
+
+func Test_WalletRPCProxy_AddressMethods(t *testing.T) {
+	wallet := rpctest.CreateWallet(t, rpctest.Config{})
+	proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{})
+	ctx := context.Background()
+
+	wallet.SetResult("get_address_index", map[string]interface{}{"index": map[string]interface{}{"major": 0, "minor": 1}})
+	index, err := proxy.GetAddressIndex(ctx, rpctest.WalletSubaddress01)
+	if err != nil || index != (rpcproxy.SubaddressIndex{Major: 0, Minor: 1}) {
+		t.Errorf("GetAddressIndex: got %+v, %v", index, err)
+	}
+	checkParams(t, wallet, "get_address_index", `{"address": "`+rpctest.WalletSubaddress01+`"}`)
+
+	wallet.SetResult("create_address", map[string]interface{}{
+		"address": rpctest.WalletSubaddress01, "address_index": 1,
+		"addresses": []string{rpctest.WalletSubaddress01}, "address_indices": []uint32{1},
+	})
+	created, err := proxy.CreateAddress(ctx, 0, "donations", 0)
+	if err != nil || created.Address != rpctest.WalletSubaddress01 || created.AddressIndex != 1 || len(created.AddressIndices) != 1 {
+		t.Errorf("CreateAddress: got %+v, %v", created, err)
+	}
+	// count为0时不发送
+	checkParams(t, wallet, "create_address", `{"account_index": 0, "label": "donations"}`)
+	proxy.CreateAddress(ctx, 1, "", 3)
+	checkParams(t, wallet, "create_address", `{"account_index": 1, "label": "", "count": 3}`)
+
+	if err := proxy.LabelAddress(ctx, rpcproxy.SubaddressIndex{Major: 1, Minor: 2}, "shop"); err != nil {
+		t.Errorf("LabelAddress: %v", err)
+	}
+	checkParams(t, wallet, "label_address", `{"index": {"major": 1, "minor": 2}, "label": "shop"}`)
+
+	accounts, err := proxy.GetAccounts(ctx, "", true)
+	if err != nil || len(accounts.SubaddressAccounts) != 2 || accounts.SubaddressAccounts[1].BaseAddress != rpctest.WalletSubaddress10 ||
+		accounts.TotalBalance != 157443303037455077 || accounts.SubaddressAccounts[0].Label != "Primary account" {
+		t.Errorf("GetAccounts: got %+v, %v", accounts, err)
+	}
+	// tag为空时不发送
+	checkParams(t, wallet, "get_accounts", `{"strict_balances": true}`)
+	proxy.GetAccounts(ctx, "savings", false)
+	checkParams(t, wallet, "get_accounts", `{"strict_balances": false, "tag": "savings"}`)
+
+	wallet.SetResult("create_account", map[string]interface{}{"account_index": 2, "address": rpctest.WalletSubaddress10})
+	account, err := proxy.CreateAccount(ctx, "savings")
+	if err != nil || account.AccountIndex != 2 || account.Address != rpctest.WalletSubaddress10 {
+		t.Errorf("CreateAccount: got %+v, %v", account, err)
+	}
+	checkParams(t, wallet, "create_account", `{"label": "savings"}`)
+
+	validated, err := proxy.ValidateAddress(ctx, rpctest.WalletSubaddress01, true, false)
+	if err != nil || !validated.Valid || !validated.Subaddress || validated.Nettype != "testnet" {
+		t.Errorf("ValidateAddress: got %+v, %v", validated, err)
+	}
+	checkParams(t, wallet, "validate_address", `{"address": "`+rpctest.WalletSubaddress01+`", "any_net_type": true, "allow_openalias": false}`)
+	if validated, err := proxy.ValidateAddress(ctx, "not an address", false, false); err != nil || validated.Valid {
+		t.Errorf("ValidateAddress with a bad address: got %+v, %v", validated, err)
+	}
+
+	key, err := proxy.QueryKey(ctx, rpcproxy.KeyTypeViewKey)
+	if err != nil || key != rpctest.WalletViewKey {
+		t.Errorf("QueryKey: got %s, %v", key, err)
+	}
+	checkParams(t, wallet, "query_key", `{"key_type": "view_key"}`)
+	rpc_error := &rpcproxy.RPCError{}
+	if _, err := proxy.QueryKey(ctx, "secret"); !errors.As(err, &rpc_error) || rpc_error.Code != -29 {
+		t.Errorf("QueryKey with unknown key type: expected rpc error -29, got %v", err)
+	}
+}
+
+func Test_WalletRPCProxy_TransferMethods(t *testing.T) {
+	wallet := rpctest.CreateWallet(t, rpctest.Config{})
+	proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{})
+	ctx := context.Background()
+	recipient := "9yZhA4eVVjBd6ihbdTTifB2BxDn2UiLKuY79Y13VdxDt7kRzpNkV3HS3XvjcbFEsz2hqUF7dzUSthN6Ea2wF6mpPVbXzsiX"
+	tx_hash := rpctest.BlockHash(1)
+	key_image := rpctest.BlockHash(2)
+
+	wallet.SetResult("incoming_transfers", map[string]interface{}{"transfers": []map[string]interface{}{{
+		"amount": 200000000000, "block_height": 2711321, "frozen": false, "global_index": 83275,
+		"key_image": key_image, "pubkey": rpctest.BlockHash(3), "spent": false,
+		"subaddr_index": map[string]interface{}{"major": 0, "minor": 1}, "tx_hash": tx_hash, "unlocked": true,
+	}}})
+	incoming, err := proxy.IncomingTransfers(ctx, rpcproxy.TransferTypeAvailable, 0, nil)
+	if err != nil || len(incoming) != 1 || incoming[0].Amount != 2*monero.XMR/10 || incoming[0].GlobalIndex != 83275 ||
+		incoming[0].SubaddrIndex.Minor != 1 || !incoming[0].Unlocked || incoming[0].KeyImage != key_image {
+		t.Errorf("IncomingTransfers: got %+v, %v", incoming, err)
+	}
+	checkParams(t, wallet, "incoming_transfers", `{"transfer_type": "available", "account_index": 0}`)
+	proxy.IncomingTransfers(ctx, rpcproxy.TransferTypeAll, 1, []uint32{0, 2})
+	checkParams(t, wallet, "incoming_transfers", `{"transfer_type": "all", "account_index": 1, "subaddr_indices": [0, 2]}`)
+
+	transfers, err := proxy.GetTransfers(ctx, rpcproxy.GetTransfersRequest{In: true, Out: true})
+	if err != nil || len(transfers.In) != 1 || len(transfers.Out) != 1 {
+		t.Fatalf("GetTransfers: got %+v, %v", transfers, err)
+	}
+	out := transfers.Out[0]
+	if out.Amount != monero.XMR/10 || out.Fee != 30740000 || len(out.Destinations) != 1 || out.Destinations[0].Address != recipient ||
+		out.Type != "out" || out.SubaddrIndices[0] != (rpcproxy.SubaddressIndex{}) || out.Height != 2712333 {
+		t.Errorf("unexpected outgoing transfer %+v", out)
+	}
+	// 为false的过滤条件和空的subaddr_indices不发送，account_index总是发送
+	checkParams(t, wallet, "get_transfers", `{"in": true, "out": true, "account_index": 0}`)
+	proxy.GetTransfers(ctx, rpcproxy.GetTransfersRequest{Pool: true, FilterByHeight: true, MinHeight: 2700000, AccountIndex: 1, SubaddrIndices: []uint32{3}})
+	checkParams(t, wallet, "get_transfers", `{"pool": true, "filter_by_height": true, "min_height": 2700000, "account_index": 1, "subaddr_indices": [3]}`)
+
+	wallet.SetResult("get_transfer_by_txid", map[string]interface{}{
+		"transfer":  map[string]interface{}{"txid": tx_hash, "amount": 100000000000, "type": "pool", "confirmations": 0},
+		"transfers": []map[string]interface{}{{"txid": tx_hash, "amount": 100000000000, "type": "pool"}},
+	})
+	by_txid, err := proxy.GetTransferByTxid(ctx, tx_hash, 0)
+	if err != nil || by_txid.Transfer.Txid != tx_hash || by_txid.Transfer.Type != "pool" || len(by_txid.Transfers) != 1 {
+		t.Errorf("GetTransferByTxid: got %+v, %v", by_txid, err)
+	}
+	checkParams(t, wallet, "get_transfer_by_txid", `{"txid": "`+tx_hash+`", "account_index": 0}`)
+
+	split_result := map[string]interface{}{
+		"amount_list": []uint64{60000000000, 40000000000}, "fee_list": []uint64{30740000, 30460000}, "weight_list": []uint64{1448, 1445},
+		"multisig_txset": "", "unsigned_txset": "",
+		"tx_hash_list": []string{tx_hash, rpctest.BlockHash(4)}, "tx_key_list": []string{rpctest.BlockHash(5), rpctest.BlockHash(6)},
+		"tx_blob_list": []string{}, "tx_metadata_list": []string{},
+		"spent_key_images_list": []map[string]interface{}{{"key_images": []string{key_image}}, {"key_images": []string{rpctest.BlockHash(7)}}},
+	}
+	wallet.SetResult("transfer_split", split_result)
+	split, err := proxy.TransferSplit(ctx, rpcproxy.TransferRequest{
+		Destinations: []rpcproxy.Destination{{Amount: monero.XMR / 10, Address: recipient}},
+		Priority:     2,
+		GetTxKeys:    true,
+	})
+	if err != nil || len(split.TxHashList) != 2 || split.AmountList[1] != 40000000000 || split.FeeList[0] != 30740000 ||
+		len(split.SpentKeyImagesList) != 2 || split.SpentKeyImagesList[0].KeyImages[0] != key_image || split.WeightList[1] != 1445 {
+		t.Errorf("TransferSplit: got %+v, %v", split, err)
+	}
+	checkParams(t, wallet, "transfer_split", `{"destinations": [{"amount": 100000000000, "address": "`+recipient+`"}], "account_index": 0, "priority": 2, "get_tx_keys": true}`)
+	if _, err := proxy.TransferSplit(ctx, rpcproxy.TransferRequest{Destinations: []rpcproxy.Destination{{Amount: 1, Address: "bad"}}}); err == nil || wallet.Calls("transfer_split") != 1 {
+		t.Errorf("TransferSplit with a bad address should fail before calling wallet-rpc: %v", err)
+	}
+
+	wallet.SetResult("sweep_all", split_result)
+	swept, err := proxy.SweepAll(ctx, rpcproxy.SweepAllRequest{Address: recipient, SubaddrIndicesAll: true, BelowAmount: monero.XMR, DoNotRelay: true})
+	if err != nil || len(swept.TxHashList) != 2 {
+		t.Errorf("SweepAll: got %+v, %v", swept, err)
+	}
+	checkParams(t, wallet, "sweep_all", `{"address": "`+recipient+`", "account_index": 0, "subaddr_indices_all": true, "below_amount": 1000000000000, "do_not_relay": true}`)
+	if _, err := proxy.SweepAll(ctx, rpcproxy.SweepAllRequest{Address: recipient[:94] + "1"}); !errors.Is(err, address.ErrChecksum) || wallet.Calls("sweep_all") != 1 {
+		t.Errorf("SweepAll with a bad address: got %v", err)
+	}
+
+	wallet.SetResult("sweep_single", map[string]interface{}{
+		"amount": 199969260000, "fee": 30740000, "tx_hash": tx_hash, "tx_key": rpctest.BlockHash(5), "weight": 1448,
+		"spent_key_images": map[string]interface{}{"key_images": []string{key_image}},
+	})
+	single, err := proxy.SweepSingle(ctx, rpcproxy.SweepSingleRequest{Address: recipient, KeyImage: key_image, GetTxKey: true})
+	if err != nil || single.Amount != 199969260000 || single.TxKey != rpctest.BlockHash(5) || single.SpentKeyImages.KeyImages[0] != key_image {
+		t.Errorf("SweepSingle: got %+v, %v", single, err)
+	}
+	checkParams(t, wallet, "sweep_single", `{"address": "`+recipient+`", "key_image": "`+key_image+`", "get_tx_key": true}`)
+
+	// 没有dust时wallet-rpc返回空的结果
+	wallet.SetResult("sweep_dust", map[string]interface{}{})
+	dust, err := proxy.SweepDust(ctx, rpcproxy.SweepDustRequest{})
+	if err != nil || len(dust.TxHashList) != 0 {
+		t.Errorf("SweepDust: got %+v, %v", dust, err)
+	}
+	checkParams(t, wallet, "sweep_dust", `{}`)
+
+	wallet.SetResult("relay_tx", map[string]interface{}{"tx_hash": tx_hash})
+	relayed, err := proxy.RelayTx(ctx, "0102")
+	if err != nil || relayed != tx_hash {
+		t.Errorf("RelayTx: got %s, %v", relayed, err)
+	}
+	checkParams(t, wallet, "relay_tx", `{"hex": "0102"}`)
+}
+
+func Test_WalletRPCProxy_ProofMethods(t *testing.T) {
+	wallet := rpctest.CreateWallet(t, rpctest.Config{})
+	proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{})
+	ctx := context.Background()
+	tx_hash := rpctest.BlockHash(1)
+	tx_key := rpctest.BlockHash(5)
+
+	wallet.SetResult("get_tx_key", map[string]interface{}{"tx_key": tx_key})
+	if key, err := proxy.GetTxKey(ctx, tx_hash); err != nil || key != tx_key {
+		t.Errorf("GetTxKey: got %s, %v", key, err)
+	}
+	checkParams(t, wallet, "get_tx_key", `{"txid": "`+tx_hash+`"}`)
+
+	wallet.SetResult("check_tx_key", map[string]interface{}{"confirmations": 12, "in_pool": false, "received": 100000000000})
+	checked, err := proxy.CheckTxKey(ctx, tx_hash, tx_key, rpctest.WalletSubaddress01)
+	if err != nil || checked.Confirmations != 12 || checked.InPool || checked.Received != monero.XMR/10 {
+		t.Errorf("CheckTxKey: got %+v, %v", checked, err)
+	}
+	checkParams(t, wallet, "check_tx_key", `{"txid": "`+tx_hash+`", "tx_key": "`+tx_key+`", "address": "`+rpctest.WalletSubaddress01+`"}`)
+
+	signature := "OutProofV2Lbzu9ZQnTHpS1XgpnWTBnEVqRKPr6XQRqqLRDBV9LLSjcK5HBVbTTL4U52WmDLb5wA8wBHEn2VUuuK4RwW8aTPKVEZCN3kpKtLDJNqPSyHM"
+	wallet.SetResult("get_tx_proof", map[string]interface{}{"signature": signature})
+	if proof, err := proxy.GetTxProof(ctx, tx_hash, rpctest.WalletSubaddress01, "invoice 42"); err != nil || proof != signature {
+		t.Errorf("GetTxProof: got %s, %v", proof, err)
+	}
+	checkParams(t, wallet, "get_tx_proof", `{"txid": "`+tx_hash+`", "address": "`+rpctest.WalletSubaddress01+`", "message": "invoice 42"}`)
+
+	wallet.SetResult("check_tx_proof", map[string]interface{}{"confirmations": 12, "good": true, "in_pool": false, "received": 100000000000})
+	proof_result, err := proxy.CheckTxProof(ctx, tx_hash, rpctest.WalletSubaddress01, "invoice 42", signature)
+	if err != nil || !proof_result.Good || proof_result.Received != monero.XMR/10 || proof_result.Confirmations != 12 {
+		t.Errorf("CheckTxProof: got %+v, %v", proof_result, err)
+	}
+	checkParams(t, wallet, "check_tx_proof", `{"txid": "`+tx_hash+`", "address": "`+rpctest.WalletSubaddress01+`", "message": "invoice 42", "signature": "`+signature+`"}`)
+
+	reserve_signature := "ReserveProofV2" + rpctest.BlockHash(8)
+	wallet.SetResult("get_reserve_proof", map[string]interface{}{"signature": reserve_signature})
+	if proof, err := proxy.GetReserveProof(ctx, rpcproxy.GetReserveProofRequest{All: true}); err != nil || proof != reserve_signature {
+		t.Errorf("GetReserveProof: got %s, %v", proof, err)
+	}
+	// message为空时不发送，all、account_index和amount总是发送
+	checkParams(t, wallet, "get_reserve_proof", `{"all": true, "account_index": 0, "amount": 0}`)
+	proxy.GetReserveProof(ctx, rpcproxy.GetReserveProofRequest{AccountIndex: 1, Amount: monero.XMR, Message: "audit"})
+	checkParams(t, wallet, "get_reserve_proof", `{"all": false, "account_index": 1, "amount": 1000000000000, "message": "audit"}`)
+
+	wallet.SetResult("check_reserve_proof", map[string]interface{}{"good": true, "spent": 0, "total": 157443303037455077})
+	reserve, err := proxy.CheckReserveProof(ctx, rpctest.WalletAddress, "audit", reserve_signature)
+	if err != nil || !reserve.Good || reserve.Total != 157443303037455077 || reserve.Spent != 0 {
+		t.Errorf("CheckReserveProof: got %+v, %v", reserve, err)
+	}
+	checkParams(t, wallet, "check_reserve_proof", `{"address": "`+rpctest.WalletAddress+`", "message": "audit", "signature": "`+reserve_signature+`"}`)
+	// 证明无效时wallet-rpc返回error
+	wallet.Script("check_reserve_proof", rpctest.ErrorResponse(-1, "Failed to check reserve proof"))
+	if _, err := proxy.CheckReserveProof(ctx, rpctest.WalletAddress, "audit", "bad"); err == nil {
+		t.Errorf("expected error for a bad reserve proof")
+	}
+
+	message_signature := "SigV2Nf5sHCGD8bRXNqRVeSUgAYjTQZMaSvf2CRNhzyqG6UuWZ8SoTg5jkJJfWJ1VFpD5tnxRbjWd3jNYB4HUo4G3GpJmq2e"
+	wallet.SetResult("sign", map[string]interface{}{"signature": message_signature})
+	if signed, err := proxy.Sign(ctx, "hello"); err != nil || signed != message_signature {
+		t.Errorf("Sign: got %s, %v", signed, err)
+	}
+	checkParams(t, wallet, "sign", `{"data": "hello"}`)
+	wallet.SetResult("verify", map[string]interface{}{"good": true})
+	if good, err := proxy.Verify(ctx, "hello", rpctest.WalletAddress, message_signature); err != nil || !good {
+		t.Errorf("Verify: got %t, %v", good, err)
+	}
+	checkParams(t, wallet, "verify", `{"data": "hello", "address": "`+rpctest.WalletAddress+`", "signature": "`+message_signature+`"}`)
+}
+
+func Test_WalletRPCProxy_MaintenanceMethods(t *testing.T) {
+	wallet := rpctest.CreateWallet(t, rpctest.Config{})
+	proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{})
+	ctx := context.Background()
+
+	if err := proxy.Store(ctx); err != nil || wallet.Calls("store") != 1 {
+		t.Errorf("Store: %v", err)
+	}
+
+	wallet.SetResult("refresh", map[string]interface{}{"blocks_fetched": 24, "received_money": true})
+	refreshed, err := proxy.Refresh(ctx, 0)
+	if err != nil || refreshed.BlocksFetched != 24 || !refreshed.ReceivedMoney {
+		t.Errorf("Refresh: got %+v, %v", refreshed, err)
+	}
+	// start_height为0时不发送
+	checkParams(t, wallet, "refresh", `{}`)
+	proxy.Refresh(ctx, 2700000)
+	checkParams(t, wallet, "refresh", `{"start_height": 2700000}`)
+
+	if err := proxy.RescanBlockchain(ctx, true); err != nil {
+		t.Errorf("RescanBlockchain: %v", err)
+	}
+	checkParams(t, wallet, "rescan_blockchain", `{"hard": true}`)
+
+	uri := "monero:" + rpctest.WalletAddress + "?tx_amount=0.5&tx_description=coffee"
+	wallet.SetResult("make_uri", map[string]interface{}{"uri": uri})
+	made, err := proxy.MakeURI(ctx, rpcproxy.PaymentURI{Address: rpctest.WalletAddress, Amount: monero.XMR / 2, TxDescription: "coffee"})
+	if err != nil || made != uri {
+		t.Errorf("MakeURI: got %s, %v", made, err)
+	}
+	checkParams(t, wallet, "make_uri", `{"address": "`+rpctest.WalletAddress+`", "amount": 500000000000, "tx_description": "coffee"}`)
+
+	wallet.SetResult("parse_uri", map[string]interface{}{"uri": map[string]interface{}{
+		"address": rpctest.WalletAddress, "amount": 500000000000, "payment_id": "", "recipient_name": "", "tx_description": "coffee",
+	}})
+	parsed, err := proxy.ParseURI(ctx, uri)
+	if err != nil || parsed.Address != rpctest.WalletAddress || parsed.Amount != monero.XMR/2 || parsed.TxDescription != "coffee" {
+		t.Errorf("ParseURI: got %+v, %v", parsed, err)
+	}
+	checkParams(t, wallet, "parse_uri", `{"uri": "`+uri+`"}`)
+}
+
+func Test_WalletRPCProxy_WalletLifecycle(t *testing.T) {
+	wallet := rpctest.CreateWallet(t, rpctest.Config{})
+	proxy := wallet.WalletProxy(rpcproxy.ProxyConfig{})
+	ctx := context.Background()
+
+	// language为空时使用English
+	if err := proxy.CreateWallet(ctx, "alice", "secret", ""); err != nil {
+		t.Errorf("CreateWallet: %v", err)
+	}
+	checkParams(t, wallet, "create_wallet", `{"filename": "alice", "password": "secret", "language": "English"}`)
+	proxy.CreateWallet(ctx, "bob", "", "Deutsch")
+	checkParams(t, wallet, "create_wallet", `{"filename": "bob", "password": "", "language": "Deutsch"}`)
+
+	if err := proxy.OpenWallet(ctx, "alice", "secret"); err != nil {
+		t.Errorf("OpenWallet: %v", err)
+	}
+	checkParams(t, wallet, "open_wallet", `{"filename": "alice", "password": "secret"}`)
+	wallet.Script("open_wallet", rpctest.ErrorResponse(-1, "Failed to open wallet"))
+	rpc_error := &rpcproxy.RPCError{}
+	if err := proxy.OpenWallet(ctx, "alice", "wrong"); !errors.As(err, &rpc_error) || rpc_error.Code != -1 {
+		t.Errorf("OpenWallet with a wrong password: expected rpc error -1, got %v", err)
+	}
+
+	if err := proxy.ChangeWalletPassword(ctx, "secret", "new secret"); err != nil {
+		t.Errorf("ChangeWalletPassword: %v", err)
+	}
+	checkParams(t, wallet, "change_wallet_password", `{"old_password": "secret", "new_password": "new secret"}`)
+
+	if err := proxy.CloseWallet(ctx); err != nil || wallet.Calls("close_wallet") != 1 {
+		t.Errorf("CloseWallet: %v", err)
+	}
+	// 没有打开的钱包时wallet-rpc返回-13
+	wallet.Script("close_wallet", rpctest.ErrorResponse(-13, "No wallet file"))
+	if err := proxy.CloseWallet(ctx); !errors.As(err, &rpc_error) || rpc_error.Code != -13 {
+		t.Errorf("CloseWallet without an open wallet: expected rpc error -13, got %v", err)
+	}
+
+	wallet.SetResult("generate_from_keys", map[string]interface{}{
+		"address": rpctest.WalletAddress, "info": "Wallet has been generated successfully.",
+	})
+	generated, err := proxy.GenerateFromKeys(ctx, rpcproxy.GenerateFromKeysRequest{
+		Filename: "full", Password: "secret", Address: rpctest.WalletAddress,
+		Spendkey: rpctest.WalletSpendKey, Viewkey: rpctest.WalletViewKey, RestoreHeight: 2700000,
+	})
+	if err != nil || generated.Address != rpctest.WalletAddress || generated.Info == "" {
+		t.Errorf("GenerateFromKeys: got %+v, %v", generated, err)
+	}
+	checkParams(t, wallet, "generate_from_keys", `{"filename": "full", "password": "secret", "address": "`+rpctest.WalletAddress+
+		`", "spendkey": "`+rpctest.WalletSpendKey+`", "viewkey": "`+rpctest.WalletViewKey+`", "restore_height": 2700000, "autosave_current": false}`)
+	// spendkey为空时生成只读钱包，不发送spendkey
+	if _, err := proxy.GenerateFromKeys(ctx, rpcproxy.GenerateFromKeysRequest{
+		Filename: "view-only", Address: rpctest.WalletAddress, Viewkey: rpctest.WalletViewKey, AutosaveCurrent: true,
+	}); err != nil {
+		t.Errorf("GenerateFromKeys view-only: %v", err)
+	}
+	checkParams(t, wallet, "generate_from_keys", `{"filename": "view-only", "password": "", "address": "`+rpctest.WalletAddress+
+		`", "viewkey": "`+rpctest.WalletViewKey+`", "autosave_current": true}`)
+
+	wallet.SetResult("get_languages", map[string]interface{}{
+		"languages":       []string{"Deutsch", "English", "Español", "Français", "Italiano", "Nederlands", "Português", "русский язык", "日本語", "简体中文 (中国)", "Esperanto", "Lojban"},
+		"languages_local": []string{"Deutsch", "English", "Español", "Français", "Italiano", "Nederlands", "Português", "русский язык", "日本語", "简体中文 (中国)", "Esperanto", "Lojban"},
+	})
+	languages, err := proxy.GetLanguages(ctx)
+	if err != nil || len(languages.Languages) != 12 || languages.Languages[1] != rpcproxy.DefaultWalletLanguage || languages.LanguagesLocal[8] != "日本語" {
+		t.Errorf("GetLanguages: got %+v, %v", languages, err)
+	}
+	if request := wallet.Requests()[len(wallet.Requests())-1]; request.Method != "get_languages" {
+		t.Errorf("unexpected last request %+v", request)
+	}
+}
+
+func Test_WalletRPCProxy_ProvisionWalletsFailure(t *testing.T) {
+	ctx := context.Background()
+	first := rpctest.CreateWallet(t, rpctest.Config{})
+	second := rpctest.CreateWallet(t, rpctest.Config{})
+	proxies := []*rpcproxy.WalletRPCProxy{
+		first.WalletProxy(rpcproxy.ProxyConfig{}),
+		second.WalletProxy(rpcproxy.ProxyConfig{}),
+	}
+
+	// 第二个钱包创建失败：关闭已创建的第一个钱包，second上没有打开的钱包
+	second.Script("create_wallet", rpctest.ErrorResponse(-21, "Cannot create wallet. Already exists."))
+	provisioned, err := rpcproxy.ProvisionWallets(ctx, proxies, 2, rpcproxy.ProvisionConfig{Password: "secret"})
+	rpc_error := &rpcproxy.RPCError{}
+	if err == nil || provisioned != nil || !errors.As(err, &rpc_error) || rpc_error.Code != -21 {
+		t.Fatalf("expected rpc error -21, got %v, %+v", err, provisioned)
+	}
+	if first.Calls("close_wallet") != 1 || second.Calls("close_wallet") != 0 {
+		t.Errorf("teardown closed %d and %d wallets", first.Calls("close_wallet"), second.Calls("close_wallet"))
+	}
+	// 默认前缀和语言
+	params := map[string]interface{}{}
+	if err := first.LastParams("create_wallet", &params); err != nil || params["language"] != rpcproxy.DefaultWalletLanguage || params["password"] != "secret" {
+		t.Errorf("unexpected create_wallet params %v", params)
+	}
+	if filename, _ := params["filename"].(string); len(filename) < 11 || filename[:11] != "experiment-" {
+		t.Errorf("unexpected wallet filename %q", filename)
+	}
+
+	// 创建后取地址失败：刚创建的钱包也要关闭
+	second.Script("get_address", rpctest.ErrorResponse(-13, "No wallet file"))
+	if _, err := rpcproxy.ProvisionWallets(ctx, proxies, 2, rpcproxy.ProvisionConfig{}); err == nil {
+		t.Fatalf("expected get_address error")
+	}
+	if first.Calls("close_wallet") != 2 || second.Calls("close_wallet") != 1 {
+		t.Errorf("teardown closed %d and %d wallets", first.Calls("close_wallet"), second.Calls("close_wallet"))
+	}
+
+	// 助记词数量不足时不调用wallet-rpc
+	calls := len(first.Requests())
+	if _, err := rpcproxy.ProvisionWallets(ctx, proxies, 2, rpcproxy.ProvisionConfig{Seeds: []string{rpctest.WalletSeed}}); err == nil || len(first.Requests()) != calls {
+		t.Errorf("expected seed count error without calling wallet-rpc, got %v", err)
 	}
-	result := wallet_proxy.Transfer(destinations, 0, nil, false, false, true, true)
-	expected := result != nil
-	if !expected {
-		t.Errorf("Test_WalletRPCProxy_Transfer failed!")
+	if _, err := rpcproxy.ProvisionWallets(ctx, nil, 1, rpcproxy.ProvisionConfig{}); err == nil {
+		t.Errorf("expected error without proxies")
 	}
 }
@@ -1 +1,119 @@
 package web
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gomonero/monero/cryptonote"
+	"gomonero/rpcproxy"
+	"strconv"
+)
+
+// 区块浏览器返回的区块：monerod的区块头加上解析后的区块
+type ExplorerBlock struct {
+	Height      uint64           `json:"height"`
+	Hash        string           `json:"hash"`
+	MinerTxHash string           `json:"miner_tx_hash"`
+	Block       cryptonote.Block `json:"block"`
+	// 解析后的miner tx的extra
+	MinerTxExtra cryptonote.Extra `json:"miner_tx_extra"`
+}
+
+// 按高度或哈希（64位hex）查询区块并解析区块的blob
+func GetExplorerBlock(ctx context.Context, daemon *rpcproxy.DaemonRPCProxy, height_or_hash string) (ExplorerBlock, error) {
+	request := rpcproxy.GetBlockRequest{}
+	if len(height_or_hash) == 64 {
+		request.Hash = height_or_hash
+	} else {
+		height, err := strconv.ParseUint(height_or_hash, 10, 64)
+		if err != nil {
+			return ExplorerBlock{}, errors.New("expected a block height or a 64-character block hash")
+		}
+		request.Height = height
+	}
+	result, err := daemon.GetBlock(ctx, request)
+	if err != nil {
+		return ExplorerBlock{}, err
+	}
+	// 自己计算区块哈希，与monerod返回的哈希比对
+	blob, err := hex.DecodeString(result.Blob)
+	if err != nil {
+		return ExplorerBlock{}, err
+	}
+	expected := cryptonote.Hash{}
+	if err := expected.UnmarshalText([]byte(result.BlockHeader.Hash)); err != nil {
+		return ExplorerBlock{}, err
+	}
+	block, err := cryptonote.VerifyBlock(blob, expected)
+	if err != nil {
+		return ExplorerBlock{}, err
+	}
+	return ExplorerBlock{
+		Height:       result.BlockHeader.Height,
+		Hash:         result.BlockHeader.Hash,
+		MinerTxHash:  result.MinerTxHash,
+		Block:        block,
+		MinerTxExtra: block.MinerTx.ParseExtra(),
+	}, nil
+}
+
+// 区块浏览器返回的交易：解析后的交易和extra
+type ExplorerTransaction struct {
+	Hash          string                 `json:"hash"`
+	InPool        bool                   `json:"in_pool"`
+	BlockHeight   uint64                 `json:"block_height"`
+	Confirmations uint64                 `json:"confirmations"`
+	Transaction   cryptonote.Transaction `json:"transaction"`
+	Extra         cryptonote.Extra       `json:"extra"`
+}
+
+// 按哈希查询交易，解析交易并校验其哈希
+func GetExplorerTransaction(ctx context.Context, daemon *rpcproxy.DaemonRPCProxy, tx_hash string) (ExplorerTransaction, error) {
+	expected := cryptonote.Hash{}
+	if err := expected.UnmarshalText([]byte(tx_hash)); err != nil {
+		return ExplorerTransaction{}, errors.New("expected a 64-character transaction hash")
+	}
+	result, err := daemon.GetTransactions(ctx, rpcproxy.GetTransactionsRequest{TxsHashes: []string{tx_hash}})
+	if err != nil {
+		return ExplorerTransaction{}, err
+	}
+	if len(result.Txs) == 0 {
+		return ExplorerTransaction{}, fmt.Errorf("transaction %s not found", tx_hash)
+	}
+	entry := result.Txs[0]
+	// 剪枝节点只返回pruned_as_hex和prunable_hash
+	pruned := entry.AsHex == ""
+	blob := entry.AsHex
+	if pruned {
+		blob = entry.PrunedAsHex
+	}
+	tx, err := cryptonote.ParseTransactionHex(blob)
+	if err != nil {
+		return ExplorerTransaction{}, err
+	}
+	var hash cryptonote.Hash
+	if pruned && tx.Version == 2 {
+		prunable_hash := cryptonote.Hash{}
+		if err := prunable_hash.UnmarshalText([]byte(entry.PrunableHash)); err != nil {
+			return ExplorerTransaction{}, err
+		}
+		hash, err = tx.HashWithPrunableHash(prunable_hash)
+	} else {
+		hash, err = tx.Hash()
+	}
+	if err != nil {
+		return ExplorerTransaction{}, err
+	}
+	if hash != expected {
+		return ExplorerTransaction{}, fmt.Errorf("%w: computed %s, expected %s", cryptonote.ErrHashMismatch, hash, expected)
+	}
+	return ExplorerTransaction{
+		Hash:          tx_hash,
+		InPool:        entry.InPool,
+		BlockHeight:   entry.BlockHeight,
+		Confirmations: entry.Confirmations,
+		Transaction:   tx,
+		Extra:         tx.ParseExtra(),
+	}, nil
+}
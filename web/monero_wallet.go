@@ -1 +1,37 @@
 package web
+
+import (
+	"context"
+	"gomonero/monero"
+	"gomonero/rpcproxy"
+)
+
+// 钱包余额，金额的JSON格式由类型决定：monero.AmountXMR为XMR字符串，monero.Amount为原子单位整数
+type WalletBalance[T monero.Amount | monero.AmountXMR] struct {
+	AccountIndex    uint32 `json:"account_index"`
+	Balance         T      `json:"balance"`
+	UnlockedBalance T      `json:"unlocked_balance"`
+	BlocksToUnlock  uint64 `json:"blocks_to_unlock"`
+}
+
+// 查询账户余额，xmr为true时金额以XMR字符串返回，否则以piconero整数返回
+func GetWalletBalance(ctx context.Context, wallet *rpcproxy.WalletRPCProxy, account_index uint32, xmr bool) (interface{}, error) {
+	result, err := wallet.GetBalance(ctx, rpcproxy.GetBalanceRequest{AccountIndex: account_index})
+	if err != nil {
+		return nil, err
+	}
+	if xmr {
+		return WalletBalance[monero.AmountXMR]{
+			AccountIndex:    account_index,
+			Balance:         result.Balance.XMR(),
+			UnlockedBalance: result.UnlockedBalance.XMR(),
+			BlocksToUnlock:  result.BlocksToUnlock,
+		}, nil
+	}
+	return WalletBalance[monero.Amount]{
+		AccountIndex:    account_index,
+		Balance:         result.Balance,
+		UnlockedBalance: result.UnlockedBalance,
+		BlocksToUnlock:  result.BlocksToUnlock,
+	}, nil
+}
@@ -0,0 +1,314 @@
+// zmtp实现ZMTP 3.0协议（NULL认证）中PUB/SUB所需的部分，用于订阅monerod的--zmq-pub
+package zmtp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SocketPub = "PUB"
+	SocketSub = "SUB"
+)
+
+// 单帧的最大长度，防止对端用超长的长度字段耗尽内存
+const MaxFrameSize = 64 << 20
+
+const greetingLength = 64
+
+const (
+	flagMore    = byte(0x01)
+	flagLong    = byte(0x02)
+	flagCommand = byte(0x04)
+)
+
+var ErrProtocol = errors.New("zmtp: protocol error")
+
+// 可以互相连接的socket类型
+var compatibleSockets = map[string][]string{
+	SocketPub: {"SUB", "XSUB"},
+	SocketSub: {"PUB", "XPUB"},
+}
+
+/*
+==================
+
+	连接和握手
+
+==================
+*/
+
+type Conn struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	write_lock sync.Mutex
+
+	PeerSocketType string
+}
+
+// 连接endpoint（如tcp://127.0.0.1:18083）并完成握手
+func Dial(ctx context.Context, endpoint string, socket_type string) (*Conn, error) {
+	address, found := strings.CutPrefix(endpoint, "tcp://")
+	if !found {
+		return nil, fmt.Errorf("zmtp: unsupported endpoint %q, only tcp:// is supported", endpoint)
+	}
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	// 握手也受ctx的超时限制
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	zmtp_conn, err := Handshake(conn, socket_type, false)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return zmtp_conn, nil
+}
+
+// 在已建立的连接上交换greeting和READY命令
+func Handshake(conn net.Conn, socket_type string, as_server bool) (*Conn, error) {
+	zmtp_conn := &Conn{conn: conn, reader: bufio.NewReader(conn)}
+
+	// 1. greeting: signature(10) + version(2) + mechanism(20) + as-server(1) + filler(31)
+	greeting := make([]byte, greetingLength)
+	greeting[0] = 0xff
+	greeting[9] = 0x7f
+	greeting[10] = 3
+	greeting[11] = 0
+	copy(greeting[12:32], "NULL")
+	if as_server {
+		greeting[32] = 1
+	}
+	if _, err := conn.Write(greeting); err != nil {
+		return nil, err
+	}
+	peer_greeting := make([]byte, greetingLength)
+	if _, err := io.ReadFull(zmtp_conn.reader, peer_greeting); err != nil {
+		return nil, err
+	}
+	if peer_greeting[0] != 0xff || peer_greeting[9] != 0x7f {
+		return nil, fmt.Errorf("%w: bad greeting signature", ErrProtocol)
+	}
+	if peer_greeting[10] < 3 {
+		return nil, fmt.Errorf("%w: unsupported version %d.%d", ErrProtocol, peer_greeting[10], peer_greeting[11])
+	}
+	if mechanism := string(bytes.TrimRight(peer_greeting[12:32], "\x00")); mechanism != "NULL" {
+		return nil, fmt.Errorf("%w: unsupported mechanism %q", ErrProtocol, mechanism)
+	}
+
+	// 2. READY命令，带Socket-Type属性
+	if err := zmtp_conn.writeFrame(flagCommand, readyCommand(socket_type)); err != nil {
+		return nil, err
+	}
+	flags, body, err := zmtp_conn.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	name, data, err := parseCommand(flags, body)
+	if err != nil {
+		return nil, err
+	}
+	if name == "ERROR" {
+		return nil, fmt.Errorf("zmtp: peer error: %s", parseErrorReason(data))
+	}
+	if name != "READY" {
+		return nil, fmt.Errorf("%w: expected READY, got %s", ErrProtocol, name)
+	}
+	properties, err := parseProperties(data)
+	if err != nil {
+		return nil, err
+	}
+	zmtp_conn.PeerSocketType = properties["Socket-Type"]
+	compatible := false
+	for _, peer_type := range compatibleSockets[socket_type] {
+		if peer_type == zmtp_conn.PeerSocketType {
+			compatible = true
+		}
+	}
+	if !compatible {
+		return nil, fmt.Errorf("%w: %s socket cannot talk to %q", ErrProtocol, socket_type, zmtp_conn.PeerSocketType)
+	}
+	return zmtp_conn, nil
+}
+
+func (conn *Conn) Close() error {
+	return conn.conn.Close()
+}
+
+func (conn *Conn) RemoteAddr() net.Addr {
+	return conn.conn.RemoteAddr()
+}
+
+/*
+==================
+
+	消息收发
+
+==================
+*/
+
+// 读取一条多帧消息，跳过对端发来的命令（ZMTP 3.1的PING等）
+func (conn *Conn) ReadMessage() ([][]byte, error) {
+	frames := [][]byte{}
+	for {
+		flags, body, err := conn.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		if flags&flagCommand != 0 {
+			name, data, err := parseCommand(flags, body)
+			if err != nil {
+				return nil, err
+			}
+			if name == "ERROR" {
+				return nil, fmt.Errorf("zmtp: peer error: %s", parseErrorReason(data))
+			}
+			continue
+		}
+		frames = append(frames, body)
+		if flags&flagMore == 0 {
+			return frames, nil
+		}
+	}
+}
+
+// 发送一条多帧消息
+func (conn *Conn) WriteMessage(frames ...[]byte) error {
+	conn.write_lock.Lock()
+	defer conn.write_lock.Unlock()
+	for i, frame := range frames {
+		flags := byte(0)
+		if i < len(frames)-1 {
+			flags |= flagMore
+		}
+		if err := conn.writeFrameLocked(flags, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SUB订阅以topic开头的消息，topic为空时订阅全部（ZMTP 3.0的订阅消息：0x01 + topic）
+func (conn *Conn) Subscribe(topic string) error {
+	return conn.WriteMessage(append([]byte{0x01}, topic...))
+}
+
+// 取消订阅：0x00 + topic
+func (conn *Conn) Unsubscribe(topic string) error {
+	return conn.WriteMessage(append([]byte{0x00}, topic...))
+}
+
+func (conn *Conn) readFrame() (byte, []byte, error) {
+	flags, err := conn.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	if flags&^(flagMore|flagLong|flagCommand) != 0 {
+		return 0, nil, fmt.Errorf("%w: bad frame flags 0x%02x", ErrProtocol, flags)
+	}
+	size := uint64(0)
+	if flags&flagLong != 0 {
+		size_bytes := make([]byte, 8)
+		if _, err := io.ReadFull(conn.reader, size_bytes); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(size_bytes)
+	} else {
+		size_byte, err := conn.reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(size_byte)
+	}
+	if size > MaxFrameSize {
+		return 0, nil, fmt.Errorf("%w: frame too large: %d bytes", ErrProtocol, size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(conn.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+func (conn *Conn) writeFrame(flags byte, body []byte) error {
+	conn.write_lock.Lock()
+	defer conn.write_lock.Unlock()
+	return conn.writeFrameLocked(flags, body)
+}
+
+func (conn *Conn) writeFrameLocked(flags byte, body []byte) error {
+	header := []byte{}
+	if len(body) > 255 {
+		header = append(header, flags|flagLong)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(body)))
+	} else {
+		header = append(header, flags, byte(len(body)))
+	}
+	_, err := conn.conn.Write(append(header, body...))
+	return err
+}
+
+/* ==== 命令 ==== */
+
+func readyCommand(socket_type string) []byte {
+	body := []byte{5}
+	body = append(body, "READY"...)
+	body = append(body, byte(len("Socket-Type")))
+	body = append(body, "Socket-Type"...)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(socket_type)))
+	body = append(body, socket_type...)
+	return body
+}
+
+// 命令帧：name-size(1) + name + data
+func parseCommand(flags byte, body []byte) (string, []byte, error) {
+	if flags&flagCommand == 0 || flags&flagMore != 0 {
+		return "", nil, fmt.Errorf("%w: expected command frame", ErrProtocol)
+	}
+	if len(body) < 1 || len(body) < 1+int(body[0]) {
+		return "", nil, fmt.Errorf("%w: truncated command", ErrProtocol)
+	}
+	return string(body[1 : 1+body[0]]), body[1+body[0]:], nil
+}
+
+// 属性：name-size(1) + name + value-size(4, big endian) + value
+func parseProperties(data []byte) (map[string]string, error) {
+	properties := map[string]string{}
+	for len(data) > 0 {
+		name_size := int(data[0])
+		if len(data) < 1+name_size+4 {
+			return nil, fmt.Errorf("%w: truncated property", ErrProtocol)
+		}
+		name := string(data[1 : 1+name_size])
+		data = data[1+name_size:]
+		value_size := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint64(len(data)) < uint64(value_size) {
+			return nil, fmt.Errorf("%w: truncated property value", ErrProtocol)
+		}
+		properties[name] = string(data[:value_size])
+		data = data[value_size:]
+	}
+	return properties, nil
+}
+
+func parseErrorReason(data []byte) string {
+	if len(data) < 1 || len(data) < 1+int(data[0]) {
+		return "unknown"
+	}
+	return string(data[1 : 1+data[0]])
+}